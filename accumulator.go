@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// summaryMovers is the number of items whose values changed the most that are listed in the summary
+const summaryMovers = 5
+
+// Mover is an item whose value was changed by the run, in either direction
+type Mover struct {
+	ItemID   githubv4.ID `json:"item_id"`
+	Name     string      `json:"name"`
+	Previous float64     `json:"previous"`
+	Value    float64     `json:"value"`
+}
+
+// Change returns the change in the item's value
+func (m Mover) Change() float64 {
+	return m.Value - m.Previous
+}
+
+// Accumulator aggregates the results of a run as they are produced, so that the summary is known
+// without keeping every result. It is safe for concurrent use by the workers of the pipeline.
+type Accumulator struct {
+	mu      sync.Mutex
+	summary Summary
+	top     int
+
+	// scores are the upvotes of every item scored, for the distribution of the scores
+	scores []float64
+}
+
+// NewAccumulator returns an empty Accumulator that keeps the top items whose values changed the most
+func NewAccumulator(top int) *Accumulator {
+	a := &Accumulator{top: top, summary: Summary{Statuses: make(map[Status]int, len(statuses))}}
+	for _, status := range statuses {
+		a.summary.Statuses[status] = 0
+	}
+	return a
+}
+
+// Add counts the result by status, adds the change in its value if it was updated or planned, and its
+// upvotes to the distribution if it was scored
+func (a *Accumulator) Add(result Result) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := &a.summary
+	s.Total++
+	s.Statuses[result.Status]++
+	if result.Inactive {
+		s.Inactive++
+	}
+	if exportable(result) {
+		a.scores = append(a.scores, result.Upvotes)
+	}
+
+	var conflict *ConflictError
+	if errors.As(result.Err, &conflict) {
+		s.Conflicts = append(s.Conflicts, Conflict{
+			ItemID:   result.ItemID,
+			Expected: conflict.Expected,
+			Actual:   conflict.Actual,
+		})
+	}
+
+	if result.Status != StatusUpdated && result.Status != StatusPlanned {
+		return
+	}
+	mover := Mover{ItemID: result.ItemID, Name: result.Name(), Previous: result.Previous, Value: result.Value}
+	s.Delta += mover.Change()
+
+	// the movers are kept sorted by the size of their change, largest first
+	i := sort.Search(len(s.Movers), func(i int) bool {
+		return moves(mover, s.Movers[i])
+	})
+	if i >= a.top {
+		return
+	}
+	s.Movers = append(s.Movers, Mover{})
+	copy(s.Movers[i+1:], s.Movers[i:])
+	s.Movers[i] = mover
+	if len(s.Movers) > a.top {
+		s.Movers = s.Movers[:a.top]
+	}
+}
+
+// moves returns true if a changed more than b, telling items whose changes are the same size apart by
+// ID, so that the movers do not depend on the order results arrive in
+func moves(a, b Mover) bool {
+	if x, y := math.Abs(a.Change()), math.Abs(b.Change()); x != y {
+		return x > y
+	}
+	return fmt.Sprint(a.ItemID) < fmt.Sprint(b.ItemID)
+}
+
+// Summary returns the summary of the results added so far
+func (a *Accumulator) Summary() Summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	summary := a.summary
+	summary.Statuses = make(map[Status]int, len(a.summary.Statuses))
+	for status, n := range a.summary.Statuses {
+		summary.Statuses[status] = n
+	}
+	summary.Conflicts = append([]Conflict(nil), a.summary.Conflicts...)
+	summary.Movers = append([]Mover(nil), a.summary.Movers...)
+	summary.Stats = NewScoreStats(a.scores)
+	return summary
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestAccumulator adds the results of 100 items from 10 goroutines at once, each item i changed by i
+// in alternate directions, and checks the counts, the net change, and that the largest changes lead the
+// movers
+func TestAccumulator(t *testing.T) {
+	acc := NewAccumulator(3)
+	var wg sync.WaitGroup
+	for w := 0; w < 10; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < 100; i += 10 {
+				change := float64(i)
+				if i%2 == 1 {
+					change = -change
+				}
+				status := StatusUpdated
+				if i == 0 {
+					status = StatusUnchanged
+				}
+				acc.Add(Result{ItemID: fmt.Sprintf("PVTI_%02d", i), Status: status, Previous: 100, Value: 100 + change})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	summary := acc.Summary()
+	expectCount(t, "items", summary.Total, 100)
+	expectCount(t, "updated items", summary.Statuses[StatusUpdated], 99)
+	// the even changes from 2 to 98 add to 2450, and the odd ones from 1 to 99 to -2500
+	if summary.Delta != -50 {
+		t.Fatalf("expected a net change of -50, got %v", summary.Delta)
+	}
+
+	var movers []string
+	for _, m := range summary.Movers {
+		movers = append(movers, m.Name)
+	}
+	if want := "PVTI_99 PVTI_98 PVTI_97"; strings.Join(movers, " ") != want {
+		t.Fatalf("expected the movers %s, got %s", want, strings.Join(movers, " "))
+	}
+}
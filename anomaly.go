@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// loadBaseline loads the scores of the latest run recorded in the history, which scores are compared
+// with to detect sharp drops
+func (e *Engine) loadBaseline() error {
+	e.baseline = HistoryEntry{}
+	if e.cfg.DropAlert <= 0 || e.store == nil {
+		return nil
+	}
+
+	entries, err := e.store.History()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) > 0 {
+		e.baseline = entries[len(entries)-1]
+	}
+
+	return nil
+}
+
+// checkDrop alerts when the item's score dropped by more than the configured percentage since the
+// previous run, which usually means that comments were deleted or moderated, or that there is a bug in
+// the scoring. The score is compared with the latest run in the history, including the breakdown of
+// each component, or with the field's previous value if the item has no history.
+func (e *Engine) checkDrop(ctx context.Context, result Result) {
+	if e.cfg.DropAlert <= 0 || !exportable(result) {
+		return
+	}
+
+	before := HistoryItem{Upvotes: result.Previous}
+	since := e.cfg.Locale.T("drop.previous")
+	if item, ok := e.baseline.Items[fmt.Sprint(result.ItemID)]; ok {
+		before = item
+		since = e.cfg.Locale.T("drop.run", e.baseline.RunID)
+	}
+
+	if before.Upvotes <= 0 {
+		return
+	}
+
+	drop := (before.Upvotes - result.Upvotes) / before.Upvotes * 100
+	if drop <= e.cfg.DropAlert {
+		return
+	}
+
+	if e.redact {
+		result.Content.Title = ""
+	}
+
+	message := e.cfg.Locale.T("drop.message", result.Name(), drop, since, before.Upvotes, result.Upvotes)
+	if changes := componentChanges(before.Components, result.Components); changes != "" {
+		message += ": " + changes
+	}
+
+	slog.WarnContext(ctx, "score dropped sharply", "item_id", result.ItemID, "previous", before.Upvotes, "upvotes", result.Upvotes, "drop_percent", drop, "since", since)
+	if e.cfg.DryRun {
+		return
+	}
+
+	if e.digest != nil {
+		e.digest.Add(message)
+		return
+	}
+	if err := e.notifiers.Notify(ctx, message); err != nil {
+		slog.ErrorContext(ctx, "failed to send score drop alert", "item_id", result.ItemID, "error", err)
+	}
+}
+
+// componentChanges describes the components whose values changed, for example `comments 12 -> 3`. It
+// returns an empty string if there is no breakdown to compare with.
+func componentChanges(before, after []ScoreComponent) string {
+	if len(before) == 0 {
+		return ""
+	}
+
+	values := func(components []ScoreComponent) map[string]float64 {
+		m := make(map[string]float64)
+		for _, c := range components {
+			m[c.Name] += c.Value
+		}
+		return m
+	}
+	old, current := values(before), values(after)
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range append(append([]ScoreComponent{}, before...), after...) {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			names = append(names, c.Name)
+		}
+	}
+
+	var changes []string
+	for _, name := range names {
+		if old[name] != current[name] {
+			changes = append(changes, fmt.Sprintf("%s %v -> %v", name, old[name], current[name]))
+		}
+	}
+
+	return strings.Join(changes, ", ")
+}
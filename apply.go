@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// applyBatchSize is the number of project items whose current values are read in a single query
+const applyBatchSize = 100
+
+// Apply performs the mutations in a plan generated by a read-only run, or left over by a run that reached
+// MaxUpdates. The plan must have been
+// generated for the configured project and field. Mutations are applied largest change first, so that the
+// most important corrections land before the run is stopped. Each item's current value is read first, and if it
+// no longer matches the value the plan was generated from, the configured conflict policy is applied.
+// Once ctx is cancelled no further mutation is started: the rest of the batch is recorded as truncated,
+// the mutations not applied are written back to the plan file, and the context's error is returned.
+func (e *Engine) Apply(ctx context.Context, plan *Plan) error {
+	// a plan is what a dry run would write, and applying it is nothing but writes
+	if e.cfg.DryRun {
+		return errors.New("apply cannot be combined with --dry-run: review the plan file instead")
+	}
+	if fmt.Sprint(plan.ProjectID) != fmt.Sprint(e.cfg.ProjectID) {
+		return fmt.Errorf("plan was generated for project %v, not %v", plan.ProjectID, e.cfg.ProjectID)
+	}
+	if fmt.Sprint(plan.FieldID) != fmt.Sprint(e.cfg.FieldID) {
+		return fmt.Errorf("plan was generated for field %v, not %v", plan.FieldID, e.cfg.FieldID)
+	}
+
+	run := RunInfo{
+		ID:        runID(e.cfg),
+		ProjectID: e.cfg.ProjectID,
+		FieldID:   e.cfg.FieldID,
+		StartedAt: time.Now(),
+	}
+
+	ctx = withLogAttrs(ctx, slog.String("run_id", run.ID))
+	slog.InfoContext(ctx, "applying plan", "plan_run_id", plan.RunID, "mutations", len(plan.Mutations))
+
+	if e.cfg.StateDir != "" {
+		audit, err := OpenAuditLog(e.cfg.StateDir, run.ID)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		e.audit = audit
+	}
+
+	// the values are tagged with the scoring profile the plan was calculated with
+	if err := e.loadProfiles(ctx, plan.Profile, plan.Rollout); err != nil {
+		return err
+	}
+
+	if err := e.reporters.Start(run); err != nil {
+		return fmt.Errorf("starting reporters: %w", err)
+	}
+
+	profileFor := profileOf(plan.Profile, plan.Rollout)
+	plan.Prioritize()
+
+	limiter := e.limiter
+	writer := &mutationWriter{gh: e.gh, projectId: e.cfg.ProjectID, fieldId: e.cfg.FieldID, audit: e.audit, policy: e.cfg.ConflictPolicy, limiter: limiter}
+
+	// pending are the mutations that were not applied because the run was stopped
+	var all []Result
+	var pending []PlannedMutation
+	for start := 0; start < len(plan.Mutations); start += applyBatchSize {
+		batch := plan.Mutations[start:min(start+applyBatchSize, len(plan.Mutations))]
+
+		if err := limiter.Wait(ctx); err != nil {
+			e.keepPending(ctx, plan, append(pending, plan.Mutations[start:]...))
+			return err
+		}
+
+		ids := make([]githubv4.ID, 0, len(batch))
+		for _, mutation := range batch {
+			ids = append(ids, mutation.ItemID)
+		}
+
+		current, err := currentValues(ctx, e.gh, limiter, ids)
+		if err != nil && ctx.Err() != nil {
+			e.keepPending(ctx, plan, append(pending, plan.Mutations[start:]...))
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("reading current values: %w", err)
+		}
+
+		for _, mutation := range batch {
+			result := Result{ItemID: mutation.ItemID, Previous: mutation.OldValue, Upvotes: mutation.NewValue, Value: mutation.NewValue}
+			value, ok := current[fmt.Sprint(mutation.ItemID)]
+
+			switch {
+			case ctx.Err() != nil:
+				result.Status = StatusTruncated
+			case !ok:
+				result.Status = StatusFailed
+				result.Err = fmt.Errorf("project item no longer exists")
+			case value == mutation.NewValue:
+				result.Status = StatusUnchanged
+			case value != mutation.OldValue && e.cfg.ConflictPolicy != ConflictOverwrite:
+				result.Status = StatusConflict
+				if e.cfg.ConflictPolicy == ConflictFail {
+					result.Status = StatusFailed
+				}
+				result.Err = &ConflictError{Expected: mutation.OldValue, Actual: value}
+			default:
+				result.Status, result.Err = writer.WriteUpvotes(ctx, mutation.ItemID, value, mutation.NewValue)
+
+				// a write cut short by the cancellation is kept in the plan; applying it again reads the
+				// value first, so it is not made twice if it landed
+				if result.Err != nil && ctx.Err() != nil {
+					result.Status, result.Err = StatusTruncated, nil
+				}
+			}
+			if result.Status == StatusTruncated {
+				pending = append(pending, mutation)
+			}
+
+			logResult(ctx, result, e.cfg.Explain)
+			if err := e.reporters.ItemResult(result); err != nil {
+				slog.ErrorContext(ctx, "failed to report project item", "item_id", result.ItemID, "error", err)
+			}
+			if plan.Profile != "" {
+				e.profiles.Observe(result, profileFor(result.ItemID))
+			}
+			all = append(all, result)
+		}
+	}
+
+	e.keepPending(ctx, plan, pending)
+
+	summary := NewSummary(all)
+	summary.RateLimit = limiter.Summary()
+	if plan.Profile != "" {
+		summary.Stale = e.saveProfiles(ctx, profileFor)
+	}
+	if err := e.reporters.Finish(summary); err != nil {
+		slog.ErrorContext(ctx, "failed to write reports", "error", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed := summary.Statuses[StatusFailed]; failed > 0 {
+		return fmt.Errorf("failed to apply %d mutations", failed)
+	}
+
+	return nil
+}
+
+// keepPending writes the mutations of the plan that were not applied back to the plan file, largest change
+// first, so that applying it again picks up where the stopped run left off
+func (e *Engine) keepPending(ctx context.Context, plan *Plan, pending []PlannedMutation) {
+	if e.cfg.Plan == "" || len(pending) == 0 {
+		return
+	}
+
+	remaining := &Plan{
+		ProjectID: plan.ProjectID,
+		FieldID:   plan.FieldID,
+		RunID:     plan.RunID,
+		Profile:   plan.Profile,
+		Rollout:   plan.Rollout,
+		CreatedAt: plan.CreatedAt,
+		Mutations: pending,
+	}
+	if err := remaining.Write(e.cfg.Plan); err != nil {
+		slog.ErrorContext(ctx, "failed to write the mutations not applied to the plan file", "path", e.cfg.Plan, "error", err)
+		return
+	}
+	slog.WarnContext(ctx, "apply was stopped, wrote the mutations not applied back to the plan file", "path", e.cfg.Plan, "mutations", len(pending))
+}
+
+// batchCurrentValues reads the current upvotes of any number of project items, keyed by item ID, in
+// batches of applyBatchSize. Only the field's value is queried, so this is far cheaper than fetching the
+// items again. The limiter may be nil.
+func batchCurrentValues(ctx context.Context, gh *githubv4.Client, limiter *rateLimiter, ids []githubv4.ID) (map[string]float64, error) {
+	values := make(map[string]float64, len(ids))
+	for start := 0; start < len(ids); start += applyBatchSize {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		batch, err := currentValues(ctx, gh, limiter, ids[start:min(start+applyBatchSize, len(ids))])
+		if err != nil {
+			return nil, err
+		}
+		for id, value := range batch {
+			values[id] = value
+		}
+	}
+	return values, nil
+}
+
+// currentValues reads the current upvotes of each project item, keyed by item ID. The limiter may be nil.
+func currentValues(ctx context.Context, gh *githubv4.Client, limiter *rateLimiter, ids []githubv4.ID) (map[string]float64, error) {
+	var query ProjectItemValuesQuery
+	if err := runQuery(withQueryKind(ctx, "values"), gh, &query, Variables().IDs("ids", ids)); err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		limiter.Observe(query.RateLimit)
+	}
+
+	values := make(map[string]float64, len(query.Nodes))
+	for _, node := range query.Nodes {
+		if node.Id == nil {
+			continue
+		}
+		values[fmt.Sprint(node.Id)] = node.UpvotesField.Value
+	}
+
+	return values, nil
+}
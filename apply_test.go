@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cancelTransport cancels the context once a request of the kind has been answered
+type cancelTransport struct {
+	base   http.RoundTripper
+	kind   string
+	cancel context.CancelFunc
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *cancelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	resp, err := t.base.RoundTrip(req)
+	if queryKind(req, body) == t.kind {
+		t.cancel()
+	}
+	return resp, err
+}
+
+// TestPrioritize applies a plan of three changes of different sizes to a fresh synthetic project, stopping
+// after the first is written, and checks that the largest was written and the other two were written
+// back to the plan file, largest first
+func TestPrioritize(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.Plan = filepath.Join(t.TempDir(), "prioritized.json")
+	server := newFakeGitHub()
+
+	plan := NewPlan(RunInfo{ID: "selftest", ProjectID: cfg.ProjectID, FieldID: cfg.FieldID})
+	for i, change := range []float64{1, 10, 5} {
+		var value float64
+		if v := server.items[i].value; v != nil {
+			value = *v
+		}
+		plan.WriteUpvotes(ctx, server.items[i].id, value, value+change)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	engine, err := newEngine(cfg, &http.Client{Transport: &cancelTransport{base: server, kind: "updateProjectV2ItemFieldValue", cancel: cancel}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Apply(ctx, plan); err != nil && ctx.Err() == nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := server.mutations[server.items[1].id]; !ok || len(server.mutations) != 1 {
+		t.Fatalf("expected only the largest change to be written, got %v", server.mutations)
+	}
+	remaining, err := LoadPlan(cfg.Plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining.Mutations) != 2 || remaining.Mutations[0].Delta() != 5 || remaining.Mutations[1].Delta() != 1 {
+		t.Fatalf("expected the changes of 5 and 1 to be left in the plan, got %+v", remaining.Mutations)
+	}
+}
+
+// TestApplyDryRun checks that a plan is not applied in a dry run, and that the plan file is left as it is
+func TestApplyDryRun(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.Plan = filepath.Join(t.TempDir(), "dry-run.json")
+	cfg.DryRun = true
+	server := newFakeGitHub()
+
+	plan := NewPlan(RunInfo{ID: "selftest", ProjectID: cfg.ProjectID, FieldID: cfg.FieldID})
+	plan.WriteUpvotes(ctx, server.items[0].id, 0, 10)
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Apply(ctx, plan); err == nil {
+		t.Fatal("expected apply to refuse --dry-run")
+	}
+	expectCount(t, "mutations", len(server.mutations), 0)
+	if _, err := os.Stat(cfg.Plan); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no plan file to be written, got %v", err)
+	}
+}
+
+// interruptTransport cancels the context while a request of the kind is in flight, so that it fails
+type interruptTransport struct {
+	base   http.RoundTripper
+	kind   string
+	cancel context.CancelFunc
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *interruptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if queryKind(req, body) == t.kind {
+		t.cancel()
+		return nil, req.Context().Err()
+	}
+	return t.base.RoundTrip(req)
+}
+
+// TestApplyInterrupted applies a plan of three changes to a fresh synthetic project, cancelling it while
+// the first is written, and checks that the cancellation is returned, that no change is reported as
+// failed, and that all three are kept in the plan file
+func TestApplyInterrupted(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := selftestConfig(dir)
+	cfg.Plan = filepath.Join(dir, "interrupted.json")
+	server := newFakeGitHub()
+
+	plan := NewPlan(RunInfo{ID: "selftest", ProjectID: cfg.ProjectID, FieldID: cfg.FieldID})
+	for i, change := range []float64{1, 10, 5} {
+		var value float64
+		if v := server.items[i].value; v != nil {
+			value = *v
+		}
+		plan.WriteUpvotes(ctx, server.items[i].id, value, value+change)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	engine, err := newEngine(cfg, &http.Client{Transport: &interruptTransport{base: server, kind: "updateProjectV2ItemFieldValue", cancel: cancel}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Apply(ctx, plan); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected apply to be cancelled, got %v", err)
+	}
+
+	results, summary, err := readReport(filepath.Join(dir, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "changes truncated", summary.Statuses[StatusTruncated], 3)
+	expectCount(t, "changes reported", len(results), 3)
+	remaining, err := LoadPlan(cfg.Plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining.Mutations) != 3 || remaining.Mutations[0].Delta() != 10 {
+		t.Fatalf("expected the three changes to be left in the plan, largest first, got %+v", remaining.Mutations)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Iteration is an iteration of a project's iteration field
+type Iteration struct {
+	Id        string
+	Title     string
+	StartDate string
+	Duration  int
+}
+
+// contains returns true if the day falls within the iteration
+func (i Iteration) contains(day time.Time) bool {
+	start, err := time.Parse(time.DateOnly, i.StartDate)
+	if err != nil {
+		return false
+	}
+	return !day.Before(start) && day.Before(start.AddDate(0, 0, i.Duration))
+}
+
+// currentIteration returns the iteration that the day falls within, if there is one
+func currentIteration(iterations []Iteration, now time.Time) (Iteration, bool) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, iteration := range iterations {
+		if iteration.contains(day) {
+			return iteration, true
+		}
+	}
+	return Iteration{}, false
+}
+
+// IterationFieldQuery looks up a project's iteration field by name, with its active iterations
+type IterationFieldQuery struct {
+	Node struct {
+		ProjectV2 struct {
+			Field struct {
+				ProjectV2IterationField struct {
+					Id            githubv4.ID
+					Configuration struct {
+						Iterations []Iteration
+					}
+				} `graphql:"...on ProjectV2IterationField"`
+			} `graphql:"field(name: $name)"`
+		} `graphql:"...on ProjectV2"`
+	} `graphql:"node(id: $nodeId)"`
+}
+
+// assignment is the iteration or milestone that the top items of a run are assigned to, once resolved
+type assignment struct {
+	fieldId   githubv4.ID
+	iteration Iteration
+	milestone string
+}
+
+// resolveAssignment looks up the iteration field the top items are assigned to, and its current
+// iteration. Between iterations, nothing is assigned to the field.
+func (e *Engine) resolveAssignment(ctx context.Context) error {
+	e.assignment = nil
+	if e.cfg.AssignTop <= 0 {
+		return nil
+	}
+
+	a := &assignment{milestone: e.cfg.AssignMilestone}
+	if e.cfg.AssignIteration != "" {
+		var query IterationFieldQuery
+		variables := Variables().ID("nodeId", e.cfg.ProjectID).String("name", e.cfg.AssignIteration)
+		if err := runQuery(ctx, e.gh, &query, variables); err != nil {
+			return fmt.Errorf("looking up field %q: %w", e.cfg.AssignIteration, err)
+		}
+
+		field := query.Node.ProjectV2.Field.ProjectV2IterationField
+		if field.Id == nil {
+			return fmt.Errorf("project has no iteration field named %q", e.cfg.AssignIteration)
+		}
+		if iteration, ok := currentIteration(field.Configuration.Iterations, time.Now()); ok {
+			a.fieldId, a.iteration = field.Id, iteration
+		} else {
+			slog.WarnContext(ctx, "no current iteration, top items are not assigned to one", "field", e.cfg.AssignIteration)
+		}
+	}
+
+	e.assignment = a
+	return nil
+}
+
+// keepTop adds the result to the top items, kept sorted by upvotes, largest first, and at most n long.
+// Only open, unarchived items whose upvotes were calculated are kept; closed and archived items are
+// only scored when every item is recalculated, and are not planned.
+func keepTop(top []Result, result Result, n int) []Result {
+	if !exportable(result) || result.Content.Closed || result.Archived {
+		return top
+	}
+
+	i := sort.Search(len(top), func(i int) bool {
+		if top[i].Upvotes != result.Upvotes {
+			return result.Upvotes > top[i].Upvotes
+		}
+		return fmt.Sprint(result.ItemID) < fmt.Sprint(top[i].ItemID)
+	})
+	if i >= n {
+		return top
+	}
+	top = append(top, Result{})
+	copy(top[i+1:], top[i:])
+	top[i] = result
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// AssignmentQuery reads the current iteration and milestone of the top items
+type AssignmentQuery struct {
+	Nodes []struct {
+		ProjectV2Item struct {
+			Id        githubv4.ID
+			Iteration struct {
+				ProjectV2ItemFieldIterationValue struct {
+					IterationId string
+				} `graphql:"...on ProjectV2ItemFieldIterationValue"`
+			} `graphql:"fieldValueByName(name: $field)"`
+			Content struct {
+				Typename string `graphql:"__typename"`
+				Issue    struct {
+					Id         githubv4.ID
+					Repository struct {
+						NameWithOwner string
+					}
+					Milestone *struct {
+						Title string
+					}
+				} `graphql:"...on Issue"`
+				PullRequest struct {
+					Id         githubv4.ID
+					Repository struct {
+						NameWithOwner string
+					}
+					Milestone *struct {
+						Title string
+					}
+				} `graphql:"...on PullRequest"`
+			}
+		} `graphql:"...on ProjectV2Item"`
+	} `graphql:"nodes(ids: $ids)"`
+}
+
+// assignTop assigns the top items of the run to the current iteration, and to the milestone in their
+// repository, skipping those already assigned to them, so that the items most asked for are planned
+// without triage. Nothing is assigned when the token cannot update the project.
+func (e *Engine) assignTop(ctx context.Context, top []Result) {
+	if e.readOnly || e.assignment == nil || len(top) == 0 {
+		return
+	}
+
+	ids := make([]githubv4.ID, len(top))
+	for i, result := range top {
+		ids[i] = result.ItemID
+	}
+	var query AssignmentQuery
+	variables := Variables().IDs("ids", ids).String("field", e.cfg.AssignIteration)
+	if err := runQuery(ctx, e.gh, &query, variables); err != nil {
+		slog.ErrorContext(ctx, "failed to read the iterations and milestones of the top items", "error", err)
+		return
+	}
+
+	milestones := make(map[string]githubv4.ID)
+	var assigned int
+	for _, node := range query.Nodes {
+		item := node.ProjectV2Item
+
+		if e.assignment.fieldId != nil && item.Iteration.ProjectV2ItemFieldIterationValue.IterationId != e.assignment.iteration.Id {
+			if err := e.assignIteration(ctx, item.Id); err != nil {
+				slog.ErrorContext(ctx, "failed to assign item to the current iteration", "item_id", item.Id, "iteration", e.assignment.iteration.Title, "error", err)
+			} else {
+				assigned++
+			}
+		}
+
+		content := item.Content.Issue
+		if item.Content.Typename == "PullRequest" {
+			content = item.Content.PullRequest
+		}
+		if e.assignment.milestone == "" || content.Id == nil || (content.Milestone != nil && content.Milestone.Title == e.assignment.milestone) {
+			continue
+		}
+
+		repo := content.Repository.NameWithOwner
+		milestoneId, ok := milestones[repo]
+		if !ok {
+			var err error
+			if milestoneId, err = findMilestone(ctx, e.gh, repo, e.assignment.milestone); err != nil {
+				slog.ErrorContext(ctx, "failed to look up milestone", "repository", repo, "milestone", e.assignment.milestone, "error", err)
+			}
+			milestones[repo] = milestoneId
+		}
+		if milestoneId == nil {
+			continue
+		}
+
+		if err := e.assignMilestone(ctx, item.Id, item.Content.Typename, content.Id, milestoneId); err != nil {
+			slog.ErrorContext(ctx, "failed to assign item to the milestone", "item_id", item.Id, "milestone", e.assignment.milestone, "error", err)
+		} else {
+			assigned++
+		}
+	}
+
+	slog.InfoContext(ctx, "assigned top items", "top", len(top), "assignments", assigned)
+}
+
+// assignIteration sets the item's iteration field to the current iteration
+func (e *Engine) assignIteration(ctx context.Context, itemId githubv4.ID) error {
+	if err := e.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	defer e.limiter.Spend(1)
+
+	record := AuditRecord{Mutation: "assign-iteration", ItemID: itemId, FieldID: e.assignment.fieldId, NewValue: e.assignment.iteration.Title}
+	return e.audit.Mutation(record, func() error {
+		value := githubv4.ProjectV2FieldValue{IterationID: githubv4.NewString(githubv4.String(e.assignment.iteration.Id))}
+		return setField(ctx, e.gh, e.cfg.ProjectID, itemId, e.assignment.fieldId, value)
+	})
+}
+
+// assignMilestone sets the milestone of the item's Issue or Pull Request
+func (e *Engine) assignMilestone(ctx context.Context, itemId githubv4.ID, typename string, contentId, milestoneId githubv4.ID) error {
+	if err := e.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	defer e.limiter.Spend(1)
+
+	record := AuditRecord{Mutation: "assign-milestone", ItemID: itemId, NewValue: e.assignment.milestone}
+	return e.audit.Mutation(record, func() error {
+		if typename == "PullRequest" {
+			var mutation struct {
+				UpdatePullRequest struct {
+					ClientMutationId string
+				} `graphql:"updatePullRequest(input: $input)"`
+			}
+			return e.gh.Mutate(ctx, &mutation, githubv4.UpdatePullRequestInput{PullRequestID: contentId, MilestoneID: &milestoneId}, nil)
+		}
+
+		var mutation struct {
+			UpdateIssue struct {
+				ClientMutationId string
+			} `graphql:"updateIssue(input: $input)"`
+		}
+		return e.gh.Mutate(ctx, &mutation, githubv4.UpdateIssueInput{ID: contentId, MilestoneID: &milestoneId}, nil)
+	})
+}
+
+// findMilestone returns the ID of the open milestone with the title in the repository, or nil if it
+// has none, since milestones belong to a repository and not every repository of a board has one
+func findMilestone(ctx context.Context, gh *githubv4.Client, repo, title string) (githubv4.ID, error) {
+	owner, name, _ := strings.Cut(repo, "/")
+	var query struct {
+		Repository struct {
+			Milestones struct {
+				Nodes []struct {
+					Id    githubv4.ID
+					Title string
+				}
+			} `graphql:"milestones(query: $title, states: OPEN, first: 20)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := Variables().String("owner", owner).String("name", name).String("title", title)
+	if err := runQuery(ctx, gh, &query, variables); err != nil {
+		return nil, err
+	}
+
+	for _, milestone := range query.Repository.Milestones.Nodes {
+		if milestone.Title == title {
+			return milestone.Id, nil
+		}
+	}
+	slog.WarnContext(ctx, "repository has no open milestone to assign top items to", "repository", repo, "milestone", title)
+	return nil, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// TestAssignment runs with the top three items assigned to the current iteration and a milestone, one of
+// them already in the iteration, and checks that only the top items are assigned, each once
+func TestAssignment(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AssignTop = 3
+	cfg.AssignIteration = "Iteration"
+	cfg.AssignMilestone = selftestMilestone
+	server := newFakeGitHub()
+
+	var open []*selftestItem
+	for _, item := range server.items {
+		if !item.closed && !item.archived {
+			open = append(open, item)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		if open[i].upvotes() != open[j].upvotes() {
+			return open[i].upvotes() > open[j].upvotes()
+		}
+		return open[i].id < open[j].id
+	})
+	server.iterations[open[0].id] = selftestIteration
+
+	run(t, cfg, server)
+
+	expectCount(t, "items in the iteration", len(server.iterations), 3)
+	expectCount(t, "items in the milestone", len(server.milestones), 3)
+	for _, item := range open[:3] {
+		if server.iterations[item.id] != selftestIteration || server.milestones[item.id] != "MI_selftest" {
+			t.Errorf("%s: expected to be assigned to the current iteration and milestone, got %q and %q", item.id, server.iterations[item.id], server.milestones[item.id])
+		}
+	}
+}
+
+// TestAssignmentPartial runs a range of the synthetic project with the top items assigned, and checks
+// that nothing is assigned, since the top of a range is not the board's
+func TestAssignmentPartial(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AssignTop = 3
+	cfg.AssignIteration = "Iteration"
+	cfg.AssignMilestone = selftestMilestone
+	cfg.Range = &Range{Count: selftestPageSize}
+	server := newFakeGitHub()
+
+	run(t, cfg, server)
+
+	expectCount(t, "items in the iteration", len(server.iterations), 0)
+	expectCount(t, "items in the milestone", len(server.milestones), 0)
+}
+
+// TestKeepTop checks that closed and archived items, which are scored when every item is recalculated,
+// are not kept among the top items
+func TestKeepTop(t *testing.T) {
+	var top []Result
+	top = keepTop(top, Result{ItemID: "closed", Status: StatusUpdated, Upvotes: 30, Content: ContentInfo{Closed: true}}, 2)
+	top = keepTop(top, Result{ItemID: "archived", Status: StatusUpdated, Upvotes: 20, Archived: true}, 2)
+	top = keepTop(top, Result{ItemID: "open", Status: StatusUpdated, Upvotes: 10}, 2)
+
+	if len(top) != 1 || top[0].ItemID != "open" {
+		t.Fatalf("expected only the open item to be kept, got %v", top)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultSuggestTop is the number of top unassigned items suggested assignees, unless another number is
+// configured
+const defaultSuggestTop = 10
+
+// Workload is the open items assigned to a person on the board, and their upvotes
+type Workload struct {
+	Login   string
+	Items   int
+	Upvotes float64
+}
+
+// AssigneeSuggestion is a top unassigned item, and the person suggested to take it, with their load
+// before the item
+type AssigneeSuggestion struct {
+	Result
+	Assignee string
+	Load     int
+}
+
+// SuggestAssignees suggests who should take each of the top unassigned open items, balancing the load
+// of the people with open items assigned on the board, or of the pool of people if one is given. Each
+// item goes to the person with the fewest open items, then the fewest upvotes, counting the items
+// suggested before it, so that suggestions spread over the team rather than all going to whoever is
+// least loaded now. It returns the suggestions, and the load of each person before them, most loaded
+// first.
+func SuggestAssignees(results []Result, pool []string, top int) ([]AssigneeSuggestion, []Workload) {
+	loads := make(map[string]*Workload)
+	for _, login := range pool {
+		loads[strings.ToLower(login)] = &Workload{Login: login}
+	}
+
+	var unassigned []Result
+	for _, result := range results {
+		if !openItem(result) {
+			continue
+		}
+		if len(result.Content.Assignees) == 0 {
+			if exportable(result) {
+				unassigned = append(unassigned, result)
+			}
+			continue
+		}
+		for _, login := range result.Content.Assignees {
+			w, ok := loads[strings.ToLower(login)]
+			if !ok {
+				if len(pool) > 0 {
+					continue
+				}
+				w = &Workload{Login: login}
+				loads[strings.ToLower(login)] = w
+			}
+			w.Items++
+			w.Upvotes += result.Upvotes
+		}
+	}
+
+	workloads := make([]Workload, 0, len(loads))
+	for _, w := range loads {
+		workloads = append(workloads, *w)
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Items != workloads[j].Items {
+			return workloads[i].Items > workloads[j].Items
+		}
+		if workloads[i].Upvotes != workloads[j].Upvotes {
+			return workloads[i].Upvotes > workloads[j].Upvotes
+		}
+		return workloads[i].Login < workloads[j].Login
+	})
+	if len(workloads) == 0 {
+		return nil, workloads
+	}
+
+	sort.SliceStable(unassigned, func(i, j int) bool {
+		return unassigned[i].Upvotes > unassigned[j].Upvotes
+	})
+	if len(unassigned) > top {
+		unassigned = unassigned[:top]
+	}
+
+	// the least loaded person is the last in the workloads, which are kept in order as items are suggested
+	planned := append([]Workload(nil), workloads...)
+	suggestions := make([]AssigneeSuggestion, 0, len(unassigned))
+	for _, result := range unassigned {
+		least := len(planned) - 1
+		for i := len(planned) - 2; i >= 0; i-- {
+			if planned[i].Items < planned[least].Items || (planned[i].Items == planned[least].Items && planned[i].Upvotes < planned[least].Upvotes) {
+				least = i
+			}
+		}
+		suggestions = append(suggestions, AssigneeSuggestion{Result: result, Assignee: planned[least].Login, Load: planned[least].Items})
+		planned[least].Items++
+		planned[least].Upvotes += result.Upvotes
+	}
+
+	return suggestions, workloads
+}
+
+// openItem returns true if the item's Issue or Pull Request is open and can be read
+func openItem(result Result) bool {
+	switch result.Status {
+	case StatusSkippedClosed, StatusSkippedArchived, StatusSkippedDraft, StatusSkippedUnsupported, StatusSkippedTerminal, StatusNoAccess, StatusArchivedActive, StatusUnarchived:
+		return false
+	}
+	return true
+}
+
+// assigneesMarkdown returns the suggestions as Markdown, followed by the current load of each person, in
+// the language of the locale
+func assigneesMarkdown(suggestions []AssigneeSuggestion, workloads []Workload, l *Locale) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", l.T("assignees.title"))
+
+	switch {
+	case len(workloads) == 0:
+		fmt.Fprintf(&b, "%s\n", l.T("assignees.nobody"))
+		return b.String()
+	case len(suggestions) == 0:
+		fmt.Fprintf(&b, "%s\n", l.T("assignees.none"))
+	default:
+		b.WriteString(l.row("summary.item", "report.upvotes", "assignees.suggested", "assignees.load"))
+		b.WriteString("| --- | ---: | --- | ---: |\n")
+		for _, s := range suggestions {
+			fmt.Fprintf(&b, "| %s | %v | @%s | %d -> %d |\n", markdownLink(s.Result), s.Upvotes, s.Assignee, s.Load, s.Load+1)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n#### %s\n\n", l.T("assignees.current"))
+	b.WriteString(l.row("assignees.assignee", "assignees.open", "report.upvotes"))
+	b.WriteString("| --- | ---: | ---: |\n")
+	for _, w := range workloads {
+		fmt.Fprintf(&b, "| @%s | %d | %v |\n", w.Login, w.Items, w.Upvotes)
+	}
+
+	return b.String()
+}
+
+// assigneesReporter writes suggested assignees for the top unassigned items, as a planning aid. Nothing
+// is assigned.
+type assigneesReporter struct {
+	collector
+	path   string
+	pool   []string
+	top    int
+	locale *Locale
+}
+
+// Finish writes the suggestions
+func (a *assigneesReporter) Finish(summary Summary) error {
+	suggestions, workloads := SuggestAssignees(a.results, a.pool, a.top)
+	return os.WriteFile(a.path, []byte(assigneesMarkdown(suggestions, workloads, a.locale)), 0o644)
+}
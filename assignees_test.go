@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestAssignees suggests assignees for three unassigned items on a board where one person has two open
+// items, another one, and a third only a closed one, and checks that the suggestions even out the load
+func TestAssignees(t *testing.T) {
+	item := func(id string, status Status, upvotes float64, assignees ...string) Result {
+		return Result{ItemID: id, Status: status, Upvotes: upvotes, Content: ContentInfo{Assignees: assignees}}
+	}
+	results := []Result{
+		item("PVTI_a1", StatusUnchanged, 5, "alice"),
+		item("PVTI_a2", StatusUpdated, 8, "alice"),
+		item("PVTI_b1", StatusUnchanged, 3, "bob"),
+		item("PVTI_c1", StatusSkippedClosed, 50, "carol"),
+		item("PVTI_top", StatusUpdated, 40),
+		item("PVTI_next", StatusUpdated, 30),
+		item("PVTI_third", StatusUnchanged, 20),
+		item("PVTI_closed", StatusSkippedClosed, 90),
+	}
+
+	suggestions, workloads := SuggestAssignees(results, nil, 3)
+	expectCount(t, "people with open items", len(workloads), 2)
+	var got []string
+	for _, s := range suggestions {
+		got = append(got, fmt.Sprintf("%v:%s", s.ItemID, s.Assignee))
+	}
+	if want := "PVTI_top:bob PVTI_next:alice PVTI_third:bob"; strings.Join(got, " ") != want {
+		t.Fatalf("expected the suggestions %s, got %s", want, strings.Join(got, " "))
+	}
+
+	suggestions, _ = SuggestAssignees(results, []string{"carol"}, 1)
+	if len(suggestions) != 1 || suggestions[0].Assignee != "carol" {
+		t.Fatalf("expected the top item to be suggested to the only person in the pool, got %v", suggestions)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// auditFile is the name of the audit log within the state directory
+const auditFile = "audit.jsonl"
+
+// Audit events recorded for each mutation
+const (
+	AuditAttempted = "attempted"
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+)
+
+// AuditRecord is a single line of the audit log
+type AuditRecord struct {
+	Timestamp time.Time   `json:"timestamp"`
+	RunID     string      `json:"run_id"`
+	Event     string      `json:"event"`
+	Mutation  string      `json:"mutation"`
+	ItemID    githubv4.ID `json:"item_id"`
+	FieldID   githubv4.ID `json:"field_id,omitempty"`
+	OldValue  interface{} `json:"old_value,omitempty"`
+	NewValue  interface{} `json:"new_value,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// AuditLog is an append-only JSONL log of every mutation the tool performs. A nil AuditLog discards
+// all records, so callers do not need to check whether auditing is enabled.
+type AuditLog struct {
+	mu    sync.Mutex
+	f     *os.File
+	runID string
+}
+
+// OpenAuditLog opens the audit log in the state directory for appending, creating it if needed
+func OpenAuditLog(stateDir, runID string) (*AuditLog, error) {
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating state directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(stateDir, auditFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+
+	return &AuditLog{f: f, runID: runID}, nil
+}
+
+// Record appends a record to the log, filling in the timestamp and run ID
+func (a *AuditLog) Record(record AuditRecord) error {
+	if a == nil {
+		return nil
+	}
+
+	record.Timestamp = time.Now().UTC()
+	record.RunID = a.runID
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, err = a.f.Write(append(b, '\n'))
+	return err
+}
+
+// Mutation records that the mutation described by record is being attempted, runs mutate, then
+// records whether it succeeded or failed. The error from mutate is returned.
+func (a *AuditLog) Mutation(record AuditRecord, mutate func() error) error {
+	record.Event = AuditAttempted
+	if err := a.Record(record); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+
+	err := mutate()
+
+	record.Event = AuditSucceeded
+	if err != nil {
+		record.Event = AuditFailed
+		record.Error = redact(err.Error())
+	}
+
+	if auditErr := a.Record(record); auditErr != nil && err == nil {
+		return fmt.Errorf("writing audit log: %w", auditErr)
+	}
+
+	return err
+}
+
+// Mutations records that the mutations described by records, sent together in a single request, are
+// being attempted, runs mutate, then records whether they succeeded or failed. The error from mutate is
+// returned.
+func (a *AuditLog) Mutations(records []AuditRecord, mutate func() error) error {
+	for _, record := range records {
+		record.Event = AuditAttempted
+		if err := a.Record(record); err != nil {
+			return fmt.Errorf("writing audit log: %w", err)
+		}
+	}
+
+	err := mutate()
+
+	for _, record := range records {
+		record.Event = AuditSucceeded
+		if err != nil {
+			record.Event = AuditFailed
+			record.Error = redact(err.Error())
+		}
+		if auditErr := a.Record(record); auditErr != nil && err == nil {
+			err = fmt.Errorf("writing audit log: %w", auditErr)
+		}
+	}
+
+	return err
+}
+
+// Close closes the log file
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxBreakerCooldown caps the time the circuit breaker stays open
+const maxBreakerCooldown = 10 * time.Minute
+
+// Circuit breaker states
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// breakerTransport is a circuit breaker around requests to GitHub. After threshold consecutive
+// failures, such as during a GitHub incident, the breaker opens and requests wait rather than fail,
+// pausing the pipeline. Once the cooldown has passed a single request is let through; if it succeeds
+// the breaker closes, otherwise it opens again with double the cooldown.
+type breakerTransport struct {
+	base      http.RoundTripper
+	threshold int
+	cooldown  time.Duration
+	metrics   *ClientMetrics
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	wait     time.Duration
+	openedAt time.Time
+
+	// probe is closed once the half-open request completes
+	probe chan struct{}
+}
+
+// newBreakerTransport returns a breakerTransport that opens after threshold consecutive failures,
+// initially for cooldown. Its state is recorded in metrics, if not nil.
+func newBreakerTransport(base http.RoundTripper, threshold int, cooldown time.Duration, metrics *ClientMetrics) *breakerTransport {
+	metrics.Breaker(breakerClosed, false)
+	return &breakerTransport{base: base, threshold: threshold, cooldown: cooldown, metrics: metrics, state: breakerClosed}
+}
+
+// RoundTrip implements http.RoundTripper
+func (b *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	probe, err := b.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.base.RoundTrip(req)
+
+	// a cancelled request says nothing about GitHub's health
+	if err != nil && req.Context().Err() != nil {
+		if probe {
+			b.abandon()
+		}
+		return resp, err
+	}
+
+	b.record(req.Context(), probe, err == nil && resp.StatusCode < 500)
+	return resp, err
+}
+
+// abandon lets another request probe once the half-open probe was cancelled
+func (b *breakerTransport) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = breakerOpen
+	b.openedAt = time.Now().Add(-b.wait)
+	b.metrics.Breaker(b.state, false)
+	close(b.probe)
+}
+
+// acquire waits until a request may be made, returning true if it is the half-open probe
+func (b *breakerTransport) acquire(ctx context.Context) (bool, error) {
+	for {
+		b.mu.Lock()
+		switch b.state {
+		case breakerClosed:
+			b.mu.Unlock()
+			return false, nil
+		case breakerOpen:
+			remaining := b.wait - time.Since(b.openedAt)
+			if remaining <= 0 {
+				b.state = breakerHalfOpen
+				b.probe = make(chan struct{})
+				b.metrics.Breaker(b.state, false)
+				b.mu.Unlock()
+				slog.InfoContext(ctx, "circuit breaker half-open, probing GitHub")
+				return true, nil
+			}
+			b.mu.Unlock()
+
+			if !sleep(ctx, remaining) {
+				return false, ctx.Err()
+			}
+		case breakerHalfOpen:
+			probe := b.probe
+			b.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-probe:
+			}
+		}
+	}
+}
+
+// record updates the breaker with the outcome of a request
+func (b *breakerTransport) record(ctx context.Context, probe, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		defer close(b.probe)
+	}
+
+	if ok {
+		if b.state != breakerClosed {
+			slog.InfoContext(ctx, "circuit breaker closed, resuming")
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		b.wait = 0
+		b.metrics.Breaker(b.state, false)
+		return
+	}
+
+	b.failures++
+	switch {
+	case probe:
+		b.wait = min(b.wait*2, maxBreakerCooldown)
+	case b.state == breakerClosed && b.failures >= b.threshold:
+		b.wait = b.cooldown
+	default:
+		return
+	}
+
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	b.metrics.Breaker(b.state, true)
+	slog.WarnContext(ctx, "circuit breaker open, pausing requests to GitHub", "consecutive_failures", b.failures, "resume_at", b.openedAt.Add(b.wait).Round(time.Second))
+}
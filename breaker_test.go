@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statusTransport answers every request with its status, after waiting for release if it is not nil
+type statusTransport struct {
+	release chan struct{}
+
+	mu     sync.Mutex
+	status int
+	calls  int
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.calls++
+	release := t.release
+	t.mu.Unlock()
+
+	if release != nil {
+		<-release
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &http.Response{StatusCode: t.status, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+// set sets the status of the responses
+func (t *statusTransport) set(status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = status
+}
+
+// breakerRequest makes a request through the breaker with the context, returning its error
+func breakerRequest(ctx context.Context, b *breakerTransport) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.github.com/graphql", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.RoundTrip(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+// expectBreaker fails the test unless the breaker is in the state, with the wait, and its metrics agree
+func expectBreaker(t *testing.T, b *breakerTransport, state string, wait time.Duration, opens int) {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != state || b.wait != wait {
+		t.Fatalf("expected the breaker to be %s for %v, got %s for %v", state, wait, b.state, b.wait)
+	}
+	metricsState, metricsOpens := b.metrics.BreakerState()
+	if metricsState != state || metricsOpens != opens {
+		t.Fatalf("expected metrics of %s after %d opens, got %s after %d", state, opens, metricsState, metricsOpens)
+	}
+}
+
+// TestBreaker fails requests through a breaker until it opens, checks that requests wait for the
+// cooldown, which doubles when the probe fails, that a request cancelled while it is open is not made,
+// and that a successful probe closes it
+func TestBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	base := &statusTransport{status: http.StatusBadGateway}
+	b := newBreakerTransport(base, 2, cooldown, NewClientMetrics())
+	ctx := context.Background()
+
+	expectBreaker(t, b, breakerClosed, 0, 0)
+	breakerRequest(ctx, b)
+	expectBreaker(t, b, breakerClosed, 0, 0)
+	breakerRequest(ctx, b)
+	expectBreaker(t, b, breakerOpen, cooldown, 1)
+
+	start := time.Now()
+	breakerRequest(ctx, b)
+	if elapsed := time.Since(start); elapsed < cooldown/2 {
+		t.Fatalf("expected the probe to wait for the cooldown, it waited %v", elapsed)
+	}
+	expectBreaker(t, b, breakerOpen, 2*cooldown, 2)
+
+	cancelled, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	if err := breakerRequest(cancelled, b); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	expectCount(t, "requests", base.calls, 3)
+
+	base.set(http.StatusOK)
+	if err := breakerRequest(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	expectBreaker(t, b, breakerClosed, 0, 2)
+	expectCount(t, "requests", base.calls, 4)
+
+	state, opens := b.metrics.BreakerState()
+	var metrics bytes.Buffer
+	if err := writeMetrics(&metrics, []TenantStatus{{Name: "selftest", Breaker: state, BreakerOpens: opens}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"github_upvotes_api_breaker_state{tenant=\"selftest\",state=\"closed\"} 1\n",
+		"github_upvotes_api_breaker_state{tenant=\"selftest\",state=\"open\"} 0\n",
+		"github_upvotes_api_breaker_opens_total{tenant=\"selftest\"} 2\n",
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("expected the metrics to include %q", want)
+		}
+	}
+}
+
+// TestBreakerHalfOpen checks that requests wait while the breaker's probe is made, and are made once it
+// succeeds
+func TestBreakerHalfOpen(t *testing.T) {
+	base := &statusTransport{status: http.StatusOK, release: make(chan struct{})}
+	b := newBreakerTransport(base, 1, time.Millisecond, NewClientMetrics())
+	b.state, b.wait, b.openedAt = breakerOpen, time.Millisecond, time.Now().Add(-time.Second)
+
+	var wg sync.WaitGroup
+	request := func() {
+		defer wg.Done()
+		if err := breakerRequest(context.Background(), b); err != nil {
+			t.Error(err)
+		}
+	}
+
+	wg.Add(1)
+	go request()
+	for {
+		b.mu.Lock()
+		state := b.state
+		b.mu.Unlock()
+		if state == breakerHalfOpen {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expectBreaker(t, b, breakerHalfOpen, time.Millisecond, 0)
+
+	wg.Add(1)
+	go request()
+	time.Sleep(20 * time.Millisecond)
+	base.mu.Lock()
+	expectCount(t, "requests during the probe", base.calls, 1)
+	base.mu.Unlock()
+
+	close(base.release)
+	wg.Wait()
+	expectCount(t, "requests", base.calls, 2)
+	expectBreaker(t, b, breakerClosed, 0, 0)
+}
+
+// TestBreakerCooldownCap checks that the cooldown stops doubling at its cap
+func TestBreakerCooldownCap(t *testing.T) {
+	base := &statusTransport{status: http.StatusBadGateway}
+	b := newBreakerTransport(base, 1, time.Millisecond, NewClientMetrics())
+	b.state, b.wait, b.openedAt = breakerOpen, maxBreakerCooldown*3/4, time.Now().Add(-maxBreakerCooldown)
+
+	breakerRequest(context.Background(), b)
+	expectBreaker(t, b, breakerOpen, maxBreakerCooldown, 1)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// scoreCacheFile is the name of the state file the score cache is kept in
+const scoreCacheFile = "score-cache.json"
+
+// CacheEntry is the tally of an item's timeline: the components of its score counted from the timeline
+// items up to Cursor, and the links and responses found in them. UpdatedAt is when the Issue or Pull Request was last
+// updated as of the tally, and CachedAt when its timeline was last tallied from the first page.
+type CacheEntry struct {
+	UpdatedAt  time.Time        `json:"updated_at"`
+	CachedAt   time.Time        `json:"cached_at"`
+	Cursor     githubv4.String  `json:"cursor,omitempty"`
+	Components []ScoreComponent `json:"components"`
+	Links      []ContentLink    `json:"links,omitempty"`
+	Responses
+}
+
+// timelineTally returns the tally of the timeline items fetched for the content, ending at cursor
+func timelineTally(content ContentFragment, cursor githubv4.String) CacheEntry {
+	return CacheEntry{Cursor: cursor, Components: content.timelineComponents(), Links: content.Links(), Responses: content.Responses()}
+}
+
+// add returns the tally of the timeline items in e followed by those in more. The result keeps the time
+// e was tallied from the first page.
+func (e CacheEntry) add(more CacheEntry) CacheEntry {
+	totals := make(map[string]float64)
+	for _, components := range [][]ScoreComponent{e.Components, more.Components} {
+		for _, component := range components {
+			totals[component.Name] += component.Value
+		}
+	}
+
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sum := CacheEntry{CachedAt: e.CachedAt, Cursor: more.Cursor, Links: e.Links, Responses: e.Responses.add(more.Responses)}
+	for _, name := range names {
+		sum.Components = append(sum.Components, ScoreComponent{Name: name, Value: totals[name]})
+	}
+
+	for _, link := range more.Links {
+		if !containsLink(sum.Links, link) {
+			sum.Links = append(sum.Links, link)
+		}
+	}
+
+	return sum
+}
+
+// containsLink returns true if link is in links
+func containsLink(links []ContentLink, link ContentLink) bool {
+	for _, l := range links {
+		if l == link {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheState is the outcome of looking an item up in the score cache
+type cacheState int
+
+const (
+	// cacheMiss means that the item's whole timeline is fetched and tallied
+	cacheMiss cacheState = iota
+
+	// cacheHit means that the item has not been updated, and its cached tally is used as it is
+	cacheHit
+
+	// cacheResumed means that the item has been updated, and only its timeline items after the cached
+	// tally's cursor are fetched and added to it
+	cacheResumed
+)
+
+// ScoreCache keeps a tally of each item's timeline across runs, keyed by project item ID. A tally is
+// used as it is while the Issue or Pull Request's updatedAt is unchanged. Once it is updated, only the
+// timeline items after the tally's cursor are fetched, and added to the tally, so that the cost of an
+// issue with a huge timeline depends on its new activity rather than its size.
+//
+// Activity that does not add a timeline item, such as reactions to comments or deleted comments, is not
+// seen by a cached tally, so tallies are recounted from the first page once they are older than the TTL.
+// A nil ScoreCache caches nothing.
+type ScoreCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]CacheEntry
+	counts  map[cacheState]int
+}
+
+// LoadScoreCache reads the score cache from the store
+func LoadScoreCache(store StateStore, ttl time.Duration) (*ScoreCache, error) {
+	c := &ScoreCache{ttl: ttl, entries: make(map[string]CacheEntry), counts: make(map[cacheState]int)}
+	if err := store.Load(scoreCacheFile, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reset empties the cache, so that every item is tallied afresh and cached again
+func (c *ScoreCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]CacheEntry)
+}
+
+// Lookup returns the item's cached tally, and whether it can be used as it is, resumed after its cursor,
+// or not at all
+func (c *ScoreCache) Lookup(id string, content ContentFragment, now time.Time) (CacheEntry, cacheState) {
+	if c == nil || content.UpdatedAt.IsZero() {
+		return CacheEntry{}, cacheMiss
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	state := cacheMiss
+	switch {
+	case !ok || now.Sub(entry.CachedAt) > c.ttl:
+	case entry.UpdatedAt.Equal(content.UpdatedAt.Time):
+		state = cacheHit
+	case entry.Cursor != "":
+		state = cacheResumed
+	}
+
+	c.counts[state]++
+	return entry, state
+}
+
+// Store caches the item's tally. A tally counted from the first page is timed from now, and a resumed
+// tally keeps the time it was first counted.
+func (c *ScoreCache) Store(id string, content ContentFragment, tally CacheEntry, state cacheState, now time.Time) {
+	if c == nil || content.UpdatedAt.IsZero() {
+		return
+	}
+
+	tally.UpdatedAt = content.UpdatedAt.Time
+	if state != cacheResumed {
+		tally.CachedAt = now
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[id] = tally
+}
+
+// Save writes the cache to the store, dropping entries older than the TTL, and returns the number of
+// items that were cache hits, resumed, and misses
+func (c *ScoreCache) Save(store StateStore, now time.Time) (hits, resumed, misses int, err error) {
+	if c == nil {
+		return 0, 0, 0, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, entry := range c.entries {
+		if now.Sub(entry.CachedAt) > c.ttl {
+			delete(c.entries, id)
+		}
+	}
+
+	hits, resumed, misses = c.counts[cacheHit], c.counts[cacheResumed], c.counts[cacheMiss]
+	if err := store.Save(scoreCacheFile, c.entries); err != nil {
+		return hits, resumed, misses, fmt.Errorf("saving score cache: %w", err)
+	}
+
+	return hits, resumed, misses, nil
+}
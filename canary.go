@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// canaryAlpha is the significance level at which the canary reports drift
+const canaryAlpha = 0.05
+
+// CanaryItem is the comparison of a sampled item's recalculated upvotes with its stored upvotes
+type CanaryItem struct {
+	ItemID  string
+	Stored  float64
+	Current float64
+
+	// Drift is the change relative to the stored upvotes
+	Drift float64
+}
+
+// CanaryReport is the outcome of a canary run
+type CanaryReport struct {
+	Items []CanaryItem
+
+	// MeanDrift is the mean relative change across the sampled items
+	MeanDrift float64
+
+	// P is the probability of a mean drift at least this large if the scoring had not changed
+	P float64
+
+	// Significant is true if the drift is both statistically significant and larger than the tolerance
+	Significant bool
+}
+
+// Canary recalculates the upvotes of a random sample of the items in the latest run recorded in the
+// score history, without writing anything, and compares them with the stored upvotes. It is meant to
+// catch scoring regressions, for example after an upgrade, before a full run overwrites every item.
+func (e *Engine) Canary(ctx context.Context, sample int, tolerance float64) (CanaryReport, error) {
+	var report CanaryReport
+
+	if e.store == nil {
+		return report, errors.New("no score history to compare with: run with --state-dir first")
+	}
+	history, err := e.store.History()
+	if err != nil {
+		return report, err
+	}
+	if len(history) == 0 {
+		return report, errors.New("no score history to compare with: run with --state-dir first")
+	}
+	stored := history[len(history)-1].Items
+
+	ids := make([]string, 0, len(stored))
+	for id := range stored {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	if sample < len(ids) {
+		ids = ids[:sample]
+	}
+
+	slog.InfoContext(ctx, "scoring canary sample", "items", len(ids), "run", history[len(history)-1].RunID)
+
+	items, err := e.fetchItems(ctx, ids)
+	if err != nil {
+		return report, err
+	}
+
+	in := make(chan Item)
+	updates := ProcessProjectItems(ctx, e.gh, e.scorer, e.limiter, nil, in)
+	go func() {
+		defer close(in)
+		for _, item := range items {
+			in <- item
+		}
+	}()
+
+	var errs []error
+	for range items {
+		update := <-updates
+		if update.Err != nil {
+			errs = append(errs, fmt.Errorf("scoring %v: %w", update.Id, update.Err))
+			continue
+		}
+
+		id := fmt.Sprint(update.Id)
+		current := float64(*update.Upvotes)
+		report.Items = append(report.Items, CanaryItem{
+			ItemID:  id,
+			Stored:  stored[id].Upvotes,
+			Current: current,
+			Drift:   (current - stored[id].Upvotes) / math.Max(math.Abs(stored[id].Upvotes), 1),
+		})
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return report, err
+	}
+
+	sort.Slice(report.Items, func(i, j int) bool {
+		return math.Abs(report.Items[i].Drift) > math.Abs(report.Items[j].Drift)
+	})
+
+	drifts := make([]float64, 0, len(report.Items))
+	for _, item := range report.Items {
+		drifts = append(drifts, item.Drift)
+	}
+	report.MeanDrift, report.P = tTest(drifts)
+	report.Significant = report.P < canaryAlpha && math.Abs(report.MeanDrift) > tolerance
+
+	return report, nil
+}
+
+// fetchItems fetches the project items with the given IDs, along with their additional fields.
+// Items that would be skipped by a run, such as closed or archived items, are left out.
+func (e *Engine) fetchItems(ctx context.Context, ids []string) ([]Item, error) {
+	var items []Item
+	var nodeIds []githubv4.ID
+
+	for _, id := range ids {
+		var query ProjectItemQuery
+		variables := Variables().
+			ID("nodeId", githubv4.ID(id)).
+			Cursor("timelineCursor", "").
+			Bool("enrich", e.cfg.Enrich).
+			Bool("mentions", len(e.cfg.Teams) > 0).
+			Bool("text", e.cfg.fetchText())
+
+		if err := runQuery(withQueryKind(ctx, "item"), e.gh, &query, variables); err != nil {
+			return nil, fmt.Errorf("fetching project item %s: %w", id, err)
+		}
+
+		if query.Id == nil || query.SkipStatus() != "" || filterStatus(e.cfg, query.ProjectItemFragment) != "" {
+			continue
+		}
+
+		items = append(items, Item{ProjectItemEdgeFragment: ProjectItemEdgeFragment{ProjectItemFragment: query.ProjectItemFragment}})
+		nodeIds = append(nodeIds, query.Id)
+	}
+
+	extra, err := e.fragments.Fetch(ctx, nodeIds)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Extra = extra[items[i].Id]
+	}
+
+	return items, nil
+}
+
+// WriteTable writes the sampled items and the outcome of the comparison as a plain text table
+func (c CanaryReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ITEM\tSTORED\tCURRENT\tDRIFT")
+	for _, item := range c.Items {
+		fmt.Fprintf(tw, "%s\t%v\t%v\t%+.1f%%\n", item.ItemID, item.Stored, item.Current, item.Drift*100)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nmean drift: %+.1f%%, p=%.3f\n", c.MeanDrift*100, c.P)
+	return err
+}
+
+// tTest returns the mean of the values and the two-sided p-value of a one-sample t-test of whether
+// the mean differs from zero
+func tTest(values []float64) (mean, p float64) {
+	n := float64(len(values))
+	if n == 0 {
+		return 0, 1
+	}
+
+	for _, v := range values {
+		mean += v
+	}
+	mean /= n
+
+	if n < 2 {
+		return mean, 1
+	}
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= n - 1
+
+	if variance == 0 {
+		if mean == 0 {
+			return mean, 1
+		}
+		return mean, 0
+	}
+
+	t := mean / math.Sqrt(variance/n)
+	df := n - 1
+
+	return mean, regularizedBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// regularizedBeta returns the regularized incomplete beta function I_x(a, b)
+func regularizedBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+
+	// the continued fraction converges quickly on this side of the distribution's mean
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(x, a, b) / a
+	}
+	return 1 - front*betaFraction(1-x, b, a)/b
+}
+
+// betaFraction evaluates the continued fraction for the incomplete beta function using Lentz's method
+func betaFraction(x, a, b float64) float64 {
+	const tiny = 1e-30
+
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1.0; m <= 200; m++ {
+		// even step
+		num := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// odd step
+		num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < 1e-12 {
+			break
+		}
+	}
+
+	return h
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// TestCanary runs the canary against a scored synthetic project, which must find no drift, then with a
+// profile that scores items by their comments tenfold, which must find a significant drift, without
+// writing anything either time
+func TestCanary(t *testing.T) {
+	cfg := testConfig(t)
+	server := scoredFakeGitHub(t, cfg)
+	server.mutations = make(map[string]float64)
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := engine.Canary(context.Background(), 5, cfg.CanaryTolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "sampled items", len(report.Items), 5)
+	if report.MeanDrift != 0 || report.Significant {
+		t.Fatalf("expected no drift, got a mean drift of %v with p=%v", report.MeanDrift, report.P)
+	}
+
+	cfg.Scoring = ScoringProfile{Comments: 10}
+	engine, err = newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = engine.Canary(context.Background(), selftestItems, cfg.CanaryTolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Significant {
+		t.Fatalf("expected a significant drift, got a mean drift of %v with p=%v", report.MeanDrift, report.P)
+	}
+	expectCount(t, "mutations", len(server.mutations), 0)
+}
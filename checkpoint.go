@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Checkpoint is the progress of a run through the project's items, kept in a JSON file so that a run
+// stopped part of the way, for example once the rate limit ran out, is resumed where it stopped by the
+// next run rather than starting over. Cursor is the cursor of the last item of the last page whose items
+// were all processed; the items of the page in progress are processed again, and those already written
+// are unchanged. The file is removed once a run reaches the end of the project. A nil Checkpoint keeps
+// no progress.
+type Checkpoint struct {
+	ProjectID githubv4.ID `json:"project_id"`
+	FieldID   githubv4.ID `json:"field_id"`
+	Cursor    string      `json:"cursor,omitempty"`
+
+	// RunID is the run that last saved the checkpoint, and Updated the number of items written since
+	// the checkpoint was started, across every run that resumed it
+	RunID   string    `json:"run_id"`
+	Updated int       `json:"updated"`
+	SavedAt time.Time `json:"saved_at"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// LoadCheckpoint reads the checkpoint at path for the run, or starts a new one if there is none. A
+// checkpoint of another project or field is not resumed from. An empty path returns nil.
+func LoadCheckpoint(ctx context.Context, path string, run RunInfo) (*Checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	c := &Checkpoint{path: path}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	default:
+		if err := json.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("parsing checkpoint %s: %w", path, err)
+		}
+	}
+
+	if c.Cursor != "" && (fmt.Sprint(c.ProjectID) != fmt.Sprint(run.ProjectID) || fmt.Sprint(c.FieldID) != fmt.Sprint(run.FieldID)) {
+		slog.WarnContext(ctx, "checkpoint is for another project or field, starting from the first item", "path", path, "project_id", c.ProjectID, "field_id", c.FieldID)
+		c.Cursor, c.Updated = "", 0
+	}
+	if c.Cursor != "" {
+		slog.InfoContext(ctx, "resuming from checkpoint", "path", path, "cursor", c.Cursor, "started_by", c.RunID, "updated", c.Updated)
+	}
+
+	c.ProjectID, c.FieldID, c.RunID = run.ProjectID, run.FieldID, run.ID
+	return c, nil
+}
+
+// Resume returns the cursor that the run resumes after, or an empty string to start from the first item
+func (c *Checkpoint) Resume() string {
+	if c == nil {
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.Cursor
+}
+
+// Page records that every item up to and including the one at cursor was processed
+func (c *Checkpoint) Page(ctx context.Context, cursor githubv4.String) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Cursor = string(cursor)
+	c.save(ctx)
+}
+
+// Written records a successful write
+func (c *Checkpoint) Written(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Updated++
+	c.save(ctx)
+}
+
+// Finish removes the checkpoint once the run has reached the end of the project, so that the next run
+// starts from the first item
+func (c *Checkpoint) Finish(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.ErrorContext(ctx, "failed to remove checkpoint", "path", c.path, "error", err)
+	}
+}
+
+// save writes the checkpoint to its file. The lock must be held. A checkpoint that cannot be written
+// is logged rather than failing the run, which only loses the progress it would have kept.
+func (c *Checkpoint) save(ctx context.Context) {
+	c.SavedAt = time.Now().UTC()
+	if err := saveState(filepath.Dir(c.path), filepath.Base(c.path), c); err != nil {
+		slog.ErrorContext(ctx, "failed to save checkpoint", "path", c.path, "error", err)
+	}
+}
+
+// checkpointWriter records each successful write in the checkpoint
+type checkpointWriter struct {
+	FieldWriter
+	checkpoint *Checkpoint
+}
+
+// WriteUpvotes writes the upvotes, and saves the checkpoint once they are written
+func (w *checkpointWriter) WriteUpvotes(ctx context.Context, itemId githubv4.ID, previous, upvotes float64) (Status, error) {
+	status, err := w.FieldWriter.WriteUpvotes(ctx, itemId, previous, upvotes)
+	if status == StatusUpdated {
+		w.checkpoint.Written(ctx)
+	}
+	return status, err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestCheckpoint runs a fresh synthetic project from a checkpoint left after its first page, and checks
+// that only the items after it are processed and that the checkpoint is removed at the end, then that a
+// saved checkpoint is only resumed for the same project
+func TestCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.CheckpointFile = filepath.Join(t.TempDir(), "checkpoint", "checkpoint.json")
+	cfg.Cache = true
+	server := newFakeGitHub()
+
+	info := RunInfo{ID: "selftest", ProjectID: cfg.ProjectID, FieldID: cfg.FieldID}
+	checkpoint, err := LoadCheckpoint(ctx, cfg.CheckpointFile, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Page(ctx, githubv4.String(fmt.Sprintf("c%d", selftestPageSize)))
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "items processed", engine.summary.Total, len(server.items)-selftestPageSize)
+	if _, err := os.Stat(cfg.CheckpointFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the checkpoint to be removed after a complete run, got %v", err)
+	}
+
+	checkpoint, err = LoadCheckpoint(ctx, cfg.CheckpointFile, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Page(ctx, "c5")
+	checkpoint, err = LoadCheckpoint(ctx, cfg.CheckpointFile, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Resume() != "c5" {
+		t.Fatalf("expected to resume after c5, got %q", checkpoint.Resume())
+	}
+
+	info.ProjectID = "PVT_other"
+	checkpoint, err = LoadCheckpoint(ctx, cfg.CheckpointFile, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Resume() != "" {
+		t.Fatalf("expected the checkpoint of another project not to be resumed, got %q", checkpoint.Resume())
+	}
+}
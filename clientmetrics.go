@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bounds of the buckets of the histograms of API calls
+var (
+	latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	costBuckets    = []float64{1, 2, 5, 10, 25, 50, 100}
+)
+
+// Histogram counts observations in cumulative buckets, as Prometheus histograms do
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// newHistogram returns an empty Histogram with buckets of the given upper bounds
+func newHistogram(bounds []float64) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]uint64, len(bounds))}
+}
+
+// Observe counts the value in every bucket whose bound it does not exceed
+func (h *Histogram) Observe(value float64) {
+	for i, bound := range h.Bounds {
+		if value <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Sum += value
+	h.Count++
+}
+
+// CallStats are the calls to the GitHub API of a single kind
+type CallStats struct {
+	Calls    int       `json:"calls"`
+	Failures int       `json:"failures"`
+	Retries  int       `json:"retries"`
+	Latency  Histogram `json:"latency_seconds"`
+	Cost     Histogram `json:"cost"`
+}
+
+// ClientMetrics accumulates the calls to the GitHub API by kind of query, across runs. A nil
+// ClientMetrics records nothing.
+type ClientMetrics struct {
+	mu    sync.Mutex
+	kinds map[string]*CallStats
+
+	// breaker is the state of the circuit breaker, if any, and breakerOpens the number of times it
+	// opened
+	breaker      string
+	breakerOpens int
+}
+
+// NewClientMetrics returns an empty ClientMetrics
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{kinds: make(map[string]*CallStats)}
+}
+
+// stats returns the stats of the kind, adding them if needed. The lock must be held.
+func (m *ClientMetrics) stats(kind string) *CallStats {
+	s, ok := m.kinds[kind]
+	if !ok {
+		s = &CallStats{Latency: newHistogram(latencyBuckets), Cost: newHistogram(costBuckets)}
+		m.kinds[kind] = s
+	}
+	return s
+}
+
+// Call records a call of the kind that took latency, cost the given rate limit points if known, and
+// failed if failed is true
+func (m *ClientMetrics) Call(kind string, latency time.Duration, cost int, failed bool) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.stats(kind)
+	s.Calls++
+	s.Latency.Observe(latency.Seconds())
+	if cost > 0 {
+		s.Cost.Observe(float64(cost))
+	}
+	if failed {
+		s.Failures++
+	}
+}
+
+// Retry records that a call of the kind is being made again after a failure
+func (m *ClientMetrics) Retry(kind string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats(kind).Retries++
+}
+
+// Breaker records the state of the circuit breaker, and whether it has just opened
+func (m *ClientMetrics) Breaker(state string, opened bool) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.breaker = state
+	if opened {
+		m.breakerOpens++
+	}
+}
+
+// BreakerState returns the state of the circuit breaker, or an empty string if there is none, and the
+// number of times it opened
+func (m *ClientMetrics) BreakerState() (string, int) {
+	if m == nil {
+		return "", 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.breaker, m.breakerOpens
+}
+
+// Snapshot returns a copy of the stats of every kind
+func (m *ClientMetrics) Snapshot() map[string]CallStats {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]CallStats, len(m.kinds))
+	for kind, s := range m.kinds {
+		c := *s
+		c.Latency.Counts = append([]uint64(nil), s.Latency.Counts...)
+		c.Cost.Counts = append([]uint64(nil), s.Cost.Counts...)
+		snapshot[kind] = c
+	}
+	return snapshot
+}
+
+// clientMetricsKey and queryKindKey are the context keys of the ClientMetrics calls are recorded in and
+// the kind of the calls made with the context
+type (
+	clientMetricsKey struct{}
+	queryKindKey     struct{}
+)
+
+// withClientMetrics returns a context whose GitHub clients record their calls in m
+func withClientMetrics(ctx context.Context, m *ClientMetrics) context.Context {
+	return context.WithValue(ctx, clientMetricsKey{}, m)
+}
+
+// clientMetrics returns the ClientMetrics of the context, or nil if there is none
+func clientMetrics(ctx context.Context) *ClientMetrics {
+	m, _ := ctx.Value(clientMetricsKey{}).(*ClientMetrics)
+	return m
+}
+
+// withQueryKind returns a context whose calls to the API are recorded as the given kind, such as items
+// or timelines
+func withQueryKind(ctx context.Context, kind string) context.Context {
+	return context.WithValue(ctx, queryKindKey{}, kind)
+}
+
+// queryKind returns the kind of the request: the kind of the context if set, the name of the mutation
+// for mutations, and other for any other query
+func queryKind(req *http.Request, body []byte) string {
+	if kind, ok := req.Context().Value(queryKindKey{}).(string); ok {
+		return kind
+	}
+
+	var request struct {
+		Query string
+	}
+	json.Unmarshal(body, &request)
+	if mutation, ok := strings.CutPrefix(strings.TrimSpace(request.Query), "mutation"); ok {
+		if _, selection, ok := strings.Cut(mutation, "{"); ok {
+			selection = strings.TrimSpace(selection)
+			if i := strings.IndexAny(selection, "({ "); i > 0 {
+				return selection[:i]
+			}
+		}
+		return "mutation"
+	}
+	return "other"
+}
+
+// metricsTransport records the kind, latency, cost, and outcome of every call to the GitHub API
+type metricsTransport struct {
+	base    http.RoundTripper
+	metrics *ClientMetrics
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.GetBody != nil {
+		if r, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(r)
+			r.Close()
+		}
+	}
+	kind := queryKind(req, body)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	latency := time.Since(start)
+	if err != nil {
+		t.metrics.Call(kind, latency, 0, true)
+		return resp, err
+	}
+
+	// the cost and any errors are read from the response, which is buffered to be read again
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		t.metrics.Call(kind, latency, 0, true)
+		return resp, nil
+	}
+
+	var response struct {
+		Data struct {
+			RateLimit *struct {
+				Cost int
+			}
+		}
+		Errors []json.RawMessage
+	}
+	json.Unmarshal(b, &response)
+
+	var cost int
+	if response.Data.RateLimit != nil {
+		cost = response.Data.RateLimit.Cost
+	}
+	t.metrics.Call(kind, latency, cost, resp.StatusCode >= 400 || len(response.Errors) > 0)
+
+	return resp, nil
+}
+
+// writeClientMetrics writes the calls to the API of each tenant in the Prometheus text format, labelled
+// by tenant and kind of query
+func writeClientMetrics(b *strings.Builder, tenants []TenantStatus) {
+	type series struct {
+		tenant, kind string
+		stats        CallStats
+	}
+	var all []series
+	for _, t := range tenants {
+		kinds := make([]string, 0, len(t.API))
+		for kind := range t.API {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			all = append(all, series{t.Name, kind, t.API[kind]})
+		}
+	}
+
+	counter := func(name, help string, value func(CallStats) int) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, s := range all {
+			fmt.Fprintf(b, "%s{tenant=%q,kind=%q} %d\n", name, s.tenant, s.kind, value(s.stats))
+		}
+	}
+	histogram := func(name, help string, value func(CallStats) Histogram) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+		for _, s := range all {
+			h := value(s.stats)
+			for i, bound := range h.Bounds {
+				fmt.Fprintf(b, "%s_bucket{tenant=%q,kind=%q,le=\"%v\"} %d\n", name, s.tenant, s.kind, bound, h.Counts[i])
+			}
+			fmt.Fprintf(b, "%s_bucket{tenant=%q,kind=%q,le=\"+Inf\"} %d\n", name, s.tenant, s.kind, h.Count)
+			fmt.Fprintf(b, "%s_sum{tenant=%q,kind=%q} %v\n", name, s.tenant, s.kind, h.Sum)
+			fmt.Fprintf(b, "%s_count{tenant=%q,kind=%q} %d\n", name, s.tenant, s.kind, h.Count)
+		}
+	}
+
+	counter("github_upvotes_api_calls_total", "Calls to the GitHub API.", func(s CallStats) int { return s.Calls })
+	counter("github_upvotes_api_call_failures_total", "Calls to the GitHub API that failed or returned errors.", func(s CallStats) int { return s.Failures })
+	counter("github_upvotes_api_call_retries_total", "Calls to the GitHub API made again after a failure.", func(s CallStats) int { return s.Retries })
+	histogram("github_upvotes_api_call_duration_seconds", "Latency of calls to the GitHub API.", func(s CallStats) Histogram { return s.Latency })
+	histogram("github_upvotes_api_call_cost", "Rate limit points used by calls to the GitHub API.", func(s CallStats) Histogram { return s.Cost })
+
+	fmt.Fprintf(b, "# HELP github_upvotes_api_breaker_state State of the circuit breaker around calls to the GitHub API.\n# TYPE github_upvotes_api_breaker_state gauge\n")
+	for _, t := range tenants {
+		if t.Breaker == "" {
+			continue
+		}
+		for _, state := range []string{breakerClosed, breakerOpen, breakerHalfOpen} {
+			var value int
+			if state == t.Breaker {
+				value = 1
+			}
+			fmt.Fprintf(b, "github_upvotes_api_breaker_state{tenant=%q,state=%q} %d\n", t.Name, state, value)
+		}
+	}
+	fmt.Fprintf(b, "# HELP github_upvotes_api_breaker_opens_total Times the circuit breaker around calls to the GitHub API opened.\n# TYPE github_upvotes_api_breaker_opens_total counter\n")
+	for _, t := range tenants {
+		if t.Breaker != "" {
+			fmt.Fprintf(b, "github_upvotes_api_breaker_opens_total{tenant=%q} %d\n", t.Name, t.BreakerOpens)
+		}
+	}
+}
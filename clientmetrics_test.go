@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestClientMetrics runs a fresh synthetic project through a client whose calls are measured, and
+// checks that the calls are counted by kind, with the cost of each query, exposed as metrics, and
+// reported as the run's points by query
+func TestClientMetrics(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	server := newFakeGitHub()
+
+	metrics := NewClientMetrics()
+	engine, err := newEngine(cfg, &http.Client{Transport: &metricsTransport{base: server, metrics: metrics}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.metrics = metrics
+	if err := engine.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	quota := make(map[string]QuotaUsage)
+	for _, q := range engine.summary.Quota {
+		quota[q.Query] = q
+	}
+	expectCount(t, "items points", quota["items"].Points, server.pages)
+	expectCount(t, "timelines points", quota["timelines"].Points, server.timelines)
+	if quota["items"].Signal == "" {
+		t.Fatal("expected the signal of the items query to be described")
+	}
+
+	api := metrics.Snapshot()
+	expectCount(t, "items calls", api["items"].Calls, server.pages)
+	expectCount(t, "items calls costed", int(api["items"].Cost.Count), server.pages)
+	expectCount(t, "timelines calls", api["timelines"].Calls, server.timelines)
+	expectCount(t, "mutation calls", api["updateProjectV2ItemFieldValue"].Calls, len(server.mutations))
+
+	var b bytes.Buffer
+	if err := writeMetrics(&b, []TenantStatus{{Name: "selftest", API: api}}); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("github_upvotes_api_calls_total{tenant=\"selftest\",kind=\"items\"} %d\n", server.pages)
+	if !strings.Contains(b.String(), want) {
+		t.Fatalf("expected the metrics to include %q", want)
+	}
+}
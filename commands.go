@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// commands maps each subcommand to the function that runs it. The default command, run, calculates
+// and writes upvotes.
+var commands = map[string]func(ctx context.Context, cfg Config) error{
+	"run":       runCommand,
+	"partition": partitionCommand,
+	"apply":     applyCommand,
+	"sweep":     sweepCommand,
+	"reset":     resetCommand,
+	"report":    reportCommand,
+	"schema":    schemaCommand,
+	"canary":    canaryCommand,
+	"daemon":    daemonCommand,
+	"serve":     serveCommand,
+	"selftest":  selftestCommand,
+	"doctor":    doctorCommand,
+	"config":    configCommand,
+	"rescore":   rescoreCommand,
+	"compare":   compareCommand,
+	"version":   versionCommand,
+}
+
+// offlineCommands lists the commands that do not connect to GitHub, and so do not require a token,
+// project, or field
+var offlineCommands = map[string]bool{
+	"report":   true,
+	"rescore":  true,
+	"schema":   true,
+	"selftest": true,
+	"version":  true,
+}
+
+// selfCheckedCommands lists the commands that check for a token, project, and field themselves, or
+// take them from elsewhere
+var selfCheckedCommands = map[string]bool{
+	"doctor":  true,
+	"serve":   true,
+	"config":  true,
+	"compare": true,
+}
+
+// runCommand calculates and writes the upvotes for the project, or for a single range of it
+func runCommand(ctx context.Context, cfg Config) error {
+	if cfg.RecalculateAll && cfg.RangeIndex >= 0 {
+		slog.WarnContext(ctx, "recalculating every item, ignoring --range")
+		cfg.RangeIndex = -1
+	}
+
+	if cfg.RangeIndex >= 0 {
+		if cfg.RangeFile == "" {
+			return errors.New("--range requires --range-file")
+		}
+
+		r, err := LoadRange(cfg.RangeFile, cfg.RangeIndex, cfg.ProjectID)
+		if err != nil {
+			return err
+		}
+		cfg.Range = &r
+		slog.InfoContext(ctx, "processing range", "range", r.Index, "count", r.Count)
+	}
+
+	engine, err := NewEngine(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	return engine.Run(ctx)
+}
+
+// partitionCommand splits the project's items into ranges and writes the range assignment file
+func partitionCommand(ctx context.Context, cfg Config) error {
+	if cfg.RangeFile == "" {
+		return errors.New("partition requires --range-file")
+	}
+
+	engine, err := NewEngine(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	assignment, err := Partition(ctx, engine.gh, cfg.ProjectID, cfg.Ranges)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteRangeAssignment(cfg.RangeFile, assignment); err != nil {
+		return fmt.Errorf("writing range file: %w", err)
+	}
+
+	slog.InfoContext(ctx, "wrote range file", "path", cfg.RangeFile, "items", assignment.Total, "ranges", len(assignment.Ranges))
+	return nil
+}
+
+// applyCommand performs the mutations in a plan file generated by a read-only run
+func applyCommand(ctx context.Context, cfg Config) error {
+	if cfg.Plan == "" {
+		return errors.New("apply requires --plan")
+	}
+
+	plan, err := LoadPlan(cfg.Plan)
+	if err != nil {
+		return fmt.Errorf("reading plan: %w", err)
+	}
+
+	engine, err := NewEngine(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	return engine.Apply(ctx, plan)
+}
+
+// sweepCommand checks archived items for new activity
+func sweepCommand(ctx context.Context, cfg Config) error {
+	engine, err := NewEngine(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	return engine.Sweep(ctx)
+}
+
+// resetCommand clears the upvotes field of every item in the project, once confirmed
+func resetCommand(ctx context.Context, cfg Config) error {
+	engine, err := NewEngine(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	return engine.Reset(ctx)
+}
+
+// daemonCommand runs the engine on a schedule until interrupted
+func daemonCommand(ctx context.Context, cfg Config) error {
+	if cfg.Interval <= 0 {
+		return errors.New("daemon requires a positive --interval")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// changes to the config file are applied between runs
+	var watcher *ConfigWatcher
+	if cfg.ConfigFile != "" {
+		var err error
+		if watcher, err = WatchConfig(ctx, cfg.ConfigFile); err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "starting daemon", "interval", cfg.Interval)
+	return Daemon(ctx, cfg, nil, watcher)
+}
+
+// serveCommand runs the daemon for every tenant in the config file until interrupted, serving their
+// status and metrics
+func serveCommand(ctx context.Context, cfg Config) error {
+	server, err := NewServer(cfg, cfg.Tenants)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return server.Serve(ctx, cfg.Listen)
+}
+
+// canaryCommand recalculates a random sample of items and compares them with the score history. It
+// returns an error if the scores have drifted significantly.
+func canaryCommand(ctx context.Context, cfg Config) error {
+	if cfg.StateDir == "" && cfg.StateURL == "" {
+		return errors.New("canary requires --state-dir or --state-url")
+	}
+
+	engine, err := NewEngine(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	report, err := engine.Canary(ctx, cfg.Sample, cfg.CanaryTolerance)
+	if err != nil {
+		return err
+	}
+
+	if err := report.WriteTable(os.Stdout); err != nil {
+		return err
+	}
+
+	if report.Significant {
+		return fmt.Errorf("scores drifted by %+.1f%% on average across %d items (p=%.3f)", report.MeanDrift*100, len(report.Items), report.P)
+	}
+
+	slog.InfoContext(ctx, "no significant drift", "items", len(report.Items), "mean_drift", report.MeanDrift, "p", report.P)
+	return nil
+}
+
+// selftestCommand runs the pipeline end to end against a synthetic in-memory GitHub API
+func selftestCommand(ctx context.Context, cfg Config) error {
+	if err := Selftest(ctx, os.Stdout); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "selftest passed")
+	return nil
+}
+
+// versionCommand prints the version the tool was built as
+func versionCommand(ctx context.Context, cfg Config) error {
+	fmt.Println(versionString())
+	return nil
+}
+
+// doctorCommand checks the configuration and the tool's access to GitHub and the state directory,
+// printing a report that can be pasted into a support request
+func doctorCommand(ctx context.Context, cfg Config) error {
+	report := Doctor(ctx, cfg)
+	if err := report.WriteMarkdown(os.Stdout); err != nil {
+		return err
+	}
+
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d doctor checks failed", failed, len(report.Checks))
+	}
+
+	return nil
+}
+
+// reportCommand writes a report of the score history recorded in the state directory
+func reportCommand(ctx context.Context, cfg Config) error {
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		return errors.New("report requires --state-dir or --state-url")
+	}
+
+	history, err := store.History()
+	if err != nil {
+		return err
+	}
+
+	dashboard, err := NewDashboard(history, cfg.ReportRuns, 10)
+	if err != nil {
+		return err
+	}
+
+	var write func(io.Writer) error
+	switch cfg.ReportFormat {
+	case "html":
+		write = dashboard.WriteHTML
+	case "markdown":
+		write = dashboard.WriteMarkdown
+	default:
+		return fmt.Errorf("invalid report format %q: must be html or markdown", cfg.ReportFormat)
+	}
+
+	path := cfg.ReportFile
+	if path == "" {
+		path = cfg.outputPath("report." + map[string]string{"html": "html", "markdown": "md"}[cfg.ReportFormat])
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := errors.Join(write(f), f.Close()); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+
+	slog.InfoContext(ctx, "wrote report", "path", path, "items", len(dashboard.Items), "runs", dashboard.Runs)
+	return nil
+}
+
+// configCommand lints the configuration, checking the fields it refers to against the live project
+// when a token and project are configured. It returns an error if any problem is found.
+func configCommand(ctx context.Context, cfg Config) error {
+	if len(cfg.Args) != 1 || cfg.Args[0] != "lint" {
+		return errors.New("usage: config lint")
+	}
+
+	var problems []LintProblem
+	if cfg.ConfigFile != "" {
+		layout, err := LintConfigFile(cfg.ConfigFile)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, layout...)
+	}
+
+	var gh *githubv4.Client
+	if cfg.Token != "" && cfg.ProjectID != "" && cfg.FieldID != "" {
+		client, err := newGitHubClient(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		gh = newGraphQLClient(cfg, client)
+	} else {
+		slog.WarnContext(ctx, "skipping checks against the live project: GITHUB_TOKEN, GITHUB_PROJECT_ID, and GITHUB_FIELD_ID are required")
+	}
+
+	options, err := LintConfig(ctx, cfg, gh, cfg.ConfigFile)
+	problems = append(problems, options...)
+	sortProblems(problems)
+
+	for _, problem := range problems {
+		if problem.Line > 0 {
+			fmt.Printf("%s:%s\n", cfg.ConfigFile, problem)
+		} else {
+			fmt.Println(problem)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in the configuration", len(problems))
+	}
+
+	fmt.Println("configuration is valid")
+	return nil
+}
+
+// rescoreCommand scores the items recorded by a run with --dump again, under the configured scoring
+// profile, and reports how their scores would change. The API is only used to read the values on the
+// board with --current-values.
+func rescoreCommand(ctx context.Context, cfg Config) error {
+	if cfg.RescoreFrom == "" {
+		return errors.New("rescore requires --from <dir>, a directory written by a run with --dump")
+	}
+
+	scorer, err := NewScorer(cfg)
+	if err != nil {
+		return err
+	}
+
+	// a rescore is not a run, so it is not recorded in the history
+	reporters, err := newReporters(cfg, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	var gh *githubv4.Client
+	if cfg.CurrentValues {
+		if cfg.Token == "" || fmt.Sprint(cfg.ProjectID) == "" {
+			return errors.New("rescore --current-values requires a token and project")
+		}
+		client, err := newGitHubClient(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		gh = newGraphQLClient(cfg, client)
+	}
+
+	summary, err := Rescore(ctx, cfg.RescoreFrom, scorer, reporters, gh)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "rescored recorded items", "items", summary.Total, "changed", summary.Statuses[StatusPlanned])
+	return nil
+}
+
+// compareCommand scores the board, or the items recorded by a run with --dump, under two scoring
+// profiles, and prints how closely their rankings agree and the items whose rank changes the most
+func compareCommand(ctx context.Context, cfg Config) error {
+	if cfg.ProfileA == "" || cfg.ProfileB == "" {
+		return errors.New("compare requires --profile-a and --profile-b")
+	}
+
+	scorers := make([]*Scorer, 2)
+	for i, path := range []string{cfg.ProfileA, cfg.ProfileB} {
+		profile, err := LoadScoringProfile(path)
+		if err != nil {
+			return err
+		}
+
+		c := cfg
+		c.Scoring = profile
+		if scorers[i], err = NewScorer(c); err != nil {
+			return err
+		}
+	}
+
+	var records []DumpRecord
+	if cfg.RescoreFrom != "" {
+		_, recorded, err := LoadDump(cfg.RescoreFrom)
+		if err != nil {
+			return err
+		}
+		records = recorded
+	} else {
+		if cfg.Token == "" || fmt.Sprint(cfg.ProjectID) == "" || fmt.Sprint(cfg.FieldID) == "" {
+			return errors.New("compare requires --from <dir>, or a token, project, and field to score the board with")
+		}
+
+		engine, err := NewEngine(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if records, err = engine.Record(ctx); err != nil {
+			return err
+		}
+	}
+
+	comparison, err := Compare(records, scorers[0], scorers[1])
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "compared scoring profiles", "profile_a", cfg.ProfileA, "profile_b", cfg.ProfileB, "items", len(comparison.Items), "correlation", comparison.Correlation)
+	return comparison.WriteTable(os.Stdout, compareSwings)
+}
+
+// schemaCommand prints the JSON Schema of an artifact the tool writes, or lists the artifacts with
+// a schema
+func schemaCommand(ctx context.Context, cfg Config) error {
+	if len(cfg.Args) == 0 || cfg.Args[0] == "list" {
+		for _, name := range schemaNames() {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	if cfg.Args[0] != "print" || len(cfg.Args) != 2 {
+		return errors.New("usage: schema print <name>, or schema list")
+	}
+
+	return printSchema(os.Stdout, cfg.Args[1])
+}
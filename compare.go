@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+)
+
+// compareSwings is the number of items with the largest rank swings shown by the compare command
+const compareSwings = 10
+
+// LoadScoringProfile reads a scoring profile from a YAML, JSON, or TOML file. The file holds either the
+// profile itself, or a config file whose scoring key holds it. Weights that are not set default to 1,
+// as they do in the config file.
+func LoadScoringProfile(path string) (ScoringProfile, error) {
+	profile := DefaultScoringProfile()
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return profile, fmt.Errorf("reading scoring profile %s: %w", path, err)
+	}
+	if v.IsSet("scoring") {
+		v = v.Sub("scoring")
+	}
+
+	if err := v.Unmarshal(&profile); err != nil {
+		return profile, fmt.Errorf("reading scoring profile %s: %w", path, err)
+	}
+
+	return profile, nil
+}
+
+// ComparisonItem is an item's score and rank, from 1, under each of the two compared profiles
+type ComparisonItem struct {
+	ItemID string
+	Name   string
+	A, B   float64
+
+	RankA, RankB int
+}
+
+// Swing returns how many places the item moves from profile A's ranking to profile B's, positive if it
+// rises
+func (i ComparisonItem) Swing() int {
+	return i.RankA - i.RankB
+}
+
+// Comparison is how two scoring profiles rank the same items
+type Comparison struct {
+	A, B  ScoringProfile
+	Items []ComparisonItem
+
+	// Correlation is Spearman's rank correlation of the two rankings, from -1 to 1, where 1 means the
+	// profiles rank the items identically
+	Correlation float64
+}
+
+// Compare scores the recorded items under each of the scorers and compares the rankings they produce
+func Compare(records []DumpRecord, a, b *Scorer) (Comparison, error) {
+	comparison := Comparison{A: a.Profile(), B: b.Profile()}
+
+	for _, record := range records {
+		scoreA, err := record.score(a)
+		if err != nil {
+			return comparison, err
+		}
+		scoreB, err := record.score(b)
+		if err != nil {
+			return comparison, err
+		}
+
+		comparison.Items = append(comparison.Items, ComparisonItem{
+			ItemID: fmt.Sprint(record.ItemID),
+			Name:   Result{ItemID: record.ItemID, Content: record.Content}.Name(),
+			A:      Total(scoreA),
+			B:      Total(scoreB),
+		})
+	}
+
+	items := comparison.Items
+	valueA := func(i ComparisonItem) float64 { return i.A }
+	valueB := func(i ComparisonItem) float64 { return i.B }
+	rank := func(value func(ComparisonItem) float64, set func(*ComparisonItem, int)) {
+		sort.SliceStable(items, func(i, j int) bool {
+			if value(items[i]) != value(items[j]) {
+				return value(items[i]) > value(items[j])
+			}
+			return items[i].ItemID < items[j].ItemID
+		})
+		for i := range items {
+			set(&items[i], i+1)
+		}
+	}
+	rank(valueA, func(i *ComparisonItem, r int) { i.RankA = r })
+	rank(valueB, func(i *ComparisonItem, r int) { i.RankB = r })
+	comparison.Correlation = correlation(fractionalRanks(items, valueA), fractionalRanks(items, valueB))
+
+	return comparison, nil
+}
+
+// fractionalRanks returns the rank of each item, in their current order, among the items sorted by value.
+// Tied items share the mean of the ranks they span, as Spearman's correlation requires.
+func fractionalRanks(items []ComparisonItem, value func(ComparisonItem) float64) []float64 {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return value(items[order[i]]) > value(items[order[j]])
+	})
+
+	ranks := make([]float64, len(items))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && value(items[order[end]]) == value(items[order[start]]) {
+			end++
+		}
+		for _, i := range order[start:end] {
+			ranks[i] = float64(start+end+1) / 2
+		}
+		start = end
+	}
+
+	return ranks
+}
+
+// correlation returns the Pearson correlation of the paired values, or 1 if either set of values does
+// not vary, since the rankings cannot then disagree
+func correlation(x, y []float64) float64 {
+	n := float64(len(x))
+	if n == 0 {
+		return 1
+	}
+
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var cov, varX, varY float64
+	for i := range x {
+		cov += (x[i] - meanX) * (y[i] - meanY)
+		varX += (x[i] - meanX) * (x[i] - meanX)
+		varY += (y[i] - meanY) * (y[i] - meanY)
+	}
+	if varX == 0 || varY == 0 {
+		return 1
+	}
+
+	return cov / math.Sqrt(varX*varY)
+}
+
+// Swings returns up to n items whose rank changes the most between the profiles, largest first
+func (c Comparison) Swings(n int) []ComparisonItem {
+	var swings []ComparisonItem
+	for _, item := range c.Items {
+		if item.Swing() != 0 {
+			swings = append(swings, item)
+		}
+	}
+
+	sort.SliceStable(swings, func(i, j int) bool {
+		a, b := swings[i].Swing(), swings[j].Swing()
+		if a*a != b*b {
+			return a*a > b*b
+		}
+		return swings[i].RankB < swings[j].RankB
+	})
+
+	return swings[:min(n, len(swings))]
+}
+
+// WriteTable writes the rank correlation and the largest rank swings as plain text
+func (c Comparison) WriteTable(w io.Writer, swings int) error {
+	if _, err := fmt.Fprintf(w, "profile A: %s\nprofile B: %s\nitems: %d\nrank correlation: %.3f\n\n", c.A.ID(), c.B.ID(), len(c.Items), c.Correlation); err != nil {
+		return err
+	}
+
+	largest := c.Swings(swings)
+	if len(largest) == 0 {
+		_, err := fmt.Fprintln(w, "both profiles rank every item the same")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ITEM\tRANK A\tRANK B\tSWING\tSCORE A\tSCORE B")
+	for _, item := range largest {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\t%v\t%v\n", item.Name, item.RankA, item.RankB, item.Swing(), item.A, item.B)
+	}
+	return tw.Flush()
+}
+
+// Record scores every item on the board without writing anything, and returns what each was scored
+// from, as a run with --dump would record it
+func (e *Engine) Record(ctx context.Context) ([]DumpRecord, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dump := &Dump{}
+	e.scorer.dump = dump
+	defer func() { e.scorer.dump = nil }()
+
+	errChan := make(chan error)
+	results := make(chan Result)
+	failed := make(chan int)
+	go func() {
+		var n int
+		for result := range results {
+			if result.Status == StatusFailed {
+				slog.ErrorContext(ctx, "failed to score project item", "item_id", result.ItemID, "error", result.Err)
+				n++
+			}
+		}
+		failed <- n
+	}()
+
+	itemChan, wg := GetProjectItems(ctx, e.gh, e.cfg, e.fragments, e.limiter, nil, results, errChan)
+	updateChan := ProcessProjectItems(ctx, e.gh, e.scorer, e.limiter, nil, itemChan)
+	done := UpdateProjectItems(ctx, wg, NewPlan(RunInfo{}), WritePolicy{}, updateChan, results)
+
+	var err error
+	select {
+	case err = <-errChan:
+		cancel()
+		<-done
+	case <-done:
+	}
+
+	close(results)
+	if n := <-failed; err == nil && n > 0 {
+		err = fmt.Errorf("failed to score %d project items", n)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	return dump.Records(), err
+}
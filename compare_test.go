@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// TestCompare scores the board without writing, and checks that comparing the configured profile with
+// itself finds no swings, and that a profile counting only comments scores every item by its comments
+func TestCompare(t *testing.T) {
+	cfg := testConfig(t)
+	server := scoredFakeGitHub(t, cfg)
+	server.mutations = make(map[string]float64)
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := engine.Record(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "mutations", len(server.mutations), 0)
+
+	a, err := NewScorer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := Compare(records, a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Correlation != 1 || len(same.Swings(compareSwings)) != 0 {
+		t.Fatalf("expected identical rankings, got a correlation of %v and %d swings", same.Correlation, len(same.Swings(compareSwings)))
+	}
+
+	cfg.Scoring = ScoringProfile{Comments: 1}
+	b, err := NewScorer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comparison, err := Compare(records, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byId := make(map[string]*selftestItem)
+	for _, item := range server.items {
+		byId[item.id] = item
+	}
+	for _, item := range comparison.Items {
+		want := byId[item.ItemID]
+		if item.A != want.upvotes() || item.B != float64(want.comments) {
+			t.Errorf("%s: expected scores of %v and %v, got %v and %v", item.ItemID, want.upvotes(), want.comments, item.A, item.B)
+		}
+	}
+	expectCount(t, "compared items", len(comparison.Items), selftestItems-2)
+}
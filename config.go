@@ -0,0 +1,431 @@
+package main
+
+import (
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Config holds the options used by the pipeline. It is populated once at the CLI edge so that
+// nothing below main needs to read from viper directly.
+type Config struct {
+	// Command is the subcommand to run
+	Command string
+
+	// Args holds the arguments that follow the subcommand
+	Args []string
+
+	// ConfigFile is the path of the config file, if any
+	ConfigFile string
+
+	// Token is the GitHub token used to authenticate with the GraphQL API
+	Token string
+
+	// ProjectID is the node ID of the GitHub Project
+	ProjectID githubv4.ID
+
+	// FieldID is the node ID of the 'upvotes' number field in the GitHub Project
+	FieldID githubv4.ID
+
+	// SyncProjectID and SyncFieldID are the node IDs of a roll-up project and its upvotes field, which
+	// the upvotes calculated for each item are also written to, for the items of the same Issues and
+	// Pull Requests. Both are nil unless a roll-up project is configured.
+	SyncProjectID githubv4.ID
+	SyncFieldID   githubv4.ID
+
+	// APIURL is the endpoint of the GraphQL API, for GitHub Enterprise Server; empty for github.com
+	APIURL string
+
+	// Proxy is the URL of the proxy used for requests to GitHub. When empty, HTTPS_PROXY and NO_PROXY
+	// are used.
+	Proxy string
+
+	// UserAgent is the User-Agent sent with requests to GitHub
+	UserAgent string
+
+	// Headers are additional headers sent with requests to GitHub, for example for an API gateway
+	Headers map[string]string
+
+	// RequestTimeout is the time limit for each attempt of a request to GitHub, or zero for no limit
+	RequestTimeout time.Duration
+
+	// Retries is the number of times a request is made again after a transient failure, waiting
+	// RetryBackoff, doubled for each retry, before the first. Zero disables retries.
+	Retries      int
+	RetryBackoff time.Duration
+
+	// BreakerThreshold is the number of consecutive failed requests that opens the circuit breaker,
+	// pausing requests to GitHub for BreakerCooldown. Zero disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Debug enables debug logging
+	Debug bool
+
+	// Output is the path to write the JSON report of every item to, in addition to the reporters. It
+	// is read from GITHUB_OUTPUT_FILE, since GITHUB_OUTPUT is the file of a step's outputs in Actions.
+	Output string
+
+	// SummaryFile is the path to write the JSON run summary to. No file is written if empty.
+	SummaryFile string
+
+	// OrgAuditRepo is the repository, as owner/name, that each run is recorded in, either as a line
+	// committed to OrgAuditPath or, if OrgAuditIssue is set, as a comment on that issue. Nothing is
+	// recorded if empty.
+	OrgAuditRepo  string
+	OrgAuditPath  string
+	OrgAuditIssue int
+
+	// StepSummary is the path of the GitHub Actions job summary file used by the actions-summary
+	// reporter. Set by Actions through GITHUB_STEP_SUMMARY.
+	StepSummary string
+
+	// EventPath is the path of the webhook event that triggered the workflow, used by the score-diff
+	// reporter to find the pull request it runs for. Set by Actions through GITHUB_EVENT_PATH.
+	EventPath string
+
+	// Reporters lists the reporters to send results to, as name or name=path
+	Reporters []string
+
+	// ReportTemplate is the path of a Go template that replaces the built-in Markdown report, and is
+	// used by the template reporter
+	ReportTemplate string
+
+	// GroupBy groups the items in reports into per-group leaderboards, by the labels matching a
+	// pattern (label:area/*) or the value of a project field (field:Component)
+	GroupBy string
+
+	// GroupTop is the number of items listed in each group's leaderboard
+	GroupTop int
+
+	// MilestoneBudget is the number of estimate points the milestone reporter fills, and
+	// MilestoneCapacity the number of items. Zero is unlimited.
+	MilestoneBudget   float64
+	MilestoneCapacity int
+
+	// InboxThreshold is the number of upvotes that items crossing it are listed for in the inbox report,
+	// NotifyThreshold if zero, and InboxVelocity how many times the median velocity an item's velocity must
+	// be to be listed as rising fast. Zero turns either section off.
+	InboxThreshold float64
+	InboxVelocity  float64
+
+	// AssignTop is the number of top items assigned to the current iteration of the AssignIteration
+	// field, and to the open milestone titled AssignMilestone in their repository, at the end of each
+	// run. Zero assigns nothing.
+	AssignTop       int
+	AssignIteration string
+	AssignMilestone string
+
+	// SuggestTop is the number of top unassigned items the assignees reporter suggests assignees for, and
+	// AssigneePool the people it suggests. Those with open items assigned on the board are suggested if
+	// the pool is empty.
+	SuggestTop   int
+	AssigneePool []string
+
+	// EstimateField is the name of the project number field holding each item's estimate, used by the
+	// milestone reporter
+	EstimateField string
+
+	// FirstResponseField and LastActivityField are the names of the project number fields that the hours
+	// until an item's first response from a maintainer, and the days since it was last commented on, are
+	// written to. Neither is written when empty.
+	FirstResponseField string
+	LastActivityField  string
+
+	// DuplicateThreshold is the combined upvotes a probable duplicate cluster needs to be reported
+	DuplicateThreshold float64
+
+	// ReportFormat is the format of the report written by the report command: html or markdown
+	ReportFormat string
+
+	// ReportFile is the path the report command writes to
+	ReportFile string
+
+	// ReportRuns is the number of recent runs the report command shows trends over
+	ReportRuns int
+
+	// Schedule is the time until the next scheduled run, used to forecast whether it fits in the rate
+	// limit. The daemon command uses Interval instead.
+	Schedule time.Duration
+
+	// Interval is the time between runs of the daemon command
+	Interval time.Duration
+
+	// Listen is the address the serve command serves tenant status and metrics on
+	Listen string
+
+	// Tenants are the named configurations the serve command runs
+	Tenants []Tenant
+
+	// Sample is the number of items the canary command recalculates
+	Sample int
+
+	// CanaryTolerance is the mean relative drift the canary command tolerates before failing
+	CanaryTolerance float64
+
+	// Notifiers lists the notifiers to send messages to, as name=target
+	Notifiers []string
+
+	// Redact controls whether titles are stripped from notifications: auto, for private projects only,
+	// always, or never
+	Redact string
+
+	// DropAlert is the percentage by which an item's upvotes must drop between runs to send an alert. Zero
+	// turns the alert off.
+	DropAlert float64
+
+	// NotifyTemplate is the Go template used to render notification messages for NotifyThreshold and
+	// NotifyDelta
+	NotifyTemplate string
+
+	// Locale is the language that Markdown reports and notifications are written in. Nil writes them in
+	// English.
+	Locale *Locale
+
+	// NotifyThreshold is the number of upvotes that an item must cross to trigger a notification. It
+	// is shorthand for a rule with a single notify action, and is disabled when zero.
+	NotifyThreshold float64
+
+	// NotifyDelta is the number of upvotes that an item must gain since the last run to trigger a
+	// notification. Like NotifyThreshold, it is shorthand for a rule, and is disabled when zero.
+	NotifyDelta float64
+
+	// RunID identifies the run in logs, state, audit records, and reports. Set by Actions through
+	// GITHUB_RUN_ID. A new ID is generated for each run when it is empty.
+	RunID string
+
+	// RunAttempt is the attempt number of a re-run workflow. Set by Actions through GITHUB_RUN_ATTEMPT.
+	RunAttempt int
+
+	// StateDir is the directory that state, such as the audit log, is written to. No state is kept
+	// when it is empty.
+	StateDir string
+
+	// StateURL is the base URL of a remote store that the state and score history are kept in instead
+	// of the state directory, authenticated with StateToken if set. The audit log is still written to
+	// the state directory.
+	StateURL   string
+	StateToken string
+
+	// Series appends the score of every item to a monthly CSV file in the state directory or remote
+	// store, for charting trends in a spreadsheet
+	Series bool
+
+	// OutputDir is the directory that relative paths of the files the tool writes, and of the state
+	// directory, are resolved against. In an Actions container it defaults to GITHUB_WORKSPACE, and
+	// the files written are given to the owner of the workspace rather than left owned by root.
+	OutputDir string
+
+	// ReadOnly computes upvotes without writing them, queueing the field updates in PlanFile instead.
+	// It is enabled automatically when the token cannot update the project.
+	ReadOnly bool
+
+	// DryRun computes upvotes as ReadOnly does, but prints the value each item would be given instead
+	// of writing a plan, to validate the scoring on a production project without touching it
+	DryRun bool
+
+	// PlanFile is the path that pending field updates are written to in read-only mode
+	PlanFile string
+
+	// MaxUpdates is the most field updates a run performs, as a guard for first runs and new
+	// configurations. The updates past it are written to PlanFile to be applied later. There is no limit
+	// when it is zero.
+	MaxUpdates int
+
+	// ConflictPolicy is applied when a field was changed by someone else after the new value was
+	// calculated: skip, overwrite, or fail
+	ConflictPolicy string
+
+	// SweepThreshold is the number of new timeline items an archived item needs for the sweep command
+	// to report or unarchive it
+	SweepThreshold int
+
+	// Unarchive unarchives items found by the sweep command rather than only reporting them
+	Unarchive bool
+
+	// Plan is the path of the plan file performed by the apply command
+	Plan string
+
+	// Confirm lets the reset command write, rather than only report the items it would reset, and
+	// ResetToZero makes it set the upvotes field to zero rather than clear it
+	Confirm     bool
+	ResetToZero bool
+
+	// ResetFilter selects the items the reset command resets. Every item is reset when it is empty.
+	ResetFilter resetFilter
+
+	// Ranges is the number of ranges the partition command splits the project's items into
+	Ranges int
+
+	// RangeFile is the path of the range assignment file written by the partition command
+	RangeFile string
+
+	// RangeIndex is the index of the range in RangeFile to process. All items are processed when negative.
+	RangeIndex int
+
+	// CheckpointFile is the path of the JSON file that the progress of a run through the project's items
+	// is kept in, so that a run that stops part of the way is resumed by the next. Empty keeps no progress.
+	CheckpointFile string
+
+	// Range limits processing to a contiguous set of items. It is loaded from RangeFile.
+	Range *Range
+
+	// AdjustmentsFile is the path of a CSV file of manual adjustments to item scores, with one
+	// url,points,reason row per adjustment
+	AdjustmentsFile string
+
+	// ScoreOverrideField is the name of a project number field that, when set on an item, replaces
+	// its calculated upvotes
+	ScoreOverrideField string
+
+	// MinScoreField and MaxScoreField are the names of project number fields that, when set on an
+	// item, clamp its calculated upvotes
+	MinScoreField string
+	MaxScoreField string
+
+	// ReservePoints is the number of rate limit points the tool never uses, for tokens that are shared
+	// with other automation. Once the remaining points reach the reserve, the run waits for the reset.
+	ReservePoints int
+
+	// MinDelta is the minimum change in an item's upvotes that is written to the project. Smaller
+	// changes are left until they add up.
+	MinDelta float64
+
+	// RoundTo is the step, such as 5 or 10, that values written to the project are rounded to, so that
+	// the board's sort order does not change with every small change. Reports include the precise value.
+	RoundTo float64
+
+	// Scoring is the scoring profile used to calculate upvotes
+	Scoring ScoringProfile
+
+	// Rollout scores a share of the items with a new scoring profile instead of Scoring
+	Rollout Rollout
+
+	// Inactivity reduces or flags the scores of items without new engagement for a number of days
+	Inactivity Inactivity
+
+	// Teams are the teams whose mentions in comments are weighed, or notified of
+	Teams []Team
+
+	// IssueFields are the issue form fields whose values in the body of an Issue or Pull Request boost
+	// its upvotes
+	IssueFields []IssueField
+
+	// Keywords are the patterns searched for in the title and body of each Issue or Pull Request, which
+	// boost or suppress the upvotes of the items they match
+	Keywords []Keyword
+
+	// Owners maps items to the teams that own them, to exclude or weigh the items of each team
+	Owners Owners
+
+	// Cache reuses the timeline components of the scores of items whose Issue or Pull Request has not
+	// been updated since they were cached in the state directory, for up to CacheTTL. SkipUnmodified
+	// also leaves those items' values alone rather than writing them.
+	Cache          bool
+	CacheTTL       time.Duration
+	SkipUnmodified bool
+
+	// RecalculateAll recalculates every item, including closed and archived items and items outside
+	// of the configured range, so that no values calculated with a previous scoring profile remain
+	RecalculateAll bool
+
+	// Age adds the age and upvotes per day of each item to the table, Markdown, and CSV reports
+	Age bool
+
+	// Explain includes the breakdown of each item's upvotes in its log line and report
+	Explain bool
+
+	// DumpDir is the directory a run records the data each item is scored from in, for the rescore
+	// command. Nothing is recorded when empty.
+	DumpDir string
+
+	// RescoreFrom is the directory of recorded data the rescore and compare commands score again
+	RescoreFrom string
+
+	// CurrentValues is true if the rescore command compares the new scores with the values currently on
+	// the board, rather than with the scores recorded in the dump
+	CurrentValues bool
+
+	// ProfileA and ProfileB are the files holding the scoring profiles the compare command compares
+	ProfileA string
+	ProfileB string
+
+	// Digest batches notification messages into a single message sent at the end of the run
+	Digest bool
+
+	// DigestInterval additionally sends the digest at this interval during the run, when non-zero
+	DigestInterval time.Duration
+
+	// Export configures the jira and linear reporters
+	Export ExportConfig
+
+	// Segments are named groups of people whose upvotes are counted separately
+	Segments []Segment
+
+	// Rules are evaluated against every calculated item, running their actions when matched
+	Rules []Rule
+
+	// CheckSchema introspects GitHub's GraphQL schema before running, warning about deprecated fields
+	// the tool relies on and failing if any are missing
+	CheckSchema bool
+
+	// Enrich fetches the title, number, repository, and assignees of each item's content, so that
+	// reports and notifications can name items rather than show node IDs
+	Enrich bool
+
+	// ExcludeRepos are the repositories, as owner/name, whose items are skipped rather than scored
+	ExcludeRepos []string
+
+	// ExcludeRepoQuery is a repository search, such as `org:acme topic:internal`, whose repositories
+	// are excluded along with ExcludeRepos. Its result is cached in the state store for RepoCacheTTL.
+	ExcludeRepoQuery string
+	RepoCacheTTL     time.Duration
+
+	// RepoQuery is a repository search, such as `org:acme topic:product`, that limits scoring to the
+	// repositories it finds; the items of every other repository are skipped as excluded. Its result is
+	// cached in the state store for RepoCacheTTL, as that of ExcludeRepoQuery is.
+	RepoQuery string
+
+	// IncludeRepos are the repositories, as owner/name, that RepoQuery found, once it has been searched.
+	// When not nil, the items of every other repository are skipped.
+	IncludeRepos []string
+
+	// TerminalStatuses are the values of the project's Status field, such as Done or Won't do, whose
+	// items are skipped rather than scored, even if their Issue or Pull Request is still open
+	TerminalStatuses []string
+
+	// ExtraFields are additional GraphQL selections on ProjectV2Item to fetch for each item, keyed by
+	// the name they are reported under
+	ExtraFields map[string]string
+}
+
+// ExportConfig configures exporting scores to an external issue tracker. Each project item is matched
+// to an external issue by the key in the KeyField project text field, or by looking up the item's
+// URL in MappingFile, a CSV file of URL and key pairs.
+type ExportConfig struct {
+	KeyField    string
+	MappingFile string
+	Jira        JiraConfig
+	Linear      LinearConfig
+}
+
+// JiraConfig configures exporting scores to a Jira custom field
+type JiraConfig struct {
+	// URL is the base URL of the Jira instance
+	URL string
+
+	// User is the email address used with Token for Jira Cloud. If empty, Token is used as a bearer token.
+	User string
+
+	// Token is the API token or personal access token
+	Token string
+
+	// Field is the ID of the custom field to write the score to, for example customfield_10042
+	Field string
+}
+
+// LinearConfig configures exporting scores to the estimate of Linear issues
+type LinearConfig struct {
+	// Token is the Linear API key
+	Token string
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// costMargin is the factor applied to the cost of the previous run when deciding whether the next run
+// fits in the remaining rate limit
+const costMargin = 1.2
+
+// RateObservation is the rate limit observed at a point in time
+type RateObservation struct {
+	Time      time.Time
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+}
+
+// Scheduler decides whether the daemon runs now. A run that is not expected to fit in the remaining
+// rate limit is deferred until the limit resets, so that heavy runs start with the full limit. The
+// cost of a run is taken from the previous run, and the points used by other consumers of the same
+// token are estimated from how the remaining points changed between runs.
+type Scheduler struct {
+	// reserve is the number of points that are never used
+	reserve int
+
+	// cost and duration of the previous run
+	cost     int
+	duration time.Duration
+
+	// the rate limit observed when the previous run finished
+	last *RateObservation
+}
+
+// Record notes the rate limit before and after a run, to estimate the cost of the next
+func (s *Scheduler) Record(before, after RateObservation) {
+	s.duration = after.Time.Sub(before.Time)
+	if before.ResetAt.Equal(after.ResetAt) {
+		s.cost = before.Remaining - after.Remaining
+	} else {
+		// the limit reset during the run, so only the points used since the reset are known
+		s.cost = max(s.cost, after.Limit-after.Remaining)
+	}
+	s.last = &after
+}
+
+// externalRate returns the points per second used by other consumers of the token since the previous
+// run finished, or zero if unknown
+func (s *Scheduler) externalRate(now RateObservation) float64 {
+	if s.last == nil || !s.last.ResetAt.Equal(now.ResetAt) {
+		return 0
+	}
+
+	elapsed := now.Time.Sub(s.last.Time).Seconds()
+	used := s.last.Remaining - now.Remaining
+	if elapsed <= 0 || used <= 0 {
+		return 0
+	}
+
+	return float64(used) / elapsed
+}
+
+// Delay returns how long to wait before running, given the current rate limit, and why. A run is
+// deferred until the limit resets if the points it is expected to need, including those used by other
+// consumers while it runs, exceed the remaining points. A run expected to need more than the whole limit
+// would be deferred forever, so it runs once the full limit is available.
+func (s *Scheduler) Delay(now RateObservation) (time.Duration, string) {
+	if s.cost == 0 {
+		return 0, ""
+	}
+
+	needed := float64(s.cost)*costMargin + s.externalRate(now)*s.duration.Seconds()
+	if now.Limit > 0 {
+		needed = min(needed, float64(now.Limit-s.reserve))
+	}
+	if float64(now.Remaining-s.reserve) >= needed {
+		return 0, ""
+	}
+
+	reason := "expected cost exceeds the remaining rate limit"
+	if s.externalRate(now) > 0 {
+		reason = "expected cost, with points used by other consumers of the token, exceeds the remaining rate limit"
+	}
+
+	return time.Until(now.ResetAt) + time.Second, reason
+}
+
+// observeRateLimit reads the current rate limit
+func observeRateLimit(ctx context.Context, gh *githubv4.Client) (RateObservation, error) {
+	var query RateLimitQuery
+	if err := runQuery(withQueryKind(ctx, "rate_limit"), gh, &query, nil); err != nil {
+		return RateObservation{}, err
+	}
+
+	return RateObservation{
+		Time:      time.Now(),
+		Limit:     query.RateLimit.Limit,
+		Remaining: query.RateLimit.Remaining,
+		ResetAt:   query.RateLimit.ResetAt.Time,
+	}, nil
+}
+
+// Daemon runs the engine every interval until the context is cancelled, deferring runs that are not
+// expected to fit in the rate limit until it resets. A failed run is logged, and the daemon carries on.
+// The outcome of each run is recorded by the recorder, and changes to the config file seen by the
+// watcher are applied between runs, if they are not nil.
+func Daemon(ctx context.Context, cfg Config, recorder *RunRecorder, watcher *ConfigWatcher) error {
+	scheduler := &Scheduler{reserve: cfg.ReservePoints}
+
+	for {
+		scheduler.reserve = cfg.ReservePoints
+		engine, err := NewEngine(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		before, err := observeRateLimit(ctx, engine.gh)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to read rate limit", "error", err)
+		} else if delay, reason := scheduler.Delay(before); delay > 0 {
+			slog.InfoContext(ctx, "deferring run until the rate limit resets", "reason", reason, "remaining", before.Remaining, "reset_at", before.ResetAt)
+			var ok bool
+			if cfg, ok = waitForReset(ctx, cfg, watcher, delay); !ok {
+				return nil
+			}
+			continue
+		}
+
+		recorder.started(time.Now())
+		err = engine.Run(ctx)
+		recorder.finished(time.Now(), engine.Summary(), err)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "run failed", "error", err)
+		}
+		if err := fixOwnership(cfg); err != nil {
+			slog.WarnContext(ctx, "failed to give written files to the owner of the output directory", "error", err)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if after, err := observeRateLimit(ctx, engine.gh); err == nil && !before.Time.IsZero() {
+			scheduler.Record(before, after)
+			slog.InfoContext(ctx, "run finished", "cost", scheduler.cost, "remaining", after.Remaining, "reset_at", after.ResetAt, "next_run", time.Now().Add(cfg.Interval).Round(time.Second))
+		}
+
+		var ok bool
+		if cfg, ok = waitForRun(ctx, cfg, watcher, time.Now()); !ok {
+			return nil
+		}
+	}
+}
+
+// waitForRun waits until the interval has passed since the previous run finished, and returns the
+// configuration for the next run. Changes to the config file are applied as soon as they are seen, so
+// that a new interval takes effect without waiting out the old one. It returns false if the context was
+// cancelled first.
+func waitForRun(ctx context.Context, cfg Config, watcher *ConfigWatcher, finished time.Time) (Config, bool) {
+	for {
+		timer := time.NewTimer(max(time.Until(finished.Add(cfg.Interval)), 0))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return cfg, false
+		case <-timer.C:
+			return cfg, true
+		case <-watcher.Changed():
+			timer.Stop()
+			cfg = applyConfigChange(ctx, cfg, watcher)
+		}
+	}
+}
+
+// waitForReset waits out the delay of a deferred run, and returns the configuration to try again with. A
+// change to the config file ends the wait early, so that the run is reconsidered with the new
+// configuration, for example a lower reserve. It returns false if the context was cancelled first.
+func waitForReset(ctx context.Context, cfg Config, watcher *ConfigWatcher, delay time.Duration) (Config, bool) {
+	timer := time.NewTimer(max(delay, 0))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return cfg, false
+	case <-timer.C:
+		return cfg, true
+	case <-watcher.Changed():
+		return applyConfigChange(ctx, cfg, watcher), true
+	}
+}
+
+// sleep waits for the duration, returning false if the context was cancelled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(max(d, 0))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSchedulerDelay checks that a run expected to cost more than the remaining points is deferred, and
+// that one expected to cost more than the whole limit still runs once the full limit is available
+func TestSchedulerDelay(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		name      string
+		cost      int
+		remaining int
+		deferred  bool
+	}{
+		{"fits", 1000, 4000, false},
+		{"exceeds the remaining points", 3000, 2000, true},
+		{"exceeds the limit, part of it used", 6000, 4000, true},
+		{"exceeds the limit, full limit available", 6000, 5000, false},
+	} {
+		s := &Scheduler{reserve: 100, cost: c.cost, duration: time.Minute}
+		delay, _ := s.Delay(RateObservation{Time: now, Limit: 5000, Remaining: c.remaining, ResetAt: now.Add(time.Hour)})
+		if got := delay > 0; got != c.deferred {
+			t.Errorf("%s: expected deferred to be %v, got a delay of %v", c.name, c.deferred, delay)
+		}
+	}
+}
+
+// TestWaitForReset checks that a change to the config file ends the wait for the rate limit to reset
+func TestWaitForReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := []byte("interval: 1h\n")
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	watcher := &ConfigWatcher{path: path, changed: make(chan struct{}, 1), contents: contents}
+	watcher.changed <- struct{}{}
+
+	done := make(chan bool)
+	go func() {
+		_, ok := waitForReset(context.Background(), Config{ConfigFile: path}, watcher, time.Hour)
+		done <- ok
+	}()
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("expected the wait to end with the configuration to try again with")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the config change to end the wait")
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dashboard is the data shown by the report command, built from the score history
+type Dashboard struct {
+	// Run is the latest run in the history
+	Run HistoryEntry
+
+	// Runs is the number of runs the trends cover
+	Runs int
+
+	// Since is the time of the oldest run the trends cover
+	Since time.Time
+
+	// Items holds every item scored by the latest run, with the most upvotes first
+	Items []DashboardItem
+
+	// Movers holds the items whose upvotes changed the most over the runs the trends cover
+	Movers []DashboardItem
+
+	// Distribution holds the distribution of the upvotes in each run the trends cover, oldest first,
+	// to show whether demand is growing overall
+	Distribution []DashboardRun
+}
+
+// DashboardRun is the distribution of the upvotes in a single run
+type DashboardRun struct {
+	RunID string
+	Time  time.Time
+	ScoreStats
+}
+
+// DashboardItem is the trend of a single item's upvotes
+type DashboardItem struct {
+	ID      string
+	URL     string
+	Upvotes float64
+
+	// Change is the difference in upvotes since the oldest run the trends cover
+	Change float64
+
+	// Trend holds the item's upvotes for each run, oldest first. Runs that did not score the item are NaN.
+	Trend []float64
+}
+
+// Sparkline returns the points of an SVG polyline of the item's trend, within a box of the given size
+func (d DashboardItem) Sparkline(width, height float64) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range d.Trend {
+		if !math.IsNaN(v) {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+
+	step := width
+	if len(d.Trend) > 1 {
+		step = width / float64(len(d.Trend)-1)
+	}
+
+	var points []string
+	for i, v := range d.Trend {
+		if math.IsNaN(v) {
+			continue
+		}
+		y := height / 2
+		if hi > lo {
+			y = height - (v-lo)/(hi-lo)*height
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", float64(i)*step, y))
+	}
+
+	return strings.Join(points, " ")
+}
+
+// NewDashboard builds the Dashboard from the history, using at most the given number of the latest
+// runs for trends, and listing at most movers items as top movers
+func NewDashboard(history []HistoryEntry, runs, movers int) (Dashboard, error) {
+	if len(history) == 0 {
+		return Dashboard{}, errors.New("no score history: run with --state-dir to record it")
+	}
+
+	if runs > 0 && len(history) > runs {
+		history = history[len(history)-runs:]
+	}
+
+	latest := history[len(history)-1]
+	d := Dashboard{Run: latest, Runs: len(history), Since: history[0].Time}
+
+	// runs recorded before the distribution was kept have it worked out from their items
+	for _, entry := range history {
+		stats := entry.Stats
+		if stats == nil {
+			scores := make([]float64, 0, len(entry.Items))
+			for _, item := range entry.Items {
+				scores = append(scores, item.Upvotes)
+			}
+			stats = NewScoreStats(scores)
+		}
+		if stats != nil {
+			d.Distribution = append(d.Distribution, DashboardRun{RunID: entry.RunID, Time: entry.Time, ScoreStats: *stats})
+		}
+	}
+
+	for id, item := range latest.Items {
+		di := DashboardItem{ID: id, URL: item.URL, Upvotes: item.Upvotes}
+
+		var first *float64
+		for _, entry := range history {
+			v, ok := entry.Items[id]
+			if !ok {
+				di.Trend = append(di.Trend, math.NaN())
+				continue
+			}
+			if first == nil {
+				first = &v.Upvotes
+			}
+			di.Trend = append(di.Trend, v.Upvotes)
+		}
+		di.Change = item.Upvotes - *first
+
+		d.Items = append(d.Items, di)
+	}
+
+	sort.Slice(d.Items, func(i, j int) bool {
+		if d.Items[i].Upvotes != d.Items[j].Upvotes {
+			return d.Items[i].Upvotes > d.Items[j].Upvotes
+		}
+		return d.Items[i].ID < d.Items[j].ID
+	})
+
+	for _, item := range d.Items {
+		if item.Change != 0 {
+			d.Movers = append(d.Movers, item)
+		}
+	}
+	sort.SliceStable(d.Movers, func(i, j int) bool {
+		return math.Abs(d.Movers[i].Change) > math.Abs(d.Movers[j].Change)
+	})
+	if movers >= 0 && len(d.Movers) > movers {
+		d.Movers = d.Movers[:movers]
+	}
+
+	return d, nil
+}
+
+// WriteMarkdown writes the top movers, the distribution of the upvotes in each run, and every item as
+// Markdown tables
+func (d Dashboard) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### Upvotes report\n\nRun `%s` at %s, with trends over %d runs since %s.\n",
+		d.Run.RunID, d.Run.Time.Format(time.RFC3339), d.Runs, d.Since.Format(time.RFC3339))
+
+	if len(d.Movers) > 0 {
+		b.WriteString("\n#### Top movers\n\n| Item | Upvotes | Change |\n| --- | ---: | ---: |\n")
+		for _, item := range d.Movers {
+			fmt.Fprintf(&b, "| %s | %v | %+g |\n", item.link(), item.Upvotes, item.Change)
+		}
+	}
+
+	if len(d.Distribution) > 0 {
+		b.WriteString("\n#### Score distribution\n\n| Run | Items | Median | P90 | Max | Total |\n| --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, run := range d.Distribution {
+			fmt.Fprintf(&b, "| %s | %d | %v | %v | %v | %v |\n", run.Time.Format(time.RFC3339), run.Items, run.Median, run.P90, run.Max, run.Total)
+		}
+	}
+
+	b.WriteString("\n#### Items\n\n| Item | Upvotes | Change |\n| --- | ---: | ---: |\n")
+	for _, item := range d.Items {
+		fmt.Fprintf(&b, "| %s | %v | %+g |\n", item.link(), item.Upvotes, item.Change)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// link returns the item as a Markdown link to its Issue or Pull Request, if its URL is known
+func (d DashboardItem) link() string {
+	if d.URL == "" {
+		return d.ID
+	}
+	return fmt.Sprintf("[%s](%s)", strings.TrimPrefix(d.URL, "https://github.com/"), d.URL)
+}
+
+// WriteHTML writes the dashboard as a self-contained HTML page
+func (d Dashboard) WriteHTML(w io.Writer) error {
+	return dashboardTemplate.Execute(w, d)
+}
+
+// dashboardTemplate is the self-contained HTML page written by WriteHTML. It has no external assets,
+// so it can be published as is, for example to GitHub Pages.
+var dashboardTemplate = htmltemplate.Must(htmltemplate.New("dashboard").Funcs(map[string]any{
+	"signed": func(v float64) string { return fmt.Sprintf("%+g", v) },
+	"trim":   func(url string) string { return strings.TrimPrefix(url, "https://github.com/") },
+	"time":   func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Upvotes report</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2328; }
+table { border-collapse: collapse; margin-bottom: 2rem; }
+th, td { padding: 0.35rem 0.75rem; border-bottom: 1px solid #d0d7de; text-align: left; }
+th { cursor: pointer; user-select: none; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.up { color: #1a7f37; }
+.down { color: #cf222e; }
+polyline { fill: none; stroke: #0969da; stroke-width: 1.5; }
+</style>
+</head>
+<body>
+<h1>Upvotes report</h1>
+<p>Run <code>{{.Run.RunID}}</code> at {{time .Run.Time}}, with trends over {{.Runs}} runs since {{time .Since}}.</p>
+{{with .Movers}}
+<h2>Top movers</h2>
+<table>
+<thead><tr><th>Item</th><th>Upvotes</th><th>Change</th><th>Trend</th></tr></thead>
+<tbody>
+{{range .}}{{template "row" .}}{{end}}
+</tbody>
+</table>
+{{end}}
+{{with .Distribution}}
+<h2>Score distribution</h2>
+<table>
+<thead><tr><th>Run</th><th>Items</th><th>Median</th><th>P90</th><th>Max</th><th>Total</th></tr></thead>
+<tbody>
+{{range .}}<tr><td>{{time .Time}}</td><td class="num">{{.Items}}</td><td class="num">{{.Median}}</td><td class="num">{{.P90}}</td><td class="num">{{.Max}}</td><td class="num">{{.Total}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+<h2>Items</h2>
+<table class="sortable">
+<thead><tr><th>Item</th><th>Upvotes</th><th>Change</th><th>Trend</th></tr></thead>
+<tbody>
+{{range .Items}}{{template "row" .}}{{end}}
+</tbody>
+</table>
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th, column) {
+  var ascending = false;
+  th.addEventListener("click", function () {
+    var tbody = th.closest("table").tBodies[0];
+    var rows = Array.from(tbody.rows);
+    ascending = !ascending;
+    rows.sort(function (a, b) {
+      var x = a.cells[column].dataset.value, y = b.cells[column].dataset.value;
+      var order = isNaN(x) || isNaN(y) ? x.localeCompare(y) : x - y;
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+{{define "row"}}<tr>
+<td data-value="{{.ID}}">{{if .URL}}<a href="{{.URL}}">{{trim .URL}}</a>{{else}}{{.ID}}{{end}}</td>
+<td class="num" data-value="{{.Upvotes}}">{{.Upvotes}}</td>
+<td class="num {{if gt .Change 0.0}}up{{else if lt .Change 0.0}}down{{end}}" data-value="{{.Change}}">{{signed .Change}}</td>
+<td data-value="{{.Change}}"><svg width="100" height="20" viewBox="-1 -1 102 22"><polyline points="{{.Sparkline 100 20}}"/></svg></td>
+</tr>
+{{end}}`))
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// notificationsFile is the name of the notification state file within the state directory
+const notificationsFile = "notifications.json"
+
+// NotificationState tracks which rules have already fired for which items, so that a rule's actions
+// run once when its condition becomes true rather than on every run. Once the condition is no longer
+// met the entry is removed, and the rule can fire again.
+type NotificationState struct {
+	// Fired maps rule names to the items the rule has fired for, and when
+	Fired map[string]map[string]time.Time `json:"fired"`
+}
+
+// NewNotificationState returns an empty NotificationState
+func NewNotificationState() *NotificationState {
+	return &NotificationState{Fired: make(map[string]map[string]time.Time)}
+}
+
+// HasFired returns true if the rule has fired for the item and its condition has not since reset
+func (n *NotificationState) HasFired(rule string, itemId interface{}) bool {
+	_, ok := n.Fired[rule][fmt.Sprint(itemId)]
+	return ok
+}
+
+// MarkFired records that the rule fired for the item
+func (n *NotificationState) MarkFired(rule string, itemId interface{}) {
+	if n.Fired[rule] == nil {
+		n.Fired[rule] = make(map[string]time.Time)
+	}
+	n.Fired[rule][fmt.Sprint(itemId)] = time.Now().UTC()
+}
+
+// Reset records that the rule's condition is no longer met for the item
+func (n *NotificationState) Reset(rule string, itemId interface{}) {
+	delete(n.Fired[rule], fmt.Sprint(itemId))
+	if len(n.Fired[rule]) == 0 {
+		delete(n.Fired, rule)
+	}
+}
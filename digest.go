@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Digest accumulates notification messages so that they can be sent as a single message, rather
+// than one message per item
+type Digest struct {
+	mu        sync.Mutex
+	messages  []string
+	notifiers Notifiers
+	locale    *Locale
+}
+
+// NewDigest returns an empty Digest that is sent to the given notifiers, in the language of the locale
+func NewDigest(notifiers Notifiers, l *Locale) *Digest {
+	return &Digest{notifiers: notifiers, locale: l}
+}
+
+// Add queues a message for the next digest
+func (d *Digest) Add(message string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.messages = append(d.messages, message)
+}
+
+// Flush sends every queued message as a single notification. Nothing is sent if no messages are queued.
+func (d *Digest) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	messages := d.messages
+	d.messages = nil
+	d.mu.Unlock()
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", d.locale.T("digest.header", len(messages)))
+	for _, message := range messages {
+		fmt.Fprintf(&b, "- %s\n", message)
+	}
+
+	return d.notifiers.Notify(ctx, b.String())
+}
+
+// FlushEvery flushes the digest each interval until the context is cancelled
+func (d *Digest) FlushEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Flush(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to send notification digest", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// channelNotifier sends the messages it is sent on its channel
+type channelNotifier chan string
+
+// Notify sends the message on the channel
+func (n channelNotifier) Notify(ctx context.Context, message string) error {
+	n <- message
+	return nil
+}
+
+// TestDigest queues messages in a digest and checks that they are sent as a single message under a
+// header in the digest's language, once, and that nothing is sent when no messages are queued
+func TestDigest(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		locale   string
+		messages []string
+		want     string
+	}{
+		{locale: "en"},
+		{locale: "en", messages: []string{"octo/api#1 gained +10 upvotes"}, want: "1 project items matched notification rules:\n- octo/api#1 gained +10 upvotes\n"},
+		{locale: "en", messages: []string{"a", "b"}, want: "2 project items matched notification rules:\n- a\n- b\n"},
+		{locale: "de", messages: []string{"a", "b"}, want: "2 Projekteinträge haben Benachrichtigungsregeln erfüllt:\n- a\n- b\n"},
+	}
+	for _, test := range tests {
+		locale, err := loadLocale(test.locale, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		notifier := &recordingNotifier{}
+		digest := NewDigest(Notifiers{notifier}, locale)
+		for _, message := range test.messages {
+			digest.Add(message)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := digest.Flush(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		switch {
+		case test.want == "" && len(notifier.messages) != 0:
+			t.Errorf("%s %v: expected nothing to be sent, got %q", test.locale, test.messages, notifier.messages)
+		case test.want != "" && (len(notifier.messages) != 1 || notifier.messages[0] != test.want):
+			t.Errorf("%s %v: expected %q to be sent once, got %q", test.locale, test.messages, test.want, notifier.messages)
+		}
+	}
+}
+
+// TestDigestFlushEvery checks that the messages queued in a digest are sent at its interval
+func TestDigestFlushEvery(t *testing.T) {
+	locale, err := loadLocale("en", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := make(channelNotifier, 1)
+	digest := NewDigest(Notifiers{notifier}, locale)
+	digest.Add("a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		digest.FlushEvery(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case message := <-notifier:
+		if want := "1 project items matched notification rules:\n- a\n"; message != want {
+			t.Errorf("expected %q, got %q", want, message)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the digest to be sent within a second")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// upvotesFieldName is the name every query reads the upvotes field by
+const upvotesFieldName = "Upvotes"
+
+// Outcomes of a doctor check
+const (
+	DoctorOK      = "ok"
+	DoctorWarn    = "warn"
+	DoctorFail    = "fail"
+	DoctorSkipped = "skipped"
+)
+
+// DoctorCheck is the outcome of a single doctor check
+type DoctorCheck struct {
+	Name   string
+	Result string
+	Detail string
+}
+
+// DoctorReport is the outcome of every doctor check, with the environment the tool runs in
+type DoctorReport struct {
+	Version string
+	Go      string
+	OS      string
+	Checks  []DoctorCheck
+}
+
+// Failed returns the number of checks that failed
+func (r DoctorReport) Failed() int {
+	var failed int
+	for _, c := range r.Checks {
+		if c.Result == DoctorFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// add records the outcome of a check
+func (r *DoctorReport) add(name, result, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, DoctorCheck{Name: name, Result: result, Detail: fmt.Sprintf(format, args...)})
+}
+
+// WriteMarkdown writes the report as Markdown that can be pasted into a support request. Secrets are
+// redacted.
+func (r DoctorReport) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## github-upvotes doctor\n\n")
+	fmt.Fprintf(&b, "- Version: %s\n- Go: %s\n- OS: %s\n\n", r.Version, r.Go, r.OS)
+	fmt.Fprintf(&b, "| Check | Result | Detail |\n| --- | --- | --- |\n")
+	for _, c := range r.Checks {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", c.Name, c.Result, strings.ReplaceAll(c.Detail, "|", `\|`))
+	}
+
+	_, err := io.WriteString(w, redact(b.String()))
+	return err
+}
+
+// Doctor checks the configuration, the token, the permissions of a fine-grained token, the project and
+// its upvotes field, the rate limit, GitHub's GraphQL schema, and the state directory. Checks that
+// depend on one that failed are skipped.
+func Doctor(ctx context.Context, cfg Config) DoctorReport {
+	report := DoctorReport{
+		Version: toolVersion(),
+		Go:      runtime.Version(),
+		OS:      runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	report.checkStateDir(cfg)
+
+	var missing []string
+	for _, setting := range []struct{ name, value string }{
+		{"GITHUB_TOKEN", cfg.Token},
+		{"GITHUB_PROJECT_ID", fmt.Sprint(cfg.ProjectID)},
+		{"GITHUB_FIELD_ID", fmt.Sprint(cfg.FieldID)},
+	} {
+		if setting.value == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+	if len(missing) > 0 {
+		report.add("configuration", DoctorFail, "missing %s", strings.Join(missing, ", "))
+		report.skip("connectivity", "token", "project", "field", "rate limit", "schema")
+		return report
+	}
+	report.add("configuration", DoctorOK, "token, project, and field are set")
+
+	client, err := newGitHubClient(ctx, cfg)
+	if err != nil {
+		report.add("connectivity", DoctorFail, "%v", err)
+		report.skip("token", "project", "field", "rate limit", "schema")
+		return report
+	}
+
+	login, scopes, latency, err := probeToken(ctx, client, cfg.apiURL())
+	var status *httpStatusError
+	switch {
+	case errors.As(err, &status):
+		report.add("connectivity", DoctorOK, "reached %s in %v", cfg.apiURL(), latency.Round(time.Millisecond))
+		report.add("token", DoctorFail, "%v", err)
+		report.skip("project", "field", "rate limit", "schema")
+		return report
+	case err != nil:
+		report.add("connectivity", DoctorFail, "%v", err)
+		report.skip("token", "project", "field", "rate limit", "schema")
+		return report
+	}
+	report.add("connectivity", DoctorOK, "reached %s in %v", cfg.apiURL(), latency.Round(time.Millisecond))
+	kind := tokenKind(cfg.Token)
+	report.checkScopes(login, scopes, kind)
+
+	gh := newGraphQLClient(cfg, client)
+	if kind == TokenFineGrained {
+		report.checkPermissions(ctx, gh, cfg.ProjectID)
+	}
+	if report.checkProject(ctx, gh, cfg.ProjectID) {
+		report.checkField(ctx, gh, cfg.ProjectID, cfg.FieldID)
+	} else {
+		report.skip("field")
+	}
+	report.checkRateLimit(ctx, gh, cfg.ReservePoints)
+	report.checkSchema(ctx, client, cfg.apiURL())
+
+	return report
+}
+
+// skip records the named checks as skipped because a check they depend on failed
+func (r *DoctorReport) skip(names ...string) {
+	for _, name := range names {
+		r.add(name, DoctorSkipped, "depends on a check that failed")
+	}
+}
+
+// httpStatusError is an HTTP response with an unexpected status
+type httpStatusError struct {
+	Status string
+	Body   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GitHub responded %s: %s", e.Status, e.Body)
+}
+
+// probeToken queries the login of the token's user, returning it with the OAuth scopes GitHub reports
+// for the token and the time the request took. Fine-grained tokens and GitHub App tokens report no scopes.
+func probeToken(ctx context.Context, client *http.Client, url string) (string, []string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(`{"query":"query { viewer { login } }"}`))
+	if err != nil {
+		return "", nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return "", nil, latency, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, latency, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, latency, &httpStatusError{Status: resp.Status, Body: string(bytes.TrimSpace(body))}
+	}
+
+	var scopes []string
+	for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	var out struct {
+		Data struct {
+			Viewer struct {
+				Login string
+			}
+		}
+		Errors []struct {
+			Message string
+		}
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", scopes, latency, err
+	}
+	if len(out.Errors) > 0 {
+		return "", scopes, latency, errors.New(out.Errors[0].Message)
+	}
+
+	return out.Data.Viewer.Login, scopes, latency, nil
+}
+
+// checkScopes records the user the token belongs to, and whether its scopes allow it to read and write
+// projects. Fine-grained and GitHub App tokens have permissions rather than scopes.
+func (r *DoctorReport) checkScopes(login string, scopes []string, kind string) {
+	switch {
+	case kind == TokenFineGrained:
+		r.add("token", DoctorOK, "authenticated as %s with a fine-grained token; see permissions", login)
+	case len(scopes) == 0:
+		r.add("token", DoctorOK, "authenticated as %s; no OAuth scopes reported (GitHub App token)", login)
+	case contains(scopes, "project"):
+		r.add("token", DoctorOK, "authenticated as %s with scopes %s", login, strings.Join(scopes, ", "))
+	case contains(scopes, "read:project"):
+		r.add("token", DoctorWarn, "authenticated as %s with scopes %s; the project scope is needed to write upvotes", login, strings.Join(scopes, ", "))
+	default:
+		r.add("token", DoctorFail, "authenticated as %s with scopes %s; the project or read:project scope is needed", login, strings.Join(scopes, ", "))
+	}
+}
+
+// checkProject records whether the project exists and the token can update it, returning true if
+// the project was found
+func (r *DoctorReport) checkProject(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID) bool {
+	var query struct {
+		Node *struct {
+			Typename  string `graphql:"__typename"`
+			ProjectV2 struct {
+				Title           string
+				Public          bool
+				ViewerCanUpdate bool
+			} `graphql:"...on ProjectV2"`
+		} `graphql:"node(id: $nodeId)"`
+	}
+
+	if err := runQuery(ctx, gh, &query, Variables().ID("nodeId", projectId)); err != nil {
+		r.add("project", DoctorFail, "%v", err)
+		return false
+	}
+	if query.Node == nil || query.Node.Typename != "ProjectV2" {
+		r.add("project", DoctorFail, "%v is not a project the token can read", projectId)
+		return false
+	}
+
+	project := query.Node.ProjectV2
+	visibility := "private"
+	if project.Public {
+		visibility = "public"
+	}
+	if !project.ViewerCanUpdate {
+		r.add("project", DoctorWarn, "%q (%s) is read-only to the token; runs will write a plan instead of updating it", project.Title, visibility)
+		return true
+	}
+
+	r.add("project", DoctorOK, "%q (%s) can be updated", project.Title, visibility)
+	return true
+}
+
+// checkField records whether the field is a number field of the project, named so that the tool
+// reads it
+func (r *DoctorReport) checkField(ctx context.Context, gh *githubv4.Client, projectId, fieldId githubv4.ID) {
+	var query struct {
+		Node *struct {
+			Typename       string `graphql:"__typename"`
+			ProjectV2Field struct {
+				Name     string
+				DataType string
+				Project  struct {
+					Id githubv4.ID
+				}
+			} `graphql:"...on ProjectV2Field"`
+		} `graphql:"node(id: $nodeId)"`
+	}
+
+	if err := runQuery(ctx, gh, &query, Variables().ID("nodeId", fieldId)); err != nil {
+		r.add("field", DoctorFail, "%v", err)
+		return
+	}
+
+	field := query.Node
+	switch {
+	case field == nil || field.Typename == "":
+		r.add("field", DoctorFail, "%v is not a field the token can read", fieldId)
+	case field.Typename != "ProjectV2Field" || field.ProjectV2Field.DataType != "NUMBER":
+		r.add("field", DoctorFail, "%v is not a number field", fieldId)
+	case fmt.Sprint(field.ProjectV2Field.Project.Id) != fmt.Sprint(projectId):
+		r.add("field", DoctorFail, "%q belongs to another project", field.ProjectV2Field.Name)
+	case field.ProjectV2Field.Name != upvotesFieldName:
+		r.add("field", DoctorFail, "%q must be named %q, the name current values are read by", field.ProjectV2Field.Name, upvotesFieldName)
+	default:
+		r.add("field", DoctorOK, "%q is a number field of the project", field.ProjectV2Field.Name)
+	}
+}
+
+// checkRateLimit records the remaining rate limit, warning if it is within the reserve
+func (r *DoctorReport) checkRateLimit(ctx context.Context, gh *githubv4.Client, reserve int) {
+	observed, err := observeRateLimit(ctx, gh)
+	if err != nil {
+		r.add("rate limit", DoctorFail, "%v", err)
+		return
+	}
+
+	result := DoctorOK
+	if observed.Remaining <= reserve {
+		result = DoctorWarn
+	}
+	r.add("rate limit", result, "%d of %d points remaining, %d reserved, resets at %s", observed.Remaining, observed.Limit, reserve, observed.ResetAt.UTC().Format(time.RFC3339))
+}
+
+// checkSchema records the types and fields the tool relies on that are missing from, or deprecated
+// in, GitHub's GraphQL schema
+func (r *DoctorReport) checkSchema(ctx context.Context, client *http.Client, url string) {
+	problems, err := CheckSchema(ctx, client, url)
+	if err != nil {
+		r.add("schema", DoctorFail, "%v", err)
+		return
+	}
+
+	result := DoctorOK
+	var details []string
+	for _, problem := range problems {
+		if problem.Missing {
+			result = DoctorFail
+		} else if result == DoctorOK {
+			result = DoctorWarn
+		}
+		details = append(details, problem.String())
+	}
+	if len(details) == 0 {
+		details = append(details, "every type and field the tool relies on is present")
+	}
+
+	r.add("schema", result, "%s", strings.Join(details, "; "))
+}
+
+// checkStateDir records whether state can be written to the state directory
+func (r *DoctorReport) checkStateDir(cfg Config) {
+	if cfg.StateDir == "" {
+		r.add("state directory", DoctorSkipped, "no --state-dir is set")
+		return
+	}
+
+	if err := os.MkdirAll(cfg.StateDir, 0o755); err != nil {
+		r.add("state directory", DoctorFail, "%v", err)
+		return
+	}
+
+	f, err := os.CreateTemp(cfg.StateDir, ".doctor-")
+	if err != nil {
+		r.add("state directory", DoctorFail, "%v", err)
+		return
+	}
+	err = errors.Join(f.Close(), os.Remove(f.Name()))
+	if err != nil {
+		r.add("state directory", DoctorFail, "%v", err)
+		return
+	}
+
+	entries, err := os.ReadDir(cfg.StateDir)
+	if err != nil {
+		r.add("state directory", DoctorFail, "%v", err)
+		return
+	}
+	r.add("state directory", DoctorOK, "%s is writable, holding %d files", filepath.Clean(cfg.StateDir), len(entries))
+}
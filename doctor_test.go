@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// doctorGitHub extends fakeGitHub with the queries of the doctor command, answering them as configured
+type doctorGitHub struct {
+	*fakeGitHub
+
+	// status is the status of every response, if not 200, and scopes the OAuth scopes reported
+	status int
+	scopes string
+
+	// readOnly is true if the token cannot update the project
+	readOnly bool
+
+	// fieldName, fieldType, and fieldProject describe the upvotes field
+	fieldName, fieldType, fieldProject string
+
+	// missing and deprecated are the Type.field names left out of the schema or deprecated in it
+	missing, deprecated string
+}
+
+// newDoctorGitHub returns a doctorGitHub whose project, field, and schema pass every check
+func newDoctorGitHub() *doctorGitHub {
+	return &doctorGitHub{fakeGitHub: newFakeGitHub(), scopes: "project, repo", fieldName: upvotesFieldName, fieldType: "NUMBER", fieldProject: "PVT_selftest"}
+}
+
+// ServeHTTP answers a GraphQL request
+func (d *doctorGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if d.status != 0 {
+		http.Error(w, `{"message":"Bad credentials"}`, d.status)
+		return
+	}
+
+	resp, err := d.serve(r, d.answer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-OAuth-Scopes", d.scopes)
+	io.Copy(w, resp.Body)
+}
+
+// answer answers the queries of the doctor command, and passes the rest to fakeGitHub
+func (d *doctorGitHub) answer(r selftestRequest) (interface{}, error) {
+	switch q := r.Query; {
+	case strings.Contains(q, "viewer { login }"):
+		return map[string]interface{}{"viewer": map[string]string{"login": selftestAuthor}}, nil
+	case strings.Contains(q, "...on ProjectV2{title"):
+		return map[string]interface{}{"node": map[string]interface{}{"__typename": "ProjectV2", "title": "selftest", "public": false, "viewerCanUpdate": !d.readOnly}}, nil
+	case strings.Contains(q, "...on ProjectV2Field{"):
+		return map[string]interface{}{"node": map[string]interface{}{
+			"__typename": "ProjectV2Field",
+			"name":       d.fieldName,
+			"dataType":   d.fieldType,
+			"project":    map[string]string{"id": d.fieldProject},
+		}}, nil
+	case strings.HasPrefix(q, "{rateLimit"):
+		return map[string]interface{}{"rateLimit": d.rateLimit()}, nil
+	case strings.Contains(q, "__type(name:"):
+		return d.schema(), nil
+	}
+	return d.fakeGitHub.answer(r)
+}
+
+// schema answers the introspection of the types and fields the tool relies on
+func (d *doctorGitHub) schema() interface{} {
+	types := make([]string, 0, len(requiredSchema))
+	for name := range requiredSchema {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	data := make(map[string]interface{})
+	for i, name := range types {
+		var fields []map[string]interface{}
+		for _, field := range requiredSchema[name] {
+			switch name + "." + field {
+			case d.missing:
+			case d.deprecated:
+				fields = append(fields, map[string]interface{}{"name": field, "isDeprecated": true, "deprecationReason": "Use something else."})
+			default:
+				fields = append(fields, map[string]interface{}{"name": field, "isDeprecated": false})
+			}
+		}
+		data[fmt.Sprintf("t%d", i)] = map[string]interface{}{"name": name, "fields": fields}
+	}
+	return data
+}
+
+// TestDoctor runs the doctor against a synthetic GitHub configured to fail each check in turn, and checks
+// the outcome of every check
+func TestDoctor(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		setup func(d *doctorGitHub, cfg *Config)
+		want  map[string]string
+	}{
+		{
+			name: "healthy",
+			want: map[string]string{"configuration": DoctorOK, "connectivity": DoctorOK, "token": DoctorOK, "project": DoctorOK, "field": DoctorOK, "rate limit": DoctorOK, "schema": DoctorOK},
+		},
+		{
+			name:  "missing configuration",
+			setup: func(d *doctorGitHub, cfg *Config) { cfg.FieldID = "" },
+			want:  map[string]string{"configuration": DoctorFail, "connectivity": DoctorSkipped, "field": DoctorSkipped, "schema": DoctorSkipped},
+		},
+		{
+			name:  "bad credentials",
+			setup: func(d *doctorGitHub, cfg *Config) { d.status = http.StatusUnauthorized },
+			want:  map[string]string{"connectivity": DoctorOK, "token": DoctorFail, "project": DoctorSkipped, "schema": DoctorSkipped},
+		},
+		{
+			name:  "read-only",
+			setup: func(d *doctorGitHub, cfg *Config) { d.scopes, d.readOnly = "read:project", true },
+			want:  map[string]string{"token": DoctorWarn, "project": DoctorWarn, "field": DoctorOK},
+		},
+		{
+			name:  "no project scope",
+			setup: func(d *doctorGitHub, cfg *Config) { d.scopes = "repo" },
+			want:  map[string]string{"token": DoctorFail},
+		},
+		{
+			name:  "misnamed field",
+			setup: func(d *doctorGitHub, cfg *Config) { d.fieldName = "Votes" },
+			want:  map[string]string{"field": DoctorFail},
+		},
+		{
+			name:  "text field",
+			setup: func(d *doctorGitHub, cfg *Config) { d.fieldType = "TEXT" },
+			want:  map[string]string{"field": DoctorFail},
+		},
+		{
+			name:  "field of another project",
+			setup: func(d *doctorGitHub, cfg *Config) { d.fieldProject = "PVT_other" },
+			want:  map[string]string{"field": DoctorFail},
+		},
+		{
+			name:  "rate limit within the reserve",
+			setup: func(d *doctorGitHub, cfg *Config) { cfg.ReservePoints = 4000 },
+			want:  map[string]string{"rate limit": DoctorWarn},
+		},
+		{
+			name:  "deprecated field",
+			setup: func(d *doctorGitHub, cfg *Config) { d.deprecated = "ProjectV2Item.fieldValueByName" },
+			want:  map[string]string{"schema": DoctorWarn},
+		},
+		{
+			name:  "missing field",
+			setup: func(d *doctorGitHub, cfg *Config) { d.missing = "Issue.trackedIssues" },
+			want:  map[string]string{"schema": DoctorFail},
+		},
+		{
+			name:  "fine-grained token",
+			token: "github_pat_doctor",
+			want:  map[string]string{"token": DoctorOK, "permissions": DoctorOK},
+		},
+		{
+			name:  "fine-grained token without access to Issues",
+			token: "github_pat_doctor",
+			setup: func(d *doctorGitHub, cfg *Config) { d.items[2].hidden = true },
+			want:  map[string]string{"permissions": DoctorFail},
+		},
+	}
+	for _, test := range tests {
+		d := newDoctorGitHub()
+		server := httptest.NewServer(d)
+
+		cfg := testConfig(t)
+		cfg.APIURL = server.URL
+		cfg.Token = "ghp_doctor"
+		if test.token != "" {
+			cfg.Token = test.token
+		}
+		if test.setup != nil {
+			test.setup(d, &cfg)
+		}
+
+		report := Doctor(context.Background(), cfg)
+		server.Close()
+
+		got := make(map[string]string)
+		for _, check := range report.Checks {
+			got[check.Name] = check.Result
+		}
+		if got["state directory"] != DoctorOK {
+			t.Errorf("%s: expected the state directory to be writable, got %s", test.name, got["state directory"])
+		}
+		for name, want := range test.want {
+			if got[name] != want {
+				t.Errorf("%s: expected the %s check to be %s, got %s: %+v", test.name, name, want, got[name], report.Checks)
+			}
+		}
+	}
+}
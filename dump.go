@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Names of the files a dump is written to
+const (
+	dumpRunFile   = "run.json"
+	dumpItemsFile = "items.jsonl"
+)
+
+// DumpRecord is the data an item was scored from: its Issue or Pull Request, the additional fields
+// fetched for it, and the unweighted components counted from the activity on it. Together they are
+// enough to score the item again under another scoring profile, without the API.
+type DumpRecord struct {
+	ItemID     githubv4.ID                `json:"item_id"`
+	Type       string                     `json:"type"`
+	Content    ContentInfo                `json:"content"`
+	Extra      map[string]json.RawMessage `json:"extra,omitempty"`
+	Components []ScoreComponent           `json:"components"`
+
+	// Upvotes is the score the item was given under the dump's scoring profile
+	Upvotes float64 `json:"upvotes"`
+}
+
+// DumpRun describes the run a dump was recorded by
+type DumpRun struct {
+	Run     RunInfo        `json:"run"`
+	Profile ScoringProfile `json:"profile"`
+	Items   int            `json:"items"`
+}
+
+// Dump records the data every item of a run is scored from. A nil Dump records nothing.
+type Dump struct {
+	mu      sync.Mutex
+	records []DumpRecord
+}
+
+// Record adds the item, the unweighted components it was scored from, and its score to the dump
+func (d *Dump) Record(item Item, unweighted, scored []ScoreComponent) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.records = append(d.records, DumpRecord{
+		ItemID:     item.Id,
+		Type:       item.Content.Type,
+		Content:    item.GetContent().Info(),
+		Extra:      item.Extra,
+		Components: unweighted,
+		Upvotes:    Total(scored),
+	})
+}
+
+// item returns the project item and content the record was scored from, as far as scoring needs them
+func (r DumpRecord) item() (Item, ContentFragment, error) {
+	var content ContentFragment
+	if r.Content.URL != "" {
+		u, err := url.Parse(r.Content.URL)
+		if err != nil {
+			return Item{}, content, fmt.Errorf("item %v: %w", r.ItemID, err)
+		}
+		content.Url = githubv4.URI{URL: u}
+	}
+
+	var item Item
+	item.Id = r.ItemID
+	item.Content.Type = r.Type
+	item.Extra = r.Extra
+	return item, content, nil
+}
+
+// Records returns the recorded items, ordered by item ID
+func (d *Dump) Records() []DumpRecord {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	records := append([]DumpRecord(nil), d.records...)
+	sort.Slice(records, func(i, j int) bool {
+		return fmt.Sprint(records[i].ItemID) < fmt.Sprint(records[j].ItemID)
+	})
+	return records
+}
+
+// score scores the record again with the scorer
+func (r DumpRecord) score(scorer *Scorer) ([]ScoreComponent, error) {
+	item, content, err := r.item()
+	if err != nil {
+		return nil, err
+	}
+	return scorer.ScoreComponents(item, content, append([]ScoreComponent(nil), r.Components...)), nil
+}
+
+// Write writes the dump to dir: the run and its scoring profile to run.json, and one record per line,
+// ordered by item ID, to items.jsonl
+func (d *Dump) Write(dir string, run RunInfo, profile ScoringProfile) error {
+	if d == nil {
+		return nil
+	}
+
+	records := d.Records()
+	if err := saveState(dir, dumpRunFile, DumpRun{Run: run, Profile: profile, Items: len(records)}); err != nil {
+		return fmt.Errorf("writing dump: %w", err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, dumpItemsFile))
+	if err != nil {
+		return fmt.Errorf("writing dump: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("writing dump: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing dump: %w", err)
+	}
+
+	return f.Close()
+}
+
+// LoadDump reads the run and records of the dump in dir
+func LoadDump(dir string) (DumpRun, []DumpRecord, error) {
+	var run DumpRun
+	if _, err := os.Stat(filepath.Join(dir, dumpRunFile)); err != nil {
+		return run, nil, fmt.Errorf("reading dump: %w", err)
+	}
+	if err := loadState(dir, dumpRunFile, &run); err != nil {
+		return run, nil, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, dumpItemsFile))
+	if err != nil {
+		return run, nil, fmt.Errorf("reading dump: %w", err)
+	}
+	defer f.Close()
+
+	var records []DumpRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var record DumpRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return run, nil, fmt.Errorf("reading dump: %s line %d: %w", dumpItemsFile, line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return run, nil, fmt.Errorf("reading dump: %w", err)
+	}
+
+	return run, records, nil
+}
+
+// Rescore scores the recorded items again with the scorer, comparing each with the score it was given
+// when recorded, and sends the results to the reporters. If gh is not nil, each item is instead compared
+// with its value on the board, which is read in batches without fetching the items again; items no
+// longer on the board are compared with their recorded score. Nothing is written to GitHub.
+func Rescore(ctx context.Context, dir string, scorer *Scorer, reporters Reporters, gh *githubv4.Client) (Summary, error) {
+	recorded, records, err := LoadDump(dir)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	slog.InfoContext(ctx, "rescoring recorded items", "items", len(records), "run", recorded.Run.ID, "recorded_profile", recorded.Profile.ID(), "profile", scorer.Profile().ID())
+
+	var current map[string]float64
+	if gh != nil {
+		ids := make([]githubv4.ID, 0, len(records))
+		for _, record := range records {
+			ids = append(ids, record.ItemID)
+		}
+		if current, err = batchCurrentValues(ctx, gh, nil, ids); err != nil {
+			return Summary{}, fmt.Errorf("reading current values: %w", err)
+		}
+	}
+
+	run := recorded.Run
+	run.ID = "rescore-" + run.ID
+	if err := reporters.Start(run); err != nil {
+		return Summary{}, fmt.Errorf("starting reporters: %w", err)
+	}
+
+	results := make([]Result, 0, len(records))
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return Summary{}, err
+		}
+
+		components, err := record.score(scorer)
+		if err != nil {
+			return Summary{}, err
+		}
+		upvotes := Total(components)
+
+		previous := record.Upvotes
+		if value, ok := current[fmt.Sprint(record.ItemID)]; ok {
+			previous = value
+		}
+
+		result := Result{
+			ItemID:     record.ItemID,
+			Status:     StatusUnchanged,
+			Previous:   previous,
+			Upvotes:    upvotes,
+			Value:      upvotes,
+			Components: components,
+			Content:    record.Content,
+			Extra:      record.Extra,
+		}
+		if upvotes != previous {
+			result.Status = StatusPlanned
+		}
+
+		if err := reporters.ItemResult(result); err != nil {
+			slog.ErrorContext(ctx, "failed to report project item", "item_id", result.ItemID, "error", err)
+		}
+		results = append(results, result)
+	}
+
+	summary := NewSummary(results)
+	if err := reporters.Finish(summary); err != nil {
+		return summary, fmt.Errorf("writing reports: %w", err)
+	}
+
+	return summary, nil
+}
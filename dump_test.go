@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestRescore records a run, and checks that rescoring it with double the weight of comments gives
+// every item's comments twice, and that an item edited on the board since is compared with its new value
+func TestRescore(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	server := scoredFakeGitHub(t, cfg)
+	dir := t.TempDir()
+	cfg.DumpDir = filepath.Join(dir, "dump")
+	run(t, cfg, server)
+
+	cfg.Scoring.Comments = 2
+	scorer, err := NewScorer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := filepath.Join(dir, "rescore.json")
+	if _, err := Rescore(ctx, cfg.DumpDir, scorer, Reporters{&jsonReporter{path: report}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	results, _, err := readReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byId := make(map[string]*selftestItem)
+	for _, item := range server.items {
+		byId[item.id] = item
+	}
+	for _, result := range results {
+		item := byId[fmt.Sprint(result.ItemID)]
+		if result.Previous != item.upvotes() || result.Upvotes != item.upvotes()+float64(item.comments) {
+			t.Errorf("%s: expected %v rescored as %v, got %v as %v", item.id, item.upvotes(), item.upvotes()+float64(item.comments), result.Previous, result.Upvotes)
+		}
+	}
+	expectCount(t, "rescored items", len(results), selftestItems-2)
+
+	// with the current values, an item edited on the board since the run is compared with its new value
+	edited := byId[fmt.Sprint(results[0].ItemID)]
+	value := float64(1000)
+	server.mu.Lock()
+	edited.value = &value
+	server.mu.Unlock()
+
+	if _, err := Rescore(ctx, cfg.DumpDir, scorer, Reporters{&jsonReporter{path: report}}, githubv4.NewClient(&http.Client{Transport: server})); err != nil {
+		t.Fatal(err)
+	}
+	if results, _, err = readReport(report); err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		item := byId[fmt.Sprint(result.ItemID)]
+		want := item.upvotes()
+		if item == edited {
+			want = value
+		}
+		if result.Previous != want {
+			t.Errorf("%s: expected the current value %v as the previous value, got %v", item.id, want, result.Previous)
+		}
+	}
+}
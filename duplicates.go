@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DuplicateCluster is a group of Issues and Pull Requests that probably describe the same demand,
+// along with the proposed canonical item to consolidate them into
+type DuplicateCluster struct {
+	Canonical GraphNode
+	Members   []GraphNode
+	Upvotes   float64
+}
+
+// DuplicateClusters finds the probable duplicate clusters in the graph whose combined upvotes are at
+// least threshold. Items are clustered when one was marked as a duplicate of another, or when two
+// project items cross-reference each other. The proposed canonical item is the one most often marked
+// as the original, then the project item with the most upvotes. Clusters are ordered by combined upvotes.
+func DuplicateClusters(graph Graph, threshold float64) []DuplicateCluster {
+	nodes := make(map[string]GraphNode, len(graph.Nodes))
+	parent := make(map[string]string, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes[node.ID] = node
+		parent[node.ID] = node.ID
+	}
+
+	var find func(id string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+	union := func(a, b string) {
+		parent[find(a)] = find(b)
+	}
+
+	references := make(map[[2]string]bool)
+	originals := make(map[string]int)
+	for _, edge := range graph.Edges {
+		switch edge.Kind {
+		case LinkDuplicate:
+			union(edge.Source, edge.Target)
+			originals[edge.Target]++
+		case LinkCrossReference:
+			references[[2]string{edge.Source, edge.Target}] = true
+		}
+	}
+	for pair := range references {
+		if references[[2]string{pair[1], pair[0]}] && nodes[pair[0]].InProject && nodes[pair[1]].InProject {
+			union(pair[0], pair[1])
+		}
+	}
+
+	members := make(map[string][]GraphNode)
+	for _, node := range graph.Nodes {
+		root := find(node.ID)
+		members[root] = append(members[root], node)
+	}
+
+	var clusters []DuplicateCluster
+	for _, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+
+		cluster := DuplicateCluster{Members: group}
+		for _, node := range group {
+			cluster.Upvotes += node.Upvotes
+		}
+		if cluster.Upvotes < threshold {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			if originals[a.ID] != originals[b.ID] {
+				return originals[a.ID] > originals[b.ID]
+			}
+			if a.InProject != b.InProject {
+				return a.InProject
+			}
+			if a.Upvotes != b.Upvotes {
+				return a.Upvotes > b.Upvotes
+			}
+			return a.ID < b.ID
+		})
+		cluster.Canonical = group[0]
+		cluster.Members = group[1:]
+
+		clusters = append(clusters, cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Upvotes != clusters[j].Upvotes {
+			return clusters[i].Upvotes > clusters[j].Upvotes
+		}
+		return clusters[i].Canonical.ID < clusters[j].Canonical.ID
+	})
+
+	return clusters
+}
+
+// duplicatesReporter writes the probable duplicate clusters as Markdown, suggesting the items to
+// consolidate and the canonical item to consolidate them into
+type duplicatesReporter struct {
+	collector
+	path      string
+	threshold float64
+}
+
+// Finish writes the file
+func (d *duplicatesReporter) Finish(summary Summary) error {
+	clusters := DuplicateClusters(NewGraph(d.results), d.threshold)
+
+	// graphLink returns the node's name, linked to it when the name is not already the URL
+	graphLink := func(node GraphNode) string {
+		if node.Name == node.ID {
+			return node.ID
+		}
+		return fmt.Sprintf("[%s](%s)", node.Name, node.ID)
+	}
+
+	// graphUpvotes describes the node's upvotes
+	graphUpvotes := func(node GraphNode) string {
+		if !node.InProject {
+			return "not in the project"
+		}
+		return fmt.Sprintf("%v upvotes", node.Upvotes)
+	}
+
+	var b strings.Builder
+	b.WriteString("### Probable duplicates\n\n")
+	if len(clusters) == 0 {
+		b.WriteString("No probable duplicates were found.\n")
+	}
+
+	for _, cluster := range clusters {
+		fmt.Fprintf(&b, "#### %s: %v combined upvotes\n\n", cluster.Canonical.Name, cluster.Upvotes)
+		fmt.Fprintf(&b, "Consolidate into %s (%s):\n\n", graphLink(cluster.Canonical), graphUpvotes(cluster.Canonical))
+		for _, member := range cluster.Members {
+			fmt.Fprintf(&b, "- %s (%s)\n", graphLink(member), graphUpvotes(member))
+		}
+		b.WriteString("\n")
+	}
+
+	return os.WriteFile(d.path, []byte(b.String()), 0o644)
+}
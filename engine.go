@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Engine is the single entrypoint for calculating and writing upvotes. It wires together the
+// GetProjectItems, ProcessProjectItems, and UpdateProjectItems stages of the pipeline.
+type Engine struct {
+	cfg       Config
+	client    *http.Client
+	gh        *githubv4.Client
+	fragments *Fragments
+	scorer    *Scorer
+	limiter   *rateLimiter
+	metrics   *ClientMetrics
+	reporters Reporters
+	notifiers Notifiers
+	rules     []Rule
+	audit     *AuditLog
+	store     Store
+	readOnly  bool
+	redact    bool
+	fired     *NotificationState
+	profiles  *ProfileState
+	digest    *Digest
+	baseline  HistoryEntry
+	segments  []Segment
+
+	// responseFields are the project fields that response times are written to
+	responseFields []responseField
+	summary        Summary
+
+	// assignment is the iteration or milestone the top items of each run are assigned to
+	assignment *assignment
+}
+
+// NewEngine returns an Engine with a GitHub client authenticated using the token in the Config. It
+// returns an error if the transport or any of the configured additional fields, reporters, notifiers,
+// or rules are invalid.
+func NewEngine(ctx context.Context, cfg Config) (*Engine, error) {
+	// calls are measured to report the points used by each kind of query, in the daemon's metrics if
+	// it has them
+	metrics := clientMetrics(ctx)
+	if metrics == nil {
+		metrics = NewClientMetrics()
+		ctx = withClientMetrics(ctx, metrics)
+	}
+
+	client, err := newGitHubClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := newEngine(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	engine.metrics = metrics
+	return engine, nil
+}
+
+// newEngine returns an Engine that sends its requests through the given client, and keeps its state in
+// the configured store
+func newEngine(cfg Config, client *http.Client) (*Engine, error) {
+	store, err := openStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEngineWithStore(cfg, client, store)
+}
+
+// newEngineWithStore returns an Engine that sends its requests through the given client, and keeps its
+// state in store. A nil store keeps no state.
+func newEngineWithStore(cfg Config, client *http.Client, store Store) (*Engine, error) {
+	fragments := NewFragments(client, cfg.apiURL())
+	for name, selection := range cfg.ExtraFields {
+		if err := fragments.Register(name, selection); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Export.KeyField != "" {
+		if err := fragments.Register(externalKeyField, externalKeySelection(cfg.Export.KeyField)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := registerPinFields(fragments, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := registerMilestoneFields(fragments, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := registerRollupFields(fragments, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := registerOwnerFields(fragments, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := registerResponseFields(fragments, cfg); err != nil {
+		return nil, err
+	}
+
+	segments, err := compileSegments(cfg.Segments)
+	if err != nil {
+		return nil, err
+	}
+	if err := registerSegmentFields(fragments, segments); err != nil {
+		return nil, err
+	}
+
+	groupBy, err := ParseGroupBy(cfg.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	if err := groupBy.Register(fragments); err != nil {
+		return nil, err
+	}
+
+	scorer, err := NewScorer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// a dry run reads the state, but saves none of it
+	if cfg.DryRun && store != nil {
+		store = dryRunStore{store}
+	}
+
+	reporters, err := newReporters(cfg, client, store)
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers, err := newNotifiers(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := localizeRules(cfg.Rules, cfg.Locale)
+	if cfg.NotifyThreshold > 0 {
+		rule := thresholdRule(cfg.NotifyThreshold, cfg.Locale)
+		if cfg.NotifyTemplate != "" {
+			rule.Actions[0].Message = cfg.NotifyTemplate
+		}
+		rules = append(rules, rule)
+	}
+	if cfg.NotifyDelta > 0 {
+		rule := deltaRule(cfg.NotifyDelta, cfg.Locale)
+		if cfg.NotifyTemplate != "" {
+			rule.Actions[0].Message = cfg.NotifyTemplate
+		}
+		rules = append(rules, rule)
+	}
+	rules = append(rules, teamRules(cfg.Teams, cfg.Locale)...)
+
+	rules, err = compileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var digest *Digest
+	if cfg.Digest {
+		digest = NewDigest(notifiers, cfg.Locale)
+	}
+
+	return &Engine{
+		cfg:       cfg,
+		client:    client,
+		gh:        newGraphQLClient(cfg, client),
+		fragments: fragments,
+		scorer:    scorer,
+		limiter:   newRateLimiter(cfg.ReservePoints),
+		reporters: reporters,
+		notifiers: notifiers,
+		rules:     rules,
+		digest:    digest,
+		segments:  segments,
+		store:     store,
+	}, nil
+}
+
+// Run executes the pipeline. An error listing the project items cancels the remaining work and is
+// returned. Errors for individual items are recorded with a failed status, and cause Run to return
+// an error once every other item has been processed.
+//
+// Cancelling ctx stops the run promptly: requests in flight are aborted, waits on the rate limit and
+// the circuit breaker end, and no mutation is started afterwards. Items that were not written are
+// recorded as truncated, reports and state are still written, and Run returns the context's error once
+// the pipeline's goroutines have stopped.
+func (e *Engine) Run(ctx context.Context) error {
+	run := RunInfo{
+		ID:        runID(e.cfg),
+		ProjectID: e.cfg.ProjectID,
+		FieldID:   e.cfg.FieldID,
+		StartedAt: time.Now(),
+	}
+
+	// every log line for this run includes the run ID
+	ctx = withLogAttrs(ctx, slog.String("run_id", run.ID))
+	slog.InfoContext(ctx, "starting run", "project_id", run.ProjectID)
+
+	// the daemon's metrics span every run, so the run's calls are those made since they were taken
+	calls := e.metrics.Snapshot()
+
+	if e.cfg.CheckSchema {
+		if err := e.checkSchema(ctx); err != nil {
+			return err
+		}
+	}
+
+	// context for early exit
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// channel for capturing errors
+	errChan := make(chan error)
+
+	// a dry run makes no mutations, so it keeps no audit log of them
+	if e.cfg.StateDir != "" && !e.cfg.DryRun {
+		audit, err := OpenAuditLog(e.cfg.StateDir, run.ID)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		e.audit = audit
+	}
+
+	// rules that have already fired are tracked across runs, so that they only fire again once reset
+	e.fired = NewNotificationState()
+	if e.store != nil {
+		if err := e.store.Load(notificationsFile, e.fired); err != nil {
+			return err
+		}
+	}
+
+	if err := e.loadBaseline(); err != nil {
+		return err
+	}
+
+	var cache *ScoreCache
+	if e.cfg.Cache && e.store != nil {
+		c, err := LoadScoreCache(e.store, e.cfg.CacheTTL)
+		if err != nil {
+			return err
+		}
+		// recalculating every item refreshes the whole cache
+		if e.cfg.RecalculateAll {
+			c.Reset()
+		}
+		cache = c
+	}
+
+	// the repositories found by the exclusion and inclusion searches are only used by this run
+	itemsCfg := e.cfg
+	if err := e.resolveExcludedRepos(ctx, &itemsCfg); err != nil {
+		return err
+	}
+	if err := e.resolveIncludedRepos(ctx, &itemsCfg); err != nil {
+		return err
+	}
+
+	// the last engagement with each item is kept to find inactive items
+	e.scorer.activity = nil
+	if e.cfg.Inactivity.Days > 0 && e.store != nil {
+		activity, err := LoadActivityLog(e.store)
+		if err != nil {
+			return err
+		}
+		e.scorer.activity = activity
+	}
+
+	var dump *Dump
+	if e.cfg.DumpDir != "" {
+		dump = &Dump{}
+		e.scorer.dump = dump
+	}
+
+	// values are tagged with the scoring profile they were calculated with
+	profile := e.scorer.Profile().ID()
+	profileFor := e.scorer.profileOf()
+	rollout := e.scorer.rollout.plan()
+	if rollout != nil {
+		slog.InfoContext(ctx, "rolling out scoring profile", "profile", rollout.Profile, "percent", rollout.Percent, "current_profile", profile)
+	}
+	if err := e.loadProfiles(ctx, profile, rollout); err != nil {
+		return err
+	}
+
+	// without write access, queue the field updates in a plan instead of writing them
+	e.readOnly = e.cfg.ReadOnly || e.cfg.DryRun
+	if !e.readOnly {
+		canUpdate, err := viewerCanUpdate(ctx, e.gh, e.cfg.ProjectID)
+		if err != nil {
+			return fmt.Errorf("checking project permissions: %w", err)
+		}
+		if !canUpdate {
+			slog.WarnContext(ctx, "token cannot update the project, writing pending updates to the plan file instead", "path", e.cfg.PlanFile)
+			e.readOnly = true
+		}
+	}
+
+	if err := e.checkRedaction(ctx); err != nil {
+		return err
+	}
+
+	if !e.readOnly {
+		if err := e.resolveSegmentFields(ctx); err != nil {
+			return err
+		}
+		if err := e.resolveResponseFields(ctx); err != nil {
+			return err
+		}
+		if err := e.resolveAssignment(ctx); err != nil {
+			return err
+		}
+	}
+
+	// progress through the project is only kept by runs that write, as a plan is only written at the end
+	var checkpoint *Checkpoint
+	if !e.readOnly {
+		var err error
+		checkpoint, err = LoadCheckpoint(ctx, e.cfg.CheckpointFile, run)
+		if err != nil {
+			return err
+		}
+	}
+
+	partial := e.cfg.Range != nil || checkpoint.Resume() != ""
+
+	var writer FieldWriter = &mutationWriter{gh: e.gh, projectId: e.cfg.ProjectID, fieldId: e.cfg.FieldID, audit: e.audit, policy: e.cfg.ConflictPolicy, limiter: e.limiter}
+	var plan *Plan
+	if e.cfg.DryRun {
+		writer = dryRunWriter{}
+	} else if e.readOnly {
+		plan = NewPlan(run)
+		plan.Profile = profile
+		plan.Rollout = rollout
+		writer = plan
+	} else if e.cfg.MaxUpdates > 0 {
+		plan = NewPlan(run)
+		plan.Profile = profile
+		plan.Rollout = rollout
+		writer = &cappedWriter{FieldWriter: writer, max: e.cfg.MaxUpdates, plan: plan}
+	}
+	if checkpoint != nil {
+		writer = &checkpointWriter{FieldWriter: writer, checkpoint: checkpoint}
+	}
+
+	e.reporters.SetContext(childCtx)
+	if err := e.reporters.Start(run); err != nil {
+		return fmt.Errorf("starting reporters: %w", err)
+	}
+
+	// channel for capturing the status of each item
+	results := make(chan Result)
+	acc := NewAccumulator(summaryMovers)
+	var top, synced []Result
+	collected := make(chan struct{})
+	go func() {
+		for result := range results {
+			if exportable(result) {
+				result.Segments = countSegments(e.segments, result)
+			}
+			logResult(ctx, result, e.cfg.Explain)
+			if err := e.reporters.ItemResult(result); err != nil {
+				slog.ErrorContext(ctx, "failed to report project item", "item_id", result.ItemID, "error", err)
+			}
+			e.evaluateRules(ctx, result)
+			e.checkDrop(ctx, result)
+			e.writeSegments(ctx, result)
+			e.writeResponses(ctx, result)
+			e.profiles.Observe(result, profileFor(result.ItemID))
+			acc.Add(result)
+			top = keepTop(top, result, e.cfg.AssignTop)
+			if e.cfg.SyncProjectID != nil {
+				synced = append(synced, result)
+			}
+		}
+		close(collected)
+	}()
+
+	if e.digest != nil && e.cfg.DigestInterval > 0 {
+		go e.digest.FlushEvery(childCtx, e.cfg.DigestInterval)
+	}
+
+	// start the pipeline
+	itemChan, wg := GetProjectItems(childCtx, e.gh, itemsCfg, e.fragments, e.limiter, checkpoint, results, errChan)
+	updateChan := ProcessProjectItems(childCtx, e.gh, e.scorer, e.limiter, cache, itemChan)
+	// a capped run spends its updates on the largest changes of the whole run, not of each page
+	capped := e.cfg.MaxUpdates > 0 && !e.readOnly
+	policy := WritePolicy{MinDelta: e.cfg.MinDelta, RoundTo: e.cfg.RoundTo, SkipUnmodified: e.cfg.SkipUnmodified, WholeRun: capped}
+	done := UpdateProjectItems(childCtx, wg, writer, policy, updateChan, results)
+
+	var err error
+	select {
+	case err = <-errChan:
+		cancel()
+		<-done
+	case <-done:
+	}
+
+	// the pipeline stops quietly when the run's context is cancelled
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	close(results)
+	<-collected
+
+	// items are only assigned and synced from a run over the whole board, since the top of a range, or of
+	// a run resumed part of the way through, is not the board's
+	if err == nil {
+		if partial {
+			slog.InfoContext(ctx, "run covered part of the project, not assigning top items or syncing the roll-up project")
+		} else {
+			e.assignTop(ctx, top)
+			e.syncRollup(ctx, synced)
+		}
+		checkpoint.Finish(ctx)
+	}
+
+	if e.digest != nil {
+		if err := e.digest.Flush(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to send notification digest", "error", err)
+		}
+	}
+
+	if e.store != nil {
+		if err := e.store.Save(notificationsFile, e.fired); err != nil {
+			slog.ErrorContext(ctx, "failed to save notification state", "error", err)
+		}
+	}
+
+	if cache != nil {
+		hits, resumed, misses, err := cache.Save(e.store, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to save score cache", "error", err)
+		} else {
+			slog.InfoContext(ctx, "score cache", "hits", hits, "resumed", resumed, "misses", misses)
+		}
+	}
+
+	if err := e.scorer.activity.Save(e.store); err != nil {
+		slog.ErrorContext(ctx, "failed to save activity log", "error", err)
+	}
+
+	if err := dump.Write(e.cfg.DumpDir, run, e.scorer.Profile()); err != nil {
+		slog.ErrorContext(ctx, "failed to write dump", "path", e.cfg.DumpDir, "error", err)
+	} else if dump != nil {
+		slog.InfoContext(ctx, "wrote dump", "path", e.cfg.DumpDir, "items", len(dump.records))
+	}
+
+	// a capped run only writes a plan of the updates left over, so that an earlier plan is not replaced
+	// by an empty one
+	if plan != nil && !e.readOnly && len(plan.Mutations) > 0 {
+		slog.WarnContext(ctx, "reached the maximum number of updates, writing the remaining updates to the plan file; review it and run apply", "max_updates", e.cfg.MaxUpdates, "remaining", len(plan.Mutations), "path", e.cfg.PlanFile)
+	}
+	if plan != nil && (e.readOnly || len(plan.Mutations) > 0) {
+		if err := plan.Write(e.cfg.PlanFile); err != nil {
+			slog.ErrorContext(ctx, "failed to write plan file", "path", e.cfg.PlanFile, "error", err)
+		} else {
+			slog.InfoContext(ctx, "wrote plan file", "path", e.cfg.PlanFile, "mutations", len(plan.Mutations))
+		}
+	}
+
+	summary := acc.Summary()
+	summary.Stale = e.saveProfiles(ctx, profileFor)
+	summary.RateLimit = e.limiter.Summary()
+	summary.Quota = quotaUsage(calls, e.metrics.Snapshot(), e.fragments.Names())
+	if r := summary.RateLimit; r != nil {
+		schedule := e.cfg.Schedule
+		if e.cfg.Command == "daemon" {
+			schedule = e.cfg.Interval
+		}
+		r.Forecast(summary.Total, schedule, time.Now())
+
+		slog.InfoContext(ctx, "rate limit usage", "used", r.Used, "remaining", r.Remaining, "reserve", r.Reserve, "unused", r.Unused, "cost_per_item", r.CostPerItem)
+		if !r.NextRunFits {
+			slog.WarnContext(ctx, "the next scheduled run is not expected to fit in the rate limit", "next_run_at", r.NextRunAt, "recommended_interval", r.RecommendedInterval)
+		}
+	}
+	e.summary = summary
+	if err := e.reporters.Finish(summary); err != nil {
+		slog.ErrorContext(ctx, "failed to write reports", "error", err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if failed := summary.Statuses[StatusFailed]; failed > 0 {
+		return fmt.Errorf("failed to process %d project items", failed)
+	}
+
+	return nil
+}
+
+// Summary returns the summary of the engine's last run
+func (e *Engine) Summary() Summary {
+	return e.summary
+}
+
+// notifies returns true if notifications can be sent, through the configured notifiers or those of
+// a rule
+func (e *Engine) notifies() bool {
+	if len(e.notifiers) > 0 {
+		return true
+	}
+	for _, rule := range e.rules {
+		for _, action := range rule.Actions {
+			if len(action.notifiers) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkRedaction decides whether notifications for the run are redacted. With the auto mode, they are
+// redacted if the project is private, since notifiers may post to places that people without access to
+// the project can read.
+func (e *Engine) checkRedaction(ctx context.Context) error {
+	e.redact = e.cfg.Redact == RedactAlways
+	if e.cfg.Redact != RedactAuto || !e.notifies() {
+		return nil
+	}
+
+	public, err := projectIsPublic(ctx, e.gh, e.cfg.ProjectID)
+	if err != nil {
+		return fmt.Errorf("checking project visibility: %w", err)
+	}
+
+	if !public {
+		slog.InfoContext(ctx, "project is private, stripping titles from notifications")
+		e.redact = true
+	}
+
+	return nil
+}
+
+// loadProfiles loads the scoring profile that each item's value was calculated with from the state
+// directory, and notes when values were calculated with a profile other than the current one, or the
+// one being rolled out
+func (e *Engine) loadProfiles(ctx context.Context, profile string, rollout *PlanRollout) error {
+	e.profiles = NewProfileState()
+	if e.store == nil {
+		return nil
+	}
+
+	if err := e.store.Load(profilesFile, e.profiles); err != nil {
+		return err
+	}
+
+	for previous, items := range e.profiles.Profiles() {
+		if previous != profile && (rollout == nil || previous != rollout.Profile) {
+			slog.WarnContext(ctx, "scoring profile changed, values calculated with the previous profile will be recalculated as items are processed", "profile", profile, "previous_profile", previous, "items", items)
+		}
+	}
+
+	return nil
+}
+
+// saveProfiles saves the scoring profile that each item's value was calculated with to the state
+// directory, and returns the number of items whose values were calculated with a profile other than the
+// one they are now scored with
+func (e *Engine) saveProfiles(ctx context.Context, profileOf func(itemId interface{}) string) int {
+	if e.store == nil {
+		return 0
+	}
+
+	if err := e.store.Save(profilesFile, e.profiles); err != nil {
+		slog.ErrorContext(ctx, "failed to save scoring profile state", "error", err)
+	}
+
+	stale := e.profiles.Stale(profileOf)
+	for _, id := range stale {
+		slog.DebugContext(ctx, "stale project item", "item_id", id, "profile", e.profiles.Items[id])
+	}
+	if len(stale) > 0 {
+		slog.WarnContext(ctx, "project items have values calculated with a previous scoring profile, run with --recalculate-all to refresh them", "items", len(stale))
+	}
+
+	return len(stale)
+}
+
+// logResult logs the outcome of processing a single project item. With explain, the breakdown of the
+// item's upvotes, and its upvoters in each segment, are included.
+func logResult(ctx context.Context, result Result, explain bool) {
+	attrs := []any{"item_id", result.ItemID, "status", result.Status}
+
+	switch result.Status {
+	case StatusFailed:
+		slog.ErrorContext(ctx, "failed to process project item", append(attrs, "error", result.Err)...)
+	case StatusUpdated, StatusUnchanged, StatusPlanned, StatusSkippedBelowDelta:
+		attrs = append(attrs, "upvotes", result.Upvotes, "previous", result.Previous)
+		if explain {
+			attrs = append(attrs, "components", formatComponents(result.Components))
+			if result.Segments != nil {
+				attrs = append(attrs, "segments", formatSegments(result.Segments))
+			}
+		}
+		slog.InfoContext(ctx, "processed project item", attrs...)
+	default:
+		slog.InfoContext(ctx, "processed project item", attrs...)
+	}
+}
+
+// runID returns the identifier for a run. Within GitHub Actions this is the workflow run ID, suffixed
+// with the attempt number for re-runs. Otherwise it is made up of the start time and a random suffix
+// so that concurrent runs can be told apart.
+func runID(cfg Config) string {
+	if cfg.RunID != "" {
+		if cfg.RunAttempt > 1 {
+			return fmt.Sprintf("%s-%d", cfg.RunID, cfg.RunAttempt)
+		}
+		return cfg.RunID
+	}
+
+	return fmt.Sprintf("%s-%04x", time.Now().UTC().Format("20060102T150405Z"), rand.Intn(0x10000))
+}
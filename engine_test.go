@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestRunCancellation cancels a run of a fresh synthetic project as its first mutation is written, and
+// checks that the run returns the context's error promptly, without writing anything more
+func TestRunCancellation(t *testing.T) {
+	server := newFakeGitHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.onMutation = cancel
+
+	engine, err := newEngine(testConfig(t), &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := engine.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("run took %v to stop", elapsed.Round(time.Millisecond))
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	expectCount(t, "mutations", len(server.mutations), 1)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// externalKeyField is the name that the external key text field is fetched under
+const externalKeyField = "external_key"
+
+// externalKeySelection returns the selection used to fetch the external key from a project text field
+func externalKeySelection(field string) string {
+	return fmt.Sprintf("fieldValueByName(name: %q) { ...on ProjectV2ItemFieldTextValue { text } }", field)
+}
+
+// keyResolver maps a result to the key of the corresponding issue in an external tracker, using either
+// the project text field fetched for the item, or a mapping file of content URLs to keys
+type keyResolver struct {
+	mapping map[string]string
+}
+
+// newKeyResolver loads the mapping file, a CSV file of content URL and external key pairs, if one is configured
+func newKeyResolver(mappingFile string) (*keyResolver, error) {
+	resolver := &keyResolver{mapping: make(map[string]string)}
+	if mappingFile == "" {
+		return resolver, nil
+	}
+
+	f, err := os.Open(mappingFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening export mapping file: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading export mapping file: %w", err)
+		}
+		resolver.mapping[strings.TrimSpace(record[0])] = strings.TrimSpace(record[1])
+	}
+
+	return resolver, nil
+}
+
+// Key returns the external key for the result, or an empty string if it has none
+func (k *keyResolver) Key(result Result) string {
+	if raw, ok := result.Extra[externalKeyField]; ok {
+		var value struct {
+			Text string
+		}
+		if err := json.Unmarshal(raw, &value); err == nil && value.Text != "" {
+			return strings.TrimSpace(value.Text)
+		}
+	}
+
+	return k.mapping[result.Content.URL]
+}
+
+// exportable returns true if the result has a calculated score to export
+func exportable(result Result) bool {
+	switch result.Status {
+	case StatusUpdated, StatusUnchanged, StatusPlanned, StatusSkippedBelowDelta, StatusSkippedUnmodified:
+		return true
+	}
+	return false
+}
+
+// exportWorkers is the number of scores pushed to an external tracker at once
+const exportWorkers = 4
+
+// exportReporter pushes the scores of the items with an external key to an external tracker once the run
+// has finished, so that the tracker's requests do not hold up the processing of the project's items
+type exportReporter struct {
+	name    string
+	keys    *keyResolver
+	push    func(ctx context.Context, key string, score float64) error
+	pending []exportedScore
+	ctx     context.Context
+}
+
+// exportedScore is a score waiting to be pushed to the issue with the key
+type exportedScore struct {
+	key   string
+	score float64
+}
+
+// SetContext sets the context that pushes are cancelled with
+func (e *exportReporter) SetContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+// Start does nothing
+func (e *exportReporter) Start(run RunInfo) error {
+	return nil
+}
+
+// ItemResult queues the score to be pushed if the item has an external key
+func (e *exportReporter) ItemResult(result Result) error {
+	if !exportable(result) {
+		return nil
+	}
+
+	if key := e.keys.Key(result); key != "" {
+		e.pending = append(e.pending, exportedScore{key: key, score: result.Upvotes})
+	}
+	return nil
+}
+
+// Finish pushes the queued scores, exportWorkers at a time, and logs how many were exported. Every score
+// is pushed even if some fail, and the failures are returned together.
+func (e *exportReporter) Finish(summary Summary) error {
+	parent := e.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	scores := make(chan exportedScore)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	var exported int
+	for w := 0; w < exportWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range scores {
+				ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+				err := e.push(ctx, s.key, s.score)
+				cancel()
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("exporting to %s issue %s: %w", e.name, s.key, err))
+				} else {
+					exported++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, s := range e.pending {
+		scores <- s
+	}
+	close(scores)
+	wg.Wait()
+
+	slog.InfoContext(parent, "exported scores", "tracker", e.name, "exported", exported, "failed", len(e.pending)-exported)
+	return errors.Join(errs...)
+}
+
+// newExportReporter returns the reporter that exports scores to the named tracker
+func newExportReporter(name string, cfg ExportConfig) (*exportReporter, error) {
+	if cfg.KeyField == "" && cfg.MappingFile == "" {
+		return nil, fmt.Errorf("%s export requires export.key_field or export.mapping_file", name)
+	}
+
+	keys, err := newKeyResolver(cfg.MappingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var push func(ctx context.Context, key string, score float64) error
+	switch name {
+	case "jira":
+		push, err = newJiraPusher(cfg.Jira)
+	case "linear":
+		push, err = newLinearPusher(cfg.Linear)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &exportReporter{name: name, keys: keys, push: push}, nil
+}
+
+// newJiraPusher returns a function that sets a custom field on a Jira issue through the REST API
+func newJiraPusher(cfg JiraConfig) (func(ctx context.Context, key string, score float64) error, error) {
+	if cfg.URL == "" || cfg.Token == "" || cfg.Field == "" {
+		return nil, errors.New("jira export requires export.jira.url, export.jira.token, and export.jira.field")
+	}
+
+	return func(ctx context.Context, key string, score float64) error {
+		body := map[string]interface{}{
+			"fields": map[string]interface{}{cfg.Field: score},
+		}
+
+		req, err := newJSONRequest(ctx, http.MethodPut, strings.TrimSuffix(cfg.URL, "/")+"/rest/api/2/issue/"+url.PathEscape(key), body)
+		if err != nil {
+			return err
+		}
+
+		// Jira Cloud uses basic auth with an API token, Jira Data Center uses a bearer personal access token
+		if cfg.User != "" {
+			req.SetBasicAuth(cfg.User, cfg.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		}
+
+		return doJSONRequest(req, nil)
+	}, nil
+}
+
+// linearURL is the endpoint of Linear's GraphQL API
+const linearURL = "https://api.linear.app/graphql"
+
+// newLinearPusher returns a function that sets the estimate of a Linear issue. Linear estimates are
+// integers, so the score is rounded.
+func newLinearPusher(cfg LinearConfig) (func(ctx context.Context, key string, score float64) error, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("linear export requires export.linear.token")
+	}
+
+	return func(ctx context.Context, key string, score float64) error {
+		body := map[string]interface{}{
+			"query": "mutation($id: String!, $estimate: Int!) { issueUpdate(id: $id, input: { estimate: $estimate }) { success } }",
+			"variables": map[string]interface{}{
+				"id":       key,
+				"estimate": int(math.Round(score)),
+			},
+		}
+
+		req, err := newJSONRequest(ctx, http.MethodPost, linearURL, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", cfg.Token)
+
+		var response struct {
+			Errors []struct {
+				Message string
+			}
+		}
+		if err := doJSONRequest(req, &response); err != nil {
+			return err
+		}
+		if len(response.Errors) > 0 {
+			return errors.New(response.Errors[0].Message)
+		}
+
+		return nil
+	}, nil
+}
+
+// newJSONRequest builds a request with a JSON body
+func newJSONRequest(ctx context.Context, method, url string, body interface{}) (*http.Request, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// doJSONRequest performs the request, returning an error for non-2xx responses, and decodes the
+// response into out if it is not nil
+func doJSONRequest(req *http.Request, out interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %v", req.URL.Host, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
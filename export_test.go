@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestExport checks that scores are only pushed once the run has finished, that every score is pushed
+// even if some fail, and that the failures are returned
+func TestExport(t *testing.T) {
+	var mu sync.Mutex
+	pushed := make(map[string]float64)
+	reporter := &exportReporter{
+		name: "jira",
+		keys: &keyResolver{mapping: map[string]string{
+			"https://github.com/selftest/repo/issues/1": "UP-1",
+			"https://github.com/selftest/repo/issues/2": "UP-2",
+			"https://github.com/selftest/repo/issues/3": "UP-3",
+		}},
+		push: func(ctx context.Context, key string, score float64) error {
+			if key == "UP-2" {
+				return errors.New("not found")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			pushed[key] = score
+			return nil
+		},
+	}
+
+	for i, status := range []Status{StatusUpdated, StatusPlanned, StatusUnchanged, StatusFailed} {
+		result := Result{Status: status, Upvotes: float64(i + 1), Content: ContentInfo{URL: "https://github.com/selftest/repo/issues/" + string(rune('1'+i))}}
+		if err := reporter.ItemResult(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectCount(t, "scores pushed before the run finished", len(pushed), 0)
+
+	err := reporter.Finish(Summary{})
+	if err == nil || !strings.Contains(err.Error(), "UP-2") {
+		t.Fatalf("expected the failure to push UP-2, got %v", err)
+	}
+	if len(pushed) != 2 || pushed["UP-1"] != 1 || pushed["UP-3"] != 3 {
+		t.Fatalf("expected UP-1 and UP-3 to be pushed, got %v", pushed)
+	}
+}
+
+// TestJiraPusher pushes scores to a Jira server, and checks that each key is escaped as a single segment
+// of the issue's path, so that a key from the mapping file cannot reach another endpoint
+func TestJiraPusher(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer jira-token" {
+			t.Errorf("unexpected %s request with %q", r.Method, r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	push, err := newJiraPusher(JiraConfig{URL: server.URL + "/jira/", Token: "jira-token", Field: "customfield_10001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, want string
+	}{
+		{key: "UP-1", want: "/jira/rest/api/2/issue/UP-1"},
+		{key: "UP-1/../../../admin", want: "/jira/rest/api/2/issue/UP-1%2F..%2F..%2F..%2Fadmin"},
+		{key: "UP 1?expand=all#x", want: "/jira/rest/api/2/issue/UP%201%3Fexpand=all%23x"},
+	}
+	for _, test := range tests {
+		paths = nil
+		if err := push(context.Background(), test.key, 1); err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) != 1 || paths[0] != test.want {
+			t.Errorf("%s: expected a request to %s, got %v", test.key, test.want, paths)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Item is a project item flowing through the pipeline, along with any additional fields that were
+// fetched for it through registered Fragments
+type Item struct {
+	ProjectItemEdgeFragment
+	Extra map[string]json.RawMessage
+
+	// Owners are the teams that own the item, when items are mapped to owners
+	Owners []string
+
+	// PageItems is the number of items of the item's page that are scored, or zero if it is not known
+	PageItems int
+}
+
+// fragmentName matches valid GraphQL aliases
+var fragmentName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// Fragments is a set of additional GraphQL selections to fetch for each project item. Each selection
+// is made against the ProjectV2Item type and aliased to its registered name, so the raw JSON for the
+// selection can be passed along with the item.
+type Fragments struct {
+	client *http.Client
+	url    string
+	fields map[string]string
+}
+
+// NewFragments returns an empty set of Fragments that will be fetched using the given client
+func NewFragments(client *http.Client, url string) *Fragments {
+	return &Fragments{
+		client: client,
+		url:    url,
+		fields: make(map[string]string),
+	}
+}
+
+// Register adds a selection on ProjectV2Item to fetch for each item, for example
+// `content { ...on Issue { assignees(first: 5) { nodes { login } } } }`. The result is available
+// in the item's Extra fields under name.
+func (f *Fragments) Register(name, selection string) error {
+	if !fragmentName.MatchString(name) || name == "id" {
+		return fmt.Errorf("invalid fragment name %q: must be a valid GraphQL alias", name)
+	}
+
+	if strings.TrimSpace(selection) == "" {
+		return fmt.Errorf("empty selection for fragment %q", name)
+	}
+
+	f.fields[name] = selection
+	return nil
+}
+
+// Empty returns true if no fragments have been registered
+func (f *Fragments) Empty() bool {
+	return f == nil || len(f.fields) == 0
+}
+
+// Names returns the names of the registered fragments, sorted
+func (f *Fragments) Names() []string {
+	if f == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(f.fields))
+	for name := range f.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// query builds the query used to fetch the registered fragments for a list of nodes, along with its
+// cost, which is recorded with the calls of the fields kind
+func (f *Fragments) query() string {
+	var b strings.Builder
+	b.WriteString("query($ids: [ID!]!) { nodes(ids: $ids) { ...on ProjectV2Item { id ")
+	for _, name := range f.Names() {
+		fmt.Fprintf(&b, "%s: %s ", name, f.fields[name])
+	}
+	b.WriteString("} } rateLimit { cost } }")
+
+	return b.String()
+}
+
+// Fetch queries the registered fragments for the given project item IDs in a single request. It
+// returns the raw JSON of each fragment, keyed by item ID and then fragment name.
+func (f *Fragments) Fetch(ctx context.Context, ids []githubv4.ID) (map[githubv4.ID]map[string]json.RawMessage, error) {
+	if f.Empty() || len(ids) == 0 {
+		return nil, nil
+	}
+
+	var data struct {
+		Nodes []map[string]json.RawMessage
+	}
+
+	if err := rawQuery(withQueryKind(ctx, "fields"), f.client, f.url, f.query(), map[string]interface{}{"ids": ids}, &data); err != nil {
+		return nil, fmt.Errorf("fetching extra fields: %w", err)
+	}
+
+	extra := make(map[githubv4.ID]map[string]json.RawMessage, len(data.Nodes))
+	for _, node := range data.Nodes {
+		var id string
+		if err := json.Unmarshal(node["id"], &id); err != nil {
+			continue
+		}
+		delete(node, "id")
+		extra[githubv4.ID(id)] = node
+	}
+
+	return extra, nil
+}
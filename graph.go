@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of link between Issues and Pull Requests found in their timelines
+const (
+	// LinkCrossReference means that the linked Issue or Pull Request mentioned the item
+	LinkCrossReference = "cross-reference"
+
+	// LinkConnected means that the linked Issue or Pull Request was connected to the item
+	LinkConnected = "connected"
+
+	// LinkDuplicate means that the item was marked as a duplicate of the linked Issue or Pull Request
+	LinkDuplicate = "duplicate"
+)
+
+// ContentLink is a link from an Issue or Pull Request to another, found in its timeline
+type ContentLink struct {
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+// Links returns the other Issues and Pull Requests linked from the timeline of the Issue or Pull
+// Request, without repeats
+func (c ContentFragment) Links() []ContentLink {
+	var links []ContentLink
+	seen := make(map[ContentLink]bool)
+
+	for _, node := range c.TimelineItems.Nodes {
+		var link ContentLink
+		switch node.Type {
+		case "CrossReferencedEvent":
+			link = ContentLink{Kind: LinkCrossReference, URL: node.CrossReferencedEvent.url()}
+		case "ConnectedEvent":
+			link = ContentLink{Kind: LinkConnected, URL: node.ConnectedEvent.url()}
+		case "MarkedAsDuplicateEvent":
+			link = ContentLink{Kind: LinkDuplicate, URL: node.MarkedAsDuplicateEvent.url()}
+		case "ReferencedEvent":
+			pr, ok := node.ReferencedEvent.pullRequest()
+			if !ok || pr.Url.URL == nil {
+				continue
+			}
+			link = ContentLink{Kind: LinkCrossReference, URL: pr.Url.String()}
+		default:
+			continue
+		}
+
+		if link.URL == "" || (c.Url.URL != nil && link.URL == c.Url.String()) || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// GraphNode is an Issue or Pull Request in the cross-reference graph. Nodes that are not in the
+// project have no upvotes.
+type GraphNode struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Upvotes   float64 `json:"upvotes"`
+	InProject bool    `json:"in_project"`
+}
+
+// GraphEdge is a link between two nodes of the cross-reference graph. Cross-references and connections
+// point from the linked Issue or Pull Request to the project item, and duplicates from the duplicate to
+// the canonical Issue or Pull Request.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+// Graph is the graph of cross-references, connections, and duplicates between the project's items and
+// the Issues and Pull Requests that link to them, keyed by URL
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// NewGraph builds the Graph from the links found while calculating the results
+func NewGraph(results []Result) Graph {
+	nodes := make(map[string]GraphNode)
+	var edges []GraphEdge
+
+	for _, result := range results {
+		url := result.Content.URL
+		if url == "" {
+			continue
+		}
+		nodes[url] = GraphNode{ID: url, Name: result.Name(), Upvotes: result.Upvotes, InProject: true}
+
+		for _, link := range result.Content.Links {
+			if _, ok := nodes[link.URL]; !ok {
+				nodes[link.URL] = GraphNode{ID: link.URL, Name: link.URL}
+			}
+
+			edge := GraphEdge{Source: link.URL, Target: url, Kind: link.Kind}
+			if link.Kind == LinkDuplicate {
+				edge = GraphEdge{Source: url, Target: link.URL, Kind: link.Kind}
+			}
+			edges = append(edges, edge)
+		}
+	}
+
+	graph := Graph{Nodes: make([]GraphNode, 0, len(nodes)), Edges: []GraphEdge{}}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+
+	// the same link may be found from both ends
+	seen := make(map[GraphEdge]bool)
+	for _, edge := range edges {
+		if !seen[edge] {
+			seen[edge] = true
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	return graph
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. Project items are drawn as boxes labelled with
+// their upvotes, and duplicates as dashed edges.
+func (g Graph) WriteDOT(w io.Writer) error {
+	fmt.Fprintln(w, "digraph upvotes {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=ellipse];")
+
+	for _, node := range g.Nodes {
+		if node.InProject {
+			label := fmt.Sprintf("%s\n%v upvotes", node.Name, node.Upvotes)
+			fmt.Fprintf(w, "  %s [label=%s, shape=box, URL=%s];\n", strconv.Quote(node.ID), strconv.Quote(label), strconv.Quote(node.ID))
+			continue
+		}
+		fmt.Fprintf(w, "  %s [URL=%s];\n", strconv.Quote(node.ID), strconv.Quote(node.ID))
+	}
+
+	for _, edge := range g.Edges {
+		style := "solid"
+		if edge.Kind == LinkDuplicate {
+			style = "dashed"
+		}
+		fmt.Fprintf(w, "  %s -> %s [label=%s, style=%s];\n", strconv.Quote(edge.Source), strconv.Quote(edge.Target), strconv.Quote(edge.Kind), style)
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// graphReporter writes the cross-reference graph, as DOT if the path ends in .dot or .gv, and as JSON
+// otherwise
+type graphReporter struct {
+	collector
+	path string
+}
+
+// Finish writes the file
+func (g *graphReporter) Finish(summary Summary) error {
+	graph := NewGraph(g.results)
+
+	switch strings.ToLower(filepath.Ext(g.path)) {
+	case ".dot", ".gv":
+		f, err := os.Create(g.path)
+		if err != nil {
+			return err
+		}
+		return errors.Join(graph.WriteDOT(f), f.Close())
+	}
+
+	b, err := json.MarshalIndent(graph, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(g.path, b, 0o644)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// graphqlURL is the endpoint of GitHub's GraphQL API, used unless an API URL is configured
+const graphqlURL = "https://api.github.com/graphql"
+
+// parseAPIURL validates the URL of the GraphQL API, such as https://github.example.com/api/graphql for
+// GitHub Enterprise Server. The base URL of the REST API, which GitHub Actions sets as GITHUB_API_URL, is
+// accepted too, and translated to its GraphQL endpoint: /graphql for github.com, and /api/graphql rather
+// than /api/v3 for GitHub Enterprise Server.
+func parseAPIURL(s string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid API URL %q, expected an http or https URL such as %s", s, graphqlURL)
+	}
+
+	switch path := strings.TrimSuffix(u.Path, "/"); {
+	case path == "":
+		u.Path = "/graphql"
+	case strings.HasSuffix(path, "/api/v3"):
+		u.Path = strings.TrimSuffix(path, "/v3") + "/graphql"
+	default:
+		u.Path = path
+	}
+	return u.String(), nil
+}
+
+// apiURL returns the endpoint of the GraphQL API that requests are sent to
+func (c Config) apiURL() string {
+	if c.APIURL == "" {
+		return graphqlURL
+	}
+	return c.APIURL
+}
+
+// newGraphQLClient returns a client for the GraphQL API of the Config, sending its requests through client
+func newGraphQLClient(cfg Config, client *http.Client) *githubv4.Client {
+	return githubv4.NewEnterpriseClient(cfg.apiURL(), client)
+}
+
+// rawQuery executes a GraphQL query that is built at runtime, rather than from a struct, and decodes
+// the data in the response into out.
+func rawQuery(ctx context.Context, client *http.Client, url string, query string, variables map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("non-200 OK status code: %v", resp.Status)
+	}
+
+	var response struct {
+		Data   json.RawMessage
+		Errors []struct {
+			Message string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+
+	if len(response.Errors) > 0 {
+		errs := make([]error, 0, len(response.Errors))
+		for _, e := range response.Errors {
+			errs = append(errs, errors.New(e.Message))
+		}
+		return errors.Join(errs...)
+	}
+
+	return json.Unmarshal(response.Data, out)
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+// TestAPIURL checks that the GraphQL endpoints of github.com and GitHub Enterprise Server are found
+// from their REST base URLs, as GitHub Actions sets them, and that invalid URLs are rejected
+func TestAPIURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://api.github.com":                  graphqlURL,
+		"https://api.github.com/graphql":          graphqlURL,
+		"https://github.example.com/api/v3":       "https://github.example.com/api/graphql",
+		"https://github.example.com/api/v3/":      "https://github.example.com/api/graphql",
+		"https://github.example.com/api/graphql/": "https://github.example.com/api/graphql",
+		"http://localhost:8080/proxy/graphql":     "http://localhost:8080/proxy/graphql",
+	} {
+		got, err := parseAPIURL(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("%s: expected %s, got %s", in, want, got)
+		}
+	}
+
+	for _, in := range []string{"", "github.example.com/api/graphql", "ftp://github.example.com", "https://github.example.com/api/graphql?x=1"} {
+		if _, err := parseAPIURL(in); err == nil {
+			t.Fatalf("expected %q to be rejected", in)
+		}
+	}
+}
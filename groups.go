@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// groupField is the name that the project field used for grouping is fetched under
+const groupField = "group_by"
+
+// noGroup is the group of items that match no group
+const noGroup = "(none)"
+
+// Grouper assigns results to groups for per-group leaderboards, by the labels or owning teams matching
+// a pattern, or by the value of a project field
+type Grouper struct {
+	// Kind is label, owner, or field
+	Kind string
+
+	// Pattern is the label or owner glob pattern, such as area/* or acme/*, or the name of the project field
+	Pattern string
+}
+
+// ParseGroupBy parses a grouping such as label:area/*, owner:acme/*, or field:Component. An empty spec returns nil.
+func ParseGroupBy(spec string) (*Grouper, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	kind, pattern, ok := strings.Cut(spec, ":")
+	if !ok || pattern == "" || (kind != "label" && kind != "owner" && kind != "field") {
+		return nil, fmt.Errorf("invalid grouping %q: must be label:<pattern>, owner:<pattern>, or field:<name>", spec)
+	}
+
+	if kind == "label" || kind == "owner" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+	}
+
+	// owners are compared in lower case, as they are recorded
+	if kind == "owner" {
+		pattern = ownerName(pattern)
+	}
+
+	return &Grouper{Kind: kind, Pattern: pattern}, nil
+}
+
+// String returns the grouping as it was specified
+func (g *Grouper) String() string {
+	return g.Kind + ":" + g.Pattern
+}
+
+// Register registers the fragment used to fetch the project field that items are grouped by
+func (g *Grouper) Register(fragments *Fragments) error {
+	if g == nil || g.Kind != "field" {
+		return nil
+	}
+
+	selection := fmt.Sprintf("fieldValueByName(name: %q) { "+
+		"...on ProjectV2ItemFieldSingleSelectValue { name } "+
+		"...on ProjectV2ItemFieldTextValue { text } "+
+		"...on ProjectV2ItemFieldNumberValue { number } "+
+		"...on ProjectV2ItemFieldIterationValue { title } }", g.Pattern)
+
+	return fragments.Register(groupField, selection)
+}
+
+// Keys returns the groups that the result belongs to. An item may be in several groups when grouping
+// by label or owner.
+func (g *Grouper) Keys(result Result) []string {
+	var keys []string
+
+	switch g.Kind {
+	case "label":
+		for _, label := range result.Content.Labels {
+			if ok, _ := path.Match(g.Pattern, label); ok {
+				keys = append(keys, label)
+			}
+		}
+	case "owner":
+		for _, owner := range result.Content.Owners {
+			if ok, _ := path.Match(g.Pattern, owner); ok {
+				keys = append(keys, owner)
+			}
+		}
+	case "field":
+		var value struct {
+			Name   string
+			Text   string
+			Title  string
+			Number *float64
+		}
+		if raw, ok := result.Extra[groupField]; ok && json.Unmarshal(raw, &value) == nil {
+			switch {
+			case value.Name != "":
+				keys = append(keys, value.Name)
+			case value.Text != "":
+				keys = append(keys, value.Text)
+			case value.Title != "":
+				keys = append(keys, value.Title)
+			case value.Number != nil:
+				keys = append(keys, fmt.Sprint(*value.Number))
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		keys = append(keys, noGroup)
+	}
+
+	return keys
+}
+
+// Group is the leaderboard of a single group
+type Group struct {
+	Name  string
+	Items int
+	Total float64
+
+	// Top holds the items in the group with the most upvotes
+	Top []Result
+}
+
+// Groups assigns the scored results to groups, and returns the groups with the most upvotes first,
+// each listing at most top items
+func (g *Grouper) Groups(results []Result, top int) []Group {
+	if g == nil {
+		return nil
+	}
+
+	byName := make(map[string]*Group)
+	for _, result := range results {
+		if !exportable(result) {
+			continue
+		}
+
+		for _, key := range g.Keys(result) {
+			group, ok := byName[key]
+			if !ok {
+				group = &Group{Name: key}
+				byName[key] = group
+			}
+			group.Items++
+			group.Total += result.Upvotes
+			group.Top = append(group.Top, result)
+		}
+	}
+
+	groups := make([]Group, 0, len(byName))
+	for _, group := range byName {
+		sort.SliceStable(group.Top, func(i, j int) bool { return group.Top[i].Upvotes > group.Top[j].Upvotes })
+		if top > 0 && len(group.Top) > top {
+			group.Top = group.Top[:top]
+		}
+		groups = append(groups, *group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Total != groups[j].Total {
+			return groups[i].Total > groups[j].Total
+		}
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups
+}
+
+// writeGroupsTable writes the groups as plain text tables
+func writeGroupsTable(w io.Writer, by *Grouper, groups []Group) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\nGROUP (%s)\tITEMS\tUPVOTES\tTOP ITEMS\n", by)
+	for _, group := range groups {
+		var top []string
+		for _, result := range group.Top {
+			top = append(top, fmt.Sprintf("%s (%v)", result.Name(), result.Upvotes))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%s\n", group.Name, group.Items, group.Total, strings.Join(top, ", "))
+	}
+
+	return tw.Flush()
+}
+
+// groupsMarkdown returns the groups as Markdown, with a table of totals followed by each group's top
+// items, in the language of the locale
+func groupsMarkdown(by *Grouper, groups []Group, l *Locale) string {
+	if len(groups) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n#### %s\n\n", l.T("groups.title", by))
+	b.WriteString(l.row("groups.group", "summary.items", "report.upvotes"))
+	b.WriteString("| --- | ---: | ---: |\n")
+	for _, group := range groups {
+		fmt.Fprintf(&b, "| %s | %d | %v |\n", group.Name, group.Items, group.Total)
+	}
+
+	for _, group := range groups {
+		fmt.Fprintf(&b, "\n**%s**\n\n", group.Name)
+		for i, result := range group.Top {
+			fmt.Fprintf(&b, "%d. %s: %v\n", i+1, markdownLink(result), result.Upvotes)
+		}
+	}
+
+	return b.String()
+}
@@ -1,35 +1,530 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"log/slog"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-// validateEnv ensures that the required variables have been supplied
-func validateEnv() error {
+// flagKeys maps command line flags to the viper key they override, for flags whose key is not
+// simply the flag name with dashes replaced by underscores. Each key can also be set by the
+// environment variable of the same name prefixed with GITHUB_, so that existing environment variable
+// configuration continues to work alongside flags.
+var flagKeys = map[string]string{
+	"extra-field":     "extra_fields",
+	"exclude-repo":    "exclude_repos",
+	"terminal-status": "terminal_statuses",
+	"reporter":        "reporters",
+	"output":          "output_file",
+	"notifier":        "notifiers",
+	"header":          "headers",
+}
 
-	viper.AutomaticEnv()
+// flagKey returns the viper key for a flag
+func flagKey(name string) string {
+	if key, ok := flagKeys[name]; ok {
+		return key
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
 
-	if viper.IsSet("RUNNER_DEBUG") {
-		slog.Info("setting debug logging")
-		opts := &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		}
+// newFlagSet returns the flags accepted by the command line
+func newFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("github-upvotes", pflag.ContinueOnError)
+
+	flags.String("config", "", "path to a YAML config file (env: GITHUB_CONFIG)")
+	flags.String("token", "", "token used to authenticate with GitHub (env: GITHUB_TOKEN)")
+	flags.String("project-id", "", "ID of the GitHub Project (env: GITHUB_PROJECT_ID)")
+	flags.String("field-id", "", "ID of the 'upvotes' field in the GitHub Project (env: GITHUB_FIELD_ID)")
+	flags.String("sync-project-id", "", "ID of a roll-up GitHub Project that the upvotes are also written to, for the items of the same issues and pull requests (env: GITHUB_SYNC_PROJECT_ID)")
+	flags.String("sync-field-id", "", "ID of the upvotes field in the roll-up project (env: GITHUB_SYNC_FIELD_ID)")
+	flags.String("api-url", graphqlURL, "URL of the GraphQL API, for GitHub Enterprise Server, such as https://github.example.com/api/graphql (env: GITHUB_API_URL)")
+	flags.String("proxy", "", "URL of the proxy used for requests to GitHub; defaults to HTTPS_PROXY (env: GITHUB_PROXY)")
+	flags.String("user-agent", defaultUserAgent, "User-Agent sent with requests to GitHub (env: GITHUB_USER_AGENT)")
+	flags.StringToString("header", nil, "additional header sent with requests to GitHub, as name=value (repeatable)")
+	flags.Duration("request-timeout", time.Minute, "time limit for each attempt of a request to GitHub (env: GITHUB_REQUEST_TIMEOUT)")
+	flags.Int("retries", 3, "times a request to GitHub is made again after a transient failure, or 0 to never retry (env: GITHUB_RETRIES)")
+	flags.Duration("retry-backoff", time.Second, "time waited before the first retry, doubling for each retry after it (env: GITHUB_RETRY_BACKOFF)")
+	flags.Int("breaker-threshold", 5, "consecutive failed requests that pause requests to GitHub, or 0 to never pause (env: GITHUB_BREAKER_THRESHOLD)")
+	flags.Duration("breaker-cooldown", 30*time.Second, "time requests to GitHub are first paused for, doubling while failures continue (env: GITHUB_BREAKER_COOLDOWN)")
+	flags.Bool("debug", false, "enable debug logging (env: RUNNER_DEBUG)")
+	flags.String("output", "", "path to write the JSON report of every item's upvotes to, the same as --reporter json=<path> (env: GITHUB_OUTPUT_FILE)")
+	flags.String("summary-file", "", "path to write the JSON run summary to (env: GITHUB_SUMMARY_FILE)")
+	flags.String("org-audit-repo", "", "owner/name of a repository to record each run in, for a tamper-evident history (env: GITHUB_ORG_AUDIT_REPO)")
+	flags.String("org-audit-path", defaultOrgAuditPath, "file in --org-audit-repo that each run is committed to (env: GITHUB_ORG_AUDIT_PATH)")
+	flags.Int("org-audit-issue", 0, "number of an issue in --org-audit-repo to comment each run on, instead of committing it (env: GITHUB_ORG_AUDIT_ISSUE)")
+	flags.StringSlice("reporter", nil, "reporter to send results to: table, json=<path>, csv=<path>, markdown=<path>, template=<path>, milestone=<path>, epics=<path>, inbox=<path>, assignees=<path>, graph=<path>, duplicates=<path>, actions-summary, jira, or linear (repeatable, env: GITHUB_REPORTERS)")
+	flags.String("report-template", "", "path to a Go template used in place of the built-in Markdown report, and by the template reporter (env: GITHUB_REPORT_TEMPLATE)")
+	flags.String("run-id", "", "ID used to correlate logs, state, and reports for a run; generated if not set (env: GITHUB_RUN_ID)")
+	flags.String("state-dir", "", "directory to keep state, such as the audit log, in (env: GITHUB_STATE_DIR)")
+	flags.String("state-url", "", "base URL of a remote store to keep state and score history in instead of --state-dir (env: GITHUB_STATE_URL)")
+	flags.String("state-token", "", "bearer token for --state-url (env: GITHUB_STATE_TOKEN)")
+	flags.Bool("series", false, "append each item's score to a monthly CSV file in the state directory or remote store (env: GITHUB_SERIES)")
+	flags.String("output-dir", "", "directory that relative paths of reports, state, and other written files are resolved against; defaults to the workspace in an Actions container (env: GITHUB_OUTPUT_DIR)")
+	flags.StringSlice("notifier", nil, "notifier to send messages to: slack=<url>, teams=<url>, webhook=<url>, or email=<smtp url> (repeatable, env: GITHUB_NOTIFIERS)")
+	flags.String("notify-template", "", "Go template used to render notification messages, or @path to read it from a file (env: GITHUB_NOTIFY_TEMPLATE)")
+	flags.Float64("notify-threshold", 0, "send a notification when an item's upvotes cross this value (env: GITHUB_NOTIFY_THRESHOLD)")
+	flags.Float64("notify-delta", 0, "send a notification when an item's upvotes grow by at least this much since the last run (env: GITHUB_NOTIFY_DELTA)")
+	flags.Float64("drop-alert", 0, "send a notification when an item's upvotes drop by more than this percentage between runs (env: GITHUB_DROP_ALERT)")
+	flags.Bool("read-only", false, "compute upvotes without writing them, queueing the updates in the plan file; enabled automatically when the token cannot update the project (env: GITHUB_READ_ONLY)")
+	flags.Bool("dry-run", false, "compute upvotes and print the value each item would be given, without writing anything to the project or the plan file (env: GITHUB_DRY_RUN)")
+	flags.String("plan-file", "mutations.json", "path that pending field updates are written to in read-only mode (env: GITHUB_PLAN_FILE)")
+	flags.String("conflict-policy", ConflictSkip, "what to do when a field was changed by someone else during the run: skip, overwrite, or fail (env: GITHUB_CONFLICT_POLICY)")
+	flags.Int("sweep-threshold", 1, "new timeline items an archived item needs for the sweep command to report it (env: GITHUB_SWEEP_THRESHOLD)")
+	flags.Bool("unarchive", false, "unarchive items found by the sweep command rather than only reporting them (env: GITHUB_UNARCHIVE)")
+	flags.Int("max-updates", 0, "most field updates to perform in a run, writing the rest to the plan file for review; 0 for no limit (env: GITHUB_MAX_UPDATES)")
+	flags.String("plan", "", "path of the plan file to perform, for the apply command")
+	flags.Bool("confirm", false, "reset the items listed by the reset command, rather than only reporting them")
+	flags.Bool("reset-to-zero", false, "set the upvotes field to 0 with the reset command, rather than clearing it")
+	flags.String("reset-filter", "", "reset only the items matching a condition such as label:bug and repo:owner/name (env: GITHUB_RESET_FILTER)")
+	flags.Int("ranges", 1, "number of ranges to split the project's items into, for the partition command")
+	flags.String("range-file", "", "path of the range assignment file written by the partition command (env: GITHUB_RANGE_FILE)")
+	flags.Int("range", -1, "index of the range in the range file to process (env: GITHUB_RANGE)")
+	flags.String("checkpoint-file", "", "path of a JSON file that the progress through the project is kept in, so that a stopped run is resumed by the next; requires --cache (env: GITHUB_CHECKPOINT_FILE)")
+	flags.String("redact", RedactAuto, "strip titles from notifications: auto, for private projects only, always, or never (env: GITHUB_REDACT)")
+	flags.String("locale", defaultLocale, "language of Markdown reports and notifications: "+strings.Join(localeNames(), ", ")+", or another with --locale-file (env: GITHUB_LOCALE)")
+	flags.String("locale-file", "", "YAML file of messages that replace those of the locale (env: GITHUB_LOCALE_FILE)")
+	flags.Bool("digest", false, "send notifications as a single digest at the end of the run (env: GITHUB_DIGEST)")
+	flags.Duration("digest-interval", 0, "also send the digest at this interval during the run, for example 15m (env: GITHUB_DIGEST_INTERVAL)")
+	flags.String("adjustments", "", "path to a CSV file of manual score adjustments, with url,points,reason rows (env: GITHUB_ADJUSTMENTS)")
+	flags.String("score-override-field", "", "name of a project number field that replaces an item's upvotes when set (env: GITHUB_SCORE_OVERRIDE_FIELD)")
+	flags.String("min-score-field", "", "name of a project number field holding the minimum upvotes of an item (env: GITHUB_MIN_SCORE_FIELD)")
+	flags.String("max-score-field", "", "name of a project number field holding the maximum upvotes of an item (env: GITHUB_MAX_SCORE_FIELD)")
+	flags.Int("reserve-points", 0, "rate limit points to leave for other users of the token, waiting for the reset rather than using them (env: GITHUB_RESERVE_POINTS)")
+	flags.Float64("min-delta", 0, "minimum change in an item's upvotes that is written to the project (env: GITHUB_MIN_DELTA)")
+	flags.Float64("rollout-percent", 0, "percentage of items scored with the rollout profile, overriding rollout.percent (env: GITHUB_ROLLOUT_PERCENT)")
+	flags.Float64("rollup-weight", 0, "weight of the scores of the open issues tracked by an epic that are added to its own, overriding scoring.rollup (env: GITHUB_ROLLUP_WEIGHT)")
+	flags.Int("inactive-days", 0, "days without new comments, reactions, or timeline items after which an item is inactive, kept in --state-dir (env: GITHUB_INACTIVE_DAYS)")
+	flags.Float64("inactive-factor", 1, "factor from 0 to 1 that the upvotes of inactive items are multiplied by; 1 only flags them (env: GITHUB_INACTIVE_FACTOR)")
+	flags.Float64("round-to", 0, "round the values written to the project to the nearest multiple of this, for example 5 (env: GITHUB_ROUND_TO)")
+	flags.Bool("cache", false, "reuse the timeline scores of items whose issue or pull request has not been updated since the last run, kept in --state-dir (env: GITHUB_CACHE)")
+	flags.Duration("cache-ttl", 24*time.Hour, "how long a cached timeline score is reused before it is recalculated (env: GITHUB_CACHE_TTL)")
+	flags.Bool("skip-unmodified", false, "with --cache, skip writing items whose issue or pull request has not been updated since the last run (env: GITHUB_SKIP_UNMODIFIED)")
+	flags.Bool("recalculate-all", false, "recalculate every item, including closed and archived items, regardless of --range (env: GITHUB_RECALCULATE_ALL)")
+	flags.Bool("age", false, "add the age and upvotes per day of each item to the table, markdown, and csv reports (env: GITHUB_AGE)")
+	flags.Bool("explain", false, "log and report the breakdown of each item's upvotes (env: GITHUB_EXPLAIN)")
+	flags.String("dump", "", "directory to record the data each item is scored from in, for the rescore command (env: GITHUB_DUMP)")
+	flags.String("from", "", "directory of data recorded with --dump that the rescore and compare commands score again (env: GITHUB_FROM)")
+	flags.Bool("current-values", false, "have the rescore command compare new scores with the values on the board rather than those recorded, read in batches (env: GITHUB_CURRENT_VALUES)")
+	flags.String("profile-a", "", "file holding the first scoring profile the compare command scores with (env: GITHUB_PROFILE_A)")
+	flags.String("profile-b", "", "file holding the second scoring profile the compare command scores with (env: GITHUB_PROFILE_B)")
+	flags.String("group-by", "", "group items in reports into leaderboards by label:<pattern>, owner:<pattern>, or field:<name> (env: GITHUB_GROUP_BY)")
+	flags.Int("group-top", 5, "number of items listed in each group's leaderboard (env: GITHUB_GROUP_TOP)")
+	flags.Float64("milestone-budget", 0, "estimate points the milestone reporter suggests items for (env: GITHUB_MILESTONE_BUDGET)")
+	flags.Int("milestone-capacity", 10, "number of items the milestone reporter suggests, or 0 for no limit (env: GITHUB_MILESTONE_CAPACITY)")
+	flags.Int("assign-top", 0, "number of top items to assign to the current iteration or a milestone at the end of each run (env: GITHUB_ASSIGN_TOP)")
+	flags.String("assign-iteration", "", "name of the project iteration field whose current iteration the top items are assigned to (env: GITHUB_ASSIGN_ITERATION)")
+	flags.String("assign-milestone", "", "title of the open milestone the top items are assigned to in their repository (env: GITHUB_ASSIGN_MILESTONE)")
+	flags.Int("suggest-top", defaultSuggestTop, "number of top unassigned items the assignees reporter suggests assignees for (env: GITHUB_SUGGEST_TOP)")
+	flags.StringSlice("assignee-pool", nil, "logins the assignees reporter suggests, rather than everyone with open items assigned on the board (repeatable, env: GITHUB_ASSIGNEE_POOL)")
+	flags.Float64("inbox-threshold", 0, "upvotes that the inbox reporter lists items crossing, or 0 for --notify-threshold (env: GITHUB_INBOX_THRESHOLD)")
+	flags.Float64("inbox-velocity", defaultInboxVelocity, "times the median velocity that the inbox reporter lists items rising faster than, or 0 to list none (env: GITHUB_INBOX_VELOCITY)")
+	flags.Float64("duplicate-threshold", 0, "combined upvotes a probable duplicate cluster needs to be reported by the duplicates reporter (env: GITHUB_DUPLICATE_THRESHOLD)")
+	flags.String("first-response-field", "", "name of the project number field to write the hours until each item's first response from a maintainer to (env: GITHUB_FIRST_RESPONSE_FIELD)")
+	flags.String("last-activity-field", "", "name of the project number field to write the days since each item was last commented on to (env: GITHUB_LAST_ACTIVITY_FIELD)")
+	flags.String("estimate-field", "", "name of the project number field holding each item's estimate (env: GITHUB_ESTIMATE_FIELD)")
+	flags.String("format", "html", "format of the report written by the report command: html or markdown")
+	flags.String("report-file", "", "path the report command writes to; defaults to report.html or report.md")
+	flags.Int("report-runs", 30, "number of recent runs the report command shows trends over")
+	flags.Duration("schedule", 24*time.Hour, "time until the next scheduled run, used to forecast whether it fits in the rate limit (env: GITHUB_SCHEDULE)")
+	flags.Duration("interval", time.Hour, "time between runs of the daemon command (env: GITHUB_INTERVAL)")
+	flags.String("listen", ":8080", "address the serve command serves tenant status and metrics on (env: GITHUB_LISTEN)")
+	flags.Int("sample", 25, "number of items the canary command recalculates")
+	flags.Float64("canary-tolerance", 0.1, "mean relative drift the canary command tolerates, for example 0.1 for 10%")
+	flags.StringSlice("exclude-repo", nil, "repository, as owner/name, whose items are skipped rather than scored (repeatable, env: GITHUB_EXCLUDE_REPOS)")
+	flags.String("exclude-repo-query", "", "repository search, such as org:acme topic:internal, whose repositories are excluded as --exclude-repo ones are (env: GITHUB_EXCLUDE_REPO_QUERY)")
+	flags.String("repo-query", "", "repository search, such as org:acme topic:product, that limits scoring to the repositories it finds (env: GITHUB_REPO_QUERY)")
+	flags.Duration("repo-cache-ttl", 24*time.Hour, "how long the repositories found by --exclude-repo-query and --repo-query are reused before searching again (env: GITHUB_REPO_CACHE_TTL)")
+	flags.StringSlice("terminal-status", nil, "value of the project's Status field whose items are skipped rather than scored, such as Done (repeatable, env: GITHUB_TERMINAL_STATUSES)")
+	flags.Bool("check-schema", false, "check that GitHub's GraphQL schema still has the types and fields the tool relies on before running (env: GITHUB_CHECK_SCHEMA)")
+	flags.Bool("enrich", false, "fetch the title, number, repository, and assignees of each item, to name items in reports and notifications (env: GITHUB_ENRICH)")
+	flags.StringToString("extra-field", nil, "additional GraphQL selection on ProjectV2Item to fetch for each item, as name=selection (repeatable)")
 
-		logger := slog.New(slog.NewTextHandler(os.Stdout, opts))
-		slog.SetDefault(logger)
+	return flags
+}
+
+// loadConfig parses the command line flags, ensures that the required variables have been supplied,
+// and returns them as a Config
+func loadConfig(args []string) (Config, error) {
+	var cfg Config
+
+	flags := newFlagSet()
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	cfg.Command = flags.Arg(0)
+	if cfg.Command == "" {
+		cfg.Command = "run"
+	}
+	if flags.NArg() > 1 {
+		cfg.Args = flags.Args()[1:]
 	}
 
 	viper.SetEnvPrefix("GITHUB")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
 
-	for _, v := range []string{"TOKEN", "PROJECT_ID", "FIELD_ID"} {
-		if !viper.IsSet(v) {
-			return fmt.Errorf("missing required environment variable: GITHUB_%v", v)
+	// matches GitHub's environment variable for Actions debugging
+	if err := viper.BindEnv("debug", "RUNNER_DEBUG"); err != nil {
+		return cfg, err
+	}
+
+	// when run as a container action, inputs are passed as INPUT_ environment variables, which are used
+	// if the GITHUB_ variable is not set
+	var bindErr error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		key := flagKey(flag.Name)
+		if err := viper.BindPFlag(key, flag); err != nil {
+			bindErr = errors.Join(bindErr, err)
+		}
+		if key == "debug" {
+			return
+		}
+		if err := viper.BindEnv(key, "GITHUB_"+strings.ToUpper(key), "INPUT_"+strings.ToUpper(key)); err != nil {
+			bindErr = errors.Join(bindErr, err)
+		}
+	})
+	if bindErr != nil {
+		return cfg, bindErr
+	}
+
+	if path := viper.GetString("config"); path != "" {
+		viper.SetConfigFile(path)
+		if err := viper.ReadInConfig(); err != nil {
+			return cfg, fmt.Errorf("reading config file: %w", err)
 		}
 	}
 
+	cfg.Debug = viper.IsSet("debug")
+	setupLogging(cfg.Debug)
+
+	return readConfig(Config{Command: cfg.Command, Args: cfg.Args, Debug: cfg.Debug, ConfigFile: viper.GetString("config")})
+}
+
+// reloadConfig returns the configuration with data as the config file's contents
+func reloadConfig(cfg Config, data []byte) (Config, error) {
+	if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return cfg, fmt.Errorf("reading config file: %w", err)
+	}
+	return readConfig(Config{Command: cfg.Command, Args: cfg.Args, Debug: cfg.Debug, ConfigFile: cfg.ConfigFile})
+}
+
+// restoreConfig restores the config file's previous contents after reloadConfig failed
+func restoreConfig(previous []byte) error {
+	if err := viper.ReadConfig(bytes.NewReader(previous)); err != nil {
+		return fmt.Errorf("restoring previous config: %w", err)
+	}
 	return nil
 }
+
+// readConfig returns the configuration for the command from the flags, environment variables, and
+// config file read by viper. It is called again when the daemon reloads the config file.
+func readConfig(cfg Config) (Config, error) {
+	for _, v := range []string{"token", "project_id", "field_id"} {
+		if !viper.IsSet(v) && !offlineCommands[cfg.Command] && !selfCheckedCommands[cfg.Command] {
+			return cfg, fmt.Errorf("missing required flag or environment variable: GITHUB_%v", strings.ToUpper(v))
+		}
+	}
+
+	cfg.Token = viper.GetString("token")
+	cfg.ProjectID = githubv4.ID(viper.GetString("project_id"))
+	cfg.FieldID = githubv4.ID(viper.GetString("field_id"))
+	if project, field := viper.GetString("sync_project_id"), viper.GetString("sync_field_id"); project != "" || field != "" {
+		if project == "" || field == "" {
+			return cfg, errors.New("a roll-up project requires both --sync-project-id and --sync-field-id")
+		}
+		cfg.SyncProjectID, cfg.SyncFieldID = githubv4.ID(project), githubv4.ID(field)
+	}
+	apiURL, err := parseAPIURL(viper.GetString("api_url"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.APIURL = apiURL
+	cfg.Proxy = viper.GetString("proxy")
+	cfg.UserAgent = viper.GetString("user_agent")
+	cfg.Headers = viper.GetStringMapString("headers")
+	registerSecrets(cfg.Token)
+	for name, value := range cfg.Headers {
+		if strings.EqualFold(name, "Authorization") {
+			return cfg, errors.New("--header cannot set Authorization: requests are authenticated with GITHUB_TOKEN")
+		}
+		if sensitiveHeader(name) {
+			registerSecrets(value)
+		}
+	}
+	cfg.RequestTimeout = viper.GetDuration("request_timeout")
+	cfg.Retries = viper.GetInt("retries")
+	cfg.RetryBackoff = viper.GetDuration("retry_backoff")
+	if cfg.Retries < 0 || cfg.Retries > 10 {
+		return cfg, errors.New("--retries must be between 0 and 10")
+	}
+	if cfg.RetryBackoff < 0 {
+		return cfg, errors.New("--retry-backoff must not be negative")
+	}
+	cfg.BreakerThreshold = viper.GetInt("breaker_threshold")
+	cfg.BreakerCooldown = viper.GetDuration("breaker_cooldown")
+	cfg.SummaryFile = viper.GetString("summary_file")
+	cfg.OrgAuditRepo = viper.GetString("org_audit_repo")
+	cfg.OrgAuditPath = viper.GetString("org_audit_path")
+	cfg.OrgAuditIssue = viper.GetInt("org_audit_issue")
+	if cfg.OrgAuditRepo != "" {
+		if err := validateOrgAuditRepo(cfg.OrgAuditRepo); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.ReportTemplate = viper.GetString("report_template")
+	cfg.GroupBy = viper.GetString("group_by")
+	cfg.GroupTop = viper.GetInt("group_top")
+	cfg.MilestoneBudget = viper.GetFloat64("milestone_budget")
+	cfg.MilestoneCapacity = viper.GetInt("milestone_capacity")
+	cfg.AssignTop = viper.GetInt("assign_top")
+	cfg.AssignIteration = viper.GetString("assign_iteration")
+	cfg.AssignMilestone = viper.GetString("assign_milestone")
+	switch {
+	case cfg.AssignTop < 0:
+		return cfg, fmt.Errorf("invalid assign top %d: must not be negative", cfg.AssignTop)
+	case cfg.AssignTop > 0 && cfg.AssignIteration == "" && cfg.AssignMilestone == "":
+		return cfg, errors.New("--assign-top requires --assign-iteration or --assign-milestone")
+	}
+	cfg.SuggestTop = viper.GetInt("suggest_top")
+	cfg.AssigneePool = viper.GetStringSlice("assignee_pool")
+	cfg.InboxThreshold = viper.GetFloat64("inbox_threshold")
+	cfg.InboxVelocity = viper.GetFloat64("inbox_velocity")
+	cfg.EstimateField = viper.GetString("estimate_field")
+	cfg.FirstResponseField = viper.GetString("first_response_field")
+	cfg.LastActivityField = viper.GetString("last_activity_field")
+	cfg.DuplicateThreshold = viper.GetFloat64("duplicate_threshold")
+	cfg.ReportFormat = viper.GetString("format")
+	cfg.ReportFile = viper.GetString("report_file")
+	cfg.ReportRuns = viper.GetInt("report_runs")
+	cfg.Interval = viper.GetDuration("interval")
+	cfg.Listen = viper.GetString("listen")
+	cfg.Schedule = viper.GetDuration("schedule")
+	cfg.Sample = viper.GetInt("sample")
+	cfg.CanaryTolerance = viper.GetFloat64("canary_tolerance")
+	cfg.StepSummary = viper.GetString("step_summary")
+	cfg.EventPath = viper.GetString("event_path")
+	cfg.ExtraFields = viper.GetStringMapString("extra_fields")
+	cfg.Enrich = viper.GetBool("enrich")
+	cfg.CheckSchema = viper.GetBool("check_schema")
+	cfg.ExcludeRepos = viper.GetStringSlice("exclude_repos")
+	cfg.TerminalStatuses = viper.GetStringSlice("terminal_statuses")
+	cfg.ExcludeRepoQuery = viper.GetString("exclude_repo_query")
+	cfg.RepoQuery = viper.GetString("repo_query")
+	cfg.RepoCacheTTL = viper.GetDuration("repo_cache_ttl")
+	cfg.StateDir = viper.GetString("state_dir")
+	cfg.StateURL = viper.GetString("state_url")
+	cfg.Series = viper.GetBool("series")
+	cfg.StateToken = viper.GetString("state_token")
+	registerSecrets(cfg.StateToken)
+	cfg.OutputDir = viper.GetString("output_dir")
+	cfg.RunID = viper.GetString("run_id")
+	cfg.RunAttempt = viper.GetInt("run_attempt")
+	cfg.ReadOnly = viper.GetBool("read_only")
+	cfg.DryRun = viper.GetBool("dry_run")
+	cfg.PlanFile = viper.GetString("plan_file")
+	cfg.MaxUpdates = viper.GetInt("max_updates")
+	if cfg.MaxUpdates < 0 {
+		return cfg, fmt.Errorf("invalid max updates %d: must not be negative", cfg.MaxUpdates)
+	}
+	cfg.Plan = viper.GetString("plan")
+	cfg.Confirm = viper.GetBool("confirm")
+	cfg.ResetToZero = viper.GetBool("reset_to_zero")
+	resetFilter, err := parseResetFilter(viper.GetString("reset_filter"))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid reset filter: %w", err)
+	}
+	cfg.ResetFilter = resetFilter
+	cfg.SweepThreshold = viper.GetInt("sweep_threshold")
+	cfg.Unarchive = viper.GetBool("unarchive")
+	cfg.AdjustmentsFile = viper.GetString("adjustments")
+	cfg.ScoreOverrideField = viper.GetString("score_override_field")
+	cfg.MinScoreField = viper.GetString("min_score_field")
+	cfg.MaxScoreField = viper.GetString("max_score_field")
+	cfg.Explain = viper.GetBool("explain")
+	cfg.DumpDir = viper.GetString("dump")
+	cfg.RescoreFrom = viper.GetString("from")
+	cfg.CurrentValues = viper.GetBool("current_values")
+	cfg.ProfileA = viper.GetString("profile_a")
+	cfg.ProfileB = viper.GetString("profile_b")
+	cfg.Age = viper.GetBool("age")
+	cfg.RecalculateAll = viper.GetBool("recalculate_all")
+	cfg.MinDelta = viper.GetFloat64("min_delta")
+	cfg.Cache = viper.GetBool("cache")
+	cfg.CacheTTL = viper.GetDuration("cache_ttl")
+	cfg.SkipUnmodified = viper.GetBool("skip_unmodified")
+	if cfg.Cache && cfg.StateDir == "" && cfg.StateURL == "" {
+		return cfg, errors.New("--cache requires --state-dir or --state-url")
+	}
+	cfg.ReservePoints = viper.GetInt("reserve_points")
+	cfg.RoundTo = viper.GetFloat64("round_to")
+
+	cfg.Scoring = DefaultScoringProfile()
+	if err := viper.UnmarshalKey("scoring", &cfg.Scoring); err != nil {
+		return cfg, fmt.Errorf("reading scoring profile: %w", err)
+	}
+	if viper.IsSet("rollup_weight") {
+		cfg.Scoring.Rollup = viper.GetFloat64("rollup_weight")
+	}
+
+	cfg.Rollout = Rollout{}
+	if viper.IsSet("rollout.profile") {
+		profile := DefaultScoringProfile()
+		if err := viper.UnmarshalKey("rollout.profile", &profile); err != nil {
+			return cfg, fmt.Errorf("reading rollout profile: %w", err)
+		}
+		cfg.Rollout.Profile = &profile
+	}
+	cfg.Rollout.Percent = viper.GetFloat64("rollout.percent")
+	if viper.IsSet("rollout_percent") {
+		cfg.Rollout.Percent = viper.GetFloat64("rollout_percent")
+	}
+	if err := cfg.Rollout.validate(); err != nil {
+		return cfg, err
+	}
+
+	cfg.Inactivity = Inactivity{Days: viper.GetInt("inactive_days"), Factor: viper.GetFloat64("inactive_factor")}
+	if err := cfg.Inactivity.validate(); err != nil {
+		return cfg, err
+	}
+
+	cfg.ConflictPolicy = viper.GetString("conflict_policy")
+	switch cfg.ConflictPolicy {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+	default:
+		return cfg, fmt.Errorf("invalid conflict policy %q: must be skip, overwrite, or fail", cfg.ConflictPolicy)
+	}
+	cfg.Ranges = viper.GetInt("ranges")
+	cfg.RangeFile = viper.GetString("range_file")
+	cfg.RangeIndex = viper.GetInt("range")
+	cfg.CheckpointFile = viper.GetString("checkpoint_file")
+	if cfg.CheckpointFile != "" && cfg.RangeIndex >= 0 {
+		return cfg, errors.New("--checkpoint-file cannot be combined with --range, which already sets where the run starts")
+	}
+	if cfg.CheckpointFile != "" && !cfg.Cache {
+		return cfg, errors.New("--checkpoint-file requires --cache, which resumes the timelines of the items already counted")
+	}
+	if cfg.CheckpointFile != "" && cfg.MaxUpdates > 0 {
+		return cfg, errors.New("--checkpoint-file cannot be combined with --max-updates, whose updates are only written once every item is scored")
+	}
+
+	cfg.Notifiers = viper.GetStringSlice("notifiers")
+	cfg.NotifyThreshold = viper.GetFloat64("notify_threshold")
+	cfg.NotifyDelta = viper.GetFloat64("notify_delta")
+	if cfg.NotifyThreshold < 0 || cfg.NotifyDelta < 0 {
+		return cfg, errors.New("--notify-threshold and --notify-delta must not be negative")
+	}
+	cfg.DropAlert = viper.GetFloat64("drop_alert")
+	cfg.Digest = viper.GetBool("digest")
+
+	cfg.Redact = viper.GetString("redact")
+	switch cfg.Redact {
+	case RedactAuto, RedactAlways, RedactNever:
+	default:
+		return cfg, fmt.Errorf("invalid redaction %q: must be auto, always, or never", cfg.Redact)
+	}
+	cfg.DigestInterval = viper.GetDuration("digest_interval")
+
+	cfg.NotifyTemplate = viper.GetString("notify_template")
+	if path, ok := strings.CutPrefix(cfg.NotifyTemplate, "@"); ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("reading notification template: %w", err)
+		}
+		cfg.NotifyTemplate = string(b)
+	}
+
+	locale, err := loadLocale(viper.GetString("locale"), viper.GetString("locale_file"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Locale = locale
+
+	cfg.Export = ExportConfig{
+		KeyField:    viper.GetString("export.key_field"),
+		MappingFile: viper.GetString("export.mapping_file"),
+		Jira: JiraConfig{
+			URL:   viper.GetString("export.jira.url"),
+			User:  viper.GetString("export.jira.user"),
+			Token: viper.GetString("export.jira.token"),
+			Field: viper.GetString("export.jira.field"),
+		},
+		Linear: LinearConfig{
+			Token: viper.GetString("export.linear.token"),
+		},
+	}
+
+	registerSecrets(cfg.Export.Jira.Token, cfg.Export.Linear.Token)
+
+	if err := viper.UnmarshalKey("segments", &cfg.Segments); err != nil {
+		return cfg, fmt.Errorf("reading segments: %w", err)
+	}
+
+	if err := viper.UnmarshalKey("rules", &cfg.Rules); err != nil {
+		return cfg, fmt.Errorf("reading rules: %w", err)
+	}
+
+	if err := viper.UnmarshalKey("tenants", &cfg.Tenants); err != nil {
+		return cfg, fmt.Errorf("reading tenants: %w", err)
+	}
+
+	if err := viper.UnmarshalKey("teams", &cfg.Teams); err != nil {
+		return cfg, fmt.Errorf("reading teams: %w", err)
+	}
+	teams, err := normalizeTeams(cfg.Teams)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Teams = teams
+
+	if err := viper.UnmarshalKey("issue_fields", &cfg.IssueFields); err != nil {
+		return cfg, fmt.Errorf("reading issue fields: %w", err)
+	}
+	fields, err := normalizeIssueFields(cfg.IssueFields)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.IssueFields = fields
+
+	if err := viper.UnmarshalKey("keywords", &cfg.Keywords); err != nil {
+		return cfg, fmt.Errorf("reading keywords: %w", err)
+	}
+	keywords, err := normalizeKeywords(cfg.Keywords)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Keywords = keywords
+
+	if err := viper.UnmarshalKey("owners", &cfg.Owners); err != nil {
+		return cfg, fmt.Errorf("reading owners: %w", err)
+	}
+	owners, err := normalizeOwners(cfg.Owners)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Owners = owners
+
+	cfg.Reporters = viper.GetStringSlice("reporters")
+	if len(cfg.Reporters) == 0 {
+		cfg.Reporters = []string{"table"}
+		if cfg.StepSummary != "" {
+			cfg.Reporters = append(cfg.Reporters, "actions-summary")
+		}
+	}
+	cfg.Output = viper.GetString("output_file")
+	if cfg.Output != "" {
+		cfg.Reporters = append(cfg.Reporters, "json="+cfg.Output)
+	}
+
+	cfg.resolveOutputPaths()
+
+	return cfg, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyFile is the name of the score history file within the state directory
+const historyFile = "history.jsonl"
+
+// HistoryEntry is the score of every item calculated during a single run, and their distribution.
+// Entries are appended to the history file as one JSON object per line.
+type HistoryEntry struct {
+	RunID string                 `json:"run_id"`
+	Time  time.Time              `json:"time"`
+	Items map[string]HistoryItem `json:"items"`
+	Stats *ScoreStats            `json:"stats,omitempty"`
+}
+
+// HistoryItem is the score of a single item in a HistoryEntry
+type HistoryItem struct {
+	URL        string           `json:"url,omitempty"`
+	Upvotes    float64          `json:"upvotes"`
+	Components []ScoreComponent `json:"components,omitempty"`
+}
+
+// AppendHistory implements HistoryStore, appending the entry to the history file
+func (s FileStore) AppendHistory(entry HistoryEntry) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.Dir, historyFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening history file: %w", err)
+	}
+
+	_, err = f.Write(append(b, '\n'))
+	return errors.Join(err, f.Close())
+}
+
+// History implements HistoryStore, reading every entry in the history file. A missing file returns no
+// entries.
+func (s FileStore) History() ([]HistoryEntry, error) {
+	f, err := os.Open(filepath.Join(s.Dir, historyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening history file: %w", err)
+	}
+	defer f.Close()
+
+	return readHistory(f)
+}
+
+// historyReporter appends the score of every item calculated during the run to the history
+type historyReporter struct {
+	collector
+	store HistoryStore
+}
+
+// Finish appends the entry
+func (h *historyReporter) Finish(summary Summary) error {
+	entry := HistoryEntry{
+		RunID: h.run.ID,
+		Time:  h.run.StartedAt.UTC(),
+		Items: make(map[string]HistoryItem),
+		Stats: summary.Stats,
+	}
+
+	for _, result := range h.results {
+		if !exportable(result) {
+			continue
+		}
+		entry.Items[fmt.Sprint(result.ItemID)] = HistoryItem{URL: result.Content.URL, Upvotes: result.Upvotes, Components: result.Components}
+	}
+
+	if len(entry.Items) == 0 {
+		return nil
+	}
+
+	return h.store.AppendHistory(entry)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activityFile is the name of the file in the state directory that the last engagement with each item
+// is kept in
+const activityFile = "activity.json"
+
+// inactiveComponent is the name of the component recording how many days an item has gone without new
+// engagement, which, once weighed, reduces the score of an inactive item
+const inactiveComponent = "inactive"
+
+// ActivityEntry is the engagement counted for an item when it was last seen, and when it last grew
+type ActivityEntry struct {
+	Engagement     float64   `json:"engagement"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+}
+
+// ActivityLog keeps when each item last had new engagement: new comments, reactions, or timeline items.
+// Engagement is counted afresh each run, and an item's last activity moves to the time of the run in
+// which its count grew. A nil ActivityLog keeps nothing.
+type ActivityLog struct {
+	mu      sync.Mutex
+	entries map[string]ActivityEntry
+}
+
+// LoadActivityLog reads the activity log from the store
+func LoadActivityLog(store StateStore) (*ActivityLog, error) {
+	a := &ActivityLog{entries: make(map[string]ActivityEntry)}
+	if err := store.Load(activityFile, &a.entries); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Observe records the item's engagement, and returns when it last grew. The first time an item is seen,
+// its last activity is taken to be when its Issue or Pull Request was last updated.
+func (a *ActivityLog) Observe(id string, engagement float64, updatedAt, now time.Time) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.entries[id]
+	switch {
+	case !ok:
+		entry.LastActivityAt = updatedAt
+		if updatedAt.IsZero() {
+			entry.LastActivityAt = now
+		}
+	case engagement > entry.Engagement:
+		entry.LastActivityAt = now
+	}
+	entry.Engagement = engagement
+
+	a.entries[id] = entry
+	return entry.LastActivityAt
+}
+
+// Save writes the activity log to the store
+func (a *ActivityLog) Save(store StateStore) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return store.Save(activityFile, a.entries)
+}
+
+// engagement returns the comments, reactions, and timeline items among the unweighted components
+func engagement(components []ScoreComponent) float64 {
+	var total float64
+	for _, c := range components {
+		if c.Name == "comments" || c.Name == "reactions" || strings.HasPrefix(c.Name, "timeline:") {
+			total += c.Value
+		}
+	}
+	return total
+}
+
+// inactiveComponents returns the component recording the days since the item's last new engagement,
+// or nothing if no activity log is kept
+func (a *ActivityLog) inactiveComponents(item Item, content ContentFragment, components []ScoreComponent, now time.Time) []ScoreComponent {
+	if a == nil {
+		return nil
+	}
+
+	last := a.Observe(fmt.Sprint(item.Id), engagement(components), content.UpdatedAt.Time, now)
+	days := float64(int(now.Sub(last).Hours() / 24))
+	return []ScoreComponent{{Name: inactiveComponent, Value: days}}
+}
+
+// Inactivity reduces the scores of items that have had no new engagement for a number of days
+type Inactivity struct {
+	// Days without new engagement after which an item is inactive. Zero turns inactivity off.
+	Days int
+
+	// Factor multiplies the score of an inactive item. 1 flags inactive items without reducing their
+	// scores.
+	Factor float64
+}
+
+// validate returns an error if the days or factor are out of range
+func (i Inactivity) validate() error {
+	if i.Days < 0 {
+		return fmt.Errorf("invalid inactive days %d: must not be negative", i.Days)
+	}
+	if i.Factor < 0 || i.Factor > 1 {
+		return fmt.Errorf("invalid inactive factor %v: must be from 0 to 1", i.Factor)
+	}
+	return nil
+}
+
+// apply replaces the component recording the days since the item's last new engagement with the
+// reduction of an inactive item's score, or drops it if the item is active or inactivity is off
+func (i Inactivity) apply(components []ScoreComponent) []ScoreComponent {
+	var days float64
+	var found bool
+	kept := make([]ScoreComponent, 0, len(components))
+	for _, c := range components {
+		if c.Name == inactiveComponent {
+			days, found = c.Value, true
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	if !found || i.Days <= 0 || days < float64(i.Days) {
+		return kept
+	}
+
+	total := Total(kept)
+	return append(kept, ScoreComponent{
+		Name:   inactiveComponent,
+		Value:  total*i.Factor - total,
+		Reason: fmt.Sprintf("no new engagement for %g days, x %g", days, i.Factor),
+	})
+}
+
+// isInactive returns true if the weighed components include the reduction of an inactive item's score
+func isInactive(components []ScoreComponent) bool {
+	for _, c := range components {
+		if c.Name == inactiveComponent {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestInactivity runs a fresh synthetic project whose items last had new engagement ten days ago,
+// apart from the second item, whose engagement has since grown, and checks that the scores of every
+// other item are halved
+func TestInactivity(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Inactivity = Inactivity{Days: 7, Factor: 0.5}
+	server := newFakeGitHub()
+
+	seen := time.Now().Add(-10 * 24 * time.Hour)
+	entries := make(map[string]ActivityEntry)
+	for i, item := range server.items {
+		entries[item.id] = ActivityEntry{Engagement: item.upvotes(), LastActivityAt: seen}
+		if i+1 == 2 {
+			entries[item.id] = ActivityEntry{Engagement: item.upvotes() - 1, LastActivityAt: seen}
+		}
+	}
+	store := NewMemoryStore()
+	if err := store.Save(activityFile, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := newEngineWithStore(cfg, &http.Client{Transport: server}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, item := range server.items {
+		if item.closed || item.archived {
+			continue
+		}
+
+		want := item.upvotes() * 0.5
+		if i+1 == 2 {
+			want = item.upvotes()
+		}
+		if got := finalValue(server, item); got != want {
+			t.Errorf("%s: expected %v, got %v", item.id, want, got)
+		}
+	}
+}
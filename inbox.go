@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultInboxVelocity is how many times the median velocity an item's velocity must be to be listed as
+// rising fast, unless another factor is configured
+const defaultInboxVelocity = 3
+
+// minInboxVelocity is the median velocity that velocities are compared with when the median is lower, so
+// that a board of mostly quiet items does not list every item with a few upvotes as rising fast
+const minInboxVelocity = 1
+
+// Inbox is the set of items that need a maintainer's attention after a run, each listed once, in the
+// first of its sections that applies: items that need a look because they failed, conflicted, can no
+// longer be read, or are archived but active again; items whose upvotes crossed the threshold; and items
+// whose velocity is abnormally high compared with the rest of the board.
+type Inbox struct {
+	Threshold float64
+	Median    float64
+	Broken    []Result
+	Crossed   []Result
+	Rising    []Result
+}
+
+// NewInbox picks the items that need attention from the results. Items crossing the threshold are
+// listed only if the threshold is above zero, and items rising fast only if the factor is.
+func NewInbox(results []Result, threshold, factor float64) Inbox {
+	inbox := Inbox{Threshold: threshold}
+
+	var velocities []float64
+	for _, result := range results {
+		if exportable(result) {
+			velocities = append(velocities, velocity(result))
+		}
+	}
+	inbox.Median = median(velocities)
+
+	for _, result := range results {
+		switch {
+		case needsLook(result):
+			inbox.Broken = append(inbox.Broken, result)
+		case !exportable(result):
+		case threshold > 0 && result.Previous < threshold && result.Upvotes >= threshold:
+			inbox.Crossed = append(inbox.Crossed, result)
+		case factor > 0 && velocity(result) >= factor*max(inbox.Median, minInboxVelocity):
+			inbox.Rising = append(inbox.Rising, result)
+		}
+	}
+
+	sort.SliceStable(inbox.Crossed, func(i, j int) bool {
+		return inbox.Crossed[i].Upvotes > inbox.Crossed[j].Upvotes
+	})
+	sort.SliceStable(inbox.Rising, func(i, j int) bool {
+		return velocity(inbox.Rising[i]) > velocity(inbox.Rising[j])
+	})
+
+	return inbox
+}
+
+// needsLook returns true if the item could not be scored as it should have been, or is archived but
+// active again
+func needsLook(result Result) bool {
+	switch result.Status {
+	case StatusFailed, StatusConflict, StatusNoAccess, StatusArchivedActive:
+		return true
+	}
+	return false
+}
+
+// median returns the median of the values, or zero if there are none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
+
+// Len returns the number of items in the inbox
+func (i Inbox) Len() int {
+	return len(i.Broken) + len(i.Crossed) + len(i.Rising)
+}
+
+// Markdown returns the inbox as a Markdown checklist, with a section for each reason items are listed, in
+// the language of the locale
+func (i Inbox) Markdown(runID string, l *Locale) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", l.T("inbox.title"))
+
+	if i.Len() == 0 {
+		fmt.Fprintf(&b, "%s\n", l.T("inbox.empty", runID))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s\n", l.T("inbox.count", i.Len(), runID))
+
+	if len(i.Broken) > 0 {
+		fmt.Fprintf(&b, "\n#### %s\n\n", l.T("inbox.broken"))
+		for _, result := range i.Broken {
+			fmt.Fprintf(&b, "- [ ] %s: %s\n", markdownLink(result), brokenReason(result, l))
+		}
+	}
+
+	if len(i.Crossed) > 0 {
+		fmt.Fprintf(&b, "\n#### %s\n\n", l.T("inbox.crossed", i.Threshold))
+		for _, result := range i.Crossed {
+			fmt.Fprintf(&b, "- [ ] %s: %s\n", markdownLink(result), l.T("inbox.crossed_item", result.Previous, result.Upvotes))
+		}
+	}
+
+	if len(i.Rising) > 0 {
+		fmt.Fprintf(&b, "\n#### %s\n\n", l.T("inbox.rising"))
+		for _, result := range i.Rising {
+			fmt.Fprintf(&b, "- [ ] %s: %s\n", markdownLink(result), l.T("inbox.rising_item", velocity(result), i.Median))
+		}
+	}
+
+	return b.String()
+}
+
+// brokenReason describes why the item needs a look
+func brokenReason(result Result, l *Locale) string {
+	switch result.Status {
+	case StatusFailed:
+		return l.T("inbox.failed", result.Err)
+	case StatusConflict:
+		return l.T("inbox.conflict")
+	case StatusNoAccess:
+		return l.T("inbox.no_access")
+	}
+	return l.T("inbox.archived_active")
+}
+
+// inboxReporter writes the items that need a maintainer's attention as a Markdown checklist, for a daily
+// triage routine, rather than a table of every item
+type inboxReporter struct {
+	collector
+	path      string
+	threshold float64
+	velocity  float64
+	locale    *Locale
+}
+
+// Finish writes the checklist
+func (r *inboxReporter) Finish(summary Summary) error {
+	inbox := NewInbox(r.results, r.threshold, r.velocity)
+	return os.WriteFile(r.path, []byte(inbox.Markdown(r.run.ID, r.locale)), 0o644)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestInbox builds the inbox from an item that failed, one that crossed the threshold, one rising far
+// faster than the rest, and quiet ones, and checks that each is listed once in its section
+func TestInbox(t *testing.T) {
+	created := time.Now().Add(-14 * 24 * time.Hour)
+	item := func(id string, status Status, previous, upvotes float64) Result {
+		return Result{ItemID: id, Status: status, Previous: previous, Upvotes: upvotes, Content: ContentInfo{CreatedAt: created}}
+	}
+	results := []Result{
+		item("PVTI_failed", StatusFailed, 150, 0),
+		item("PVTI_crossed", StatusUpdated, 90, 110),
+		item("PVTI_rising", StatusUpdated, 20, 60),
+		item("PVTI_quiet1", StatusUnchanged, 4, 4),
+		item("PVTI_quiet2", StatusUnchanged, 6, 6),
+		item("PVTI_quiet3", StatusUnchanged, 8, 8),
+		item("PVTI_closed", StatusSkippedClosed, 500, 500),
+	}
+	results[0].Err = errors.New("boom")
+
+	inbox := NewInbox(results, 100, 3)
+	expectCount(t, "items in the inbox", inbox.Len(), 3)
+	markdown := inbox.Markdown("selftest", nil)
+	for _, want := range []string{"- [ ] PVTI_failed: could not be scored: boom", "#### Crossed 100 upvotes\n\n- [ ] PVTI_crossed: 90 -> 110 upvotes\n", "#### Rising fast\n\n- [ ] PVTI_rising:"} {
+		if !strings.Contains(markdown, want) {
+			t.Fatalf("expected the inbox to contain %q, got:\n%s", want, markdown)
+		}
+	}
+
+	if markdown := NewInbox(results[3:], 100, 3).Markdown("selftest", nil); !strings.Contains(markdown, "Nothing needs attention") {
+		t.Fatalf("expected an empty inbox, got:\n%s", markdown)
+	}
+}
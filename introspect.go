@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// requiredSchema lists the GraphQL types and fields that the tool relies on, by type
+var requiredSchema = map[string][]string{
+	"Query":                               {"node", "nodes", "rateLimit", "search"},
+	"Mutation":                            {"updateProjectV2ItemFieldValue", "unarchiveProjectV2Item", "addComment", "addLabelsToLabelable"},
+	"ProjectV2":                           {"items", "viewerCanUpdate", "public", "field"},
+	"ProjectV2Item":                       {"id", "isArchived", "type", "updatedAt", "fieldValueByName", "content"},
+	"ProjectV2ItemFieldNumberValue":       {"number"},
+	"ProjectV2ItemFieldSingleSelectValue": {"name"},
+	"Issue":                               {"id", "url", "closed", "createdAt", "updatedAt", "labels", "comments", "reactions", "timelineItems", "title", "number", "repository", "assignees", "trackedIssues", "milestone"},
+	"PullRequest":                         {"id", "url", "closed", "createdAt", "updatedAt", "labels", "comments", "reactions", "timelineItems", "title", "number", "repository", "assignees", "milestone", "files"},
+	"IssueComment":                        {"reactions"},
+	"ConnectedEvent":                      {"source"},
+	"CrossReferencedEvent":                {"source"},
+	"MarkedAsDuplicateEvent":              {"canonical"},
+	"ReferencedEvent":                     {"isCrossRepository", "actor", "commit"},
+	"Commit":                              {"associatedPullRequests"},
+	"RateLimit":                           {"limit", "remaining", "cost", "resetAt"},
+}
+
+// SchemaProblem is a type or field the tool relies on that is missing from, or deprecated in, GitHub's
+// GraphQL schema
+type SchemaProblem struct {
+	Type       string
+	Field      string
+	Missing    bool
+	Deprecated string
+}
+
+// String describes the problem
+func (p SchemaProblem) String() string {
+	name := p.Type
+	if p.Field != "" {
+		name += "." + p.Field
+	}
+
+	if p.Missing {
+		return name + " is missing from the schema"
+	}
+	return fmt.Sprintf("%s is deprecated: %s", name, p.Deprecated)
+}
+
+// CheckSchema introspects the types and fields the tool relies on, and returns those that are missing
+// or deprecated, ordered by type and field
+func CheckSchema(ctx context.Context, client *http.Client, url string) ([]SchemaProblem, error) {
+	types := make([]string, 0, len(requiredSchema))
+	for name := range requiredSchema {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	var b strings.Builder
+	b.WriteString("query {")
+	for i, name := range types {
+		fmt.Fprintf(&b, " t%d: __type(name: %q) { name fields(includeDeprecated: true) { name isDeprecated deprecationReason } }", i, name)
+	}
+	b.WriteString(" }")
+
+	type introspectedType struct {
+		Name   string
+		Fields []struct {
+			Name              string
+			IsDeprecated      bool
+			DeprecationReason string
+		}
+	}
+	data := make(map[string]*introspectedType)
+	if err := rawQuery(ctx, client, url, b.String(), nil, &data); err != nil {
+		return nil, fmt.Errorf("introspecting schema: %w", err)
+	}
+
+	var problems []SchemaProblem
+	for i, name := range types {
+		introspected := data[fmt.Sprintf("t%d", i)]
+		if introspected == nil {
+			problems = append(problems, SchemaProblem{Type: name, Missing: true})
+			continue
+		}
+
+		fields := make(map[string]SchemaProblem)
+		for _, field := range introspected.Fields {
+			fields[field.Name] = SchemaProblem{Type: name, Field: field.Name, Deprecated: field.DeprecationReason}
+			if field.IsDeprecated && field.DeprecationReason == "" {
+				fields[field.Name] = SchemaProblem{Type: name, Field: field.Name, Deprecated: "no reason given"}
+			}
+		}
+
+		required := append([]string{}, requiredSchema[name]...)
+		sort.Strings(required)
+		for _, field := range required {
+			problem, ok := fields[field]
+			switch {
+			case !ok:
+				problems = append(problems, SchemaProblem{Type: name, Field: field, Missing: true})
+			case problem.Deprecated != "":
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// checkSchema logs the deprecated types and fields the tool relies on, and returns an error listing
+// any that are missing
+func (e *Engine) checkSchema(ctx context.Context) error {
+	problems, err := CheckSchema(ctx, e.client, e.cfg.apiURL())
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, problem := range problems {
+		if problem.Missing {
+			missing = append(missing, problem.String())
+			continue
+		}
+		slog.WarnContext(ctx, "GitHub's GraphQL schema deprecates a field the tool relies on", "type", problem.Type, "field", problem.Field, "reason", problem.Deprecated)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("GitHub's GraphQL schema has changed: %s", strings.Join(missing, "; "))
+	}
+
+	return nil
+}
+
+// schemaErrorPatterns match the errors returned when a query no longer matches GitHub's GraphQL schema,
+// either rejected by GitHub or failing to unmarshal into the query's types
+var schemaErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`struct field for "[^"]+" doesn't exist in any of \d+ places to unmarshal`),
+	regexp.MustCompile(`Field '[^']+' doesn't exist on type '[^']+'`),
+	regexp.MustCompile(`No such type [^,]+, so it can't be a fragment condition`),
+	regexp.MustCompile(`Argument '[^']+' on Field '[^']+' has an invalid value`),
+}
+
+// ErrSchemaChanged is wrapped by errors caused by a change to GitHub's GraphQL schema
+var ErrSchemaChanged = errors.New("GitHub's GraphQL schema appears to have changed")
+
+// diagnoseSchemaError wraps errors caused by a change to GitHub's GraphQL schema with ErrSchemaChanged
+// and a pointer to --check-schema, since the underlying errors are obscure. Other errors are returned
+// unchanged.
+func diagnoseSchemaError(err error) error {
+	if err == nil || errors.Is(err, ErrSchemaChanged) {
+		return err
+	}
+
+	for _, pattern := range schemaErrorPatterns {
+		if pattern.MatchString(err.Error()) {
+			return fmt.Errorf("%w, run with --check-schema for details: %w", ErrSchemaChanged, err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// formComponent prefixes the name of the component recording the value of an issue form field, as in
+// form:severity=critical
+const formComponent = "form:"
+
+// IssueField is a field of an issue form, such as Severity, whose value in the body of an Issue or Pull
+// Request boosts the item's upvotes
+type IssueField struct {
+	// Field is the label of the field, as it appears in the body
+	Field string `mapstructure:"field"`
+
+	// Boosts are the points added to the upvotes of items for each value of the field, matched
+	// regardless of case
+	Boosts map[string]float64 `mapstructure:"boosts"`
+}
+
+// normalizeIssueFields lowercases the fields' labels and the values they boost, as both are matched
+// regardless of case, and returns an error if a field has no label or is listed more than once
+func normalizeIssueFields(fields []IssueField) ([]IssueField, error) {
+	seen := make(map[string]bool)
+	normalized := make([]IssueField, 0, len(fields))
+	for i, field := range fields {
+		field.Field = strings.ToLower(strings.TrimSpace(field.Field))
+		if field.Field == "" {
+			return nil, fmt.Errorf("issue_fields[%d]: field is required", i)
+		}
+		if seen[field.Field] {
+			return nil, fmt.Errorf("issue field %s is configured more than once", field.Field)
+		}
+		seen[field.Field] = true
+
+		boosts := make(map[string]float64, len(field.Boosts))
+		for value, points := range field.Boosts {
+			boosts[strings.ToLower(strings.TrimSpace(value))] = points
+		}
+		field.Boosts = boosts
+		normalized = append(normalized, field)
+	}
+	return normalized, nil
+}
+
+// formHeading matches the heading an issue form renders each field's label as, such as ### Severity
+var formHeading = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*$`)
+
+// formLine matches a field written on a single line, such as Severity: critical or **Severity:** critical
+var formLine = regexp.MustCompile(`^[*_]*([^:*_]+?)[*_]*\s*:\s*[*_]*(.+?)[*_]*\s*$`)
+
+// issueFieldValues returns the value of each of the fields found in the body, keyed by the field's
+// label, lowercased. A field is either rendered by an issue form, as a heading followed by its value,
+// or written on a single line as label: value. Fields left empty in an issue form are not returned.
+func issueFieldValues(body string, fields []IssueField) map[string]string {
+	wanted := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		wanted[field.Field] = true
+	}
+
+	values := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if match := formHeading.FindStringSubmatch(line); match != nil {
+			label := strings.ToLower(match[1])
+			if !wanted[label] {
+				continue
+			}
+			// the value is the first line after the heading that is not blank
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == "" {
+				i++
+			}
+			if i+1 < len(lines) {
+				value := strings.TrimSpace(lines[i+1])
+				if value != "_No response_" && !formHeading.MatchString(value) {
+					setFieldValue(values, label, value)
+				}
+			}
+			continue
+		}
+
+		if match := formLine.FindStringSubmatch(line); match != nil {
+			if label := strings.ToLower(strings.TrimSpace(match[1])); wanted[label] {
+				setFieldValue(values, label, match[2])
+			}
+		}
+	}
+
+	return values
+}
+
+// setFieldValue records the field's value, lowercased, unless the field was already found earlier in
+// the body
+func setFieldValue(values map[string]string, label, value string) {
+	if _, ok := values[label]; !ok {
+		values[label] = strings.ToLower(strings.TrimSpace(value))
+	}
+}
+
+// formComponents returns a component for the value of each of the fields found in the body of the
+// Issue or Pull Request, which is only fetched when issue fields are configured
+func formComponents(body string, fields []IssueField) []ScoreComponent {
+	values := issueFieldValues(body, fields)
+
+	var components []ScoreComponent
+	for _, field := range fields {
+		if value, ok := values[field.Field]; ok {
+			components = append(components, ScoreComponent{Name: formComponent + field.Field + "=" + value, Value: 1})
+		}
+	}
+	return components
+}
+
+// boostFields replaces the value of each issue field's component with the points its value is boosted
+// by, and drops the components of values that are not boosted, or of fields that are no longer
+// configured
+func boostFields(fields []IssueField, components []ScoreComponent) []ScoreComponent {
+	boosts := make(map[string]map[string]float64, len(fields))
+	for _, field := range fields {
+		boosts[field.Field] = field.Boosts
+	}
+
+	boosted := make([]ScoreComponent, 0, len(components))
+	for _, c := range components {
+		name, ok := strings.CutPrefix(c.Name, formComponent)
+		if !ok {
+			boosted = append(boosted, c)
+			continue
+		}
+
+		label, value, _ := strings.Cut(name, "=")
+		points, ok := boosts[label][value]
+		if !ok {
+			continue
+		}
+		c.Value = points
+		c.Reason = fmt.Sprintf("%s: %s", label, value)
+		boosted = append(boosted, c)
+	}
+	return boosted
+}
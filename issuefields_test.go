@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+// TestIssueFields runs a fresh synthetic project with a boost for critical severity, and checks that
+// only the items of critical severity gain it
+func TestIssueFields(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.IssueFields = []IssueField{{Field: "severity", Boosts: map[string]float64{"critical": 10}}}
+
+	expectBoosts(t, cfg, func(n int) float64 {
+		if n%5 == 0 {
+			return 10
+		}
+		return 0
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// keywordComponent prefixes the name of the component recording a keyword's match in the title or body
+const keywordComponent = "keyword:"
+
+// Keyword is a regular expression searched for in the title and body of each Issue or Pull Request,
+// which boosts the upvotes of the items it matches, or suppresses them with negative points
+type Keyword struct {
+	// Name names the keyword in explanations. It defaults to the pattern.
+	Name string `mapstructure:"name"`
+
+	// Pattern is the regular expression, matched regardless of case
+	Pattern string `mapstructure:"pattern"`
+
+	// Points are added to the upvotes of items that match, once however many times they match
+	Points float64 `mapstructure:"points"`
+
+	// In is where the pattern is searched for: title, body, or, by default, both
+	In string `mapstructure:"in"`
+
+	re *regexp.Regexp
+}
+
+// normalizeKeywords compiles the keywords' patterns, defaults their names, and returns an error if a
+// pattern is missing or invalid, a name is used more than once, or a keyword searches somewhere
+// unknown
+func normalizeKeywords(keywords []Keyword) ([]Keyword, error) {
+	seen := make(map[string]bool)
+	normalized := make([]Keyword, 0, len(keywords))
+	for i, keyword := range keywords {
+		if keyword.Pattern == "" {
+			return nil, fmt.Errorf("keywords[%d]: pattern is required", i)
+		}
+		re, err := regexp.Compile("(?i)" + keyword.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("keywords[%d]: invalid pattern: %w", i, err)
+		}
+		keyword.re = re
+
+		if keyword.Name == "" {
+			keyword.Name = keyword.Pattern
+		}
+		if seen[keyword.Name] {
+			return nil, fmt.Errorf("keyword %s is configured more than once", keyword.Name)
+		}
+		seen[keyword.Name] = true
+
+		switch keyword.In = strings.ToLower(keyword.In); keyword.In {
+		case "", "title", "body":
+		default:
+			return nil, fmt.Errorf("keyword %s: invalid in %q, expected title or body", keyword.Name, keyword.In)
+		}
+
+		normalized = append(normalized, keyword)
+	}
+	return normalized, nil
+}
+
+// match returns the first text the keyword matches in the title or body, and whether it matched
+func (k Keyword) match(title, body string) (string, bool) {
+	if k.re == nil {
+		return "", false
+	}
+	if k.In != "body" {
+		if m := k.re.FindString(title); m != "" {
+			return m, true
+		}
+	}
+	if k.In != "title" {
+		if m := k.re.FindString(body); m != "" {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// keywordComponents returns a component for each keyword matched in the title or body of the Issue or
+// Pull Request, with the text it matched as the reason
+func keywordComponents(title, body string, keywords []Keyword) []ScoreComponent {
+	if title == "" && body == "" {
+		return nil
+	}
+
+	var components []ScoreComponent
+	for _, keyword := range keywords {
+		if m, ok := keyword.match(title, body); ok {
+			components = append(components, ScoreComponent{Name: keywordComponent + keyword.Name, Value: 1, Reason: fmt.Sprintf("matched %q", m)})
+		}
+	}
+	return components
+}
+
+// weighKeywords replaces the value of each keyword's component with the keyword's points, and drops the
+// components of keywords that are no longer configured
+func weighKeywords(keywords []Keyword, components []ScoreComponent) []ScoreComponent {
+	points := make(map[string]float64, len(keywords))
+	for _, keyword := range keywords {
+		points[keyword.Name] = keyword.Points
+	}
+
+	weighed := make([]ScoreComponent, 0, len(components))
+	for _, c := range components {
+		name, ok := strings.CutPrefix(c.Name, keywordComponent)
+		if !ok {
+			weighed = append(weighed, c)
+			continue
+		}
+
+		p, ok := points[name]
+		if !ok {
+			continue
+		}
+		c.Value = p
+		weighed = append(weighed, c)
+	}
+	return weighed
+}
+
+// fetchText returns true if the title and body of each Issue or Pull Request are to be fetched, to
+// search them for issue fields and keywords
+func (cfg Config) fetchText() bool {
+	return len(cfg.IssueFields) > 0 || len(cfg.Keywords) > 0
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+// TestKeywords runs a fresh synthetic project with a keyword boosting items whose body mentions a
+// critical severity, and one suppressing questions, and checks that only the items they match gain or
+// lose their points
+func TestKeywords(t *testing.T) {
+	cfg := testConfig(t)
+	keywords, err := normalizeKeywords([]Keyword{
+		{Pattern: "critical", Points: 3, In: "body"},
+		{Name: "question", Pattern: `^question\b`, Points: -1, In: "title"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Keywords = keywords
+
+	expectBoosts(t, cfg, func(n int) float64 {
+		var boost float64
+		if n%5 == 0 {
+			boost += 3
+		}
+		if n%4 == 0 {
+			boost--
+		}
+		return boost
+	})
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// LintProblem is a problem found in the configuration, at the line and column of the offending key or
+// value in the config file. Line is zero when the option was not set in the file.
+type LintProblem struct {
+	Line    int
+	Column  int
+	Key     string
+	Message string
+}
+
+// String formats the problem as line:column: key: message
+func (p LintProblem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Key, p.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Column, p.Key, p.Message)
+}
+
+// configKeys are the keys of the config file that are not set by a flag, and the type of their value
+var configKeys = map[string]reflect.Type{
+	"scoring":      reflect.TypeOf(ScoringProfile{}),
+	"rollout":      reflect.TypeOf(Rollout{}),
+	"segments":     reflect.TypeOf([]Segment{}),
+	"rules":        reflect.TypeOf([]Rule{}),
+	"tenants":      reflect.TypeOf([]Tenant{}),
+	"teams":        reflect.TypeOf([]Team{}),
+	"issue_fields": reflect.TypeOf([]IssueField{}),
+	"keywords":     reflect.TypeOf([]Keyword{}),
+	"export":       reflect.TypeOf(exportLayout{}),
+	"step_summary": reflect.TypeOf(""),
+	"event_path":   reflect.TypeOf(""),
+	"run_attempt":  reflect.TypeOf(0),
+}
+
+// exportLayout is the layout of the export key of the config file, which is read key by key into an
+// ExportConfig
+type exportLayout struct {
+	KeyField    string `mapstructure:"key_field"`
+	MappingFile string `mapstructure:"mapping_file"`
+	Jira        struct {
+		URL   string `mapstructure:"url"`
+		User  string `mapstructure:"user"`
+		Token string `mapstructure:"token"`
+		Field string `mapstructure:"field"`
+	} `mapstructure:"jira"`
+	Linear struct {
+		Token string `mapstructure:"token"`
+	} `mapstructure:"linear"`
+}
+
+// flagTypes maps the type names of pflag values to the type of their value in the config file
+var flagTypes = map[string]reflect.Type{
+	"string":         reflect.TypeOf(""),
+	"bool":           reflect.TypeOf(false),
+	"int":            reflect.TypeOf(0),
+	"float64":        reflect.TypeOf(0.0),
+	"duration":       reflect.TypeOf(time.Duration(0)),
+	"stringSlice":    reflect.TypeOf([]string{}),
+	"stringToString": reflect.TypeOf(map[string]string{}),
+}
+
+// durationType is the type of duration values, which are written as strings such as 1h30m
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// linter collects the problems found in a config file
+type linter struct {
+	root     *yaml.Node
+	problems []LintProblem
+}
+
+// add records a problem at the node, or without a location if node is nil
+func (l *linter) add(node *yaml.Node, key, format string, args ...interface{}) {
+	problem := LintProblem{Key: key, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		problem.Line, problem.Column = node.Line, node.Column
+	}
+	l.problems = append(l.problems, problem)
+}
+
+// locate returns the node at the path of mapping keys and sequence indexes, or nil if it is not in the
+// config file
+func (l *linter) locate(path ...interface{}) *yaml.Node {
+	node := l.root
+	for _, step := range path {
+		if node == nil {
+			return nil
+		}
+
+		switch step := step.(type) {
+		case string:
+			var next *yaml.Node
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == step {
+						next = node.Content[i+1]
+					}
+				}
+			}
+			node = next
+		case int:
+			if node.Kind != yaml.SequenceNode || step >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[step]
+		}
+	}
+	return node
+}
+
+// LintConfigFile checks the layout of the config file at path: that every key is an option, and that
+// every value has the type its option expects. It returns an error if the file cannot be parsed at all.
+func LintConfigFile(path string) ([]LintProblem, error) {
+	l, err := parseLintFile(path)
+	if err != nil || l.root == nil {
+		return nil, err
+	}
+
+	l.lintLayout()
+	return l.problems, nil
+}
+
+// parseLintFile parses the config file at path, returning a linter with no root if the file is empty
+func parseLintFile(path string) (*linter, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	l := &linter{}
+	if len(doc.Content) > 0 {
+		l.root = doc.Content[0]
+	}
+	return l, nil
+}
+
+// lintLayout checks every top-level key against the flags and other options
+func (l *linter) lintLayout() {
+	if l.root.Kind != yaml.MappingNode {
+		l.add(l.root, "config", "expected a mapping of options")
+		return
+	}
+
+	types := make(map[string]reflect.Type, len(configKeys))
+	for key, t := range configKeys {
+		types[key] = t
+	}
+	newFlagSet().VisitAll(func(flag *pflag.Flag) {
+		if t, ok := flagTypes[flag.Value.Type()]; ok {
+			types[flagKey(flag.Name)] = t
+		}
+	})
+
+	for i := 0; i+1 < len(l.root.Content); i += 2 {
+		key, value := l.root.Content[i], l.root.Content[i+1]
+		t, ok := types[key.Value]
+		if !ok {
+			l.add(key, key.Value, "unknown option%s", suggestKey(key.Value, types))
+			continue
+		}
+		l.lintValue(value, t, key.Value)
+	}
+}
+
+// suggestKey returns a suggestion of a known key that key may be a misspelling of, or an empty string
+func suggestKey(key string, types map[string]reflect.Type) string {
+	for _, candidate := range []string{strings.ReplaceAll(key, "-", "_"), strings.TrimSuffix(key, "s"), key + "s"} {
+		if _, ok := types[candidate]; ok && candidate != key {
+			return fmt.Sprintf(", did you mean %q?", candidate)
+		}
+	}
+	return ""
+}
+
+// lintValue checks that the node holds a value of type t, the value of the option at key
+func (l *linter) lintValue(node *yaml.Node, t reflect.Type, key string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		if node.Kind != yaml.ScalarNode {
+			l.add(node, key, "expected a duration, such as 1h30m")
+		} else if _, err := time.ParseDuration(node.Value); err != nil {
+			if _, err := strconv.ParseInt(node.Value, 10, 64); err != nil {
+				l.add(node, key, "expected a duration, such as 1h30m, got %q", node.Value)
+			}
+		}
+
+	case t.Kind() == reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			l.add(node, key, "expected a mapping")
+			return
+		}
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			if tag := t.Field(i).Tag.Get("mapstructure"); tag != "" {
+				fields[tag] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[name.Value]
+			if !ok {
+				l.add(name, key+"."+name.Value, "unknown key%s", suggestKey(name.Value, fields))
+				continue
+			}
+			l.lintValue(value, field, key+"."+name.Value)
+		}
+
+	case t.Kind() == reflect.Slice:
+		// a list of strings may also be given as a single string of space separated values
+		if t.Elem().Kind() == reflect.String && node.Kind == yaml.ScalarNode {
+			return
+		}
+		if node.Kind != yaml.SequenceNode {
+			l.add(node, key, "expected a list")
+			return
+		}
+		for i, elem := range node.Content {
+			l.lintValue(elem, t.Elem(), fmt.Sprintf("%s[%d]", key, i))
+		}
+
+	case t.Kind() == reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			l.add(node, key, "expected a mapping")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			l.lintValue(node.Content[i+1], t.Elem(), key+"."+node.Content[i].Value)
+		}
+
+	default:
+		if node.Kind != yaml.ScalarNode {
+			l.add(node, key, "expected %s", describeKind(t.Kind()))
+			return
+		}
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			l.add(node, key, "expected %s, got %q", describeKind(t.Kind()), node.Value)
+		}
+	}
+}
+
+// describeKind describes the values of a kind of scalar
+func describeKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64:
+		return "a whole number"
+	case reflect.Float64:
+		return "a number"
+	}
+	return "a string"
+}
+
+// LintConfig checks the configuration's options for problems that its layout does not show: scoring
+// weights, rule and segment syntax, and reporter and notifier settings. When gh is not nil, the project
+// fields the configuration refers to are checked against the live project. Problems are located in the
+// config file at path, if it is not empty.
+func LintConfig(ctx context.Context, cfg Config, gh *githubv4.Client, path string) ([]LintProblem, error) {
+	l := &linter{}
+	if path != "" {
+		parsed, err := parseLintFile(path)
+		if err != nil {
+			return nil, err
+		}
+		l = parsed
+	}
+
+	l.lintScoring(cfg.Scoring, "scoring")
+	if cfg.Rollout.Profile != nil {
+		l.lintScoring(*cfg.Rollout.Profile, "rollout", "profile")
+	}
+
+	for i, rule := range cfg.Rules {
+		if _, err := compileRules([]Rule{rule}); err != nil {
+			l.add(l.locate("rules", i), fmt.Sprintf("rules[%d]", i), "%v", err)
+		}
+	}
+
+	for i, segment := range cfg.Segments {
+		if _, err := compileSegments([]Segment{segment}); err != nil {
+			l.add(l.locate("segments", i), fmt.Sprintf("segments[%d]", i), "%v", err)
+		}
+	}
+	if _, err := compileSegments(cfg.Segments); err != nil && len(l.problems) == 0 {
+		l.add(l.locate("segments"), "segments", "%v", err)
+	}
+
+	for i, spec := range cfg.Notifiers {
+		if _, err := newNotifiers(Config{Notifiers: []string{spec}}); err != nil {
+			l.add(l.locate("notifiers", i), fmt.Sprintf("notifiers[%d]", i), "%v", err)
+		}
+	}
+
+	for i, spec := range cfg.Reporters {
+		single := cfg
+		single.Reporters = []string{spec}
+		if _, err := newReporters(single, nil, nil); err != nil {
+			l.add(l.locate("reporters", i), fmt.Sprintf("reporters[%d]", i), "%v", err)
+		}
+	}
+
+	if gh != nil {
+		if err := l.lintFields(ctx, cfg, gh); err != nil {
+			return l.problems, err
+		}
+	}
+
+	return l.problems, nil
+}
+
+// sortProblems orders problems by where they are in the config file, with those not in the file first
+func sortProblems(problems []LintProblem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+}
+
+// lintScoring checks that the weights of the scoring profile at the path of keys are not negative
+func (l *linter) lintScoring(p ScoringProfile, root ...string) {
+	check := func(weight float64, path ...string) {
+		if weight >= 0 {
+			return
+		}
+		keys := append(append([]string(nil), root...), path...)
+		steps := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			steps = append(steps, key)
+		}
+		l.add(l.locate(steps...), strings.Join(keys, "."), "weight must not be negative, got %v", weight)
+	}
+
+	check(p.Comments, "comments")
+	check(p.Reactions, "reactions")
+	check(p.Timeline, "timeline")
+	if p.BotReferences != nil {
+		check(*p.BotReferences, "bot_references")
+	}
+	check(p.Rollup, "rollup")
+
+	for _, override := range []struct {
+		name    string
+		scoring *ContentScoring
+	}{{"issues", p.Issues}, {"pull_requests", p.PullRequests}} {
+		if override.scoring == nil {
+			continue
+		}
+		for _, weight := range []struct {
+			name  string
+			value *float64
+		}{{"comments", override.scoring.Comments}, {"reactions", override.scoring.Reactions}, {"timeline", override.scoring.Timeline}} {
+			if weight.value != nil {
+				check(*weight.value, override.name, weight.name)
+			}
+		}
+	}
+}
+
+// ProjectFieldsQuery lists the fields of a project, with the options of single select fields
+type ProjectFieldsQuery struct {
+	Node struct {
+		ProjectV2 struct {
+			Fields struct {
+				Nodes []struct {
+					Field struct {
+						Id       githubv4.ID
+						Name     string
+						DataType string
+					} `graphql:"...on ProjectV2FieldCommon"`
+					SingleSelect struct {
+						Options []struct {
+							Id   string
+							Name string
+						}
+					} `graphql:"...on ProjectV2SingleSelectField"`
+				}
+			} `graphql:"fields(first: 100)"`
+		} `graphql:"...on ProjectV2"`
+	} `graphql:"node(id: $projectId)"`
+}
+
+// projectField is a field of the live project
+type projectField struct {
+	name     string
+	dataType string
+	options  map[string]bool
+}
+
+// lintFields checks the fields the configuration refers to against the live project: the upvotes
+// field, the named number fields, the segments' fields, and the fields and options set by rules
+func (l *linter) lintFields(ctx context.Context, cfg Config, gh *githubv4.Client) error {
+	var query ProjectFieldsQuery
+	if err := runQuery(ctx, gh, &query, Variables().ID("projectId", cfg.ProjectID)); err != nil {
+		return fmt.Errorf("listing project fields: %w", err)
+	}
+	if len(query.Node.ProjectV2.Fields.Nodes) == 0 {
+		return errors.New("listing project fields: project not found, or it has no fields")
+	}
+
+	byId := make(map[string]projectField)
+	byName := make(map[string]projectField)
+	for _, node := range query.Node.ProjectV2.Fields.Nodes {
+		field := projectField{name: node.Field.Name, dataType: node.Field.DataType, options: make(map[string]bool)}
+		for _, option := range node.SingleSelect.Options {
+			field.options[option.Id] = true
+		}
+		byId[fmt.Sprint(node.Field.Id)] = field
+		byName[node.Field.Name] = field
+	}
+
+	if field, ok := byId[fmt.Sprint(cfg.FieldID)]; !ok {
+		l.add(l.locate("field_id"), "field_id", "project has no field %v", cfg.FieldID)
+	} else if field.dataType != "NUMBER" {
+		l.add(l.locate("field_id"), "field_id", "%q is a %s field, not a number field", field.name, field.dataType)
+	}
+
+	for _, named := range []struct{ key, name string }{
+		{"estimate_field", cfg.EstimateField},
+		{"score_override_field", cfg.ScoreOverrideField},
+		{"min_score_field", cfg.MinScoreField},
+		{"max_score_field", cfg.MaxScoreField},
+		{"first_response_field", cfg.FirstResponseField},
+		{"last_activity_field", cfg.LastActivityField},
+	} {
+		l.lintNumberField(byName, named.name, l.locate(named.key), named.key)
+	}
+	if name := cfg.AssignIteration; name != "" {
+		if field, ok := byName[name]; !ok {
+			l.add(l.locate("assign_iteration"), "assign_iteration", "project has no field named %q", name)
+		} else if field.dataType != "ITERATION" {
+			l.add(l.locate("assign_iteration"), "assign_iteration", "%q is a %s field, not an iteration field", name, field.dataType)
+		}
+	}
+	for i, segment := range cfg.Segments {
+		l.lintNumberField(byName, segment.Field, l.locate("segments", i, "field"), fmt.Sprintf("segments[%d].field", i))
+	}
+
+	for i, rule := range cfg.Rules {
+		for j, action := range rule.Actions {
+			if action.Type != "set-field" {
+				continue
+			}
+			key := fmt.Sprintf("rules[%d].actions[%d]", i, j)
+
+			field, ok := byId[action.FieldID]
+			if !ok {
+				l.add(l.locate("rules", i, "actions", j, "field_id"), key+".field_id", "project has no field %s", action.FieldID)
+				continue
+			}
+
+			switch {
+			case action.OptionID != "" && field.dataType != "SINGLE_SELECT":
+				l.add(l.locate("rules", i, "actions", j, "option_id"), key+".option_id", "%q is a %s field, not a single select field", field.name, field.dataType)
+			case action.OptionID != "" && !field.options[action.OptionID]:
+				l.add(l.locate("rules", i, "actions", j, "option_id"), key+".option_id", "%q has no option %s", field.name, action.OptionID)
+			case action.Number != nil && field.dataType != "NUMBER":
+				l.add(l.locate("rules", i, "actions", j, "number"), key+".number", "%q is a %s field, not a number field", field.name, field.dataType)
+			case action.Text != "" && field.dataType != "TEXT":
+				l.add(l.locate("rules", i, "actions", j, "text"), key+".text", "%q is a %s field, not a text field", field.name, field.dataType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// lintNumberField checks that the project has a number field with the name, if it is not empty
+func (l *linter) lintNumberField(byName map[string]projectField, name string, node *yaml.Node, key string) {
+	if name == "" {
+		return
+	}
+	if field, ok := byName[name]; !ok {
+		l.add(node, key, "project has no field named %q", name)
+	} else if field.dataType != "NUMBER" {
+		l.add(node, key, "%q is a %s field, not a number field", name, field.dataType)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestLint lints rules that set a field the project has, one of its options that it does not have, and
+// a field that it does not have, and checks that only the latter two are found
+func TestLint(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	client := &http.Client{Transport: newFakeGitHub()}
+	cfg.Rules = []Rule{{Name: "triage", When: "score > 10", Actions: []Action{
+		{Type: "set-field", FieldID: "PVTSSF_priority", OptionID: "P1"},
+		{Type: "set-field", FieldID: "PVTSSF_priority", OptionID: "P0"},
+		{Type: "set-field", FieldID: "PVTF_missing", Number: new(float64)},
+	}}}
+
+	problems, err := LintConfig(ctx, cfg, githubv4.NewClient(client), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "problems", len(problems), 2)
+	for i, key := range []string{"rules[0].actions[1].option_id", "rules[0].actions[2].field_id"} {
+		if problems[i].Key != key {
+			t.Fatalf("expected a problem with %s, got %s", key, problems[i])
+		}
+	}
+}
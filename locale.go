@@ -0,0 +1,491 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultLocale is the locale messages are written in unless another is configured
+const defaultLocale = "en"
+
+// catalogs are the built-in translations of the messages written to Markdown reports and sent as
+// notifications, keyed by language. Each message is a format string, or a Go template for the default
+// notification messages, and any message missing from a catalog is written in English.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"summary.title":         "Upvotes summary",
+		"summary.status":        "Status",
+		"summary.items":         "Items",
+		"summary.total":         "total",
+		"summary.conflicts":     "Conflicts",
+		"summary.conflicts_by":  "These items were changed by someone else during the run.",
+		"summary.item":          "Item",
+		"summary.expected":      "Expected",
+		"summary.found":         "Found",
+		"summary.movers":        "Largest changes",
+		"summary.movers_by":     "The values written changed by %s in all.",
+		"summary.previous":      "Previous",
+		"summary.value":         "Value",
+		"summary.change":        "Change",
+		"summary.rate_limit":    "The run used %d rate limit points, leaving %d, of which %d were unused above the reserve of %d.",
+		"summary.forecast":      "At %v points per item, the next run at %s %s. The recommended interval between runs is %s.",
+		"summary.quota":         "Rate limit points by query",
+		"summary.query":         "Query",
+		"summary.calls":         "Calls",
+		"summary.points":        "Points",
+		"summary.signal":        "Signal",
+		"summary.fits":          "is expected to fit in the rate limit",
+		"summary.not_fits":      "is not expected to fit in the rate limit",
+		"summary.stale":         "%d items have values calculated with a previous scoring profile. Run with `--recalculate-all` to refresh them.",
+		"summary.inactive":      "%d items have had no new engagement for the configured number of days.",
+		"summary.no_access":     "%d items belong to repositories the token cannot read, and were left as they are. Grant the token access to those repositories to score them.",
+		"summary.stats":         "Score distribution",
+		"summary.stats_by":      "Upvotes of %d items: lowest %v, median %v, 90th percentile %v, highest %v, %v in all.",
+		"report.run":            "Run `%s` started at %s.",
+		"report.upvotes":        "Upvotes",
+		"report.age":            "Age (days)",
+		"report.per_day":        "Per day",
+		"groups.title":          "Leaderboard by `%s`",
+		"groups.group":          "Group",
+		"diff.title":            "Score changes",
+		"diff.none":             "No items were scored.",
+		"diff.changed":          "With this configuration, %d of %d items would change, by %v upvotes on average.",
+		"diff.rank":             "Rank",
+		"diff.current":          "Current",
+		"diff.new":              "New",
+		"diff.leaving":          "Leaving the top %d:",
+		"diff.leaving_item":     "%s, from #%d to #%d",
+		"digest.header":         "%d project items matched notification rules:",
+		"drop.message":          "%s dropped %.0f%% since %s (%v -> %v)",
+		"drop.previous":         "the previous value",
+		"drop.run":              "run %s",
+		"sweep.unarchived":      "%s was unarchived after %d new timeline items since it was archived",
+		"email.subject":         "GitHub upvotes",
+		"notify.default":        defaultNotifyTemplate,
+		"notify.threshold":      thresholdNotifyTemplate,
+		"notify.delta":          deltaNotifyTemplate,
+		"notify.team":           teamNotifyTemplate,
+		"inbox.title":           "Triage inbox",
+		"inbox.count":           "%d items need attention after run `%s`.",
+		"inbox.empty":           "Nothing needs attention after run `%s`.",
+		"inbox.broken":          "Needs a look",
+		"inbox.failed":          "could not be scored: %v",
+		"inbox.conflict":        "its field was changed by someone else during the run",
+		"inbox.no_access":       "the token cannot read it, it may have moved to another repository",
+		"inbox.archived_active": "archived, but active again",
+		"inbox.crossed":         "Crossed %v upvotes",
+		"inbox.crossed_item":    "%v -> %v upvotes",
+		"inbox.rising":          "Rising fast",
+		"inbox.rising_item":     "%.1f upvotes a week, against a median of %.1f",
+		"assignees.title":       "Suggested assignees",
+		"assignees.nobody":      "Nobody has open items assigned on the board to balance suggestions against. Set --assignee-pool to suggest assignees.",
+		"assignees.none":        "Every open item is assigned.",
+		"assignees.suggested":   "Suggested",
+		"assignees.load":        "Load",
+		"assignees.current":     "Current load",
+		"assignees.assignee":    "Assignee",
+		"assignees.open":        "Open items",
+	},
+	"de": {
+		"summary.title":         "Upvotes-Übersicht",
+		"summary.status":        "Status",
+		"summary.items":         "Einträge",
+		"summary.total":         "gesamt",
+		"summary.conflicts":     "Konflikte",
+		"summary.conflicts_by":  "Diese Einträge wurden während des Laufs von jemand anderem geändert.",
+		"summary.item":          "Eintrag",
+		"summary.expected":      "Erwartet",
+		"summary.found":         "Gefunden",
+		"summary.movers":        "Größte Änderungen",
+		"summary.movers_by":     "Die geschriebenen Werte haben sich insgesamt um %s geändert.",
+		"summary.previous":      "Vorher",
+		"summary.value":         "Wert",
+		"summary.change":        "Änderung",
+		"summary.rate_limit":    "Der Lauf hat %d Punkte des Rate Limits verbraucht. Es bleiben %d, davon %d ungenutzt über der Reserve von %d.",
+		"summary.forecast":      "Bei %v Punkten pro Eintrag %[3]s der nächste Lauf um %[2]s. Das empfohlene Intervall zwischen Läufen ist %[4]s.",
+		"summary.quota":         "Punkte des Rate Limits nach Abfrage",
+		"summary.query":         "Abfrage",
+		"summary.calls":         "Aufrufe",
+		"summary.points":        "Punkte",
+		"summary.signal":        "Signal",
+		"summary.fits":          "passt voraussichtlich ins Rate Limit",
+		"summary.not_fits":      "passt voraussichtlich nicht ins Rate Limit",
+		"summary.stale":         "%d Einträge haben Werte, die mit einem früheren Bewertungsprofil berechnet wurden. Führe einen Lauf mit `--recalculate-all` aus, um sie zu aktualisieren.",
+		"summary.inactive":      "%d Einträge hatten seit der konfigurierten Anzahl von Tagen keine neue Aktivität.",
+		"summary.no_access":     "%d Einträge gehören zu Repositories, die das Token nicht lesen kann, und wurden nicht verändert. Gib dem Token Zugriff auf diese Repositories, um sie zu bewerten.",
+		"summary.stats":         "Verteilung der Punkte",
+		"summary.stats_by":      "Upvotes von %d Einträgen: niedrigster Wert %v, Median %v, 90. Perzentil %v, höchster Wert %v, insgesamt %v.",
+		"report.run":            "Lauf `%s` gestartet um %s.",
+		"report.upvotes":        "Upvotes",
+		"report.age":            "Alter (Tage)",
+		"report.per_day":        "Pro Tag",
+		"groups.title":          "Rangliste nach `%s`",
+		"groups.group":          "Gruppe",
+		"diff.title":            "Änderungen der Bewertung",
+		"diff.none":             "Es wurden keine Einträge bewertet.",
+		"diff.changed":          "Mit dieser Konfiguration würden sich %d von %d Einträgen ändern, im Durchschnitt um %v Upvotes.",
+		"diff.rank":             "Rang",
+		"diff.current":          "Aktuell",
+		"diff.new":              "Neu",
+		"diff.leaving":          "Verlassen die Top %d:",
+		"diff.leaving_item":     "%s, von #%d auf #%d",
+		"digest.header":         "%d Projekteinträge haben Benachrichtigungsregeln erfüllt:",
+		"drop.message":          "%s ist seit %[3]s um %.0[2]f%% gefallen (%[4]v -> %[5]v)",
+		"drop.previous":         "dem vorherigen Wert",
+		"drop.run":              "Lauf %s",
+		"sweep.unarchived":      "%s wurde nach %d neuen Einträgen in der Timeline seit der Archivierung aus dem Archiv geholt",
+		"email.subject":         "GitHub-Upvotes",
+		"notify.default":        `{{.Name}} erfüllt {{.Rule}} mit {{.Upvotes}} Upvotes ({{printf "%+g" .Delta}})`,
+		"notify.threshold":      `{{.Name}} hat {{.Threshold}} Upvotes überschritten ({{.Previous}} -> {{.Upvotes}}, {{printf "%+g" .Delta}})`,
+		"notify.delta":          `{{.Name}} hat seit dem letzten Lauf {{printf "%+g" .Delta}} Upvotes gewonnen ({{.Previous}} -> {{.Upvotes}})`,
+		"notify.team":           `@%s: {{.Name}}, das das Team erwähnt, hat {{.Threshold}} Upvotes überschritten ({{.Previous}} -> {{.Upvotes}}, {{printf "%%+g" .Delta}})`,
+		"inbox.title":           "Triage-Posteingang",
+		"inbox.count":           "%d Einträge brauchen nach Lauf `%s` Aufmerksamkeit.",
+		"inbox.empty":           "Nach Lauf `%s` braucht nichts Aufmerksamkeit.",
+		"inbox.broken":          "Zu prüfen",
+		"inbox.failed":          "konnte nicht bewertet werden: %v",
+		"inbox.conflict":        "das Feld wurde während des Laufs von jemand anderem geändert",
+		"inbox.no_access":       "das Token kann den Eintrag nicht lesen, er wurde vielleicht in ein anderes Repository verschoben",
+		"inbox.archived_active": "archiviert, aber wieder aktiv",
+		"inbox.crossed":         "Über %v Upvotes",
+		"inbox.crossed_item":    "%v -> %v Upvotes",
+		"inbox.rising":          "Schnell steigend",
+		"inbox.rising_item":     "%.1f Upvotes pro Woche, bei einem Median von %.1f",
+		"assignees.title":       "Vorgeschlagene Zuständige",
+		"assignees.nobody":      "Niemandem sind auf dem Board offene Einträge zugewiesen, gegen die Vorschläge abgewogen werden können. Setze --assignee-pool, um Zuständige vorzuschlagen.",
+		"assignees.none":        "Alle offenen Einträge sind zugewiesen.",
+		"assignees.suggested":   "Vorschlag",
+		"assignees.load":        "Last",
+		"assignees.current":     "Aktuelle Last",
+		"assignees.assignee":    "Zuständig",
+		"assignees.open":        "Offene Einträge",
+	},
+	"es": {
+		"summary.title":         "Resumen de votos",
+		"summary.status":        "Estado",
+		"summary.items":         "Elementos",
+		"summary.total":         "total",
+		"summary.conflicts":     "Conflictos",
+		"summary.conflicts_by":  "Otra persona cambió estos elementos durante la ejecución.",
+		"summary.item":          "Elemento",
+		"summary.expected":      "Esperado",
+		"summary.found":         "Encontrado",
+		"summary.movers":        "Mayores cambios",
+		"summary.movers_by":     "Los valores escritos cambiaron %s en total.",
+		"summary.previous":      "Anterior",
+		"summary.value":         "Valor",
+		"summary.change":        "Cambio",
+		"summary.rate_limit":    "La ejecución usó %d puntos del límite de peticiones y quedan %d, de los cuales %d no se usaron por encima de la reserva de %d.",
+		"summary.forecast":      "A %v puntos por elemento, la próxima ejecución a las %s %s. El intervalo recomendado entre ejecuciones es %s.",
+		"summary.quota":         "Puntos del límite de peticiones por consulta",
+		"summary.query":         "Consulta",
+		"summary.calls":         "Llamadas",
+		"summary.points":        "Puntos",
+		"summary.signal":        "Señal",
+		"summary.fits":          "debería caber en el límite de peticiones",
+		"summary.not_fits":      "no debería caber en el límite de peticiones",
+		"summary.stale":         "%d elementos tienen valores calculados con un perfil de puntuación anterior. Ejecuta con `--recalculate-all` para actualizarlos.",
+		"summary.inactive":      "%d elementos no han tenido actividad nueva durante el número de días configurado.",
+		"summary.no_access":     "%d elementos pertenecen a repositorios que el token no puede leer y se dejaron como estaban. Da acceso al token a esos repositorios para puntuarlos.",
+		"summary.stats":         "Distribución de puntuaciones",
+		"summary.stats_by":      "Votos de %d elementos: mínimo %v, mediana %v, percentil 90 %v, máximo %v, %v en total.",
+		"report.run":            "Ejecución `%s` iniciada a las %s.",
+		"report.upvotes":        "Votos",
+		"report.age":            "Antigüedad (días)",
+		"report.per_day":        "Por día",
+		"groups.title":          "Clasificación por `%s`",
+		"groups.group":          "Grupo",
+		"diff.title":            "Cambios de puntuación",
+		"diff.none":             "No se puntuó ningún elemento.",
+		"diff.changed":          "Con esta configuración cambiarían %d de %d elementos, en %v votos de media.",
+		"diff.rank":             "Puesto",
+		"diff.current":          "Actual",
+		"diff.new":              "Nuevo",
+		"diff.leaving":          "Salen de los %d primeros:",
+		"diff.leaving_item":     "%s, del #%d al #%d",
+		"digest.header":         "%d elementos del proyecto cumplieron reglas de notificación:",
+		"drop.message":          "%s bajó un %.0f%% desde %s (%v -> %v)",
+		"drop.previous":         "el valor anterior",
+		"drop.run":              "la ejecución %s",
+		"sweep.unarchived":      "%s se desarchivó tras %d elementos nuevos en su cronología desde que se archivó",
+		"email.subject":         "Votos de GitHub",
+		"notify.default":        `{{.Name}} cumplió {{.Rule}} con {{.Upvotes}} votos ({{printf "%+g" .Delta}})`,
+		"notify.threshold":      `{{.Name}} superó los {{.Threshold}} votos ({{.Previous}} -> {{.Upvotes}}, {{printf "%+g" .Delta}})`,
+		"notify.delta":          `{{.Name}} ganó {{printf "%+g" .Delta}} votos desde la última ejecución ({{.Previous}} -> {{.Upvotes}})`,
+		"notify.team":           `@%s: {{.Name}}, que menciona al equipo, superó los {{.Threshold}} votos ({{.Previous}} -> {{.Upvotes}}, {{printf "%%+g" .Delta}})`,
+		"inbox.title":           "Bandeja de triaje",
+		"inbox.count":           "%d elementos necesitan atención tras la ejecución `%s`.",
+		"inbox.empty":           "Nada necesita atención tras la ejecución `%s`.",
+		"inbox.broken":          "Para revisar",
+		"inbox.failed":          "no se pudo puntuar: %v",
+		"inbox.conflict":        "otra persona cambió su campo durante la ejecución",
+		"inbox.no_access":       "el token no puede leerlo, puede que se haya movido a otro repositorio",
+		"inbox.archived_active": "archivado, pero activo de nuevo",
+		"inbox.crossed":         "Superaron los %v votos",
+		"inbox.crossed_item":    "%v -> %v votos",
+		"inbox.rising":          "En rápido ascenso",
+		"inbox.rising_item":     "%.1f votos por semana, frente a una mediana de %.1f",
+		"assignees.title":       "Responsables sugeridos",
+		"assignees.nobody":      "Nadie tiene elementos abiertos asignados en el tablero con los que equilibrar las sugerencias. Usa --assignee-pool para sugerir responsables.",
+		"assignees.none":        "Todos los elementos abiertos están asignados.",
+		"assignees.suggested":   "Sugerencia",
+		"assignees.load":        "Carga",
+		"assignees.current":     "Carga actual",
+		"assignees.assignee":    "Responsable",
+		"assignees.open":        "Elementos abiertos",
+	},
+	"fr": {
+		"summary.title":         "Résumé des votes",
+		"summary.status":        "Statut",
+		"summary.items":         "Éléments",
+		"summary.total":         "total",
+		"summary.conflicts":     "Conflits",
+		"summary.conflicts_by":  "Ces éléments ont été modifiés par quelqu'un d'autre pendant l'exécution.",
+		"summary.item":          "Élément",
+		"summary.expected":      "Attendu",
+		"summary.found":         "Trouvé",
+		"summary.movers":        "Plus grands changements",
+		"summary.movers_by":     "Les valeurs écrites ont changé de %s au total.",
+		"summary.previous":      "Avant",
+		"summary.value":         "Valeur",
+		"summary.change":        "Changement",
+		"summary.rate_limit":    "L'exécution a utilisé %d points de la limite de requêtes. Il en reste %d, dont %d inutilisés au-delà de la réserve de %d.",
+		"summary.forecast":      "À %v points par élément, la prochaine exécution à %s %s. L'intervalle recommandé entre les exécutions est de %s.",
+		"summary.quota":         "Points de la limite de requêtes par requête",
+		"summary.query":         "Requête",
+		"summary.calls":         "Appels",
+		"summary.points":        "Points",
+		"summary.signal":        "Signal",
+		"summary.fits":          "devrait tenir dans la limite de requêtes",
+		"summary.not_fits":      "ne devrait pas tenir dans la limite de requêtes",
+		"summary.stale":         "%d éléments ont des valeurs calculées avec un profil de notation précédent. Lancez avec `--recalculate-all` pour les actualiser.",
+		"summary.inactive":      "%d éléments n'ont eu aucune nouvelle activité depuis le nombre de jours configuré.",
+		"summary.no_access":     "%d éléments appartiennent à des dépôts que le jeton ne peut pas lire et ont été laissés tels quels. Donnez au jeton l'accès à ces dépôts pour les noter.",
+		"summary.stats":         "Répartition des scores",
+		"summary.stats_by":      "Votes de %d éléments : minimum %v, médiane %v, 90e centile %v, maximum %v, %v au total.",
+		"report.run":            "Exécution `%s` démarrée à %s.",
+		"report.upvotes":        "Votes",
+		"report.age":            "Âge (jours)",
+		"report.per_day":        "Par jour",
+		"groups.title":          "Classement par `%s`",
+		"groups.group":          "Groupe",
+		"diff.title":            "Changements de score",
+		"diff.none":             "Aucun élément n'a été noté.",
+		"diff.changed":          "Avec cette configuration, %d éléments sur %d changeraient, de %v votes en moyenne.",
+		"diff.rank":             "Rang",
+		"diff.current":          "Actuel",
+		"diff.new":              "Nouveau",
+		"diff.leaving":          "Quittent le top %d :",
+		"diff.leaving_item":     "%s, de #%d à #%d",
+		"digest.header":         "%d éléments du projet ont satisfait des règles de notification :",
+		"drop.message":          "%s a baissé de %.0f%% depuis %s (%v -> %v)",
+		"drop.previous":         "la valeur précédente",
+		"drop.run":              "l'exécution %s",
+		"sweep.unarchived":      "%s a été désarchivé après %d nouveaux éléments dans sa chronologie depuis son archivage",
+		"email.subject":         "Votes GitHub",
+		"notify.default":        `{{.Name}} a satisfait {{.Rule}} avec {{.Upvotes}} votes ({{printf "%+g" .Delta}})`,
+		"notify.threshold":      `{{.Name}} a dépassé {{.Threshold}} votes ({{.Previous}} -> {{.Upvotes}}, {{printf "%+g" .Delta}})`,
+		"notify.delta":          `{{.Name}} a gagné {{printf "%+g" .Delta}} votes depuis la dernière exécution ({{.Previous}} -> {{.Upvotes}})`,
+		"notify.team":           `@%s : {{.Name}}, qui mentionne l'équipe, a dépassé {{.Threshold}} votes ({{.Previous}} -> {{.Upvotes}}, {{printf "%%+g" .Delta}})`,
+		"inbox.title":           "Boîte de tri",
+		"inbox.count":           "%d éléments demandent de l'attention après l'exécution `%s`.",
+		"inbox.empty":           "Rien ne demande d'attention après l'exécution `%s`.",
+		"inbox.broken":          "À vérifier",
+		"inbox.failed":          "n'a pas pu être noté : %v",
+		"inbox.conflict":        "son champ a été modifié par quelqu'un d'autre pendant l'exécution",
+		"inbox.no_access":       "le jeton ne peut pas le lire, il a peut-être été déplacé dans un autre dépôt",
+		"inbox.archived_active": "archivé, mais de nouveau actif",
+		"inbox.crossed":         "Plus de %v votes",
+		"inbox.crossed_item":    "%v -> %v votes",
+		"inbox.rising":          "En forte hausse",
+		"inbox.rising_item":     "%.1f votes par semaine, pour une médiane de %.1f",
+		"assignees.title":       "Responsables suggérés",
+		"assignees.nobody":      "Personne n'a d'éléments ouverts assignés sur le tableau pour équilibrer les suggestions. Définissez --assignee-pool pour suggérer des responsables.",
+		"assignees.none":        "Tous les éléments ouverts sont assignés.",
+		"assignees.suggested":   "Suggestion",
+		"assignees.load":        "Charge",
+		"assignees.current":     "Charge actuelle",
+		"assignees.assignee":    "Responsable",
+		"assignees.open":        "Éléments ouverts",
+	},
+	"pt": {
+		"summary.title":         "Resumo de votos",
+		"summary.status":        "Status",
+		"summary.items":         "Itens",
+		"summary.total":         "total",
+		"summary.conflicts":     "Conflitos",
+		"summary.conflicts_by":  "Estes itens foram alterados por outra pessoa durante a execução.",
+		"summary.item":          "Item",
+		"summary.expected":      "Esperado",
+		"summary.found":         "Encontrado",
+		"summary.movers":        "Maiores mudanças",
+		"summary.movers_by":     "Os valores escritos mudaram %s no total.",
+		"summary.previous":      "Anterior",
+		"summary.value":         "Valor",
+		"summary.change":        "Mudança",
+		"summary.rate_limit":    "A execução usou %d pontos do limite de requisições, restando %d, dos quais %d não foram usados acima da reserva de %d.",
+		"summary.forecast":      "A %v pontos por item, a próxima execução às %s %s. O intervalo recomendado entre execuções é %s.",
+		"summary.quota":         "Pontos do limite de requisições por consulta",
+		"summary.query":         "Consulta",
+		"summary.calls":         "Chamadas",
+		"summary.points":        "Pontos",
+		"summary.signal":        "Sinal",
+		"summary.fits":          "deve caber no limite de requisições",
+		"summary.not_fits":      "não deve caber no limite de requisições",
+		"summary.stale":         "%d itens têm valores calculados com um perfil de pontuação anterior. Execute com `--recalculate-all` para atualizá-los.",
+		"summary.inactive":      "%d itens não tiveram atividade nova pelo número de dias configurado.",
+		"summary.no_access":     "%d itens pertencem a repositórios que o token não consegue ler e foram deixados como estavam. Dê ao token acesso a esses repositórios para pontuá-los.",
+		"summary.stats":         "Distribuição das pontuações",
+		"summary.stats_by":      "Votos de %d itens: mínimo %v, mediana %v, percentil 90 %v, máximo %v, %v no total.",
+		"report.run":            "Execução `%s` iniciada às %s.",
+		"report.upvotes":        "Votos",
+		"report.age":            "Idade (dias)",
+		"report.per_day":        "Por dia",
+		"groups.title":          "Classificação por `%s`",
+		"groups.group":          "Grupo",
+		"diff.title":            "Mudanças de pontuação",
+		"diff.none":             "Nenhum item foi pontuado.",
+		"diff.changed":          "Com esta configuração, %d de %d itens mudariam, em %v votos em média.",
+		"diff.rank":             "Posição",
+		"diff.current":          "Atual",
+		"diff.new":              "Novo",
+		"diff.leaving":          "Saindo dos %d primeiros:",
+		"diff.leaving_item":     "%s, de #%d para #%d",
+		"digest.header":         "%d itens do projeto atenderam a regras de notificação:",
+		"drop.message":          "%s caiu %.0f%% desde %s (%v -> %v)",
+		"drop.previous":         "o valor anterior",
+		"drop.run":              "a execução %s",
+		"sweep.unarchived":      "%s foi desarquivado após %d novos itens na linha do tempo desde que foi arquivado",
+		"email.subject":         "Votos do GitHub",
+		"notify.default":        `{{.Name}} atendeu {{.Rule}} com {{.Upvotes}} votos ({{printf "%+g" .Delta}})`,
+		"notify.threshold":      `{{.Name}} passou de {{.Threshold}} votos ({{.Previous}} -> {{.Upvotes}}, {{printf "%+g" .Delta}})`,
+		"notify.delta":          `{{.Name}} ganhou {{printf "%+g" .Delta}} votos desde a última execução ({{.Previous}} -> {{.Upvotes}})`,
+		"notify.team":           `@%s: {{.Name}}, que menciona a equipe, passou de {{.Threshold}} votos ({{.Previous}} -> {{.Upvotes}}, {{printf "%%+g" .Delta}})`,
+		"inbox.title":           "Caixa de triagem",
+		"inbox.count":           "%d itens precisam de atenção após a execução `%s`.",
+		"inbox.empty":           "Nada precisa de atenção após a execução `%s`.",
+		"inbox.broken":          "Para revisar",
+		"inbox.failed":          "não pôde ser pontuado: %v",
+		"inbox.conflict":        "o campo foi alterado por outra pessoa durante a execução",
+		"inbox.no_access":       "o token não consegue lê-lo, ele pode ter sido movido para outro repositório",
+		"inbox.archived_active": "arquivado, mas ativo de novo",
+		"inbox.crossed":         "Passaram de %v votos",
+		"inbox.crossed_item":    "%v -> %v votos",
+		"inbox.rising":          "Subindo rápido",
+		"inbox.rising_item":     "%.1f votos por semana, contra uma mediana de %.1f",
+		"assignees.title":       "Responsáveis sugeridos",
+		"assignees.nobody":      "Ninguém tem itens abertos atribuídos no quadro para equilibrar as sugestões. Defina --assignee-pool para sugerir responsáveis.",
+		"assignees.none":        "Todos os itens abertos estão atribuídos.",
+		"assignees.suggested":   "Sugestão",
+		"assignees.load":        "Carga",
+		"assignees.current":     "Carga atual",
+		"assignees.assignee":    "Responsável",
+		"assignees.open":        "Itens abertos",
+	},
+}
+
+// Locale holds the messages written to Markdown reports and sent as notifications in one language. A nil
+// Locale writes them in English.
+type Locale struct {
+	Name     string
+	messages map[string]string
+}
+
+// loadLocale returns the built-in catalog of the named locale, such as de or pt-BR, which falls back to
+// its language, with the messages of the catalog file at path, if set, in place of the built-in ones.
+// The file is a YAML map of message keys to messages, and lets a locale without a built-in catalog be
+// used, or the wording of a built-in one be changed.
+func loadLocale(name, path string) (*Locale, error) {
+	if name == "" {
+		name = defaultLocale
+	}
+
+	l := &Locale{Name: name, messages: make(map[string]string)}
+	lang, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(name, "_", "-")), "-")
+	catalog, ok := catalogs[strings.ToLower(name)]
+	if !ok {
+		catalog, ok = catalogs[lang]
+	}
+	if !ok && path == "" {
+		return nil, fmt.Errorf("unknown locale %q: must be one of %s, or set --locale-file", name, strings.Join(localeNames(), ", "))
+	}
+	for key, message := range catalog {
+		l.messages[key] = message
+	}
+
+	if path == "" {
+		return l, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading locale file: %w", err)
+	}
+	var messages map[string]string
+	if err := yaml.Unmarshal(b, &messages); err != nil {
+		return nil, fmt.Errorf("parsing locale file: %w", err)
+	}
+	for key, message := range messages {
+		if _, ok := catalogs[defaultLocale][key]; !ok {
+			return nil, fmt.Errorf("locale file: unknown message %q", key)
+		}
+		l.messages[key] = message
+	}
+
+	return l, nil
+}
+
+// localeNames returns the names of the built-in locales, sorted
+func localeNames() []string {
+	names := make([]string, 0, len(catalogs))
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// T returns the message with the key, formatted with the arguments if any are given
+func (l *Locale) T(key string, args ...interface{}) string {
+	message, ok := "", false
+	if l != nil {
+		message, ok = l.messages[key]
+	}
+	if !ok {
+		message = catalogs[defaultLocale][key]
+	}
+
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// row returns a Markdown table row of the messages with the keys
+func (l *Locale) row(keys ...string) string {
+	cells := make([]string, len(keys))
+	for i, key := range keys {
+		cells[i] = l.T(key)
+	}
+	return "| " + strings.Join(cells, " | ") + " |\n"
+}
+
+// localizeRules returns the rules with the locale's default message in place of the empty message of
+// each notify action. The rules given are not changed.
+func localizeRules(rules []Rule, l *Locale) []Rule {
+	localized := make([]Rule, len(rules))
+	for i, rule := range rules {
+		rule.Actions = append([]Action(nil), rule.Actions...)
+		for j, action := range rule.Actions {
+			if action.Type == "notify" && action.Message == "" {
+				rule.Actions[j].Message = l.T("notify.default")
+			}
+		}
+		localized[i] = rule
+	}
+	return localized
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLocale renders the summary in Spanish, a drop alert in German, whose catalog reorders the
+// arguments, and the summary again with a locale file that changes one message and falls back to
+// Portuguese for the rest
+func TestLocale(t *testing.T) {
+	dir := t.TempDir()
+	summary := NewSummary([]Result{{ItemID: "PVTI_1", Status: StatusUpdated, Previous: 1, Value: 3}})
+
+	es, err := loadLocale("es", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if markdown := summary.Markdown(es); !strings.Contains(markdown, "### Resumen de votos") || !strings.Contains(markdown, "| Elemento | Anterior | Valor | Cambio |") {
+		t.Fatalf("expected a summary in Spanish, got:\n%s", markdown)
+	}
+	if markdown := summary.Markdown(nil); !strings.Contains(markdown, "### Upvotes summary") {
+		t.Fatalf("expected a summary in English without a locale, got:\n%s", markdown)
+	}
+
+	de, err := loadLocale("de_DE", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#1 ist seit dem vorherigen Wert um 75% gefallen (8 -> 2)"
+	if got := de.T("drop.message", "#1", 75.0, de.T("drop.previous"), 8.0, 2.0); got != want {
+		t.Fatalf("expected the drop alert %q, got %q", want, got)
+	}
+
+	file := filepath.Join(dir, "locale.yaml")
+	if err := os.WriteFile(file, []byte("summary.title: Placar de votos\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pt, err := loadLocale("pt-BR", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if markdown := summary.Markdown(pt); !strings.Contains(markdown, "### Placar de votos") || !strings.Contains(markdown, "| Status | Itens |") {
+		t.Fatalf("expected a summary in Portuguese with the title from the locale file, got:\n%s", markdown)
+	}
+
+	if err := os.WriteFile(file, []byte("summary.titel: Upvotes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadLocale("de", file); err == nil {
+		t.Fatal("expected an unknown message in the locale file to be an error")
+	}
+	if _, err := loadLocale("xx", ""); err == nil {
+		t.Fatal("expected a locale without a catalog or locale file to be an error")
+	}
+}
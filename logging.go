@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// logAttrsKey is the context key for attributes that are added to every log record
+type logAttrsKey struct{}
+
+// withLogAttrs returns a context whose log records, when logged with one of the slog *Context
+// functions, include the given attributes
+func withLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, logAttrsKey{}, append(existing[:len(existing):len(existing)], attrs...))
+}
+
+// contextHandler adds the attributes stored in the context to each record before passing it on
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context's attributes to the record
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a contextHandler wrapping the handler with the given attributes
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a contextHandler wrapping the handler with the given group
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// redacted replaces secrets in logs and dumps
+const redacted = "[REDACTED]"
+
+// secretPatterns match credentials that can end up in error messages, such as GitHub tokens, echoed
+// Authorization headers, and passwords or tokens embedded in URLs. The first and second groups of each
+// pattern, where present, are kept either side of the redacted credential.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`()\b(?:gh[pousr]_[A-Za-z0-9_]{20,}|github_pat_[A-Za-z0-9_]{20,})`),
+	regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*[\["']*(?:bearer |token |basic )?)[^\s"',\]]+`),
+	regexp.MustCompile(`(?i)(\bbearer )[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+(@)`),
+	regexp.MustCompile(`(?i)([?&](?:access_token|token|api_key|apikey|key|password)=)[^&\s"]+`),
+}
+
+// secrets are the configured credentials, which are redacted wherever they appear, longest first so that
+// a credential containing another is redacted whole. Each is kept once, however often it is registered.
+var secrets struct {
+	sync.RWMutex
+	values     []string
+	registered map[string]bool
+}
+
+// registerSecrets adds credentials that are redacted wherever they appear in logs and dumps. Empty
+// values, and those already registered, such as the token of a reloaded config file, are ignored.
+func registerSecrets(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+
+	if secrets.registered == nil {
+		secrets.registered = make(map[string]bool)
+	}
+	for _, v := range values {
+		if v != "" && !secrets.registered[v] {
+			secrets.registered[v] = true
+			secrets.values = append(secrets.values, v)
+		}
+	}
+	sort.SliceStable(secrets.values, func(i, j int) bool {
+		return len(secrets.values[i]) > len(secrets.values[j])
+	})
+}
+
+// sensitiveHeader returns true if the named header is likely to carry a credential
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"auth", "token", "key", "secret", "cookie", "password"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces the configured credentials, and anything that looks like a credential, in s
+func redact(s string) string {
+	secrets.RLock()
+	for _, secret := range secrets.values {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	secrets.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	}
+
+	return s
+}
+
+// redactValue returns the value with credentials redacted from strings and errors, including those
+// nested in groups. Any other value, such as a struct, a map, or a URL, is replaced by its redacted
+// text if it holds a credential either as text or as JSON, which is how handlers write it.
+func redactValue(v slog.Value) slog.Value {
+	v = v.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.StringValue(redact(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redactedAttrs := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			redactedAttrs[i] = slog.Attr{Key: a.Key, Value: redactValue(a.Value)}
+		}
+		return slog.GroupValue(redactedAttrs...)
+	case slog.KindAny:
+		switch a := v.Any().(type) {
+		case nil:
+		case error:
+			return slog.StringValue(redact(a.Error()))
+		default:
+			text := fmt.Sprintf("%+v", a)
+			if stringer, ok := a.(fmt.Stringer); ok {
+				text = stringer.String()
+			}
+			b, _ := json.Marshal(a)
+			if redact(text) != text || redact(string(b)) != string(b) {
+				return slog.StringValue(redact(text))
+			}
+		}
+	}
+
+	return v
+}
+
+// redactHandler scrubs credentials from the message and attributes of every record before passing it
+// on, since errors from the HTTP and GraphQL clients sometimes embed URLs and headers
+type redactHandler struct {
+	slog.Handler
+}
+
+// Handle redacts the record
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	scrubbed := slog.NewRecord(r.Time, r.Level, redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		scrubbed.AddAttrs(slog.Attr{Key: a.Key, Value: redactValue(a.Value)})
+		return true
+	})
+	return h.Handler.Handle(ctx, scrubbed)
+}
+
+// WithAttrs returns a redactHandler wrapping the handler with the given attributes, redacted
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = slog.Attr{Key: a.Key, Value: redactValue(a.Value)}
+	}
+	return redactHandler{h.Handler.WithAttrs(scrubbed)}
+}
+
+// WithGroup returns a redactHandler wrapping the handler with the given group
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+// setupLogging sets the default logger, enabling debug logging if requested. Credentials are redacted
+// from every record. The default handler is always replaced, since wrapping slog's own default handler
+// and setting it as the default would loop back through the log package.
+func setupLogging(debug bool) {
+	opts := &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}
+
+	if debug {
+		opts.Level = slog.LevelDebug
+	}
+
+	slog.SetDefault(slog.New(contextHandler{redactHandler{slog.NewTextHandler(os.Stdout, opts)}}))
+
+	if debug {
+		slog.Info("setting debug logging")
+	}
+}
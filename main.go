@@ -2,47 +2,48 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/shurcooL/githubv4"
-	"github.com/spf13/viper"
-	"golang.org/x/oauth2"
+	"github.com/spf13/pflag"
 )
 
 func main() {
 
-	if err := validateEnv(); err != nil {
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, pflag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		// the config file is linted to point out where the problem is, if it is in the file
+		if cfg.ConfigFile != "" {
+			problems, _ := LintConfigFile(cfg.ConfigFile)
+			for _, problem := range problems {
+				slog.Error("problem in config file", "path", cfg.ConfigFile, "line", problem.Line, "column", problem.Column, "key", problem.Key, "message", problem.Message)
+			}
+		}
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
 
-	// setup github client
-	ctx := context.Background()
-	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: viper.GetString("TOKEN")})
-	gh := githubv4.NewClient(oauth2.NewClient(ctx, src))
-
-	// context for early exit
-	childCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	// channel for capturing errors
-	errChan := make(chan error)
-
-	// load project data
-	project := githubv4.ID(viper.GetString("PROJECT_ID"))
-	field := githubv4.ID(viper.GetString("FIELD_ID"))
-
-	// start the pipeline
-	itemChan, wg := GetProjectItems(childCtx, gh, project, errChan)
-	updateChan := ProcessProjectItems(childCtx, gh, itemChan, errChan)
-	done := UpdateProjectItems(childCtx, gh, wg, project, field, updateChan, errChan)
+	command, ok := commands[cfg.Command]
+	if !ok {
+		slog.Error("unknown command", "command", cfg.Command)
+		os.Exit(1)
+	}
 
-	select {
-	case err := <-errChan:
-		cancel()
-		slog.Error(err.Error())
-	case <-done:
-		break
+	// an interrupt cancels the command, which stops promptly and still writes its reports and state
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = command(ctx, cfg)
+	stop()
+	if ownershipErr := fixOwnership(cfg); ownershipErr != nil {
+		slog.Warn("failed to give written files to the owner of the output directory", "error", ownershipErr)
+	}
+	if err != nil {
+		slog.Error(diagnosePermissionError(diagnoseSchemaError(err)).Error())
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Names that the fields used for milestone planning are fetched under
+const (
+	milestoneField = "milestone"
+	estimateField  = "estimate"
+)
+
+// milestoneSelection fetches the milestone of the Issue or Pull Request
+const milestoneSelection = "content { ...on Issue { milestone { title } } ...on PullRequest { milestone { title } } }"
+
+// registerMilestoneFields registers the fragments used by the milestone reporter, if it is configured
+func registerMilestoneFields(fragments *Fragments, cfg Config) error {
+	var used bool
+	for _, spec := range cfg.Reporters {
+		if name, _, _ := strings.Cut(spec, "="); name == "milestone" {
+			used = true
+		}
+	}
+	if !used {
+		return nil
+	}
+
+	if err := fragments.Register(milestoneField, milestoneSelection); err != nil {
+		return err
+	}
+
+	if cfg.EstimateField != "" {
+		return fragments.Register(estimateField, numberFieldSelection(cfg.EstimateField))
+	}
+
+	return nil
+}
+
+// hasMilestone returns true if the result's Issue or Pull Request is assigned to a milestone
+func hasMilestone(result Result) bool {
+	var content struct {
+		Milestone *struct {
+			Title string
+		}
+	}
+	raw, ok := result.Extra[milestoneField]
+	return ok && json.Unmarshal(raw, &content) == nil && content.Milestone != nil
+}
+
+// Suggestion is an item suggested for the next milestone
+type Suggestion struct {
+	Result
+	Cost float64
+}
+
+// SuggestMilestone returns the open items that are not assigned to a milestone which give the most
+// upvotes for the capacity. Items are picked greedily by upvotes per point of cost until either the
+// budget of points or the capacity in items is used up; a zero budget or capacity is unlimited. An
+// item's cost is its estimate, or 1 if it has none.
+func SuggestMilestone(results []Result, budget float64, capacity int) []Suggestion {
+	var candidates []Suggestion
+	for _, result := range results {
+		if !exportable(result) || hasMilestone(result) {
+			continue
+		}
+
+		cost, ok := numberField(Item{Extra: result.Extra}, estimateField)
+		if !ok || cost <= 0 {
+			cost = 1
+		}
+		candidates = append(candidates, Suggestion{Result: result, Cost: cost})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Upvotes/candidates[i].Cost > candidates[j].Upvotes/candidates[j].Cost
+	})
+
+	var picked []Suggestion
+	var spent float64
+	for _, candidate := range candidates {
+		if capacity > 0 && len(picked) >= capacity {
+			break
+		}
+		if budget > 0 && spent+candidate.Cost > budget {
+			continue
+		}
+		picked = append(picked, candidate)
+		spent += candidate.Cost
+	}
+
+	return picked
+}
+
+// milestoneReporter writes the items suggested for the next milestone as a Markdown checklist, for
+// pasting into planning documents
+type milestoneReporter struct {
+	collector
+	path     string
+	budget   float64
+	capacity int
+}
+
+// Finish writes the file
+func (m *milestoneReporter) Finish(summary Summary) error {
+	suggestions := SuggestMilestone(m.results, m.budget, m.capacity)
+
+	var spent, upvotes float64
+	for _, s := range suggestions {
+		spent += s.Cost
+		upvotes += s.Upvotes
+	}
+
+	var b strings.Builder
+	b.WriteString("### Suggested for the next milestone\n\n")
+	fmt.Fprintf(&b, "%d open items without a milestone, with %v upvotes for %v points", len(suggestions), upvotes, spent)
+	switch {
+	case m.budget > 0 && m.capacity > 0:
+		fmt.Fprintf(&b, " (budget %v points, capacity %d items)", m.budget, m.capacity)
+	case m.budget > 0:
+		fmt.Fprintf(&b, " (budget %v points)", m.budget)
+	case m.capacity > 0:
+		fmt.Fprintf(&b, " (capacity %d items)", m.capacity)
+	}
+	b.WriteString(".\n\n")
+
+	for _, s := range suggestions {
+		fmt.Fprintf(&b, "- [ ] %s: %v upvotes, %v points\n", markdownLink(s.Result), s.Upvotes, s.Cost)
+	}
+
+	return os.WriteFile(m.path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// defaultOrgAuditPath is the file in the organization's audit repository that runs are recorded in,
+// unless another is configured
+const defaultOrgAuditPath = "audit/github-upvotes.jsonl"
+
+// OrgAuditRecord is the record of a run kept in an organization's audit repository: who ran it, with
+// which token, and the items it changed. Records committed to a file are chained, each holding the
+// hash of the line before it, so that a record removed or edited after the fact breaks the chain.
+type OrgAuditRecord struct {
+	RunID      string           `json:"run_id"`
+	ProjectID  githubv4.ID      `json:"project_id"`
+	FieldID    githubv4.ID      `json:"field_id"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Actor      string           `json:"actor"`
+	Token      string           `json:"token"`
+	Statuses   map[Status]int   `json:"statuses"`
+	Changes    []OrgAuditChange `json:"changes"`
+	Previous   string           `json:"previous,omitempty"`
+}
+
+// OrgAuditChange is an item whose field was written by the run
+type OrgAuditChange struct {
+	ItemID   githubv4.ID `json:"item_id"`
+	OldValue float64     `json:"old_value"`
+	NewValue float64     `json:"new_value"`
+}
+
+// tokenFingerprint identifies a token in audit records without revealing it
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// lineHash returns the hash a record holds of the line before it
+func lineHash(line string) string {
+	sum := sha256.Sum256([]byte(line))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// orgAuditReporter records each run in a repository owned by the organization, either as a line
+// committed to a JSON Lines file on the repository's default branch, or as a comment on an issue. Unlike
+// the audit log in the state directory, the record is kept out of reach of whoever runs the tool, once
+// the repository is protected.
+type orgAuditReporter struct {
+	collector
+	gh    *githubv4.Client
+	repo  string
+	path  string
+	issue int
+	token string
+	ctx   context.Context
+}
+
+// SetContext sets the context that the record is written with
+func (o *orgAuditReporter) SetContext(ctx context.Context) {
+	o.ctx = ctx
+}
+
+// Finish writes the record of the run
+func (o *orgAuditReporter) Finish(summary Summary) error {
+	ctx := o.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var viewer struct {
+		Viewer struct {
+			Login string
+		}
+	}
+	if err := runQuery(ctx, o.gh, &viewer, nil); err != nil {
+		return fmt.Errorf("reading the token's user for the org audit: %w", err)
+	}
+
+	record := OrgAuditRecord{
+		RunID:      o.run.ID,
+		ProjectID:  o.run.ProjectID,
+		FieldID:    o.run.FieldID,
+		StartedAt:  o.run.StartedAt,
+		FinishedAt: time.Now().UTC(),
+		Actor:      viewer.Viewer.Login,
+		Token:      tokenFingerprint(o.token),
+		Statuses:   summary.Statuses,
+		Changes:    []OrgAuditChange{},
+	}
+	for _, result := range o.results {
+		if result.Status == StatusUpdated {
+			record.Changes = append(record.Changes, OrgAuditChange{ItemID: result.ItemID, OldValue: result.Previous, NewValue: result.Value})
+		}
+	}
+
+	owner, name, _ := strings.Cut(o.repo, "/")
+	if o.issue > 0 {
+		return o.comment(ctx, owner, name, record)
+	}
+	return o.commit(ctx, owner, name, record)
+}
+
+// OrgAuditFileQuery reads the head of the default branch of the audit repository, and the audit file
+// on it
+type OrgAuditFileQuery struct {
+	Repository struct {
+		DefaultBranchRef struct {
+			Name   string
+			Target struct {
+				Oid githubv4.GitObjectID
+			}
+		}
+		Object struct {
+			Blob struct {
+				Text string
+			} `graphql:"...on Blob"`
+		} `graphql:"object(expression: $expression)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// commit appends the record to the audit file, chained to the line before it. The commit is made
+// against the head the file was read from, so a concurrent commit fails the write rather than being
+// overwritten.
+func (o *orgAuditReporter) commit(ctx context.Context, owner, name string, record OrgAuditRecord) error {
+	var query OrgAuditFileQuery
+	variables := Variables().String("owner", owner).String("name", name).String("expression", "HEAD:"+o.path)
+	if err := runQuery(ctx, o.gh, &query, variables); err != nil {
+		return fmt.Errorf("reading the org audit file: %w", err)
+	}
+	branch := query.Repository.DefaultBranchRef
+	if branch.Name == "" {
+		return fmt.Errorf("org audit repository %s has no default branch", o.repo)
+	}
+
+	text := strings.TrimRight(query.Repository.Object.Blob.Text, "\n")
+	if text != "" {
+		record.Previous = lineHash(text[strings.LastIndex(text, "\n")+1:])
+		text += "\n"
+	}
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	text += string(b) + "\n"
+
+	var m struct {
+		CreateCommitOnBranch struct {
+			Commit struct {
+				Oid githubv4.GitObjectID
+			}
+		} `graphql:"createCommitOnBranch(input: $input)"`
+	}
+	input := githubv4.CreateCommitOnBranchInput{
+		Branch: githubv4.CommittableBranch{
+			RepositoryNameWithOwner: githubv4.NewString(githubv4.String(o.repo)),
+			BranchName:              githubv4.NewString(githubv4.String(branch.Name)),
+		},
+		Message:         githubv4.CommitMessage{Headline: githubv4.String(fmt.Sprintf("Record github-upvotes run %s", record.RunID))},
+		ExpectedHeadOid: branch.Target.Oid,
+		FileChanges: &githubv4.FileChanges{
+			Additions: &[]githubv4.FileAddition{{
+				Path:     githubv4.String(o.path),
+				Contents: githubv4.Base64String(base64.StdEncoding.EncodeToString([]byte(text))),
+			}},
+		},
+	}
+	if err := o.gh.Mutate(ctx, &m, input, nil); err != nil {
+		return fmt.Errorf("committing to the org audit file: %w", err)
+	}
+
+	return nil
+}
+
+// comment adds the record to the audit issue as a comment
+func (o *orgAuditReporter) comment(ctx context.Context, owner, name string, record OrgAuditRecord) error {
+	var query struct {
+		Repository struct {
+			Issue struct {
+				Id githubv4.ID
+			} `graphql:"issue(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := Variables().String("owner", owner).String("name", name).Int("number", o.issue)
+	if err := runQuery(ctx, o.gh, &query, variables); err != nil {
+		return fmt.Errorf("reading the org audit issue: %w", err)
+	}
+
+	b, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("github-upvotes run `%s` by @%s changed %d items.\n\n```json\n%s\n```\n", record.RunID, record.Actor, len(record.Changes), b)
+
+	var m struct {
+		AddComment struct {
+			CommentEdge struct {
+				Node struct {
+					Id githubv4.ID
+				}
+			}
+		} `graphql:"addComment(input: $input)"`
+	}
+	input := githubv4.AddCommentInput{SubjectID: query.Repository.Issue.Id, Body: githubv4.String(body)}
+	if err := o.gh.Mutate(ctx, &m, input, nil); err != nil {
+		return fmt.Errorf("commenting on the org audit issue: %w", err)
+	}
+
+	return nil
+}
+
+// validateOrgAuditRepo returns an error if the repository is not given as owner/name
+func validateOrgAuditRepo(repo string) error {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid org audit repository %q: expected owner/name", repo)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestOrgAudit runs a fresh synthetic project twice with an org audit repository, and checks that each
+// run is committed to the audit file, that the first records the items it wrote, and that the second is
+// chained to the first
+func TestOrgAudit(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.StateDir = ""
+	cfg.OrgAuditRepo = "selftest/audit"
+	cfg.OrgAuditPath = defaultOrgAuditPath
+	cfg.Token = "selftest-token"
+	server := newFakeGitHub()
+
+	for i := 0; i < 2; i++ {
+		run(t, cfg, server)
+	}
+	expectCount(t, "audit commits", server.auditCommits, 2)
+
+	lines := strings.Split(strings.TrimSuffix(server.auditFile, "\n"), "\n")
+	expectCount(t, "audit records", len(lines), 2)
+	records := make([]OrgAuditRecord, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if records[0].Actor != selftestAuthor || records[0].Token != tokenFingerprint(cfg.Token) {
+		t.Fatalf("expected the run by %s with %s, got %s with %s", selftestAuthor, tokenFingerprint(cfg.Token), records[0].Actor, records[0].Token)
+	}
+	expectCount(t, "changes recorded by the first run", len(records[0].Changes), len(server.mutations))
+	expectCount(t, "changes recorded by the second run", len(records[1].Changes), 0)
+	if records[0].Previous != "" || records[1].Previous != lineHash(lines[0]) {
+		t.Fatalf("expected the second record to be chained to the first, got %q", records[1].Previous)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// fileOwner returns false, as files are not owned by numeric users and groups on this platform
+func fileOwner(path string) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
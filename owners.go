@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// changedFilesField is the name that the files changed by a Pull Request are fetched under
+const changedFilesField = "changed_files"
+
+// changedFilesSelection fetches the paths of the files changed by a Pull Request, to find its code owners
+const changedFilesSelection = "content { ...on PullRequest { files(first: 100) { nodes { path } } } }"
+
+// Owners maps items to the teams that own them, from area labels and from the CODEOWNERS of the files
+// a Pull Request changes, so that the items of some teams can be excluded from a shared project, or
+// weighed differently. Team names are compared without regard to case, and with or without the @ of
+// a CODEOWNERS file.
+type Owners struct {
+	// Labels maps labels, such as area/networking, to the team that owns the items with them
+	Labels map[string]string `mapstructure:"labels"`
+
+	// CodeOwners finds the owners of each Pull Request from the CODEOWNERS file of its repository
+	CodeOwners bool `mapstructure:"codeowners"`
+
+	// Exclude lists the teams whose items are skipped rather than scored
+	Exclude []string `mapstructure:"exclude"`
+
+	// Weights multiplies the upvotes of the items owned by each team. An item owned by several teams
+	// with a weight is given the largest.
+	Weights map[string]float64 `mapstructure:"weights"`
+}
+
+// Enabled returns true if items are mapped to owners at all
+func (o Owners) Enabled() bool {
+	return len(o.Labels) > 0 || o.CodeOwners
+}
+
+// ownerName normalizes the name of an owner for comparison
+func ownerName(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+}
+
+// normalizeOwners checks the owner mapping and normalizes its labels and teams for comparison
+func normalizeOwners(o Owners) (Owners, error) {
+	normalized := Owners{CodeOwners: o.CodeOwners}
+
+	if len(o.Labels) > 0 {
+		normalized.Labels = make(map[string]string, len(o.Labels))
+		for label, team := range o.Labels {
+			if ownerName(team) == "" {
+				return o, fmt.Errorf("owners: label %q has no team", label)
+			}
+			normalized.Labels[strings.ToLower(label)] = ownerName(team)
+		}
+	}
+
+	for _, team := range o.Exclude {
+		normalized.Exclude = append(normalized.Exclude, ownerName(team))
+	}
+
+	if len(o.Weights) > 0 {
+		normalized.Weights = make(map[string]float64, len(o.Weights))
+		for team, weight := range o.Weights {
+			if weight < 0 {
+				return o, fmt.Errorf("owners: weight of %s must not be negative", team)
+			}
+			normalized.Weights[ownerName(team)] = weight
+		}
+	}
+
+	if !normalized.Enabled() && (len(normalized.Exclude) > 0 || len(normalized.Weights) > 0) {
+		return o, fmt.Errorf("owners: excluding or weighing teams requires labels or codeowners to find them")
+	}
+
+	return normalized, nil
+}
+
+// Excluded returns true if any of the owners is excluded
+func (o Owners) Excluded(owners []string) bool {
+	for _, owner := range owners {
+		for _, excluded := range o.Exclude {
+			if owner == excluded {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Weight returns the largest weight of the owners, and the owner it is given to, or false if none of
+// them has a weight
+func (o Owners) Weight(owners []string) (string, float64, bool) {
+	var team string
+	var weight float64
+	found := false
+	for _, owner := range owners {
+		if w, ok := o.Weights[owner]; ok && (!found || w > weight) {
+			team, weight, found = owner, w, true
+		}
+	}
+	return team, weight, found
+}
+
+// ownerComponent returns the component that multiplies the total of the components by the weight of
+// the item's owners, and false if none of them has a weight
+func ownerComponent(o Owners, owners []string, components []ScoreComponent) (ScoreComponent, bool) {
+	team, weight, ok := o.Weight(owners)
+	if !ok || weight == 1 {
+		return ScoreComponent{}, false
+	}
+	return ScoreComponent{Name: "owner", Value: Total(components) * (weight - 1), Reason: team}, true
+}
+
+// registerOwnerFields registers the fragment used to fetch the files changed by Pull Requests, if their
+// owners are found through CODEOWNERS
+func registerOwnerFields(fragments *Fragments, cfg Config) error {
+	if !cfg.Owners.CodeOwners {
+		return nil
+	}
+	return fragments.Register(changedFilesField, changedFilesSelection)
+}
+
+// changedFiles returns the paths of the files changed by the item's Pull Request, fetched under
+// changedFilesField
+func changedFiles(extra map[string]json.RawMessage) []string {
+	raw, ok := extra[changedFilesField]
+	if !ok {
+		return nil
+	}
+
+	var content struct {
+		Files struct {
+			Nodes []struct {
+				Path string
+			}
+		}
+	}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(content.Files.Nodes))
+	for _, file := range content.Files.Nodes {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+// CodeOwnersQuery is used to read the CODEOWNERS file of a repository from each of the locations GitHub
+// looks in, in the order it looks in them
+type CodeOwnersQuery struct {
+	Repository struct {
+		GitHub CodeOwnersBlob `graphql:"github: object(expression: \"HEAD:.github/CODEOWNERS\")"`
+		Root   CodeOwnersBlob `graphql:"root: object(expression: \"HEAD:CODEOWNERS\")"`
+		Docs   CodeOwnersBlob `graphql:"docs: object(expression: \"HEAD:docs/CODEOWNERS\")"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// CodeOwnersBlob is a CODEOWNERS file, which is empty if it does not exist
+type CodeOwnersBlob struct {
+	Blob struct {
+		Text string
+	} `graphql:"...on Blob"`
+}
+
+// text returns the first CODEOWNERS file found, or an empty string if the repository has none
+func (q CodeOwnersQuery) text() string {
+	for _, blob := range []CodeOwnersBlob{q.Repository.GitHub, q.Repository.Root, q.Repository.Docs} {
+		if blob.Blob.Text != "" {
+			return blob.Blob.Text
+		}
+	}
+	return ""
+}
+
+// codeOwnersRule is a line of a CODEOWNERS file: the paths it matches, and their owners
+type codeOwnersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// parseCodeOwners parses a CODEOWNERS file, skipping comments and invalid patterns, as GitHub does
+func parseCodeOwners(text string) []codeOwnersRule {
+	var rules []codeOwnersRule
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		pattern, err := codeOwnersPattern(fields[0])
+		if err != nil {
+			continue
+		}
+		rule := codeOwnersRule{pattern: pattern}
+		for _, owner := range fields[1:] {
+			rule.owners = append(rule.owners, ownerName(owner))
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// codeOwnersPattern translates a CODEOWNERS pattern, which follows the rules of .gitignore, into a
+// regular expression matching the paths of the files it applies to. A pattern without a slash, other
+// than a trailing one, matches at any depth, and a pattern matching a directory matches every file in it.
+func codeOwnersPattern(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("(?:/.*)?$")
+
+	return regexp.Compile(strings.Replace(b.String(), "/(?:/.*)?$", "/.*$", 1))
+}
+
+// codeOwnersOf returns the owners of the file at path: those of the last rule matching it
+func codeOwnersOf(rules []codeOwnersRule, path string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].pattern.MatchString(path) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+// ownerResolver finds the owners of project items, reading the CODEOWNERS file of each repository once
+type ownerResolver struct {
+	gh      *githubv4.Client
+	limiter *rateLimiter
+	owners  Owners
+
+	mu         sync.Mutex
+	codeOwners map[string][]codeOwnersRule
+}
+
+// newOwnerResolver returns the resolver for the owner mapping, or nil if items are not mapped to owners
+func newOwnerResolver(gh *githubv4.Client, limiter *rateLimiter, owners Owners) *ownerResolver {
+	if !owners.Enabled() {
+		return nil
+	}
+	return &ownerResolver{gh: gh, limiter: limiter, owners: owners, codeOwners: make(map[string][]codeOwnersRule)}
+}
+
+// Resolve returns the owners of the item, sorted: the teams of its labels, and for Pull Requests the
+// code owners of the files it changes. A CODEOWNERS file that cannot be read is logged, and treated as
+// empty for the rest of the run.
+func (r *ownerResolver) Resolve(ctx context.Context, item ProjectItemFragment, extra map[string]json.RawMessage) []string {
+	if r == nil {
+		return nil
+	}
+
+	found := make(map[string]bool)
+	content := item.GetContent()
+	for _, label := range content.LabelNames() {
+		if team, ok := r.owners.Labels[strings.ToLower(label)]; ok {
+			found[team] = true
+		}
+	}
+
+	if r.owners.CodeOwners && item.Content.Type == "PullRequest" && content.Url.URL != nil {
+		rules := r.rules(ctx, content.Url.URL)
+		for _, path := range changedFiles(extra) {
+			for _, owner := range codeOwnersOf(rules, path) {
+				found[owner] = true
+			}
+		}
+	}
+
+	owners := make([]string, 0, len(found))
+	for owner := range found {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// rules returns the CODEOWNERS rules of the repository of the content at u, reading them the first time
+func (r *ownerResolver) rules(ctx context.Context, u *url.URL) []codeOwnersRule {
+	repo := contentRepo(u)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rules, ok := r.codeOwners[repo]; ok {
+		return rules
+	}
+
+	owner, name, _ := strings.Cut(repo, "/")
+	var query CodeOwnersQuery
+	err := r.limiter.Wait(ctx)
+	if err == nil {
+		err = runQuery(withQueryKind(ctx, "codeowners"), r.gh, &query, Variables().String("owner", owner).String("name", name))
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read CODEOWNERS, the repository's pull requests are only mapped by label", "repository", repo, "error", err)
+	}
+
+	rules := parseCodeOwners(query.text())
+	r.codeOwners[repo] = rules
+	return rules
+}
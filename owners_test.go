@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestOwners maps a Pull Request to its owners through an area label and the CODEOWNERS of the files it
+// changes, and checks the exclusion, weight, and grouping of its owners
+func TestOwners(t *testing.T) {
+	ctx := context.Background()
+	owners, err := normalizeOwners(Owners{
+		Labels:     map[string]string{"Area/Docs": "@Acme/Docs"},
+		CodeOwners: true,
+		Exclude:    []string{"acme/security"},
+		Weights:    map[string]float64{"acme/docs": 2, "acme/api": 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := parseCodeOwners("# owners\n*.md @acme/docs\n/api/ @acme/api\n/api/internal/** @acme/security\nbuild/ @acme/infra # anywhere\n")
+	for path, want := range map[string]string{
+		"README.md":             "acme/docs",
+		"api/v1/handler.go":     "acme/api",
+		"api/internal/keys.go":  "acme/security",
+		"tools/build/run.sh":    "acme/infra",
+		"cmd/api/main.go":       "",
+		"docs/api/reference.md": "acme/docs",
+	} {
+		if got := strings.Join(codeOwnersOf(rules, path), ","); got != want {
+			t.Fatalf("owners of %s: expected %q, got %q", path, want, got)
+		}
+	}
+
+	u, err := url.Parse("https://github.com/acme/app/pull/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item ProjectItemFragment
+	item.Content.Type = "PullRequest"
+	item.Content.PullRequest.Url = githubv4.URI{URL: u}
+	item.Content.PullRequest.Labels.Nodes = append(item.Content.PullRequest.Labels.Nodes, struct{ Name string }{"area/docs"})
+
+	// the repository's CODEOWNERS is already read, so the resolver makes no request
+	resolver := newOwnerResolver(nil, nil, owners)
+	resolver.codeOwners["acme/app"] = rules
+	extra := map[string]json.RawMessage{changedFilesField: json.RawMessage(`{"files":{"nodes":[{"path":"api/v1/handler.go"}]}}`)}
+	found := resolver.Resolve(ctx, item, extra)
+	if got := strings.Join(found, ","); got != "acme/api,acme/docs" {
+		t.Fatalf("expected owners acme/api,acme/docs, got %q", got)
+	}
+	if owners.Excluded(found) {
+		t.Fatal("expected acme/api and acme/docs not to be excluded")
+	}
+	if !owners.Excluded([]string{"acme/docs", "acme/security"}) {
+		t.Fatal("expected acme/security to be excluded")
+	}
+
+	component, ok := ownerComponent(owners, found, []ScoreComponent{{Name: "reactions", Value: 10}})
+	if !ok || component.Value != 10 || component.Reason != "acme/docs" {
+		t.Fatalf("expected the weight of acme/docs to add 10, got %+v", component)
+	}
+
+	grouper, err := ParseGroupBy("owner:Acme/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(grouper.Keys(Result{Content: ContentInfo{Owners: found}}), ","); got != "acme/api,acme/docs" {
+		t.Fatalf("expected groups acme/api,acme/docs, got %q", got)
+	}
+}
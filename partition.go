@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// RangeAssignment splits the items of a project into contiguous cursor ranges, so that a project that
+// is too large to process in a single run can be processed across several invocations
+type RangeAssignment struct {
+	ProjectID githubv4.ID `json:"project_id"`
+	Total     int         `json:"total"`
+	Ranges    []Range     `json:"ranges"`
+}
+
+// Range is a contiguous set of project items. Processing starts after the After cursor, or at the
+// first item if it is empty, and stops once Count items have been seen.
+type Range struct {
+	Index int    `json:"index"`
+	After string `json:"after"`
+	Count int    `json:"count"`
+}
+
+// Partition pages through the cursors of every item in the project, and splits them into the given
+// number of ranges of roughly equal size. Only the cursors are queried, so this is much cheaper than
+// processing the items.
+func Partition(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID, ranges int) (RangeAssignment, error) {
+	assignment := RangeAssignment{ProjectID: projectId}
+
+	if ranges < 1 {
+		return assignment, fmt.Errorf("number of ranges must be at least 1")
+	}
+
+	var query ProjectItemCursorsQuery
+	variables := Variables().ID("nodeId", projectId).Cursor("cursor", "")
+
+	var cursors []githubv4.String
+	for {
+		if err := runQuery(withQueryKind(ctx, "cursors"), gh, &query, variables); err != nil {
+			return assignment, err
+		}
+
+		for _, edge := range query.Items.Edges {
+			cursors = append(cursors, edge.Cursor)
+		}
+
+		slog.DebugContext(ctx, "listed project item cursors", "count", len(cursors), "total", query.Items.TotalCount)
+
+		if !query.Items.HasNextPage {
+			break
+		}
+		variables.Cursor("cursor", query.Items.EndCursor)
+	}
+
+	assignment.Total = len(cursors)
+	size := (assignment.Total + ranges - 1) / ranges
+
+	for start := 0; start < assignment.Total; start += size {
+		r := Range{
+			Index: len(assignment.Ranges),
+			Count: min(size, assignment.Total-start),
+		}
+		if start > 0 {
+			r.After = string(cursors[start-1])
+		}
+		assignment.Ranges = append(assignment.Ranges, r)
+	}
+
+	return assignment, nil
+}
+
+// WriteRangeAssignment writes the range assignment file
+func WriteRangeAssignment(path string, assignment RangeAssignment) error {
+	b, err := json.MarshalIndent(assignment, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// LoadRange reads a range from a range assignment file, ensuring it was generated for the project
+func LoadRange(path string, index int, projectId githubv4.ID) (Range, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Range{}, fmt.Errorf("reading range file: %w", err)
+	}
+
+	var assignment RangeAssignment
+	if err := json.Unmarshal(b, &assignment); err != nil {
+		return Range{}, fmt.Errorf("parsing range file: %w", err)
+	}
+
+	if fmt.Sprint(assignment.ProjectID) != fmt.Sprint(projectId) {
+		return Range{}, fmt.Errorf("range file was generated for project %v, not %v", assignment.ProjectID, projectId)
+	}
+
+	if index < 0 || index >= len(assignment.Ranges) {
+		return Range{}, fmt.Errorf("range %d does not exist, range file has %d ranges", index, len(assignment.Ranges))
+	}
+
+	return assignment.Ranges[index], nil
+}
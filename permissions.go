@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Kinds of token, told apart by their prefix
+const (
+	TokenClassic      = "classic"
+	TokenFineGrained  = "fine-grained"
+	TokenOAuth        = "oauth"
+	TokenInstallation = "installation"
+	TokenUnknown      = "unknown"
+)
+
+// tokenKind returns the kind of the token from its prefix
+func tokenKind(token string) string {
+	switch {
+	case strings.HasPrefix(token, "github_pat_"):
+		return TokenFineGrained
+	case strings.HasPrefix(token, "ghp_"):
+		return TokenClassic
+	case strings.HasPrefix(token, "gho_"), strings.HasPrefix(token, "ghu_"):
+		return TokenOAuth
+	case strings.HasPrefix(token, "ghs_"):
+		return TokenInstallation
+	}
+	return TokenUnknown
+}
+
+// Permissions of a fine-grained token, as they are named on its settings page
+const (
+	permissionProjectsRead  = "Organization permissions > Projects: Read-only"
+	permissionProjectsWrite = "Organization permissions > Projects: Read and write"
+	permissionIssues        = "Repository permissions > Issues: Read-only"
+	permissionPullRequests  = "Repository permissions > Pull requests: Read-only"
+	permissionRepositories  = "Repository access: All repositories, or the repositories of the project's items"
+)
+
+// PermissionsQuery probes what a token can do with a project: whether it can read and update it, who
+// owns it, and whether it can read the content of its first 20 items
+type PermissionsQuery struct {
+	Node *struct {
+		ProjectV2 struct {
+			ViewerCanUpdate bool
+			Owner           struct {
+				Typename string `graphql:"__typename"`
+			}
+			Items struct {
+				Nodes []struct {
+					Type    string
+					Content *struct {
+						Typename string `graphql:"__typename"`
+					}
+				}
+			} `graphql:"items(first: 20)"`
+		} `graphql:"...on ProjectV2"`
+	} `graphql:"node(id: $nodeId)"`
+}
+
+// missingPermissions probes the project with a fine-grained token, and returns the permissions the
+// token needs that it is missing, in the order they are set on the token's settings page. Readonly is
+// true if the only permission missing is the one to update the project, without which runs still write
+// a plan.
+func missingPermissions(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID) (missing []string, readonly bool, err error) {
+	var query PermissionsQuery
+	if err := runQuery(ctx, gh, &query, Variables().ID("nodeId", projectId)); err != nil {
+		if !permissionDenied(err) {
+			return nil, false, err
+		}
+	}
+	if query.Node == nil {
+		return []string{permissionProjectsRead}, false, nil
+	}
+
+	project := query.Node.ProjectV2
+	if project.Owner.Typename == "User" {
+		return nil, false, fmt.Errorf("the project is owned by a user, whose projects fine-grained tokens cannot access; use a classic token with the project scope")
+	}
+
+	var issues, pullRequests bool
+	for _, item := range project.Items.Nodes {
+		if item.Content != nil && item.Type != "REDACTED" {
+			continue
+		}
+		switch item.Type {
+		case "PULL_REQUEST":
+			pullRequests = true
+		case "ISSUE", "REDACTED":
+			issues = true
+		}
+	}
+
+	if issues || pullRequests {
+		missing = append(missing, permissionRepositories)
+	}
+	if issues {
+		missing = append(missing, permissionIssues)
+	}
+	if pullRequests {
+		missing = append(missing, permissionPullRequests)
+	}
+	if !project.ViewerCanUpdate {
+		missing = append(missing, permissionProjectsWrite)
+		readonly = len(missing) == 1
+	}
+
+	return missing, readonly, nil
+}
+
+// permissionErrorPattern matches the errors GitHub returns when a token lacks a permission
+var permissionErrorPattern = regexp.MustCompile(`(?i)resource not accessible by (personal access token|integration)`)
+
+// permissionDenied returns true if the error is GitHub denying the token a permission
+func permissionDenied(err error) bool {
+	return err != nil && permissionErrorPattern.MatchString(err.Error())
+}
+
+// diagnosePermissionError points errors caused by a missing permission to the doctor command, which
+// lists the permissions to enable, since GitHub's errors don't name them. Other errors are returned
+// unchanged.
+func diagnosePermissionError(err error) error {
+	if !permissionDenied(err) {
+		return err
+	}
+	return fmt.Errorf("%w: the token is missing a permission, run the doctor command to list the permissions to enable", err)
+}
+
+// checkPermissions records the permissions a fine-grained token is missing, as the exact toggles to
+// enable on its settings page
+func (r *DoctorReport) checkPermissions(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID) {
+	missing, readonly, err := missingPermissions(ctx, gh, projectId)
+	switch {
+	case err != nil:
+		r.add("permissions", DoctorFail, "%v", err)
+	case len(missing) == 0:
+		r.add("permissions", DoctorOK, "the fine-grained token can read and update the project and the items sampled")
+	case readonly:
+		r.add("permissions", DoctorWarn, "enable %s to update the project rather than write a plan", missing[0])
+	default:
+		r.add("permissions", DoctorFail, "enable %s", strings.Join(missing, "; "))
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestPermissions probes a synthetic project with a token that can read every Issue, and then with one
+// that cannot read some, and checks that only the second is missing repository access and Issues
+func TestPermissions(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeGitHub()
+	gh := githubv4.NewClient(&http.Client{Transport: &accessTransport{base: server}})
+
+	missing, _, err := missingPermissions(ctx, gh, "PVT_selftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "permissions missing with access to every Issue", len(missing), 0)
+
+	server.items[2].hidden = true
+	missing, readonly, err := missingPermissions(ctx, gh, "PVT_selftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{permissionRepositories, permissionIssues}; readonly || strings.Join(missing, "; ") != strings.Join(want, "; ") {
+		t.Fatalf("expected %q to be missing, got %q", want, missing)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+)
+
+// profilesFile is the name of the scoring profile state file within the state directory
+const profilesFile = "profiles.json"
+
+// ProfileState records the ID of the scoring profile that each item's written value was calculated
+// with, so that values calculated with a previous profile can be reported as stale
+type ProfileState struct {
+	Items map[string]string `json:"items"`
+}
+
+// NewProfileState returns an empty ProfileState
+func NewProfileState() *ProfileState {
+	return &ProfileState{Items: make(map[string]string)}
+}
+
+// Record tags the value written for the item with the profile ID
+func (p *ProfileState) Record(itemId interface{}, profile string) {
+	p.Items[fmt.Sprint(itemId)] = profile
+}
+
+// Profiles returns the number of items tagged with each profile ID
+func (p *ProfileState) Profiles() map[string]int {
+	counts := make(map[string]int)
+	for _, profile := range p.Items {
+		counts[profile]++
+	}
+	return counts
+}
+
+// Stale returns the IDs of the items whose values were calculated with a profile other than the one
+// they are now scored with
+func (p *ProfileState) Stale(profileOf func(itemId interface{}) string) []string {
+	var stale []string
+	for id, tagged := range p.Items {
+		if tagged != profileOf(id) {
+			stale = append(stale, id)
+		}
+	}
+	return stale
+}
+
+// Observe tags the result's value with the profile if the value was written, or the field already
+// held the calculated value
+func (p *ProfileState) Observe(result Result, profile string) {
+	switch result.Status {
+	case StatusUpdated, StatusUnchanged:
+		p.Record(result.ItemID, profile)
+	}
+}
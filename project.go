@@ -2,46 +2,121 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/shurcooL/githubv4"
 )
 
 // GetProjectItems pages through the list of items within the GitHub Project. It requires a context, GitHub client,
-// the ID of the GitHub Project, and a channel on which to send errors. It returns a channel that receives ProjectItemEdgeFragment
-// types, and a WaitGroup used for synchronizing when the next page should be queried.
-func GetProjectItems(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID, errChan chan<- error) (<-chan ProjectItemEdgeFragment, *sync.WaitGroup) {
-	out := make(chan ProjectItemEdgeFragment)
+// the Config containing the ID of the GitHub Project, any additional Fragments to fetch for each item, the rateLimiter
+// that each page waits on, the Checkpoint that pages are resumed from and recorded in, a channel on which to send the
+// results of skipped items, and a channel on which to send errors. It returns a channel that receives Item types, and a WaitGroup used for synchronizing when the next page
+// should be queried.
+func GetProjectItems(ctx context.Context, gh *githubv4.Client, cfg Config, fragments *Fragments, limiter *rateLimiter, checkpoint *Checkpoint, results chan<- Result, errChan chan<- error) (<-chan Item, *sync.WaitGroup) {
+	out := make(chan Item)
 	var wg sync.WaitGroup
 
 	var query ProjectItemsQuery
-	variables := map[string]interface{}{
-		"nodeId": projectId,
-		"cursor": (*githubv4.String)(nil),
+	variables := Variables().
+		ID("nodeId", cfg.ProjectID).
+		Cursor("cursor", "").
+		// the first page of each item's timeline; later pages are fetched separately, by fetchTimelines
+		Cursor("timelineCursor", "").
+		// whether to fetch the title, number, repository, and assignees of the content
+		Bool("enrich", cfg.Enrich).
+		// whether to fetch the bodies of comments, to find the mentions of teams
+		Bool("mentions", len(cfg.Teams) > 0).
+		// whether to fetch the title and body of the content, to search them for issue fields and keywords
+		Bool("text", cfg.fetchText())
 
-		// TODO: Fix this
-		// not used here, but a required variable nonetheless
-		"timelineCursor": (*githubv4.String)(nil),
+	// when processing a range, start after the range's cursor and stop once every item in it has been seen
+	var limit int
+	if cfg.Range != nil {
+		if cfg.Range.After != "" {
+			variables.Cursor("cursor", githubv4.String(cfg.Range.After))
+		}
+		limit = cfg.Range.Count
+	}
+
+	// a run with a checkpoint resumes after the last page that a previous run finished
+	if cursor := checkpoint.Resume(); cursor != "" {
+		variables.Cursor("cursor", githubv4.String(cursor))
 	}
 
+	owners := newOwnerResolver(gh, limiter, cfg.Owners)
+
 	go func() {
+		var seen int
+
 	pager:
 		for {
 			// paginated query, errors should cancel the context, need error channel as input
-			if err := gh.Query(ctx, &query, variables); err != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				errChan <- err
+				break
+			}
+			if err := runQuery(withQueryKind(ctx, "items"), gh, &query, variables); err != nil {
 				// send the error to the channel so that the context gets cancelled,
 				// break the for loop so that the channel gets closed
 				errChan <- err
 				break
 			}
+			limiter.Observe(query.RateLimit)
 
 			// work through the project items to see which ones should be skipped
+			var items []ProjectItemEdgeFragment
+			var ids []githubv4.ID
 			for _, item := range query.Items.Edges {
-				if !item.Skip() {
-					wg.Add(1)
-					out <- item
+				if limit > 0 && seen >= limit {
+					break
+				}
+				seen++
+
+				// recalculating every item includes closed and archived items, which keep their values otherwise
+				if status := item.SkipStatus(); status != "" && !(cfg.RecalculateAll && status != StatusSkippedDraft && status != StatusSkippedUnsupported && status != StatusNoAccess) {
+					results <- Result{ItemID: item.Id, Status: status, Previous: item.UpvotesField.Value}
+					continue
 				}
+
+				if status := filterStatus(cfg, item.ProjectItemFragment); status != "" {
+					results <- Result{ItemID: item.Id, Status: status, Previous: item.UpvotesField.Value}
+					continue
+				}
+
+				items = append(items, item)
+				ids = append(ids, item.Id)
+			}
+
+			// fetch any additional fields for the whole page at once
+			extra, err := fragments.Fetch(ctx, ids)
+			if err != nil {
+				errChan <- err
+				break
+			}
+
+			var scored []Item
+			for _, item := range items {
+				// items owned by an excluded team are only known to be once their owners are found
+				itemOwners := owners.Resolve(ctx, item.ProjectItemFragment, extra[item.Id])
+				if cfg.Owners.Excluded(itemOwners) {
+					results <- Result{ItemID: item.Id, Status: StatusSkippedExcluded, Previous: item.UpvotesField.Value}
+					continue
+				}
+				scored = append(scored, Item{ProjectItemEdgeFragment: item, Extra: extra[item.Id], Owners: itemOwners})
+			}
+
+			// the items of the page are counted, so that their writes can be ordered once all are scored
+			for _, item := range scored {
+				item.PageItems = len(scored)
+				wg.Add(1)
+				out <- item
 			}
 
 			// wait on waitgroup, context to be cancelled
@@ -50,12 +125,14 @@ func GetProjectItems(ctx context.Context, gh *githubv4.Client, projectId githubv
 			case <-ctx.Done():
 				break pager
 			default:
-				if !query.HasNextPage() {
+				// every item of the page was processed, so a later run can resume after it
+				checkpoint.Page(ctx, query.Items.EndCursor)
+				if !query.HasNextPage() || (limit > 0 && seen >= limit) {
 					break pager
 				}
 
 				// update the cursor before breaking the select and moving to the next iteration
-				variables["cursor"] = query.Items.EndCursor
+				variables.Cursor("cursor", query.Items.EndCursor)
 				break
 			}
 		}
@@ -65,96 +142,369 @@ func GetProjectItems(ctx context.Context, gh *githubv4.Client, projectId githubv
 	return out, &wg
 }
 
-// ProcessProjectItems processing incoming ProjectItemEdgeFragment types, calculates the number of upvotes, and
-// generates an Update type, representing the data required to update a project item's upvotes. It requires a context,
-// GitHub client, a channel in which to receive ProjectItemEdgeFragment types, and a channel on which to report errors.
-// It returns a channel that receives Update types.
-func ProcessProjectItems(ctx context.Context, gh *githubv4.Client, in <-chan ProjectItemEdgeFragment, errChan chan<- error) <-chan Update {
-	out := make(chan Update)
+// filterStatus returns the status to record if the Config filters the project item out of scoring, or
+// an empty Status if the item should be processed. Unlike SkipStatus, this applies even when every item
+// is being recalculated.
+func filterStatus(cfg Config, item ProjectItemFragment) Status {
+	switch {
+	case cfg.Rollout.ProfileFor(cfg.Scoring, item.Id).Disabled(item.Content.Type):
+		return StatusSkippedDisabled
+	case excludedRepo(cfg.ExcludeRepos, item.GetContent()):
+		return StatusSkippedExcluded
+	case cfg.IncludeRepos != nil && !includedRepo(cfg.IncludeRepos, item.GetContent()):
+		return StatusSkippedExcluded
+	case terminalStatus(cfg.TerminalStatuses, item.StatusField.SingleSelectValue.Name):
+		return StatusSkippedTerminal
+	}
 
-	process := func(item ProjectItemEdgeFragment) {
-		content := item.GetContent()
+	return ""
+}
 
-		if content.TimelineItems.HasNextPage {
-			var query ProjectItemQuery
+// excludedRepo returns true if the Issue or Pull Request belongs to one of the repositories, given as
+// owner/name. The repository is taken from the content's URL, so it is known without enrichment.
+func excludedRepo(repos []string, content ContentFragment) bool {
+	if len(repos) == 0 || content.Url.URL == nil {
+		return false
+	}
 
-			variables := map[string]interface{}{
-				"nodeId":         item.Id,
-				"timelineCursor": content.TimelineItems.EndCursor,
-			}
+	repo := contentRepo(content.Url.URL)
+	if repo == "" {
+		return false
+	}
 
-			for {
-				slog.Debug("querying for additional timeline items", "node_id", item.Id)
-				if err := gh.Query(ctx, &query, variables); err != nil {
-					errChan <- err
+	for _, excluded := range repos {
+		if strings.EqualFold(excluded, repo) {
+			return true
+		}
+	}
 
-					// TODO: This doesn't decrement the waitgroup from GetProjectItems
-					// which I think is a bug -- if I'm not mistaken, this could lead to deadlock
-					return
-				}
+	return false
+}
+
+// includedRepo returns true if the Issue or Pull Request belongs to one of the repositories, given as
+// owner/name, or if its repository is not known
+func includedRepo(repos []string, content ContentFragment) bool {
+	if content.Url.URL == nil {
+		return true
+	}
 
-				content.TimelineItems.Nodes = append(content.TimelineItems.Nodes, query.GetContent().TimelineItems.Nodes...)
+	repo := contentRepo(content.Url.URL)
+	if repo == "" {
+		return true
+	}
 
-				if !query.HasNextPage() {
-					break
-				}
+	for _, included := range repos {
+		if strings.EqualFold(included, repo) {
+			return true
+		}
+	}
 
-				variables["timelineCursor"] = query.GetContent().TimelineItems.EndCursor
-			}
+	return false
+}
+
+// terminalStatus returns true if the item's Status is one of the terminal statuses, compared without
+// regard to case. Items without a Status are never terminal.
+func terminalStatus(statuses []string, status string) bool {
+	if status == "" {
+		return false
+	}
+	for _, terminal := range statuses {
+		if strings.EqualFold(terminal, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentRepo returns the repository of an Issue or Pull Request, as owner/name, from its URL, or an
+// empty string if the URL is not one of an Issue or Pull Request
+func contentRepo(u *url.URL) string {
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// ProcessProjectItems processing incoming Item types, calculates the number of upvotes, and
+// generates an Update type, representing the data required to update a project item's upvotes. It requires a context,
+// GitHub client, the Scorer used to calculate the upvotes, the rateLimiter that additional queries wait on, and a
+// channel in which to receive Item types. Items with more than one page of timeline items are batched for up to
+// timelineBatchWait, and their additional pages fetched together. The ScoreCache, which may be nil, provides the
+// timeline components of items that have not been updated, and the pages already tallied for items that have, so
+// that only newer pages are fetched. It returns a channel that receives Update types, which is closed once the
+// input is closed and every item has been sent. Errors encountered while processing an item are attached to that
+// item's Update, including the context's error once it is cancelled; every item received is still sent.
+func ProcessProjectItems(ctx context.Context, gh *githubv4.Client, scorer *Scorer, limiter *rateLimiter, cache *ScoreCache, in <-chan Item) <-chan Update {
+	out := make(chan Update)
+
+	// score sends the update for an item, with the components of its timeline tallied from every page of
+	// its timeline items or taken from the score cache
+	score := func(item Item, content ContentFragment, tally CacheEntry, state cacheState, err error) {
+		update := Update{
+			Id:        item.Id,
+			Previous:  item.UpvotesField.Value,
+			Cursor:    item.Cursor,
+			Content:   content.Info(),
+			Extra:     item.Extra,
+			Archived:  item.IsArchived,
+			PageItems: item.PageItems,
+		}
+
+		if err != nil {
+			update.Err = err
+			out <- update
+			return
 		}
 
-		out <- Update{
-			Id:      item.Id,
-			Upvotes: githubv4.NewFloat(githubv4.Float(content.Upvotes())),
-			Cursor:  item.Cursor,
+		if state == cacheHit {
+			update.Cached = true
+		} else {
+			cache.Store(fmt.Sprint(item.Id), content, tally, state, time.Now())
 		}
+
+		update.Content.Links = tally.Links
+		update.Content.Responses = tally.Responses
+		update.Content.Owners = item.Owners
+		update.Components = scorer.ScoreComponents(item, content, append(content.countComponents(), tally.Components...))
+		update.Upvotes = githubv4.NewFloat(githubv4.Float(Total(update.Components)))
+		out <- update
+	}
+
+	// pending is an item whose additional pages of timeline items are to be fetched, resuming after the
+	// pages tallied by a previous run if its cache entry can be resumed
+	type pending struct {
+		item  Item
+		entry CacheEntry
+		state cacheState
 	}
 
 	go func() {
-		for item := range in {
-			go process(item)
+		var processing sync.WaitGroup
+
+		// items with more timeline items are batched, so that their additional pages are fetched together
+		var batch []pending
+		var timeout <-chan time.Time
+		flush := func() {
+			items := batch
+			batch, timeout = nil, nil
+
+			processing.Add(1)
+			go func() {
+				defer processing.Done()
+
+				contents := make([]ContentFragment, len(items))
+				fetches := make([]*timelineFetch, len(items))
+				for i, p := range items {
+					contents[i] = p.item.GetContent()
+					fetches[i] = &timelineFetch{id: githubv4.ID(contents[i].Id), cursor: contents[i].TimelineItems.EndCursor, content: &contents[i]}
+
+					// the first page was tallied by a previous run, so only the pages after it are fetched
+					if p.state == cacheResumed {
+						fetches[i].cursor = p.entry.Cursor
+						contents[i].TimelineItems.Nodes = nil
+					}
+				}
+
+				slog.DebugContext(ctx, "querying for additional timeline items", "items", len(items))
+				fetchTimelines(ctx, gh, limiter, scorer.Mentions(), fetches)
+
+				for i, p := range items {
+					tally := timelineTally(contents[i], fetches[i].cursor)
+					if p.state == cacheResumed {
+						tally = p.entry.add(tally)
+					}
+					score(p.item, contents[i], tally, p.state, fetches[i].err)
+				}
+			}()
+		}
+
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					if len(batch) > 0 {
+						flush()
+					}
+					processing.Wait()
+					close(out)
+					return
+				}
+
+				content := item.GetContent()
+				entry, state := cache.Lookup(fmt.Sprint(item.Id), content, time.Now())
+				if state == cacheHit || !content.TimelineItems.HasNextPage {
+					processing.Add(1)
+					go func(item Item) {
+						defer processing.Done()
+						if state == cacheHit {
+							score(item, content, entry, state, nil)
+							return
+						}
+						score(item, content, timelineTally(content, content.TimelineItems.EndCursor), cacheMiss, nil)
+					}(item)
+					continue
+				}
+
+				batch = append(batch, pending{item: item, entry: entry, state: state})
+				switch {
+				case len(batch) == timelineBatchSize:
+					flush()
+				case len(batch) == 1:
+					timeout = time.After(timelineBatchWait)
+				}
+			case <-timeout:
+				flush()
+			}
 		}
-		close(out)
 	}()
 
 	return out
 }
 
+// WritePolicy controls which values UpdateProjectItems writes to the project
+type WritePolicy struct {
+	// MinDelta is the minimum change in upvotes worth writing
+	MinDelta float64
+
+	// RoundTo is the step that written values are rounded to, or zero to write precise values
+	RoundTo float64
+
+	// SkipUnmodified leaves items scored from the cache alone, without comparing or writing their values
+	SkipUnmodified bool
+
+	// WholeRun holds every write until all of the run's items are scored, rather than those of each page,
+	// so that a run capped at a number of updates spends them on the largest changes of the whole run
+	WholeRun bool
+}
+
+// Value returns the value written to the project for the upvotes
+func (p WritePolicy) Value(upvotes float64) float64 {
+	if p.RoundTo <= 0 {
+		return upvotes
+	}
+	return math.Round(upvotes/p.RoundTo) * p.RoundTo
+}
+
 // UpdateProjectItems processes incoming Update types and uses them to update the project item's upvote count.
-// It requires a context, GitHub client, a WaitGroup for syncronizing pagination, the GitHub Project's ID,
-// and the ID of the custom 'upvotes' field on the Project. It returns a channel used to indicate that all
-// updates have completed.
-func UpdateProjectItems(ctx context.Context, gh *githubv4.Client, wg *sync.WaitGroup, projectId githubv4.ID, fieldId githubv4.ID, in <-chan Update, errChan chan<- error) <-chan struct{} {
+// It requires a context, a WaitGroup for syncronizing pagination, the FieldWriter used to write the upvotes,
+// the WritePolicy, and a channel on which to send the result of each item. Items whose value has not changed,
+// or changed by less than the policy's minimum, are not written. The writes of each page are held until every
+// item of the page is scored, or of the whole run if the policy says so, and made largest change first, so that
+// the most important corrections land first when the rate limit or the job's time runs short. It returns a
+// channel used to indicate that all updates have completed.
+func UpdateProjectItems(ctx context.Context, wg *sync.WaitGroup, writer FieldWriter, policy WritePolicy, in <-chan Update, results chan<- Result) <-chan struct{} {
 	out := make(chan struct{})
 
-	var mutation struct {
-		UpdateProjectItemV2FieldValue struct {
-			ClientMutationId string
-		} `graphql:"updateProjectV2ItemFieldValue(input: $input)"`
+	// evaluate returns the result of the update, and true if its value is to be written
+	evaluate := func(update Update) (Result, bool) {
+		result := Result{
+			ItemID:     update.Id,
+			Previous:   update.Previous,
+			Content:    update.Content,
+			Components: update.Components,
+			Extra:      update.Extra,
+			Archived:   update.Archived,
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			result.Status = StatusTruncated
+			return result, false
+		case update.Err != nil:
+			result.Status = StatusFailed
+			result.Err = update.Err
+			return result, false
+		}
+
+		result.Upvotes = float64(*update.Upvotes)
+		result.Value = policy.Value(result.Upvotes)
+		result.setAge(time.Now())
+		result.setActivity(time.Now())
+		result.Inactive = isInactive(result.Components)
+		if update.Cached && policy.SkipUnmodified {
+			result.Status = StatusSkippedUnmodified
+			return result, false
+		}
+		if result.Value == update.Previous {
+			result.Status = StatusUnchanged
+			return result, false
+		}
+
+		if math.Abs(result.Value-update.Previous) < policy.MinDelta {
+			result.Status = StatusSkippedBelowDelta
+			return result, false
+		}
+
+		return result, true
 	}
 
-	input := githubv4.UpdateProjectV2ItemFieldValueInput{
-		ProjectID: projectId,
-		FieldID:   fieldId,
+	// write writes the value of the result, and records the outcome
+	write := func(result Result) Result {
+		if ctx.Err() != nil {
+			result.Status = StatusTruncated
+			return result
+		}
+
+		result.Status, result.Err = writer.WriteUpvotes(ctx, result.ItemID, result.Previous, result.Value)
+		if result.Err != nil && ctx.Err() != nil {
+			result.Status = StatusTruncated
+		}
+		return result
 	}
 
 	go func() {
-		for update := range in {
-
-			input.ItemID = update.Id
-			input.Value = githubv4.ProjectV2FieldValue{Number: update.Upvotes}
+		// held are the writes waiting for the rest of their page, or of the run, to be scored, and seen the
+		// number of items of the page received so far
+		var held []Result
+		var seen int
+		flush := func(done bool) {
+			sort.SliceStable(held, func(i, j int) bool {
+				return math.Abs(held[i].Value-held[i].Previous) > math.Abs(held[j].Value-held[j].Previous)
+			})
+			for _, result := range held {
+				results <- write(result)
+				if done {
+					wg.Done()
+				}
+			}
+			held = nil
+		}
 
-			if err := gh.Mutate(ctx, &mutation, input, nil); err != nil {
-				errChan <- err
+		for u := range in {
+			result, candidate := evaluate(u)
 
-				// TODO: This doesn't decrement the waitgroup from GetProjectItems
-				// which I think is a bug -- if I'm not mistaken, this could lead to deadlock
-				break
+			switch {
+			case policy.WholeRun:
+				// the next page is only fetched once every item of this one is done with
+				if candidate {
+					held = append(held, result)
+				} else {
+					results <- result
+				}
+				wg.Done()
+			case u.PageItems == 0:
+				if candidate {
+					result = write(result)
+				}
+				results <- result
+				wg.Done()
+			default:
+				seen++
+				if candidate {
+					held = append(held, result)
+				} else {
+					results <- result
+					wg.Done()
+				}
+				if seen == u.PageItems {
+					flush(true)
+					seen = 0
+				}
 			}
-
-			wg.Done()
-			slog.Info("updated project item", "item_id", update.Id, "upvotes", *update.Upvotes)
 		}
+
+		flush(!policy.WholeRun)
 		close(out)
 	}()
 
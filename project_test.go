@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestTerminalStatus checks that items are skipped if their Status is terminal, whatever its case, and
+// that items without a Status are scored
+func TestTerminalStatus(t *testing.T) {
+	cfg := Config{TerminalStatuses: []string{"Done", "Won't do"}}
+	for status, want := range map[string]Status{"": "", "In progress": "", "done": StatusSkippedTerminal, "Won't do": StatusSkippedTerminal} {
+		var item ProjectItemFragment
+		item.Content.Type = "Issue"
+		item.StatusField.SingleSelectValue.Name = status
+		if got := filterStatus(cfg, item); got != want {
+			t.Fatalf("status %q: expected %q, got %q", status, want, got)
+		}
+	}
+}
+
+// orderWriter records the changes it is asked to write, in order
+type orderWriter struct {
+	changes []float64
+}
+
+// WriteUpvotes records the change
+func (w *orderWriter) WriteUpvotes(ctx context.Context, itemId githubv4.ID, previous, upvotes float64) (Status, error) {
+	w.changes = append(w.changes, upvotes-previous)
+	return StatusUpdated, nil
+}
+
+// TestWriteOrder checks that the writes of each page are made largest change first, and those of the
+// whole run when the policy holds them for the run
+func TestWriteOrder(t *testing.T) {
+	pages := [][]float64{{1, -5, 3}, {2, 4}}
+	for policy, want := range map[WritePolicy][]float64{
+		{}:               {-5, 3, 1, 4, 2},
+		{WholeRun: true}: {-5, 4, 3, 2, 1},
+	} {
+		var wg sync.WaitGroup
+		in := make(chan Update)
+		results := make(chan Result)
+		writer := &orderWriter{}
+		done := UpdateProjectItems(context.Background(), &wg, writer, policy, in, results)
+		go func() {
+			for range results {
+			}
+		}()
+
+		for p, changes := range pages {
+			for i, change := range changes {
+				upvotes := githubv4.Float(10 + change)
+				wg.Add(1)
+				in <- Update{Id: fmt.Sprintf("PVTI_%d_%d", p, i), Previous: 10, Upvotes: &upvotes, PageItems: len(changes)}
+			}
+			wg.Wait()
+		}
+		close(in)
+		<-done
+		close(results)
+
+		if fmt.Sprint(writer.changes) != fmt.Sprint(want) {
+			t.Errorf("whole run %v: expected the changes to be written in the order %v, got %v", policy.WholeRun, want, writer.changes)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// querySignals describes what each kind of query fetches, so that the cost of a signal can be weighed
+// against the option that enables it. The timeline is fetched as a whole, so the comments searched for
+// mentions, cross-references, and links are reported together.
+var querySignals = map[string]string{
+	"items":      "comments, reactions, and the first page of each timeline",
+	"timelines":  "further pages of timelines: cross-references, links, and comments searched for mentions",
+	"fields":     "additional fields",
+	"codeowners": "owners from CODEOWNERS files",
+	"repos":      "repositories excluded by search",
+	"values":     "values re-read to check for conflicts",
+	"cursors":    "partitions",
+	"sync":       "roll-up project items",
+}
+
+// QuotaUsage is the rate limit points used by the queries of a single kind during a run, and the
+// signals they fetch. Mutations do not report their cost, so they are counted by calls alone.
+type QuotaUsage struct {
+	Query  string `json:"query"`
+	Signal string `json:"signal,omitempty"`
+	Calls  int    `json:"calls"`
+	Points int    `json:"points"`
+}
+
+// quotaUsage returns the calls and points of each kind of query made between the snapshots before and
+// after, most expensive first. fields are the names of the additional fields fetched, which make up
+// the signal of the fields query.
+func quotaUsage(before, after map[string]CallStats, fields []string) []QuotaUsage {
+	var usage []QuotaUsage
+	for kind, stats := range after {
+		calls := stats.Calls - before[kind].Calls
+		if calls <= 0 {
+			continue
+		}
+
+		signal := querySignals[kind]
+		if kind == "fields" && len(fields) > 0 {
+			signal += ": " + strings.Join(fields, ", ")
+		}
+		usage = append(usage, QuotaUsage{
+			Query:  kind,
+			Signal: signal,
+			Calls:  calls,
+			Points: int(stats.Cost.Sum - before[kind].Cost.Sum),
+		})
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Points != usage[j].Points {
+			return usage[i].Points > usage[j].Points
+		}
+		return usage[i].Query < usage[j].Query
+	})
+	return usage
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter tracks the GraphQL rate limit reported by queries, and pauses callers until the limit
+// resets once the remaining points fall to the reserve. A nil rateLimiter never waits.
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	resetAt   time.Time
+	reserve   int
+	observed  bool
+	used      int
+}
+
+// RateLimitSummary reports how much of the rate limit a run used, and how much it left unused above
+// the reserve. Once forecast, it also reports the observed cost per item, whether the next scheduled
+// run is expected to fit in the rate limit, and the recommended time between runs.
+type RateLimitSummary struct {
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	Reserve   int       `json:"reserve"`
+	Unused    int       `json:"unused"`
+	ResetAt   time.Time `json:"reset_at"`
+	Limit     int       `json:"limit,omitempty"`
+
+	CostPerItem         float64   `json:"cost_per_item,omitempty"`
+	NextRunAt           time.Time `json:"next_run_at,omitempty"`
+	NextRunFits         bool      `json:"next_run_fits"`
+	RecommendedInterval string    `json:"recommended_interval,omitempty"`
+}
+
+// Forecast estimates, from the cost of this run over the given number of items, whether a run of the
+// same cost starting after interval fits in the rate limit available to it, and the shortest time
+// between runs that gives every run the full limit above the reserve. A run that starts after the
+// limit resets has the full limit; otherwise it has what this run left.
+func (r *RateLimitSummary) Forecast(items int, interval time.Duration, now time.Time) {
+	if r == nil {
+		return
+	}
+
+	if items > 0 {
+		r.CostPerItem = math.Round(float64(r.Used)/float64(items)*100) / 100
+	}
+
+	r.NextRunAt = now.Add(interval).UTC()
+	available := r.Remaining - r.Reserve
+	if !r.NextRunAt.Before(r.ResetAt) && r.Limit > 0 {
+		available = r.Limit - r.Reserve
+	}
+	needed := float64(r.Used) * costMargin
+	r.NextRunFits = needed <= float64(available)
+
+	// the limit resets every hour, so runs that need more than one window's worth of points must be
+	// spread over several windows
+	if window := r.Limit - r.Reserve; window > 0 {
+		windows := math.Max(math.Ceil(needed/float64(window)), 1)
+		r.RecommendedInterval = (time.Duration(windows) * time.Hour).String()
+	}
+}
+
+// newRateLimiter returns a rateLimiter that waits once the remaining points reach reserve
+func newRateLimiter(reserve int) *rateLimiter {
+	return &rateLimiter{reserve: reserve}
+}
+
+// Observe records the rate limit reported by a query
+func (r *rateLimiter) Observe(limit RateLimit) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.limit = limit.Limit
+	r.remaining = limit.Remaining
+	r.resetAt = limit.ResetAt.Time
+	r.observed = true
+	r.used += limit.Cost
+}
+
+// Spend records points used by a request that does not report the rate limit, such as a mutation
+func (r *rateLimiter) Spend(points int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remaining -= points
+	r.used += points
+}
+
+// Summary returns the points used since the rateLimiter was created, and the points left, or nil if
+// the rate limit was never observed
+func (r *rateLimiter) Summary() *RateLimitSummary {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.observed && r.used == 0 {
+		return nil
+	}
+
+	return &RateLimitSummary{
+		Used:      r.used,
+		Remaining: r.remaining,
+		Reserve:   r.reserve,
+		Unused:    max(r.remaining-r.reserve, 0),
+		ResetAt:   r.resetAt,
+		Limit:     r.limit,
+	}
+}
+
+// Wait blocks until there are more points remaining than the reserve, or the context is cancelled
+func (r *rateLimiter) Wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	wait := r.observed && r.remaining <= r.reserve && time.Now().Before(r.resetAt)
+	resetAt := r.resetAt
+	r.mu.Unlock()
+
+	if !wait {
+		return nil
+	}
+
+	slog.WarnContext(ctx, "rate limit reserve reached, waiting for reset", "reserve", r.reserve, "reset_at", resetAt)
+
+	timer := time.NewTimer(time.Until(resetAt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	r.mu.Lock()
+	r.observed = false
+	r.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestRateLimitCancellation cancels a caller waiting for the rate limit to reset, and checks that it
+// returns the context's error at once rather than at the reset
+func TestRateLimitCancellation(t *testing.T) {
+	limiter := newRateLimiter(100)
+	limiter.Observe(RateLimit{Limit: 5000, Remaining: 50, ResetAt: githubv4.DateTime{Time: time.Now().Add(time.Hour)}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("wait took %v to stop", elapsed.Round(time.Millisecond))
+	}
+}
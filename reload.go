@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configSettle is how long the config file must go unchanged before it is reloaded, so that a file
+// written in several steps is not read half way through
+const configSettle = 200 * time.Millisecond
+
+// ConfigWatcher watches the config file, so that the daemon can apply changes to it without
+// restarting. A nil ConfigWatcher never sees a change.
+type ConfigWatcher struct {
+	path    string
+	watcher *fsnotify.Watcher
+	changed chan struct{}
+
+	// contents is the config file as last applied, which is restored if a change is invalid
+	contents []byte
+}
+
+// WatchConfig starts watching the config file at path. The directory is watched rather than the file,
+// since editors and configuration management often replace the file instead of writing to it.
+func WatchConfig(ctx context.Context, path string) (*ConfigWatcher, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watching config file: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("watching config file: %w", err)
+	}
+
+	w := &ConfigWatcher{path: path, watcher: watcher, changed: make(chan struct{}, 1), contents: contents}
+	go w.watch(ctx)
+
+	return w, nil
+}
+
+// watch signals Changed once the config file has been written, created, or renamed into place, and
+// then left alone for configSettle, until the context is cancelled. Changes are coalesced until they
+// are reloaded.
+func (w *ConfigWatcher) watch(ctx context.Context) {
+	defer w.watcher.Close()
+
+	settle := time.NewTimer(0)
+	<-settle.C
+	defer settle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.WarnContext(ctx, "error watching config file", "path", w.path, "error", err)
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == filepath.Clean(w.path) && event.Has(fsnotify.Write|fsnotify.Create) {
+				settle.Reset(configSettle)
+			}
+		case <-settle.C:
+			select {
+			case w.changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Changed returns a channel that receives when the config file may have changed
+func (w *ConfigWatcher) Changed() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.changed
+}
+
+// Reload returns the configuration from the config file's current contents, and whether they changed.
+// The new configuration is validated by building an engine with it. If it is invalid, the previous
+// configuration stays in place and is returned with the error.
+func (w *ConfigWatcher) Reload(ctx context.Context, cfg Config) (Config, bool, error) {
+	contents, err := os.ReadFile(w.path)
+	if err != nil {
+		return cfg, false, fmt.Errorf("reading config file: %w", err)
+	}
+	if bytes.Equal(contents, w.contents) {
+		return cfg, false, nil
+	}
+
+	next, err := reloadConfig(cfg, contents)
+	if err == nil && next.Interval <= 0 {
+		err = errors.New("the daemon requires a positive interval")
+	}
+	if err == nil {
+		_, err = NewEngine(ctx, next)
+	}
+	if err != nil {
+		return cfg, false, errors.Join(err, restoreConfig(w.contents))
+	}
+
+	w.contents = contents
+	return next, true, nil
+}
+
+// applyConfigChange reloads the config file, logging the outcome, and returns the configuration to use
+func applyConfigChange(ctx context.Context, cfg Config, watcher *ConfigWatcher) Config {
+	next, changed, err := watcher.Reload(ctx, cfg)
+	switch {
+	case err != nil:
+		slog.ErrorContext(ctx, "config file is invalid, keeping the previous configuration", "path", cfg.ConfigFile, "error", err)
+	case changed:
+		slog.InfoContext(ctx, "reloaded config file", "path", cfg.ConfigFile, "interval", next.Interval)
+	}
+	return next
+}
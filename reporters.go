@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// RunInfo describes the run that is being reported on
+type RunInfo struct {
+	ID        string      `json:"id"`
+	ProjectID githubv4.ID `json:"project_id"`
+	FieldID   githubv4.ID `json:"field_id"`
+	StartedAt time.Time   `json:"started_at"`
+}
+
+// Reporter receives the result of each project item as it is processed. Start is called before the
+// first item is processed, ItemResult once for every item, and Finish once every item has been
+// processed. Calls are never made concurrently.
+type Reporter interface {
+	Start(run RunInfo) error
+	ItemResult(result Result) error
+	Finish(summary Summary) error
+}
+
+// contextReporter is implemented by reporters that make requests while reporting, so that the requests
+// are cancelled along with the run
+type contextReporter interface {
+	SetContext(ctx context.Context)
+}
+
+// Reporters fans each call out to every Reporter in the list
+type Reporters []Reporter
+
+// SetContext passes the run's context to each Reporter that makes requests
+func (r Reporters) SetContext(ctx context.Context) {
+	for _, reporter := range r {
+		if c, ok := reporter.(contextReporter); ok {
+			c.SetContext(ctx)
+		}
+	}
+}
+
+// Start calls Start on each Reporter, returning all errors
+func (r Reporters) Start(run RunInfo) error {
+	var errs []error
+	for _, reporter := range r {
+		errs = append(errs, reporter.Start(run))
+	}
+	return errors.Join(errs...)
+}
+
+// ItemResult calls ItemResult on each Reporter, returning all errors
+func (r Reporters) ItemResult(result Result) error {
+	var errs []error
+	for _, reporter := range r {
+		errs = append(errs, reporter.ItemResult(result))
+	}
+	return errors.Join(errs...)
+}
+
+// Finish calls Finish on each Reporter, returning all errors
+func (r Reporters) Finish(summary Summary) error {
+	var errs []error
+	for _, reporter := range r {
+		errs = append(errs, reporter.Finish(summary))
+	}
+	return errors.Join(errs...)
+}
+
+// newReporters builds the Reporters selected in the Config. Each entry is the name of a built-in
+// reporter, optionally followed by =path for reporters that write to a file. When a report template
+// is configured, it replaces the built-in Markdown report. Reporters that make GitHub requests send
+// them through client. The scores of every run are appended to the history, if not nil.
+func newReporters(cfg Config, client *http.Client, history HistoryStore) (Reporters, error) {
+	var reporters Reporters
+
+	groupBy, err := ParseGroupBy(cfg.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var tmpl *reportTemplate
+	if cfg.ReportTemplate != "" {
+		if tmpl, err = loadReportTemplate(cfg.ReportTemplate); err != nil {
+			return nil, err
+		}
+	}
+
+	// markdown returns the reporter for a Markdown report, using the report template if there is one
+	markdown := func(path string, append bool) Reporter {
+		if tmpl != nil {
+			return &templateReporter{path: path, append: append, template: tmpl, groupBy: groupBy, groupTop: cfg.GroupTop}
+		}
+		return &markdownReporter{path: path, append: append, age: cfg.Age, groupBy: groupBy, groupTop: cfg.GroupTop, locale: cfg.Locale}
+	}
+
+	for _, spec := range cfg.Reporters {
+		name, path, _ := strings.Cut(spec, "=")
+
+		switch name {
+		case "table":
+			reporters = append(reporters, &tableReporter{w: os.Stdout, explain: cfg.Explain, age: cfg.Age, groupBy: groupBy, groupTop: cfg.GroupTop})
+		case "json":
+			if path == "" {
+				return nil, errors.New("json reporter requires a path: json=<path>")
+			}
+			reporters = append(reporters, &jsonReporter{path: path})
+		case "csv":
+			if path == "" {
+				return nil, errors.New("csv reporter requires a path: csv=<path>")
+			}
+			reporters = append(reporters, &csvReporter{path: path, age: cfg.Age})
+		case "markdown":
+			if path == "" {
+				return nil, errors.New("markdown reporter requires a path: markdown=<path>")
+			}
+			reporters = append(reporters, markdown(path, false))
+		case "actions-summary":
+			if path == "" {
+				path = cfg.StepSummary
+			}
+			if path == "" {
+				return nil, errors.New("actions-summary reporter requires GITHUB_STEP_SUMMARY or a path: actions-summary=<path>")
+			}
+			reporters = append(reporters, markdown(path, true))
+		case "template":
+			if path == "" {
+				return nil, errors.New("template reporter requires a path: template=<path>")
+			}
+			if tmpl == nil {
+				return nil, errors.New("template reporter requires a report template: --report-template <path>")
+			}
+			reporters = append(reporters, &templateReporter{path: path, template: tmpl, groupBy: groupBy, groupTop: cfg.GroupTop})
+		case "milestone":
+			if path == "" {
+				return nil, errors.New("milestone reporter requires a path: milestone=<path>")
+			}
+			reporters = append(reporters, &milestoneReporter{path: path, budget: cfg.MilestoneBudget, capacity: cfg.MilestoneCapacity})
+		case "inbox":
+			if path == "" {
+				return nil, errors.New("inbox reporter requires a path: inbox=<path>")
+			}
+			threshold := cfg.InboxThreshold
+			if threshold == 0 {
+				threshold = cfg.NotifyThreshold
+			}
+			reporters = append(reporters, &inboxReporter{path: path, threshold: threshold, velocity: cfg.InboxVelocity, locale: cfg.Locale})
+		case "assignees":
+			if path == "" {
+				return nil, errors.New("assignees reporter requires a path: assignees=<path>")
+			}
+			if !cfg.Enrich {
+				return nil, errors.New("assignees reporter requires the assignees of each item: --enrich")
+			}
+			reporters = append(reporters, &assigneesReporter{path: path, pool: cfg.AssigneePool, top: cfg.SuggestTop, locale: cfg.Locale})
+		case "epics":
+			if path == "" {
+				return nil, errors.New("epics reporter requires a path: epics=<path>")
+			}
+			reporters = append(reporters, &epicsReporter{path: path, profile: cfg.Scoring})
+		case "graph":
+			if path == "" {
+				return nil, errors.New("graph reporter requires a path: graph=<path>")
+			}
+			reporters = append(reporters, &graphReporter{path: path})
+		case "duplicates":
+			if path == "" {
+				return nil, errors.New("duplicates reporter requires a path: duplicates=<path>")
+			}
+			reporters = append(reporters, &duplicatesReporter{path: path, threshold: cfg.DuplicateThreshold})
+		case "score-diff":
+			reporters = append(reporters, &scoreDiffReporter{path: path, gh: newGraphQLClient(cfg, client), eventPath: cfg.EventPath, locale: cfg.Locale, dryRun: cfg.DryRun})
+		case "jira", "linear":
+			reporter, err := newExportReporter(name, cfg.Export)
+			if err != nil {
+				return nil, err
+			}
+			// the exporter is still built in a dry run, so that its configuration is checked
+			if !cfg.DryRun {
+				reporters = append(reporters, reporter)
+			}
+		default:
+			return nil, fmt.Errorf("unknown reporter %q", name)
+		}
+	}
+
+	if cfg.DryRun {
+		reporters = append(reporters, &dryRunReporter{w: os.Stdout})
+	}
+
+	if cfg.SummaryFile != "" {
+		reporters = append(reporters, &summaryReporter{path: cfg.SummaryFile})
+	}
+
+	// runs are recorded in the org audit repository, but dry runs, rescores, and other offline reports
+	// are not
+	if cfg.OrgAuditRepo != "" && client != nil && !cfg.DryRun {
+		reporters = append(reporters, &orgAuditReporter{gh: newGraphQLClient(cfg, client), repo: cfg.OrgAuditRepo, path: cfg.OrgAuditPath, issue: cfg.OrgAuditIssue, token: cfg.Token})
+	}
+
+	if history != nil {
+		reporters = append(reporters, &historyReporter{store: history})
+		if series, ok := history.(SeriesStore); ok && cfg.Series {
+			reporters = append(reporters, &seriesReporter{store: series})
+		}
+	}
+
+	return reporters, nil
+}
+
+// dryRunReporter prints the upvotes calculated for each item in a dry run, along with the value that
+// would have been written to its field, if any
+type dryRunReporter struct {
+	collector
+	w io.Writer
+}
+
+// Finish prints the table, followed by the number of items that would have been updated
+func (d *dryRunReporter) Finish(summary Summary) error {
+	tw := tabwriter.NewWriter(d.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ITEM\tSTATUS\tCURRENT\tUPVOTES\tWOULD WRITE")
+
+	for _, result := range d.results {
+		if !exportable(result) {
+			continue
+		}
+		write := "-"
+		if result.Status == StatusPlanned {
+			write = fmt.Sprint(result.Value)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%v\t%v\t%s\n", result.Name(), result.Status, result.Previous, result.Upvotes, write)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(d.w, "\ndry run: %d of %d items would have been updated\n", summary.Statuses[StatusPlanned], summary.Total)
+	return err
+}
+
+// collector is embedded by reporters that only write once all results are known
+type collector struct {
+	run     RunInfo
+	results []Result
+}
+
+// Start records the run information
+func (c *collector) Start(run RunInfo) error {
+	c.run = run
+	return nil
+}
+
+// ItemResult records the result
+func (c *collector) ItemResult(result Result) error {
+	c.results = append(c.results, result)
+	return nil
+}
+
+// tableReporter prints a table of every item, followed by the count of items by status, and the
+// leaderboard of each group if items are grouped. With age, the age and upvotes per day of each item
+// are included, and with explain, the breakdown of each item's upvotes and its upvoters in each segment.
+type tableReporter struct {
+	collector
+	w        io.Writer
+	explain  bool
+	age      bool
+	groupBy  *Grouper
+	groupTop int
+}
+
+// Finish prints the tables
+func (t *tableReporter) Finish(summary Summary) error {
+	tw := tabwriter.NewWriter(t.w, 0, 0, 2, ' ', 0)
+
+	header := "ITEM\tSTATUS\tPREVIOUS\tUPVOTES"
+	if t.age {
+		header += "\tAGE\tPER DAY"
+	}
+	if t.explain {
+		header += "\tCOMPONENTS"
+	}
+	fmt.Fprintln(tw, header)
+
+	for _, result := range t.results {
+		row := fmt.Sprintf("%s\t%s\t%v\t%v", result.Name(), result.Status, result.Previous, result.Upvotes)
+		if t.age {
+			row += fmt.Sprintf("\t%s\t%s", formatAge(result), formatPerDay(result))
+		}
+		if t.explain {
+			row += "\t" + formatComponents(result.Components)
+			if result.Segments != nil {
+				row += " segments: " + formatSegments(result.Segments)
+			}
+		}
+		fmt.Fprintln(tw, row)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if err := writeGroupsTable(t.w, t.groupBy, t.groupBy.Groups(t.results, t.groupTop)); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(t.w)
+	if err := summary.WriteTable(t.w); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(t.w, "\nrun: %s\n", t.run.ID)
+	return err
+}
+
+// jsonReporter writes the run, summary, and every item's result to a JSON file
+type jsonReporter struct {
+	collector
+	path string
+}
+
+// Finish writes the file
+func (j *jsonReporter) Finish(summary Summary) error {
+	report := struct {
+		Run     RunInfo  `json:"run"`
+		Summary Summary  `json:"summary"`
+		Items   []Result `json:"items"`
+	}{j.run, summary, j.results}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(j.path, b, 0o644)
+}
+
+// summaryReporter writes only the summary to a JSON file
+type summaryReporter struct {
+	collector
+	path string
+}
+
+// Finish writes the file
+func (s *summaryReporter) Finish(summary Summary) error {
+	b, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, b, 0o644)
+}
+
+// csvReporter writes a row to a CSV file for every item as it is processed. With age, the age and upvotes
+// per day of each item are added as the last columns.
+type csvReporter struct {
+	path  string
+	age   bool
+	runID string
+	f     *os.File
+	w     *csv.Writer
+}
+
+// Start creates the file and writes the header
+func (c *csvReporter) Start(run RunInfo) error {
+	f, err := os.Create(c.path)
+	if err != nil {
+		return err
+	}
+
+	c.f = f
+	c.w = csv.NewWriter(f)
+	c.runID = run.ID
+
+	header := []string{"run_id", "item_id", "status", "previous", "upvotes", "value", "error"}
+	if c.age {
+		header = append(header, "age_days", "upvotes_per_day")
+	}
+
+	return c.w.Write(header)
+}
+
+// ItemResult writes the result as a row
+func (c *csvReporter) ItemResult(result Result) error {
+	if c.w == nil {
+		return nil
+	}
+
+	var msg string
+	if result.Err != nil {
+		msg = redact(result.Err.Error())
+	}
+
+	row := []string{
+		c.runID,
+		fmt.Sprint(result.ItemID),
+		string(result.Status),
+		strconv.FormatFloat(result.Previous, 'f', -1, 64),
+		strconv.FormatFloat(result.Upvotes, 'f', -1, 64),
+		strconv.FormatFloat(result.Value, 'f', -1, 64),
+		msg,
+	}
+	if c.age {
+		row = append(row, formatAge(result), formatPerDay(result))
+	}
+
+	return c.w.Write(row)
+}
+
+// Finish flushes and closes the file
+func (c *csvReporter) Finish(summary Summary) error {
+	if c.w == nil {
+		return nil
+	}
+
+	c.w.Flush()
+	return errors.Join(c.w.Error(), c.f.Close())
+}
+
+// markdownReporter writes the summary, the leaderboard of each group if items are grouped, and a table
+// of every item as Markdown, with the age and upvotes per day of each item if age is set. If append is set, the file is appended to rather than replaced, as required
+// for the GitHub Actions job summary. The report is written in the language of the locale.
+type markdownReporter struct {
+	collector
+	path     string
+	append   bool
+	age      bool
+	groupBy  *Grouper
+	groupTop int
+	locale   *Locale
+}
+
+// Finish writes the file
+func (m *markdownReporter) Finish(summary Summary) error {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if m.append {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(m.path, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	b.WriteString(summary.Markdown(m.locale))
+	fmt.Fprintf(&b, "\n%s\n", m.locale.T("report.run", m.run.ID, m.run.StartedAt.UTC().Format(time.RFC3339)))
+	b.WriteString(groupsMarkdown(m.groupBy, m.groupBy.Groups(m.results, m.groupTop), m.locale))
+	if m.age {
+		b.WriteString("\n" + m.locale.row("summary.item", "summary.status", "summary.previous", "report.upvotes", "report.age", "report.per_day"))
+		b.WriteString("| --- | --- | ---: | ---: | ---: | ---: |\n")
+	} else {
+		b.WriteString("\n" + m.locale.row("summary.item", "summary.status", "summary.previous", "report.upvotes"))
+		b.WriteString("| --- | --- | ---: | ---: |\n")
+	}
+	for _, result := range m.results {
+		if m.age {
+			fmt.Fprintf(&b, "| %s | %s | %v | %v | %s | %s |\n", markdownLink(result), result.Status, result.Previous, result.Upvotes, formatAge(result), formatPerDay(result))
+			continue
+		}
+		fmt.Fprintf(&b, "| %s | %s | %v | %v |\n", markdownLink(result), result.Status, result.Previous, result.Upvotes)
+	}
+
+	_, err = f.WriteString(b.String())
+	return err
+}
+
+// markdownLink returns the item's name, linked to its Issue or Pull Request when the URL is known
+func markdownLink(result Result) string {
+	name := strings.ReplaceAll(result.Name(), "|", "\\|")
+	if result.Content.URL == "" || name == result.Content.URL {
+		return name
+	}
+	return fmt.Sprintf("[%s](%s)", name, result.Content.URL)
+}
+
+// formatAge returns the item's age in days, or an empty string if it is not known or the item's upvotes
+// were not calculated
+func formatAge(result Result) string {
+	if result.Content.CreatedAt.IsZero() || !exportable(result) {
+		return ""
+	}
+	return strconv.Itoa(result.AgeDays)
+}
+
+// formatPerDay returns the item's upvotes per day of age, or an empty string if it is not known
+func formatPerDay(result Result) string {
+	if formatAge(result) == "" {
+		return ""
+	}
+	return strconv.FormatFloat(result.PerDay, 'f', -1, 64)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDryRun runs the synthetic project as a dry run, and checks that nothing is written, not even the
+// plan file, and that every changed item is printed with the value it would have been given
+func TestDryRun(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DryRun = true
+	server := newFakeGitHub()
+
+	var changed int
+	for _, item := range server.items {
+		if !item.closed && !item.archived && (item.value == nil || *item.value != item.upvotes()) {
+			changed++
+		}
+	}
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	for _, reporter := range engine.reporters {
+		if r, ok := reporter.(*dryRunReporter); ok {
+			r.w = &out
+		}
+	}
+	if err := engine.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expectCount(t, "updates written", len(server.mutations), 0)
+	if _, err := os.Stat(cfg.PlanFile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no plan file, got %v", err)
+	}
+	if want := fmt.Sprintf("dry run: %d of %d items would have been updated", changed, len(server.items)); !strings.Contains(out.String(), want) {
+		t.Fatalf("expected %q in the output, got %q", want, out.String())
+	}
+}
+
+// TestDryRunSideEffects runs the synthetic project with notifications, an export, an org audit
+// repository, and the state, history, and series enabled, first for real and then as a dry run of a
+// fresh project, and checks that the dry run sends, exports, records, and saves none of what the real
+// run does
+func TestDryRunSideEffects(t *testing.T) {
+	run := func(dryRun bool) (*fakeGitHub, int, *recordingNotifier, []os.DirEntry) {
+		dir := t.TempDir()
+		cfg := selftestConfig(dir)
+		cfg.Reporters = nil
+		cfg.StateDir = filepath.Join(dir, "state")
+		cfg.Series = true
+		cfg.Cache = true
+		cfg.NotifyThreshold = 1
+		cfg.OrgAuditRepo = "selftest/audit"
+		cfg.OrgAuditPath = defaultOrgAuditPath
+		cfg.DryRun = dryRun
+
+		var exported int
+		jira := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			exported++
+		}))
+		defer jira.Close()
+		mapping := filepath.Join(dir, "mapping.csv")
+		if err := os.WriteFile(mapping, []byte("https://github.com/selftest/repo/issues/1,UP-1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg.Reporters = []string{"jira"}
+		cfg.Export = ExportConfig{MappingFile: mapping, Jira: JiraConfig{URL: jira.URL, Token: "token", Field: "customfield_1"}}
+
+		server := newFakeGitHub()
+		engine, err := newEngine(cfg, &http.Client{Transport: server})
+		if err != nil {
+			t.Fatal(err)
+		}
+		notifier := &recordingNotifier{}
+		engine.notifiers = Notifiers{notifier}
+		for _, reporter := range engine.reporters {
+			if r, ok := reporter.(*dryRunReporter); ok {
+				r.w = io.Discard
+			}
+		}
+		if err := engine.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := os.ReadDir(cfg.StateDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+		return server, exported, notifier, state
+	}
+
+	server, exported, notifier, state := run(false)
+	if server.auditCommits == 0 || exported == 0 || len(notifier.messages) == 0 || len(state) == 0 {
+		t.Fatalf("expected the run to record, export, notify, and save state, got %d audit commits, %d exports, %d notifications, and %d state files", server.auditCommits, exported, len(notifier.messages), len(state))
+	}
+
+	server, exported, notifier, state = run(true)
+	expectCount(t, "audit commits", server.auditCommits, 0)
+	expectCount(t, "exports", exported, 0)
+	expectCount(t, "notifications", len(notifier.messages), 0)
+	expectCount(t, "state files", len(state), 0)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// reposFile is the name of the state file that the repositories found by a search are cached in
+const reposFile = "repos.json"
+
+// RepoSearchQuery is used to find repositories with GitHub's search syntax, such as
+// `org:acme topic:internal`. GitHub returns at most 1,000 repositories for a search.
+type RepoSearchQuery struct {
+	Search struct {
+		PageInfo `graphql:"pageInfo"`
+		Nodes    []struct {
+			Repository struct {
+				NameWithOwner string
+			} `graphql:"...on Repository"`
+		}
+	} `graphql:"search(type: REPOSITORY, query: $query, first: 100, after: $cursor)"`
+	RateLimit RateLimit
+}
+
+// RepoCache holds the repositories found by each search, by query, so that the search is only made
+// again once its result is older than the cache's TTL
+type RepoCache struct {
+	Queries map[string]CachedRepos `json:"queries"`
+}
+
+// CachedRepos are the repositories, as owner/name, that a search found, and when
+type CachedRepos struct {
+	Repos     []string  `json:"repos"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// searchRepos returns the repositories, as owner/name, that the search query finds, following every page
+// of the results
+func searchRepos(ctx context.Context, gh *githubv4.Client, limiter *rateLimiter, search string) ([]string, error) {
+	var query RepoSearchQuery
+	variables := Variables().String("query", search).Cursor("cursor", "")
+
+	var repos []string
+	for {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		if err := runQuery(withQueryKind(ctx, "repos"), gh, &query, variables); err != nil {
+			return nil, fmt.Errorf("searching repositories: %w", err)
+		}
+		limiter.Observe(query.RateLimit)
+
+		for _, node := range query.Search.Nodes {
+			if name := node.Repository.NameWithOwner; name != "" {
+				repos = append(repos, name)
+			}
+		}
+
+		if !query.Search.HasNextPage {
+			return repos, nil
+		}
+		variables.Cursor("cursor", query.Search.EndCursor)
+	}
+}
+
+// resolveExcludedRepos adds the repositories found by the exclusion search to those cfg excludes
+func (e *Engine) resolveExcludedRepos(ctx context.Context, cfg *Config) error {
+	search := strings.TrimSpace(cfg.ExcludeRepoQuery)
+	if search == "" {
+		return nil
+	}
+
+	cached, err := e.cachedSearch(ctx, search, cfg.RepoCacheTTL)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "excluding repositories found by search", "query", search, "repositories", len(cached.Repos), "fetched_at", cached.FetchedAt)
+	cfg.ExcludeRepos = append(append([]string(nil), cfg.ExcludeRepos...), cached.Repos...)
+	return nil
+}
+
+// resolveIncludedRepos sets the repositories that cfg limits scoring to, from the inclusion search
+func (e *Engine) resolveIncludedRepos(ctx context.Context, cfg *Config) error {
+	search := strings.TrimSpace(cfg.RepoQuery)
+	if search == "" {
+		return nil
+	}
+
+	cached, err := e.cachedSearch(ctx, search, cfg.RepoCacheTTL)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "scoring only repositories found by search", "query", search, "repositories", len(cached.Repos), "fetched_at", cached.FetchedAt)
+	cfg.IncludeRepos = append([]string{}, cached.Repos...)
+	return nil
+}
+
+// cachedSearch returns the repositories that the search finds. The result of the search is cached in the
+// state store for ttl, when there is one.
+func (e *Engine) cachedSearch(ctx context.Context, search string, ttl time.Duration) (CachedRepos, error) {
+	cache := RepoCache{Queries: make(map[string]CachedRepos)}
+	if e.store != nil {
+		if err := e.store.Load(reposFile, &cache); err != nil {
+			return CachedRepos{}, err
+		}
+	}
+
+	cached, ok := cache.Queries[search]
+	if ok && time.Since(cached.FetchedAt) < ttl {
+		return cached, nil
+	}
+
+	repos, err := searchRepos(ctx, e.gh, e.limiter, search)
+	if err != nil {
+		return CachedRepos{}, err
+	}
+	cached = CachedRepos{Repos: repos, FetchedAt: time.Now().UTC()}
+
+	if e.store != nil {
+		if cache.Queries == nil {
+			cache.Queries = make(map[string]CachedRepos)
+		}
+		cache.Queries[search] = cached
+		if err := e.store.Save(reposFile, cache); err != nil {
+			slog.ErrorContext(ctx, "failed to save repository cache", "error", err)
+		}
+	}
+
+	return cached, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRepoSearch excludes the repositories found by a search, twice, and checks that both pages of the
+// results are followed, and that the second time they are read from the cache
+func TestRepoSearch(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	server := newFakeGitHub()
+	cfg.ExcludeRepos = []string{"selftest/static"}
+	cfg.ExcludeRepoQuery = "org:selftest topic:excluded"
+	cfg.RepoCacheTTL = time.Hour
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		itemsCfg := cfg
+		if err := engine.resolveExcludedRepos(ctx, &itemsCfg); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Join(itemsCfg.ExcludeRepos, " "), "selftest/static selftest/repo selftest/other"; got != want {
+			t.Fatalf("expected %q to be excluded, got %q", want, got)
+		}
+	}
+	expectCount(t, "pages of search results requested", server.searches, 2)
+}
+
+// TestRepoQuery limits scoring to the repositories found by a search, and checks that the items of those
+// repositories are scored, and those of any other are skipped as excluded
+func TestRepoQuery(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RepoQuery = "org:selftest topic:product"
+	cfg.RepoCacheTTL = time.Hour
+	server := newFakeGitHub()
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemsCfg := cfg
+	if err := engine.resolveIncludedRepos(context.Background(), &itemsCfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for rawURL, want := range map[string]Status{
+		"https://github.com/selftest/other/issues/1": "",
+		"https://github.com/Selftest/Repo/pull/2":    "",
+		"https://github.com/acme/tooling/issues/3":   StatusSkippedExcluded,
+	} {
+		var item ProjectItemFragment
+		item.Content.Type = "Issue"
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		item.Content.Issue.Url.URL = u
+		if got := filterStatus(itemsCfg, item); got != want {
+			t.Errorf("%s: expected status %q, got %q", rawURL, want, got)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// resetBatchSize is the number of project items cleared in a single request
+const resetBatchSize = 25
+
+// ResetItemsQuery is used to list the items in a project along with their current upvotes, if any
+type ResetItemsQuery struct {
+	Node struct {
+		ProjectV2 struct {
+			Items struct {
+				PageInfo `graphql:"pageInfo"`
+				Nodes    []ResetItem
+			} `graphql:"items(first: 100, after: $cursor)"`
+		} `graphql:"...on ProjectV2"`
+	} `graphql:"node(id: $nodeId)"`
+	RateLimit RateLimit
+}
+
+// ResetItem is a project item and its current upvotes, if its field is not empty, along with what the
+// reset filter matches on: its status, and its Issue or Pull Request's URL and labels
+type ResetItem struct {
+	Id           githubv4.ID
+	UpvotesField struct {
+		NumberValue struct {
+			Number *float64
+		} `graphql:"...on ProjectV2ItemFieldNumberValue"`
+	} `graphql:"fieldValueByName(name:\"Upvotes\")"`
+	StatusField struct {
+		SingleSelectValue struct {
+			Name string
+		} `graphql:"...on ProjectV2ItemFieldSingleSelectValue"`
+	} `graphql:"status: fieldValueByName(name:\"Status\")"`
+	Content struct {
+		Typename    string       `graphql:"__typename"`
+		Issue       ResetContent `graphql:"...on Issue"`
+		PullRequest ResetContent `graphql:"...on PullRequest"`
+	}
+}
+
+// ResetContent is the Issue or Pull Request connected to a ResetItem
+type ResetContent struct {
+	Url    githubv4.URI
+	Labels struct {
+		Nodes []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 20)"`
+}
+
+// value returns the item's current upvotes, and false if the field is empty
+func (i ResetItem) value() (float64, bool) {
+	if number := i.UpvotesField.NumberValue.Number; number != nil {
+		return *number, true
+	}
+	return 0, false
+}
+
+// content returns the Issue or Pull Request connected to the item, which is empty for draft issues
+func (i ResetItem) content() ResetContent {
+	switch i.Content.Typename {
+	case "Issue":
+		return i.Content.Issue
+	case "PullRequest":
+		return i.Content.PullRequest
+	}
+	return ResetContent{}
+}
+
+// resetFilter selects the items the reset command resets, in the syntax of a rule's condition: terms
+// joined with `and` and `or`, such as `label:bug and repo:owner/name or status:Done`
+type resetFilter [][]resetTerm
+
+// resetTerm is a single term of a resetFilter: `label:name`, `score op value` compared with the current
+// upvotes, `repo:owner/name`, or `status:name` for the value of the project's Status field
+type resetTerm struct {
+	term
+	repo   string
+	status string
+}
+
+// parseResetFilter parses a reset filter, which is empty if s is
+func parseResetFilter(s string) (resetFilter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var filter resetFilter
+	for _, group := range splitKeyword(s, "or") {
+		var terms []resetTerm
+		for _, part := range splitKeyword(group, "and") {
+			t, err := parseResetTerm(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, t)
+		}
+		filter = append(filter, terms)
+	}
+
+	return filter, nil
+}
+
+// parseResetTerm parses a single term of a reset filter. Only the upvotes already on the board are known
+// to the reset command, so the other metrics of a rule and mentions are not supported.
+func parseResetTerm(s string) (resetTerm, error) {
+	if repo, ok := strings.CutPrefix(s, "repo:"); ok {
+		if strings.Count(repo, "/") != 1 {
+			return resetTerm{}, fmt.Errorf("invalid repository in %q, expected owner/name", s)
+		}
+		return resetTerm{repo: repo}, nil
+	}
+
+	if status, ok := strings.CutPrefix(s, "status:"); ok {
+		if status == "" {
+			return resetTerm{}, fmt.Errorf("empty status in %q", s)
+		}
+		return resetTerm{status: status}, nil
+	}
+
+	t, err := parseTerm(s)
+	if err != nil {
+		return resetTerm{}, err
+	}
+	if t.mention != "" || (t.metric != "" && t.metric != "score") {
+		return resetTerm{}, fmt.Errorf("invalid term %q, expected `label:name`, `repo:owner/name`, `status:name`, or `score op value`", s)
+	}
+	return resetTerm{term: t}, nil
+}
+
+// match returns true if the filter is empty, or any group has all of its terms met by the item
+func (f resetFilter) match(item ResetItem) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	content := item.content()
+	value, _ := item.value()
+	n := Notification{Result: Result{Previous: value, Upvotes: value, Content: ContentInfo{URL: content.Url.String()}}}
+	for _, label := range content.Labels.Nodes {
+		n.Content.Labels = append(n.Content.Labels, label.Name)
+	}
+
+	for _, group := range f {
+		matched := true
+		for _, t := range group {
+			if !t.match(item, content, n) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns true if the term is met by the item
+func (t resetTerm) match(item ResetItem, content ResetContent, n Notification) bool {
+	switch {
+	case t.repo != "":
+		return content.Url.URL != nil && strings.EqualFold(contentRepo(content.Url.URL), t.repo)
+	case t.status != "":
+		return strings.EqualFold(item.StatusField.SingleSelectValue.Name, t.status)
+	}
+	return t.term.eval(n)
+}
+
+// Reset clears the upvotes field of every item in the project, or sets it to zero with ResetToZero, for
+// a clean slate when the scoring scheme changes. With a ResetFilter, only the items it matches are reset,
+// and the others are skipped as excluded. Items whose field is already empty, or zero, are left alone. Without Confirm nothing is written: each item that would be reset is reported as planned. Items
+// are cleared in batches of resetBatchSize, waiting on the rate limit before each batch.
+func (e *Engine) Reset(ctx context.Context) error {
+	if e.cfg.DryRun && e.cfg.Confirm {
+		return errors.New("reset --confirm cannot be combined with --dry-run: run reset without --confirm to see what it would clear")
+	}
+
+	run := RunInfo{
+		ID:        runID(e.cfg),
+		ProjectID: e.cfg.ProjectID,
+		FieldID:   e.cfg.FieldID,
+		StartedAt: time.Now(),
+	}
+
+	ctx = withLogAttrs(ctx, slog.String("run_id", run.ID))
+	slog.InfoContext(ctx, "resetting upvotes", "project_id", run.ProjectID, "confirm", e.cfg.Confirm, "to_zero", e.cfg.ResetToZero)
+
+	if e.cfg.Confirm {
+		canUpdate, err := viewerCanUpdate(ctx, e.gh, e.cfg.ProjectID)
+		if err != nil {
+			return fmt.Errorf("checking project permissions: %w", err)
+		}
+		if !canUpdate {
+			return errors.New("the token cannot update the project")
+		}
+	}
+
+	if e.cfg.StateDir != "" {
+		audit, err := OpenAuditLog(e.cfg.StateDir, run.ID)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		e.audit = audit
+	}
+
+	if err := e.reporters.Start(run); err != nil {
+		return fmt.Errorf("starting reporters: %w", err)
+	}
+
+	acc := NewAccumulator(summaryMovers)
+	report := func(result Result) {
+		logResult(ctx, result, false)
+		if err := e.reporters.ItemResult(result); err != nil {
+			slog.ErrorContext(ctx, "failed to report project item", "item_id", result.ItemID, "error", err)
+		}
+		acc.Add(result)
+	}
+
+	var query ResetItemsQuery
+	variables := Variables().ID("nodeId", e.cfg.ProjectID).Cursor("cursor", "")
+
+	var err error
+	for {
+		if err = e.limiter.Wait(ctx); err != nil {
+			break
+		}
+		if err = runQuery(withQueryKind(ctx, "reset"), e.gh, &query, variables); err != nil {
+			err = fmt.Errorf("listing project items: %w", err)
+			break
+		}
+		e.limiter.Observe(query.RateLimit)
+
+		var batch []Result
+		for _, item := range query.Node.ProjectV2.Items.Nodes {
+			result := Result{ItemID: item.Id, Status: StatusUnchanged}
+			value, ok := item.value()
+			result.Previous = value
+			if !e.cfg.ResetFilter.match(item) {
+				result.Status = StatusSkippedExcluded
+				report(result)
+				continue
+			}
+			if ok && (value != 0 || !e.cfg.ResetToZero) {
+				batch = append(batch, result)
+				continue
+			}
+			report(result)
+		}
+
+		for start := 0; start < len(batch); start += resetBatchSize {
+			for _, result := range e.resetBatch(ctx, batch[start:min(start+resetBatchSize, len(batch))]) {
+				report(result)
+			}
+		}
+
+		if !query.Node.ProjectV2.Items.HasNextPage {
+			break
+		}
+		variables.Cursor("cursor", query.Node.ProjectV2.Items.EndCursor)
+	}
+
+	// the values of a reset are not scores, so they have no distribution
+	summary := acc.Summary()
+	summary.Stats = nil
+	summary.RateLimit = e.limiter.Summary()
+	e.summary = summary
+	if err := e.reporters.Finish(summary); err != nil {
+		slog.ErrorContext(ctx, "failed to write reports", "error", err)
+	}
+
+	if err != nil {
+		return err
+	}
+	if !e.cfg.Confirm {
+		slog.InfoContext(ctx, "dry run, nothing was reset; run again with --confirm to reset the items planned", "planned", summary.Statuses[StatusPlanned])
+	}
+	if failed := summary.Statuses[StatusFailed]; failed > 0 {
+		return fmt.Errorf("failed to reset %d project items", failed)
+	}
+	return nil
+}
+
+// resetBatch clears the items' upvotes, or sets them to zero, in a single request, and returns their
+// results. Without Confirm nothing is written, and every item is planned.
+func (e *Engine) resetBatch(ctx context.Context, batch []Result) []Result {
+	for i := range batch {
+		batch[i].Status = StatusPlanned
+	}
+	if !e.cfg.Confirm {
+		return batch
+	}
+
+	if err := e.limiter.Wait(ctx); err != nil {
+		for i := range batch {
+			batch[i].Status = StatusTruncated
+		}
+		return batch
+	}
+
+	// every mutation in the request is recorded as attempted before it is sent, and as succeeded or
+	// failed after
+	mutation := "clear-upvotes"
+	var newValue interface{}
+	if e.cfg.ResetToZero {
+		mutation, newValue = "reset-upvotes", 0.0
+	}
+	records := make([]AuditRecord, len(batch))
+	for i, result := range batch {
+		records[i] = AuditRecord{Mutation: mutation, ItemID: result.ItemID, FieldID: e.cfg.FieldID, OldValue: result.Previous, NewValue: newValue}
+	}
+	err := e.audit.Mutations(records, func() error {
+		query, variables := resetMutation(e.cfg.ProjectID, e.cfg.FieldID, batch, e.cfg.ResetToZero)
+		var out map[string]interface{}
+		return rawQuery(withQueryKind(ctx, "reset"), e.client, e.cfg.apiURL(), query, variables, &out)
+	})
+	e.limiter.Spend(len(batch))
+
+	for i := range batch {
+		batch[i].Status = StatusUpdated
+		if err != nil {
+			batch[i].Status, batch[i].Err = StatusFailed, err
+		}
+	}
+	return batch
+}
+
+// resetMutation builds a mutation that clears the upvotes of every item in the batch, or sets them to
+// zero, each under its own alias
+func resetMutation(projectId, fieldId githubv4.ID, batch []Result, zero bool) (string, map[string]interface{}) {
+	variables := map[string]interface{}{
+		"projectId": projectId,
+		"fieldId":   fieldId,
+	}
+
+	params := []string{"$projectId: ID!", "$fieldId: ID!"}
+	var b strings.Builder
+	for i, result := range batch {
+		name := fmt.Sprintf("i%d", i)
+		variables[name] = result.ItemID
+		params = append(params, fmt.Sprintf("$%s: ID!", name))
+
+		if zero {
+			fmt.Fprintf(&b, " r%d: updateProjectV2ItemFieldValue(input: {projectId: $projectId, itemId: $%s, fieldId: $fieldId, value: {number: 0}}) { clientMutationId }", i, name)
+			continue
+		}
+		fmt.Fprintf(&b, " r%d: clearProjectV2ItemFieldValue(input: {projectId: $projectId, itemId: $%s, fieldId: $fieldId}) { clientMutationId }", i, name)
+	}
+
+	return fmt.Sprintf("mutation(%s) {%s }", strings.Join(params, ", "), b.String()), variables
+}
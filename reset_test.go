@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// TestReset resets the synthetic project without confirming, which must change nothing, then confirmed,
+// which must clear every item that has a value in a single batch
+func TestReset(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	server := newFakeGitHub()
+	cfg.StateDir = ""
+
+	var valued int
+	for _, item := range server.items {
+		if item.value != nil {
+			valued++
+		}
+	}
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "items planned without --confirm", engine.Summary().Statuses[StatusPlanned], valued)
+	expectCount(t, "items reset without --confirm", len(server.mutations), 0)
+
+	cfg.Confirm = true
+	if engine, err = newEngine(cfg, &http.Client{Transport: server}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "items reset", len(server.mutations), valued)
+	expectCount(t, "reset requests", server.resets, (valued+resetBatchSize-1)/resetBatchSize)
+	for _, item := range server.items {
+		if item.value != nil {
+			t.Fatalf("%s: expected its field to be cleared, got %v", item.id, *item.value)
+		}
+	}
+}
+
+// TestResetFilter resets the synthetic project with a filter, which must clear only the valued items it
+// matches and skip the others as excluded
+func TestResetFilter(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.Confirm = true
+	server := newFakeGitHub()
+
+	filter, err := parseResetFilter("label:bug and status:Todo or repo:other/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ResetFilter = filter
+
+	var matched int
+	for i, item := range server.items {
+		if item.value != nil && selftestLabel(i+1) == "bug" && selftestStatus(i+1) == "Todo" {
+			matched++
+		}
+	}
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "items reset", len(server.mutations), matched)
+	expectCount(t, "items excluded", engine.Summary().Statuses[StatusSkippedExcluded], selftestItems-selftestItems/3+selftestItems/15)
+	for i, item := range server.items {
+		if cleared := item.value == nil; !cleared && selftestLabel(i+1) == "bug" && selftestStatus(i+1) == "Todo" {
+			t.Fatalf("%s: expected its field to be cleared, got %v", item.id, *item.value)
+		}
+	}
+
+	if _, err := parseResetFilter("mention:org/team"); err == nil {
+		t.Fatal("expected a filter on mentions to be invalid")
+	}
+}
+
+// TestResetDryRun checks that a confirmed reset is refused in a dry run, and clears nothing
+func TestResetDryRun(t *testing.T) {
+	cfg := testConfig(t)
+	server := newFakeGitHub()
+	cfg.StateDir = ""
+	cfg.Confirm = true
+	cfg.DryRun = true
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reset(context.Background()); err == nil {
+		t.Fatal("expected reset --confirm to refuse --dry-run")
+	}
+	expectCount(t, "items reset", len(server.mutations), 0)
+	expectCount(t, "reset requests", server.resets, 0)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Names that the response time fields are fetched under
+const (
+	firstResponseField = "first_response"
+	lastActivityField  = "last_activity"
+)
+
+// maintainerAssociations are the author associations of the people whose comments count as a response
+var maintainerAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+
+// Responses is when the Issue or Pull Request was first responded to by a maintainer, and when it was
+// last commented on, as found in its timeline
+type Responses struct {
+	FirstResponseAt *time.Time `json:"first_response_at,omitempty"`
+	LastCommentAt   *time.Time `json:"last_comment_at,omitempty"`
+}
+
+// Responses returns the responses found in the timeline items fetched for the content. A response is a
+// comment by an owner, member, or collaborator of the repository, other than the author of the Issue or
+// Pull Request and bots.
+func (c ContentFragment) Responses() Responses {
+	var r Responses
+	for _, node := range c.TimelineItems.Nodes {
+		comment := node.IssueComment
+		if node.Type != "IssueComment" || comment.CreatedAt.IsZero() {
+			continue
+		}
+
+		at := comment.CreatedAt.Time
+		if r.LastCommentAt == nil || at.After(*r.LastCommentAt) {
+			r.LastCommentAt = &at
+		}
+
+		login := comment.Author.Login
+		if !contains(maintainerAssociations, comment.AuthorAssociation) || login == c.Author.Login || strings.HasSuffix(login, "[bot]") {
+			continue
+		}
+		if r.FirstResponseAt == nil || at.Before(*r.FirstResponseAt) {
+			r.FirstResponseAt = &at
+		}
+	}
+	return r
+}
+
+// add returns the responses in r and more together: the earlier first response, and the later last
+// comment
+func (r Responses) add(more Responses) Responses {
+	if more.FirstResponseAt != nil && (r.FirstResponseAt == nil || more.FirstResponseAt.Before(*r.FirstResponseAt)) {
+		r.FirstResponseAt = more.FirstResponseAt
+	}
+	if more.LastCommentAt != nil && (r.LastCommentAt == nil || more.LastCommentAt.After(*r.LastCommentAt)) {
+		r.LastCommentAt = more.LastCommentAt
+	}
+	return r
+}
+
+// setActivity sets the hours the Issue or Pull Request waited for its first response, if it has had
+// one, and the days since it was last commented on, or since it was created if it has no comments
+func (r *Result) setActivity(now time.Time) {
+	created := r.Content.CreatedAt
+	if created.IsZero() {
+		return
+	}
+
+	if first := r.Content.FirstResponseAt; first != nil {
+		hours := math.Round(first.Sub(created).Hours()*10) / 10
+		r.FirstResponseHours = &hours
+	}
+
+	last := created
+	if r.Content.LastCommentAt != nil {
+		last = *r.Content.LastCommentAt
+	}
+	r.IdleDays = int(now.Sub(last).Hours() / 24)
+}
+
+// registerResponseFields registers the fragments used to fetch the current values of the response
+// time fields configured in cfg
+func registerResponseFields(fragments *Fragments, cfg Config) error {
+	fields := map[string]string{
+		firstResponseField: cfg.FirstResponseField,
+		lastActivityField:  cfg.LastActivityField,
+	}
+
+	for name, field := range fields {
+		if field == "" {
+			continue
+		}
+		if err := fragments.Register(name, numberFieldSelection(field)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// responseField is a project field that a response time is written to
+type responseField struct {
+	name    string
+	fieldId githubv4.ID
+	value   func(Result) (float64, bool)
+}
+
+// resolveResponseFields looks up the IDs of the configured response time fields
+func (e *Engine) resolveResponseFields(ctx context.Context) error {
+	e.responseFields = nil
+
+	fields := []struct {
+		name, field string
+		value       func(Result) (float64, bool)
+	}{
+		{firstResponseField, e.cfg.FirstResponseField, func(r Result) (float64, bool) {
+			if r.FirstResponseHours == nil {
+				return 0, false
+			}
+			return *r.FirstResponseHours, true
+		}},
+		{lastActivityField, e.cfg.LastActivityField, func(r Result) (float64, bool) {
+			return float64(r.IdleDays), !r.Content.CreatedAt.IsZero()
+		}},
+	}
+
+	for _, f := range fields {
+		if f.field == "" {
+			continue
+		}
+
+		id, err := projectNumberField(ctx, e.gh, e.cfg.ProjectID, f.field)
+		if err != nil {
+			return err
+		}
+		e.responseFields = append(e.responseFields, responseField{name: f.name, fieldId: id, value: f.value})
+	}
+
+	return nil
+}
+
+// writeResponses writes the item's response times to their project fields, where they have changed.
+// Nothing is written when the token cannot update the project.
+func (e *Engine) writeResponses(ctx context.Context, result Result) {
+	if e.readOnly || !exportable(result) {
+		return
+	}
+
+	for _, field := range e.responseFields {
+		value, ok := field.value(result)
+		if !ok {
+			continue
+		}
+
+		current, set := numberField(Item{Extra: result.Extra}, field.name)
+		if set && current == value {
+			continue
+		}
+
+		if err := e.limiter.Wait(ctx); err != nil {
+			return
+		}
+
+		record := AuditRecord{Mutation: "set-response-time", ItemID: result.ItemID, FieldID: field.fieldId, OldValue: current, NewValue: value}
+		err := e.audit.Mutation(record, func() error {
+			v := githubv4.ProjectV2FieldValue{Number: githubv4.NewFloat(githubv4.Float(value))}
+			return setField(ctx, e.gh, e.cfg.ProjectID, result.ItemID, field.fieldId, v)
+		})
+		e.limiter.Spend(1)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to write response time field", "item_id", result.ItemID, "field", field.name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestResponses checks that the JSON report gives the items with timeline items a first response an
+// hour after the first comment, which is their author's own, and that the others have had none
+func TestResponses(t *testing.T) {
+	dir := t.TempDir()
+	server := newFakeGitHub()
+	run(t, selftestConfig(dir), server)
+
+	path := filepath.Join(dir, "report.json")
+	items, _, err := readReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byId := make(map[string]*selftestItem)
+	for _, item := range server.items {
+		byId[item.id] = item
+	}
+	for _, result := range items {
+		item := byId[fmt.Sprint(result.ItemID)]
+		if item == nil || item.closed || item.archived {
+			continue
+		}
+
+		if item.timeline == 0 {
+			if result.FirstResponseHours != nil {
+				t.Fatalf("%s: expected no first response, got %v hours", item.id, *result.FirstResponseHours)
+			}
+			continue
+		}
+		if result.FirstResponseHours == nil || *result.FirstResponseHours != 2 {
+			t.Fatalf("%s: expected a first response after 2 hours, got %v", item.id, result.FirstResponseHours)
+		}
+		last := selftestCreatedAt.Add(time.Duration(item.timeline) * time.Hour)
+		if result.Content.LastCommentAt == nil || !result.Content.LastCommentAt.Equal(last) {
+			t.Fatalf("%s: expected the last comment at %v, got %v", item.id, last, result.Content.LastCommentAt)
+		}
+	}
+}
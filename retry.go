@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryBackoff caps the time waited before a single retry
+const maxRetryBackoff = time.Minute
+
+// nonIdempotentMutations are the mutations that would be applied twice if made again after GitHub
+// received them, so they are only retried when the request never reached GitHub
+var nonIdempotentMutations = map[string]bool{
+	"addComment":           true,
+	"createCommitOnBranch": true,
+}
+
+// retryTransport makes requests to GitHub again after transient failures, waiting an exponentially
+// growing, jittered backoff between attempts, so that a single flaky response does not fail the run.
+// Failures are transient when the connection failed or was reset, GitHub answered 502, 503, or 504, or
+// the GraphQL API answered with its generic "Something went wrong" error. Every other failure, such as
+// an invalid query or a missing permission, is returned at once. Each retry is recorded in metrics.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	backoff time.Duration
+	metrics *ClientMetrics
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a request whose body cannot be read again cannot be retried
+	if req.Body != nil && req.GetBody == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.GetBody != nil {
+		if r, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(r)
+			r.Close()
+		}
+	}
+	kind := queryKind(req, body)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			r, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = r
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		retry, wait := t.retryable(kind, resp, err)
+		if !retry || attempt >= t.retries || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		wait = max(wait, t.delay(attempt))
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		slog.WarnContext(req.Context(), "transient failure from GitHub, retrying", "kind", kind, "attempt", attempt+1, "retries", t.retries, "wait", wait.Round(time.Millisecond), "reason", reason)
+		t.metrics.Retry(kind)
+
+		if !sleep(req.Context(), wait) {
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryable returns true if the failure of a request of the kind is transient, and the time GitHub
+// asked to wait before retrying, if any. The body of a successful response is read to look for errors,
+// and restored to be read again.
+func (t *retryTransport) retryable(kind string, resp *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		// a connection that was never made never reached GitHub, so even a mutation can be made again
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true, 0
+		}
+		return !nonIdempotentMutations[kind] && transientError(err), 0
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return !nonIdempotentMutations[kind], retryAfter(resp)
+	case http.StatusOK:
+	default:
+		return false, 0
+	}
+
+	b, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if readErr != nil {
+		return false, 0
+	}
+
+	var response struct {
+		Errors []struct {
+			Message string
+		}
+	}
+	if json.Unmarshal(b, &response) != nil || len(response.Errors) == 0 {
+		return false, 0
+	}
+	for _, e := range response.Errors {
+		if !strings.HasPrefix(e.Message, "Something went wrong") {
+			return false, 0
+		}
+	}
+	return !nonIdempotentMutations[kind], 0
+}
+
+// transientError returns true if the error is a reset or timed out connection, or a response cut short
+func transientError(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return strings.Contains(err.Error(), "connection reset")
+}
+
+// retryAfter returns the time the Retry-After header of the response asks to wait, or zero
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryBackoff)
+}
+
+// delay returns the backoff before the retry after the given attempt: the backoff doubled for each
+// earlier attempt, capped at maxRetryBackoff, of which a random half is waited, so that concurrent
+// requests failing together do not retry together
+func (t *retryTransport) delay(attempt int) time.Duration {
+	d := min(t.backoff<<attempt, maxRetryBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
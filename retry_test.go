@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails the first requests of a kind, first with a 502 and then with GraphQL's generic
+// error, before passing them to its base
+type flakyTransport struct {
+	base     http.RoundTripper
+	kind     string
+	failures int
+
+	mu       sync.Mutex
+	attempts int
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	fail := queryKind(req, body) == t.kind && t.attempts < t.failures
+	if fail {
+		t.attempts++
+	}
+	attempt := t.attempts
+	t.mu.Unlock()
+
+	switch {
+	case fail && attempt%2 == 1:
+		return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	case fail:
+		b := `{"data":null,"errors":[{"message":"Something went wrong while executing your query. Please try again later."}]}`
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(b)), Request: req}, nil
+	}
+	return t.base.RoundTrip(req)
+}
+
+// TestRetry runs a fresh synthetic project whose first requests for items fail with transient errors,
+// and checks that the run succeeds once they are retried, and that mutations that cannot be made twice
+// and failures that are not transient are not retried
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	server := newFakeGitHub()
+
+	metrics := NewClientMetrics()
+	flaky := &flakyTransport{base: server, kind: "items", failures: 2}
+	retry := &retryTransport{base: &metricsTransport{base: flaky, metrics: metrics}, retries: 2, backoff: time.Millisecond, metrics: metrics}
+	engine, err := newEngine(cfg, &http.Client{Transport: retry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "items retries", metrics.Snapshot()["items"].Retries, 2)
+	expectCount(t, "items processed", engine.summary.Total, len(server.items))
+	expectCount(t, "items failed", engine.summary.Statuses[StatusFailed], 0)
+
+	for _, c := range []struct {
+		kind   string
+		status int
+		want   bool
+	}{
+		{"items", http.StatusServiceUnavailable, true},
+		{"addComment", http.StatusBadGateway, false},
+		{"items", http.StatusBadRequest, false},
+		{"items", http.StatusUnauthorized, false},
+	} {
+		resp := &http.Response{StatusCode: c.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+		if got, _ := retry.retryable(c.kind, resp, nil); got != c.want {
+			t.Fatalf("%s answered %d: expected a retry to be %v", c.kind, c.status, c.want)
+		}
+	}
+}
+
+// TestRetryCancellation cancels a request while it waits to be retried, and checks that it returns the
+// context's error at once rather than after the backoff
+func TestRetryCancellation(t *testing.T) {
+	unavailable := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+	retry := &retryTransport{base: unavailable, retries: 3, backoff: time.Hour, metrics: NewClientMetrics()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphqlURL, strings.NewReader(`{"query":"query{viewer{login}}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := retry.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request took %v to stop", elapsed.Round(time.Millisecond))
+	}
+}
+
+// roundTripFunc is an http.RoundTripper answering every request with a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
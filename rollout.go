@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+// Rollout stages the adoption of a new scoring profile: items in the rollout are scored with its
+// profile, and every other item with the current one. Which items are in the rollout depends only on
+// their IDs, so an item stays in the rollout from run to run, and raising the percentage only adds
+// items to it.
+type Rollout struct {
+	// Profile is the new scoring profile. Without one, there is no rollout.
+	Profile *ScoringProfile `mapstructure:"profile"`
+
+	// Percent is the percentage of items, from 0 to 100, scored with the new profile
+	Percent float64 `mapstructure:"percent"`
+}
+
+// rolloutBucket places the item at a point from 0 to 100 that is the same in every run
+func rolloutBucket(itemId interface{}) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(fmt.Sprint(itemId)))
+	return float64(binary.BigEndian.Uint64(h.Sum(nil))%10000) / 100
+}
+
+// Includes returns true if the item is scored with the new profile
+func (r Rollout) Includes(itemId interface{}) bool {
+	return r.Profile != nil && rolloutBucket(itemId) < r.Percent
+}
+
+// ProfileFor returns the profile the item is scored with: the new profile if the item is in the
+// rollout, and the current profile otherwise
+func (r Rollout) ProfileFor(current ScoringProfile, itemId interface{}) ScoringProfile {
+	if r.Includes(itemId) {
+		return *r.Profile
+	}
+	return current
+}
+
+// validate returns an error if the percentage is out of range
+func (r Rollout) validate() error {
+	if r.Percent < 0 || r.Percent > 100 {
+		return fmt.Errorf("invalid rollout percentage %v: must be from 0 to 100", r.Percent)
+	}
+	return nil
+}
+
+// PlanRollout records the rollout that a plan's values were calculated with, so that applying the plan
+// tags each value with the profile it was calculated with
+type PlanRollout struct {
+	Profile string  `json:"profile"`
+	Percent float64 `json:"percent"`
+}
+
+// plan returns the rollout as recorded in a plan, or nil if there is none
+func (r Rollout) plan() *PlanRollout {
+	if r.Profile == nil {
+		return nil
+	}
+	return &PlanRollout{Profile: r.Profile.ID(), Percent: r.Percent}
+}
+
+// profileOf returns a function naming the ID of the profile each item's value was calculated with, from
+// the current profile's ID and the rollout
+func profileOf(current string, rollout *PlanRollout) func(itemId interface{}) string {
+	return func(itemId interface{}) string {
+		if rollout != nil && rolloutBucket(itemId) < rollout.Percent {
+			return rollout.Profile
+		}
+		return current
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+// TestRollout runs a fresh synthetic project with half of the items rolled out to a profile that
+// doubles the weight of comments, and checks that each item is written and tagged with its own profile
+func TestRollout(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Rollout = Rollout{Profile: &ScoringProfile{Comments: 2, Reactions: 1, Timeline: 1}, Percent: 50}
+	server := newFakeGitHub()
+	run(t, cfg, server)
+
+	profiles := NewProfileState()
+	if err := loadState(cfg.StateDir, profilesFile, profiles); err != nil {
+		t.Fatal(err)
+	}
+
+	var rolledOut int
+	for _, item := range server.items {
+		if item.closed || item.archived {
+			continue
+		}
+
+		want, profile := item.upvotes(), cfg.Scoring.ID()
+		if cfg.Rollout.Includes(item.id) {
+			want, profile = want+float64(item.comments), cfg.Rollout.Profile.ID()
+			rolledOut++
+		}
+
+		if got := finalValue(server, item); got != want {
+			t.Errorf("%s: expected %v, got %v", item.id, want, got)
+		}
+		if profiles.Items[item.id] != profile {
+			t.Errorf("%s: expected to be tagged with profile %s, got %s", item.id, profile, profiles.Items[item.id])
+		}
+	}
+
+	if rolledOut == 0 || rolledOut == selftestItems-2 {
+		t.Fatalf("expected a share of the items to be rolled out, got %d of %d", rolledOut, selftestItems-2)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// trackedIssuesField is the name that the issues tracked by an item's task list are fetched under
+const trackedIssuesField = "tracked_issues"
+
+// trackedIssuesSelection fetches the issues tracked in the task list of an Issue, along with the
+// activity used to score them
+const trackedIssuesSelection = "content { ...on Issue { trackedIssues(first: 50) { totalCount nodes { id url title number state repository { nameWithOwner } comments { totalCount } reactions { totalCount } } } } }"
+
+// registerRollupFields registers the fragment used to fetch tracked issues, if the scoring profile
+// rolls them up or the epics reporter is configured
+func registerRollupFields(fragments *Fragments, cfg Config) error {
+	used := cfg.Scoring.Rollup != 0
+	for _, spec := range cfg.Reporters {
+		if name, _, _ := strings.Cut(spec, "="); name == "epics" {
+			used = true
+		}
+	}
+	if !used {
+		return nil
+	}
+
+	return fragments.Register(trackedIssuesField, trackedIssuesSelection)
+}
+
+// TrackedIssue is an issue tracked in the task list of an epic
+type TrackedIssue struct {
+	Id         string
+	Url        string
+	Title      string
+	Number     int
+	State      string
+	Repository struct {
+		NameWithOwner string
+	}
+	Comments  TotalCountFragment
+	Reactions TotalCountFragment
+}
+
+// Open returns true if the tracked issue is open
+func (t TrackedIssue) Open() bool {
+	return t.State == "OPEN"
+}
+
+// Score returns the tracked issue's comments and reactions, weighted by the profile. Timeline items
+// are not fetched for tracked issues, to keep the cost of the query down.
+func (t TrackedIssue) Score(profile ScoringProfile) float64 {
+	profile = profile.ForType("Issue")
+	return float64(t.Comments.TotalCount)*profile.Comments + float64(t.Reactions.TotalCount)*profile.Reactions
+}
+
+// Name returns a human-readable name for the tracked issue, such as `owner/repo#12: Title`
+func (t TrackedIssue) Name() string {
+	return fmt.Sprintf("%s#%d: %s", t.Repository.NameWithOwner, t.Number, t.Title)
+}
+
+// trackedIssues returns the issues tracked by the item, if they were fetched
+func trackedIssues(extra map[string]json.RawMessage) []TrackedIssue {
+	raw, ok := extra[trackedIssuesField]
+	if !ok {
+		return nil
+	}
+
+	var content struct {
+		TrackedIssues struct {
+			Nodes []TrackedIssue
+		}
+	}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil
+	}
+
+	return content.TrackedIssues.Nodes
+}
+
+// rollupScore returns the component that adds the weighted scores of an epic's open tracked issues
+// to its own, and false if the item tracks no open issues
+func rollupScore(profile ScoringProfile, item Item) (ScoreComponent, bool) {
+	var sum float64
+	var open int
+	for _, tracked := range trackedIssues(item.Extra) {
+		if !tracked.Open() {
+			continue
+		}
+		sum += tracked.Score(profile)
+		open++
+	}
+
+	if open == 0 {
+		return ScoreComponent{}, false
+	}
+
+	return ScoreComponent{
+		Name:   "rollup",
+		Value:  sum * profile.Rollup,
+		Reason: fmt.Sprintf("%g from %d tracked issues x %g", sum, open, profile.Rollup),
+	}, true
+}
+
+// EpicNode is an epic or one of its tracked issues in the epic tree. Score is the item's own score, and
+// RolledUp its score plus the rolled up scores of its open tracked issues.
+type EpicNode struct {
+	Name     string      `json:"name"`
+	URL      string      `json:"url"`
+	Score    float64     `json:"score"`
+	RolledUp float64     `json:"rolled_up"`
+	Children []*EpicNode `json:"children,omitempty"`
+}
+
+// ownScore returns the item's score without the rolled up scores of its tracked issues
+func ownScore(result Result) float64 {
+	if len(result.Components) == 0 {
+		return result.Upvotes
+	}
+
+	var score float64
+	for _, c := range result.Components {
+		if c.Name != "rollup" {
+			score += c.Value
+		}
+	}
+	return score
+}
+
+// EpicTree builds the tree of epics in the results. Roots are the items that track open issues and are
+// not themselves tracked by another epic in the results. Tracked issues that are in the results use
+// their calculated score, and are expanded if they are epics themselves; others are scored from their
+// comments and reactions. Scores are always computed at the leaves, and rolled up unweighted.
+func EpicTree(results []Result, profile ScoringProfile) []*EpicNode {
+	byURL := make(map[string]Result)
+	tracked := make(map[string]bool)
+	for _, result := range results {
+		if !exportable(result) || result.Content.URL == "" {
+			continue
+		}
+		byURL[result.Content.URL] = result
+		for _, child := range trackedIssues(result.Extra) {
+			tracked[child.Url] = true
+		}
+	}
+
+	var build func(result Result, seen map[string]bool) *EpicNode
+	build = func(result Result, seen map[string]bool) *EpicNode {
+		node := &EpicNode{Name: result.Name(), URL: result.Content.URL, Score: ownScore(result)}
+		node.RolledUp = node.Score
+
+		seen[node.URL] = true
+		defer delete(seen, node.URL)
+
+		for _, child := range trackedIssues(result.Extra) {
+			if !child.Open() || seen[child.Url] {
+				continue
+			}
+
+			var childNode *EpicNode
+			if childResult, ok := byURL[child.Url]; ok {
+				childNode = build(childResult, seen)
+			} else {
+				score := child.Score(profile)
+				childNode = &EpicNode{Name: child.Name(), URL: child.Url, Score: score, RolledUp: score}
+			}
+
+			node.Children = append(node.Children, childNode)
+			node.RolledUp += childNode.RolledUp
+		}
+
+		return node
+	}
+
+	var roots []*EpicNode
+	for _, result := range results {
+		if !exportable(result) || tracked[result.Content.URL] {
+			continue
+		}
+		if node := build(result, make(map[string]bool)); len(node.Children) > 0 {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots
+}
+
+// epicsReporter writes the tree of epics and their tracked issues as a Markdown list, with each item's
+// own and rolled up scores, so that planning can happen at the epic level
+type epicsReporter struct {
+	collector
+	path    string
+	profile ScoringProfile
+}
+
+// Finish writes the file
+func (e *epicsReporter) Finish(summary Summary) error {
+	var b strings.Builder
+	b.WriteString("### Epics\n\n")
+
+	roots := EpicTree(e.results, e.profile)
+	if len(roots) == 0 {
+		b.WriteString("No epics track open issues.\n")
+	}
+
+	var write func(node *EpicNode, depth int)
+	write = func(node *EpicNode, depth int) {
+		name := node.Name
+		if node.URL != "" && node.URL != node.Name {
+			name = fmt.Sprintf("[%s](%s)", name, node.URL)
+		}
+
+		fmt.Fprintf(&b, "%s- %s: %v", strings.Repeat("  ", depth), name, node.Score)
+		if len(node.Children) > 0 {
+			fmt.Fprintf(&b, " own, %v rolled up", node.RolledUp)
+		}
+		b.WriteString("\n")
+
+		for _, child := range node.Children {
+			write(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		write(root, 0)
+	}
+
+	return os.WriteFile(e.path, []byte(b.String()), 0o644)
+}
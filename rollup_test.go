@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// trackedFixture is an issue tracked in an epic's task list, with the activity it is scored from
+type trackedFixture struct {
+	url                 string
+	open                bool
+	comments, reactions int
+}
+
+// trackedExtra returns the additional fields of an epic that tracks the issues, as fetched
+func trackedExtra(issues ...trackedFixture) map[string]json.RawMessage {
+	nodes := make([]map[string]interface{}, 0, len(issues))
+	for i, issue := range issues {
+		state := "CLOSED"
+		if issue.open {
+			state = "OPEN"
+		}
+		nodes = append(nodes, map[string]interface{}{
+			"id":         fmt.Sprintf("I_%d", i),
+			"url":        issue.url,
+			"title":      issue.url,
+			"number":     i + 1,
+			"state":      state,
+			"repository": map[string]string{"nameWithOwner": "octo/repo"},
+			"comments":   map[string]int{"totalCount": issue.comments},
+			"reactions":  map[string]int{"totalCount": issue.reactions},
+		})
+	}
+
+	raw, _ := json.Marshal(map[string]interface{}{"trackedIssues": map[string]interface{}{"nodes": nodes}})
+	return map[string]json.RawMessage{trackedIssuesField: raw}
+}
+
+// TestRollupScore checks the component an epic's open tracked issues add to its score, weighted by
+// comments, reactions, and the rollup weight
+func TestRollupScore(t *testing.T) {
+	profile := ScoringProfile{Comments: 1, Reactions: 2, Rollup: 0.5}
+
+	tests := []struct {
+		name   string
+		extra  map[string]json.RawMessage
+		ok     bool
+		value  float64
+		reason string
+	}{
+		{name: "not fetched"},
+		{name: "no tracked issues", extra: trackedExtra()},
+		{name: "only closed", extra: trackedExtra(trackedFixture{url: "a", comments: 10, reactions: 10})},
+		{
+			name:   "one open",
+			extra:  trackedExtra(trackedFixture{url: "a", open: true, comments: 3, reactions: 1}),
+			ok:     true,
+			value:  2.5,
+			reason: "5 from 1 tracked issues x 0.5",
+		},
+		{
+			name: "open and closed",
+			extra: trackedExtra(
+				trackedFixture{url: "a", open: true, comments: 3, reactions: 1},
+				trackedFixture{url: "b", open: true, comments: 2, reactions: 2},
+				trackedFixture{url: "c", comments: 10, reactions: 10},
+			),
+			ok:     true,
+			value:  5.5,
+			reason: "11 from 2 tracked issues x 0.5",
+		},
+		{
+			name:   "open without activity",
+			extra:  trackedExtra(trackedFixture{url: "a", open: true}),
+			ok:     true,
+			reason: "0 from 1 tracked issues x 0.5",
+		},
+	}
+	for _, test := range tests {
+		component, ok := rollupScore(profile, Item{Extra: test.extra})
+		if ok != test.ok || component.Value != test.value || component.Reason != test.reason {
+			t.Errorf("%s: expected %v, %v (%s), got %v, %v (%s)", test.name, test.ok, test.value, test.reason, ok, component.Value, component.Reason)
+		}
+		if ok && component.Name != "rollup" {
+			t.Errorf("%s: expected the rollup component, got %s", test.name, component.Name)
+		}
+	}
+}
+
+// epicResult returns the result of an item at the URL scored upvotes, of which rollup were rolled up from
+// the issues it tracks
+func epicResult(url string, upvotes, rollup float64, tracked ...trackedFixture) Result {
+	result := Result{Status: StatusUpdated, Upvotes: upvotes, Content: ContentInfo{URL: url}, Extra: trackedExtra(tracked...)}
+	if rollup != 0 {
+		result.Components = []ScoreComponent{{Name: "comments", Value: upvotes - rollup}, {Name: "rollup", Value: rollup}}
+	}
+	return result
+}
+
+// formatTree returns the nodes as URL own/rolled-up, followed by their children in brackets
+func formatTree(nodes []*EpicNode) string {
+	var s string
+	for i, node := range nodes {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%s %v/%v", node.URL, node.Score, node.RolledUp)
+		if len(node.Children) > 0 {
+			s += " [" + formatTree(node.Children) + "]"
+		}
+	}
+	return s
+}
+
+// TestEpicTree checks the tree of epics built from a run's results: which items are roots, how tracked
+// issues are scored, and how their scores are rolled up
+func TestEpicTree(t *testing.T) {
+	profile := ScoringProfile{Comments: 1, Reactions: 2, Rollup: 0.5}
+	open := func(url string, comments, reactions int) trackedFixture {
+		return trackedFixture{url: url, open: true, comments: comments, reactions: reactions}
+	}
+
+	tests := []struct {
+		name    string
+		results []Result
+		want    string
+	}{
+		{
+			name:    "no epics",
+			results: []Result{epicResult("e", 3, 0)},
+		},
+		{
+			name:    "tracked issues outside the results",
+			results: []Result{epicResult("e", 5.5, 2.5, open("a", 3, 1), trackedFixture{url: "c", comments: 10})},
+			want:    "e 3/8 [a 5/5]",
+		},
+		{
+			name:    "tracked issue in the results",
+			results: []Result{epicResult("e", 3, 0, open("a", 3, 1)), epicResult("a", 7, 0)},
+			want:    "e 3/10 [a 7/7]",
+		},
+		{
+			name:    "nested epics",
+			results: []Result{epicResult("f", 2.5, 0.5, open("g", 1, 0)), epicResult("e", 3, 0, open("f", 0, 0))},
+			want:    "e 3/6 [f 2/3 [g 1/1]]",
+		},
+		{
+			name: "cycle",
+			results: []Result{
+				epicResult("e", 1, 0, open("f", 0, 0)),
+				epicResult("f", 1, 0, open("g", 0, 0)),
+				epicResult("g", 1, 0, open("f", 0, 0)),
+			},
+			want: "e 1/3 [f 1/2 [g 1/1]]",
+		},
+		{
+			name:    "failed epic",
+			results: []Result{{Status: StatusFailed, Content: ContentInfo{URL: "e"}, Extra: trackedExtra(open("a", 1, 0))}},
+		},
+		{
+			name:    "two roots",
+			results: []Result{epicResult("e", 1, 0, open("a", 1, 0)), epicResult("f", 2, 0, open("b", 0, 1))},
+			want:    "e 1/2 [a 1/1], f 2/4 [b 2/2]",
+		},
+	}
+	for _, test := range tests {
+		if got := formatTree(EpicTree(test.results, profile)); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Rule is a condition evaluated against every calculated project item, along with the actions to take
+// when the condition is met. Rules are configured in the YAML config file, for example:
+//
+//	rules:
+//	  - name: popular-bug
+//	    when: score >= 100 and label:bug
+//	    actions:
+//	      - type: notify
+//	      - type: label
+//	        label: popular
+//
+// A rule fires once when its condition becomes true, and not again until the condition has stopped
+// being true, unless Repeat is set. This is tracked across runs in the state directory.
+type Rule struct {
+	Name    string   `mapstructure:"name"`
+	When    string   `mapstructure:"when"`
+	Repeat  bool     `mapstructure:"repeat"`
+	Actions []Action `mapstructure:"actions"`
+
+	condition condition
+}
+
+// Action is something to do when a Rule's condition is met. The fields used depend on the Type:
+//
+//   - notify: sends Message (or the default template) to Notifiers, or the configured notifiers
+//   - label: adds Label to the Issue or Pull Request
+//   - comment: adds a comment rendered from Message to the Issue or Pull Request
+//   - set-field: sets the project field FieldID to Text, Number, or the single select OptionID
+type Action struct {
+	Type     string   `mapstructure:"type"`
+	Message  string   `mapstructure:"message"`
+	Label    string   `mapstructure:"label"`
+	FieldID  string   `mapstructure:"field_id"`
+	Text     string   `mapstructure:"text"`
+	Number   *float64 `mapstructure:"number"`
+	OptionID string   `mapstructure:"option_id"`
+
+	// Notifiers are where a notify action sends its message, in the same form as --notifier
+	Notifiers []string `mapstructure:"notifiers"`
+
+	message   *MessageTemplate
+	notifiers Notifiers
+}
+
+// compile parses the rule's condition and action templates
+func (r *Rule) compile() error {
+	cond, err := parseCondition(r.When)
+	if err != nil {
+		return fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	r.condition = cond
+
+	for i := range r.Actions {
+		action := &r.Actions[i]
+
+		switch action.Type {
+		case "notify":
+			notifiers, err := newNotifiers(Config{Notifiers: action.Notifiers})
+			if err != nil {
+				return fmt.Errorf("rule %q: %w", r.Name, err)
+			}
+			action.notifiers = notifiers
+		case "comment":
+		case "label":
+			if action.Label == "" {
+				return fmt.Errorf("rule %q: label action requires a label", r.Name)
+			}
+		case "set-field":
+			if action.FieldID == "" {
+				return fmt.Errorf("rule %q: set-field action requires a field_id", r.Name)
+			}
+			if action.Text == "" && action.Number == nil && action.OptionID == "" {
+				return fmt.Errorf("rule %q: set-field action requires one of text, number, or option_id", r.Name)
+			}
+		default:
+			return fmt.Errorf("rule %q: unknown action type %q", r.Name, action.Type)
+		}
+
+		message, err := NewMessageTemplate(action.Message)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		action.message = message
+	}
+
+	return nil
+}
+
+// Matches returns true if the rule's condition is met by the notification's result
+func (r Rule) Matches(n Notification) bool {
+	return r.condition.eval(n)
+}
+
+// Threshold returns the value of the first comparison against the score in the rule's condition, so
+// that it is available to message templates
+func (r Rule) Threshold() float64 {
+	for _, group := range r.condition {
+		for _, t := range group {
+			if t.metric == "score" {
+				return t.value
+			}
+		}
+	}
+	return 0
+}
+
+// compileRules parses every rule, returning the first error
+func compileRules(rules []Rule) ([]Rule, error) {
+	compiled := make([]Rule, len(rules))
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		compiled[i] = rule
+	}
+	return compiled, nil
+}
+
+// thresholdRule returns the rule equivalent of the --notify-threshold flag, which notifies when an
+// item's upvotes cross the threshold, with its message in the language of the locale
+func thresholdRule(threshold float64, l *Locale) Rule {
+	return Rule{
+		Name:    "notify-threshold",
+		When:    fmt.Sprintf("score >= %[1]g and previous < %[1]g", threshold),
+		Actions: []Action{{Type: "notify", Message: l.T("notify.threshold")}},
+	}
+}
+
+// deltaRule returns the rule equivalent of the --notify-delta flag, which notifies when an item's upvotes
+// grew by at least delta since the last run, with its message in the language of the locale. It fires
+// again after a run in which the item grew by less.
+func deltaRule(delta float64, l *Locale) Rule {
+	return Rule{
+		Name:    "notify-delta",
+		When:    fmt.Sprintf("delta >= %g", delta),
+		Actions: []Action{{Type: "notify", Message: l.T("notify.delta")}},
+	}
+}
+
+// condition is a list of groups that are OR'd together, each of which is a list of terms that are
+// AND'd together
+type condition [][]term
+
+// term is a single comparison in a condition, either `metric op value`, `label:name`, or
+// `mention:org/team`
+type term struct {
+	metric  string
+	op      string
+	value   float64
+	label   string
+	mention string
+}
+
+// metrics lists the values that can be compared in a condition
+var metrics = []string{"score", "previous", "delta", "velocity"}
+
+// operators lists the supported comparison operators, longest first so that they are matched correctly
+var operators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// parseCondition parses a condition such as `score >= 100 and label:bug or delta >= 20`
+func parseCondition(s string) (condition, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("empty condition")
+	}
+
+	var cond condition
+	for _, group := range splitKeyword(s, "or") {
+		var terms []term
+		for _, part := range splitKeyword(group, "and") {
+			t, err := parseTerm(part)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, t)
+		}
+		cond = append(cond, terms)
+	}
+
+	return cond, nil
+}
+
+// splitKeyword splits s on a keyword surrounded by whitespace, ignoring case
+func splitKeyword(s, keyword string) []string {
+	fields := strings.Fields(s)
+
+	var parts []string
+	var current []string
+	for _, field := range fields {
+		if strings.EqualFold(field, keyword) {
+			parts = append(parts, strings.Join(current, " "))
+			current = nil
+			continue
+		}
+		current = append(current, field)
+	}
+
+	return append(parts, strings.Join(current, " "))
+}
+
+// parseTerm parses a single term of a condition
+func parseTerm(s string) (term, error) {
+	s = strings.TrimSpace(s)
+
+	if label, ok := strings.CutPrefix(s, "label:"); ok {
+		if label == "" {
+			return term{}, fmt.Errorf("empty label in %q", s)
+		}
+		return term{label: label}, nil
+	}
+
+	if team, ok := strings.CutPrefix(s, "mention:"); ok {
+		if team == "" {
+			return term{}, fmt.Errorf("empty team in %q", s)
+		}
+		return term{mention: strings.ToLower(strings.TrimPrefix(team, "@"))}, nil
+	}
+
+	for _, op := range operators {
+		metric, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+
+		metric = strings.TrimSpace(metric)
+		if !slices.Contains(metrics, metric) {
+			return term{}, fmt.Errorf("unknown metric %q in %q, must be one of %s", metric, s, strings.Join(metrics, ", "))
+		}
+
+		v, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return term{}, fmt.Errorf("invalid value in %q: %w", s, err)
+		}
+
+		return term{metric: metric, op: op, value: v}, nil
+	}
+
+	return term{}, fmt.Errorf("invalid term %q, expected `metric op value`, `label:name`, or `mention:org/team`", s)
+}
+
+// parseValue parses a number, optionally followed by a rate of /day or /week. Rates are converted
+// to a weekly value, which is the unit used for velocity.
+func parseValue(s string) (float64, error) {
+	number, unit, _ := strings.Cut(s, "/")
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	switch strings.TrimSpace(unit) {
+	case "", "week":
+		return v, nil
+	case "day":
+		return v * 7, nil
+	default:
+		return 0, fmt.Errorf("unknown rate %q, must be day or week", unit)
+	}
+}
+
+// eval returns true if any group has all of its terms met
+func (c condition) eval(n Notification) bool {
+	for _, group := range c {
+		matched := true
+		for _, t := range group {
+			if !t.eval(n) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// eval returns true if the term is met
+func (t term) eval(n Notification) bool {
+	if t.label != "" {
+		return slices.ContainsFunc(n.Content.Labels, func(l string) bool { return strings.EqualFold(l, t.label) })
+	}
+	if t.mention != "" {
+		return slices.ContainsFunc(n.Components, func(c ScoreComponent) bool { return c.Name == mentionComponent+t.mention })
+	}
+
+	var v float64
+	switch t.metric {
+	case "score":
+		v = n.Upvotes
+	case "previous":
+		v = n.Previous
+	case "delta":
+		v = n.Delta
+	case "velocity":
+		v = n.Velocity
+	}
+
+	switch t.op {
+	case ">=":
+		return v >= t.value
+	case "<=":
+		return v <= t.value
+	case "==":
+		return v == t.value
+	case "!=":
+		return v != t.value
+	case ">":
+		return v > t.value
+	case "<":
+		return v < t.value
+	}
+
+	return false
+}
+
+// velocity returns the upvotes per week since the content was created
+func velocity(result Result) float64 {
+	if result.Content.CreatedAt.IsZero() {
+		return 0
+	}
+
+	weeks := time.Since(result.Content.CreatedAt).Hours() / (24 * 7)
+	if weeks < 1 {
+		weeks = 1
+	}
+
+	return result.Upvotes / weeks
+}
+
+// evaluateRules runs the actions of every rule whose condition is met by the result. Only items
+// whose upvotes were calculated are evaluated.
+func (e *Engine) evaluateRules(ctx context.Context, result Result) {
+	if result.Status != StatusUpdated && result.Status != StatusUnchanged {
+		return
+	}
+
+	for _, rule := range e.rules {
+		n := NewNotification(result, rule)
+		if !rule.Matches(n) {
+			e.fired.Reset(rule.Name, result.ItemID)
+			continue
+		}
+
+		if !rule.Repeat && e.fired.HasFired(rule.Name, result.ItemID) {
+			slog.DebugContext(ctx, "rule already fired, skipping", "rule", rule.Name, "item_id", result.ItemID)
+			continue
+		}
+		e.fired.MarkFired(rule.Name, result.ItemID)
+
+		slog.DebugContext(ctx, "rule matched", "rule", rule.Name, "item_id", result.ItemID)
+
+		for _, action := range rule.Actions {
+			if err := e.runAction(ctx, action, n); err != nil {
+				slog.ErrorContext(ctx, "failed to run rule action", "rule", rule.Name, "action", action.Type, "item_id", result.ItemID, "error", err)
+			}
+		}
+	}
+}
+
+// runAction performs a single rule action for the notification's item. Actions that write to GitHub
+// are skipped when the token cannot update the project, and dry runs send no notifications either.
+func (e *Engine) runAction(ctx context.Context, action Action, n Notification) error {
+	if e.cfg.DryRun {
+		slog.DebugContext(ctx, "skipping rule action in dry run", "rule", n.Rule, "action", action.Type, "item_id", n.ItemID)
+		return nil
+	}
+	if e.readOnly && action.Type != "notify" {
+		slog.DebugContext(ctx, "skipping rule action in read-only mode", "rule", n.Rule, "action", action.Type, "item_id", n.ItemID)
+		return nil
+	}
+
+	switch action.Type {
+	case "notify":
+		if e.redact {
+			n = n.Redacted()
+		}
+		message, err := action.message.Render(n)
+		if err != nil {
+			return err
+		}
+		if len(action.notifiers) > 0 {
+			return action.notifiers.Notify(ctx, message)
+		}
+		if e.digest != nil {
+			e.digest.Add(message)
+			return nil
+		}
+		return e.notifiers.Notify(ctx, message)
+	case "comment":
+		body, err := action.message.Render(n)
+		if err != nil {
+			return err
+		}
+		record := AuditRecord{Mutation: "add-comment", ItemID: n.ItemID, NewValue: body}
+		return e.audit.Mutation(record, func() error {
+			return addComment(ctx, e.gh, n.Content.ID, body)
+		})
+	case "label":
+		record := AuditRecord{Mutation: "add-label", ItemID: n.ItemID, NewValue: action.Label}
+		return e.audit.Mutation(record, func() error {
+			return addLabel(ctx, e.gh, n.Content.ID, action.Label)
+		})
+	case "set-field":
+		record := AuditRecord{Mutation: "set-field", ItemID: n.ItemID, FieldID: githubv4.ID(action.FieldID)}
+		value := githubv4.ProjectV2FieldValue{}
+		switch {
+		case action.Number != nil:
+			value.Number = githubv4.NewFloat(githubv4.Float(*action.Number))
+			record.NewValue = *action.Number
+		case action.OptionID != "":
+			value.SingleSelectOptionID = githubv4.NewString(githubv4.String(action.OptionID))
+			record.NewValue = action.OptionID
+		default:
+			value.Text = githubv4.NewString(githubv4.String(action.Text))
+			record.NewValue = action.Text
+		}
+		return e.audit.Mutation(record, func() error {
+			return setField(ctx, e.gh, e.cfg.ProjectID, n.ItemID, githubv4.ID(action.FieldID), value)
+		})
+	}
+
+	return nil
+}
+
+// addComment adds a comment to an Issue or Pull Request
+func addComment(ctx context.Context, gh *githubv4.Client, subjectId githubv4.ID, body string) error {
+	var mutation struct {
+		AddComment struct {
+			ClientMutationId string
+		} `graphql:"addComment(input: $input)"`
+	}
+
+	return gh.Mutate(ctx, &mutation, githubv4.AddCommentInput{SubjectID: subjectId, Body: githubv4.String(body)}, nil)
+}
+
+// addLabel adds an existing label, looked up by name in the content's repository, to an Issue or Pull Request
+func addLabel(ctx context.Context, gh *githubv4.Client, labelableId githubv4.ID, name string) error {
+	type repositoryLabel struct {
+		Repository struct {
+			Label struct {
+				Id githubv4.ID
+			} `graphql:"label(name: $name)"`
+		}
+	}
+
+	var query struct {
+		Node struct {
+			Issue       repositoryLabel `graphql:"...on Issue"`
+			PullRequest repositoryLabel `graphql:"...on PullRequest"`
+		} `graphql:"node(id: $nodeId)"`
+	}
+
+	variables := Variables().ID("nodeId", labelableId).String("name", name)
+	if err := runQuery(ctx, gh, &query, variables); err != nil {
+		return err
+	}
+
+	labelId := query.Node.Issue.Repository.Label.Id
+	if labelId == nil {
+		labelId = query.Node.PullRequest.Repository.Label.Id
+	}
+	if labelId == nil {
+		return fmt.Errorf("label %q does not exist in the repository", name)
+	}
+
+	var mutation struct {
+		AddLabelsToLabelable struct {
+			ClientMutationId string
+		} `graphql:"addLabelsToLabelable(input: $input)"`
+	}
+
+	input := githubv4.AddLabelsToLabelableInput{
+		LabelableID: labelableId,
+		LabelIDs:    []githubv4.ID{labelId},
+	}
+
+	return gh.Mutate(ctx, &mutation, input, nil)
+}
+
+// setField sets the value of a field on a project item
+func setField(ctx context.Context, gh *githubv4.Client, projectId, itemId, fieldId githubv4.ID, value githubv4.ProjectV2FieldValue) error {
+	var mutation struct {
+		UpdateProjectItemV2FieldValue struct {
+			ClientMutationId string
+		} `graphql:"updateProjectV2ItemFieldValue(input: $input)"`
+	}
+
+	input := githubv4.UpdateProjectV2ItemFieldValueInput{
+		ProjectID: projectId,
+		ItemID:    itemId,
+		FieldID:   fieldId,
+		Value:     value,
+	}
+
+	return gh.Mutate(ctx, &mutation, input, nil)
+}
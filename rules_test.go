@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+// TestNotifyDelta checks that the rule of --notify-delta matches an item that gained ten upvotes, and
+// not one that gained fewer
+func TestNotifyDelta(t *testing.T) {
+	rule := deltaRule(10, nil)
+	if err := rule.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	for previous, want := range map[float64]bool{5: true, 6: false, 20: false} {
+		n := NewNotification(Result{Previous: previous, Upvotes: 15}, rule)
+		if got := rule.Matches(n); got != want {
+			t.Fatalf("%v -> 15: expected a match to be %v", previous, want)
+		}
+	}
+}
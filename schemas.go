@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// schemaBaseURL is the base of the $id of every schema
+const schemaBaseURL = "https://github.com/justinretzolk/github-upvotes/schemas/"
+
+// schema is a JSON Schema document, built from map literals so that the statuses stay in sync with
+// the statuses the tool reports
+type schema = map[string]any
+
+// schemas maps the name of each artifact the tool writes to a function returning its JSON Schema.
+// Changes to an artifact that are not backwards compatible must bump the version in its $id.
+var schemas = map[string]func() schema{
+	"summary": summarySchema,
+	"report":  reportSchema,
+	"plan":    planSchema,
+	"audit":   auditSchema,
+	"history": historySchema,
+}
+
+// schemaDocument wraps the definition of an artifact with the fields common to every schema
+func schemaDocument(name, title string, definition schema) schema {
+	definition["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	definition["$id"] = schemaBaseURL + name + ".v1.json"
+	definition["title"] = title
+	return definition
+}
+
+// object returns the schema of an object with the given properties, all of which are required
+// unless listed in optional
+func object(properties schema, optional ...string) schema {
+	var required []string
+	for name := range properties {
+		if !contains(optional, name) {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+
+	return schema{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// contains returns true if s is in list
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// scalar schemas shared by several artifacts
+var (
+	stringSchema   = schema{"type": "string"}
+	numberSchema   = schema{"type": "number"}
+	integerSchema  = schema{"type": "integer", "minimum": 0}
+	dateTimeSchema = schema{"type": "string", "format": "date-time"}
+)
+
+// statusSchema returns the schema of an item status
+func statusSchema() schema {
+	enum := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		enum = append(enum, string(status))
+	}
+	return schema{"type": "string", "enum": enum}
+}
+
+// statsDefinition returns the schema of ScoreStats
+func statsDefinition() schema {
+	return object(schema{
+		"items":  integerSchema,
+		"total":  numberSchema,
+		"min":    numberSchema,
+		"median": numberSchema,
+		"p90":    numberSchema,
+		"max":    numberSchema,
+		"buckets": schema{"type": "array", "items": object(schema{
+			"min":   numberSchema,
+			"max":   schema{"type": "number", "description": "exclusive upper bound, absent for the last bucket"},
+			"items": integerSchema,
+		}, "max")},
+	})
+}
+
+// summaryDefinition returns the schema of a Summary
+func summaryDefinition() schema {
+	return object(schema{
+		"total":    integerSchema,
+		"statuses": schema{"type": "object", "propertyNames": statusSchema(), "additionalProperties": integerSchema},
+		"conflicts": schema{"type": "array", "items": object(schema{
+			"item_id":  stringSchema,
+			"expected": numberSchema,
+			"actual":   numberSchema,
+		})},
+		"delta": numberSchema,
+		"movers": schema{"type": "array", "items": object(schema{
+			"item_id":  stringSchema,
+			"name":     stringSchema,
+			"previous": numberSchema,
+			"value":    numberSchema,
+		})},
+		"stale":    integerSchema,
+		"inactive": integerSchema,
+		"stats":    statsDefinition(),
+		"rate_limit": object(schema{
+			"used":      integerSchema,
+			"remaining": schema{"type": "integer"},
+			"reserve":   integerSchema,
+			"unused":    integerSchema,
+			"reset_at":  dateTimeSchema,
+			"limit":     integerSchema,
+
+			"cost_per_item":        numberSchema,
+			"next_run_at":          dateTimeSchema,
+			"next_run_fits":        schema{"type": "boolean"},
+			"recommended_interval": stringSchema,
+		}, "limit", "cost_per_item", "next_run_at", "recommended_interval"),
+	}, "conflicts", "movers", "stale", "inactive", "stats", "rate_limit")
+}
+
+// summarySchema is the schema of the file written by --summary-file
+func summarySchema() schema {
+	return schemaDocument("summary", "github-upvotes run summary", summaryDefinition())
+}
+
+// reportSchema is the schema of the file written by the json reporter
+func reportSchema() schema {
+	result := object(schema{
+		"item_id":  stringSchema,
+		"status":   statusSchema(),
+		"previous": numberSchema,
+		"upvotes":  numberSchema,
+		"value":    numberSchema,
+		"components": schema{"type": "array", "items": object(schema{
+			"name":   stringSchema,
+			"value":  numberSchema,
+			"reason": stringSchema,
+		}, "reason")},
+		"content": object(schema{
+			"id":         stringSchema,
+			"url":        stringSchema,
+			"labels":     schema{"type": "array", "items": stringSchema},
+			"created_at": dateTimeSchema,
+			"title":      stringSchema,
+			"number":     integerSchema,
+			"repository": stringSchema,
+			"assignees":  schema{"type": "array", "items": stringSchema},
+			"links": schema{"type": "array", "items": object(schema{
+				"kind": schema{"type": "string", "enum": []string{LinkCrossReference, LinkConnected, LinkDuplicate}},
+				"url":  stringSchema,
+			})},
+			"first_response_at": dateTimeSchema,
+			"last_comment_at":   dateTimeSchema,
+		}, "id", "url", "labels", "created_at", "title", "number", "repository", "assignees", "links", "first_response_at", "last_comment_at"),
+		"extra":                schema{"type": "object", "description": "raw JSON of each additional field, by name"},
+		"age_days":             integerSchema,
+		"upvotes_per_day":      numberSchema,
+		"segments":             schema{"type": "object", "additionalProperties": integerSchema, "description": "unique upvoters in each segment, by name"},
+		"first_response_hours": schema{"type": "number", "description": "hours until a maintainer first responded"},
+		"idle_days":            schema{"type": "integer", "description": "days since the last comment, or since creation"},
+		"inactive":             schema{"type": "boolean", "description": "whether the item has had no new engagement for the configured number of days"},
+	}, "components", "extra", "age_days", "upvotes_per_day", "segments", "first_response_hours", "idle_days", "inactive")
+
+	return schemaDocument("report", "github-upvotes JSON report", object(schema{
+		"run": object(schema{
+			"id":         stringSchema,
+			"project_id": stringSchema,
+			"field_id":   stringSchema,
+			"started_at": dateTimeSchema,
+		}),
+		"summary": summaryDefinition(),
+		"items":   schema{"type": []string{"array", "null"}, "items": result},
+	}))
+}
+
+// planSchema is the schema of the plan file written in read-only mode and performed by apply
+func planSchema() schema {
+	return schemaDocument("plan", "github-upvotes mutation plan", object(schema{
+		"project_id": stringSchema,
+		"field_id":   stringSchema,
+		"run_id":     stringSchema,
+		"profile":    stringSchema,
+		"rollout": object(schema{
+			"profile": stringSchema,
+			"percent": numberSchema,
+		}),
+		"created_at": dateTimeSchema,
+		"mutations": schema{"type": []string{"array", "null"}, "items": object(schema{
+			"item_id":   stringSchema,
+			"old_value": numberSchema,
+			"new_value": numberSchema,
+		})},
+	}, "profile", "rollout"))
+}
+
+// auditSchema is the schema of each line of the audit log
+func auditSchema() schema {
+	return schemaDocument("audit", "github-upvotes audit log record", object(schema{
+		"timestamp": dateTimeSchema,
+		"run_id":    stringSchema,
+		"event":     schema{"type": "string", "enum": []string{AuditAttempted, AuditSucceeded, AuditFailed}},
+		"mutation":  stringSchema,
+		"item_id":   stringSchema,
+		"field_id":  stringSchema,
+		"old_value": schema{"description": "the field value before the mutation, of the type the mutation writes"},
+		"new_value": schema{"description": "the field value written by the mutation, of the type the mutation writes"},
+		"error":     stringSchema,
+	}, "field_id", "old_value", "new_value", "error"))
+}
+
+// historySchema is the schema of each line of the score history
+func historySchema() schema {
+	return schemaDocument("history", "github-upvotes score history entry", object(schema{
+		"run_id": stringSchema,
+		"time":   dateTimeSchema,
+		"items": schema{"type": "object", "additionalProperties": object(schema{
+			"url":     stringSchema,
+			"upvotes": numberSchema,
+			"components": schema{"type": "array", "items": object(schema{
+				"name":   stringSchema,
+				"value":  numberSchema,
+				"reason": stringSchema,
+			}, "reason")},
+		}, "url", "components")},
+		"stats": statsDefinition(),
+	}, "stats"))
+}
+
+// printSchema writes the named schema as indented JSON
+func printSchema(w io.Writer, name string) error {
+	build, ok := schemas[name]
+	if !ok {
+		return fmt.Errorf("unknown schema %q: must be one of %s", name, strings.Join(schemaNames(), ", "))
+	}
+
+	b, err := json.MarshalIndent(build(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// schemaNames returns the names of every schema, sorted
+func schemaNames() []string {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
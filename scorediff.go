@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// scoreDiffTop is the number of top items, by their new upvotes, shown in the score diff
+const scoreDiffTop = 50
+
+// scoreDiffMarker identifies the pull request comment holding the score diff, so that later runs
+// update it rather than adding another
+const scoreDiffMarker = "<!-- github-upvotes:score-diff -->"
+
+// ScoreDiffItem is an item's place in the ranking by its current upvotes and by its new upvotes
+type ScoreDiffItem struct {
+	Result
+
+	// Rank and PreviousRank are the item's place, from 1, ranked by its new and current upvotes
+	Rank         int
+	PreviousRank int
+}
+
+// Change returns how much the item's upvotes would change
+func (i ScoreDiffItem) Change() float64 {
+	return i.Upvotes - i.Previous
+}
+
+// ScoreDiff is how a run's configuration would change the scores of the project's items from their
+// current values
+type ScoreDiff struct {
+	Items []ScoreDiffItem
+
+	// Changed is the number of items whose upvotes would change, and MeanChange the mean absolute
+	// change across every item
+	Changed    int
+	MeanChange float64
+}
+
+// NewScoreDiff ranks the scored results by their current and new upvotes
+func NewScoreDiff(results []Result) ScoreDiff {
+	var diff ScoreDiff
+	for _, result := range results {
+		if exportable(result) {
+			diff.Items = append(diff.Items, ScoreDiffItem{Result: result})
+		}
+	}
+
+	rank := func(value func(ScoreDiffItem) float64, set func(*ScoreDiffItem, int)) {
+		sort.SliceStable(diff.Items, func(i, j int) bool {
+			if value(diff.Items[i]) != value(diff.Items[j]) {
+				return value(diff.Items[i]) > value(diff.Items[j])
+			}
+			return diff.Items[i].Name() < diff.Items[j].Name()
+		})
+		for i := range diff.Items {
+			set(&diff.Items[i], i+1)
+		}
+	}
+	rank(func(i ScoreDiffItem) float64 { return i.Previous }, func(i *ScoreDiffItem, r int) { i.PreviousRank = r })
+	rank(func(i ScoreDiffItem) float64 { return i.Upvotes }, func(i *ScoreDiffItem, r int) { i.Rank = r })
+
+	var total float64
+	for _, item := range diff.Items {
+		if item.Change() != 0 {
+			diff.Changed++
+		}
+		total += math.Abs(item.Change())
+	}
+	if len(diff.Items) > 0 {
+		diff.MeanChange = math.Round(total/float64(len(diff.Items))*100) / 100
+	}
+
+	return diff
+}
+
+// Markdown returns the diff as Markdown: a summary, a table of the top items by their new upvotes, and
+// the items that would leave the top, in the language of the locale
+func (d ScoreDiff) Markdown(top int, l *Locale) string {
+	var b strings.Builder
+	b.WriteString(scoreDiffMarker + "\n")
+	fmt.Fprintf(&b, "### %s\n\n", l.T("diff.title"))
+
+	if len(d.Items) == 0 {
+		fmt.Fprintf(&b, "%s\n", l.T("diff.none"))
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%s\n\n", l.T("diff.changed", d.Changed, len(d.Items), d.MeanChange))
+
+	b.WriteString(l.row("diff.rank", "summary.item", "diff.current", "diff.new", "summary.change"))
+	fmt.Fprintf(&b, "| --- | --- | --- | --- | --- |\n")
+	for _, item := range d.Items[:min(top, len(d.Items))] {
+		fmt.Fprintf(&b, "| %d %s | %s | %v | %v | %s |\n", item.Rank, formatRankChange(item), markdownLink(item.Result), item.Previous, item.Upvotes, formatChange(item.Change()))
+	}
+
+	var leaving []string
+	for _, item := range d.Items {
+		if item.PreviousRank <= top && item.Rank > top {
+			leaving = append(leaving, "- "+l.T("diff.leaving_item", markdownLink(item.Result), item.PreviousRank, item.Rank))
+		}
+	}
+	if len(leaving) > 0 {
+		fmt.Fprintf(&b, "\n%s\n\n%s\n", l.T("diff.leaving", top), strings.Join(leaving, "\n"))
+	}
+
+	return b.String()
+}
+
+// formatRankChange describes how the item's rank would change
+func formatRankChange(item ScoreDiffItem) string {
+	switch {
+	case item.Rank < item.PreviousRank:
+		return fmt.Sprintf("(▲%d)", item.PreviousRank-item.Rank)
+	case item.Rank > item.PreviousRank:
+		return fmt.Sprintf("(▼%d)", item.Rank-item.PreviousRank)
+	}
+	return ""
+}
+
+// formatChange formats a change in upvotes with its sign
+func formatChange(change float64) string {
+	if change > 0 {
+		return fmt.Sprintf("+%v", change)
+	}
+	return fmt.Sprint(change)
+}
+
+// scoreDiffReporter writes how the run's configuration would change the scores of the top items from
+// their current values, and when run for a pull request, posts it as a comment on the pull request. It is
+// meant for read-only runs of a pull request that changes the scoring configuration, so that the
+// change can be reviewed before it is merged.
+type scoreDiffReporter struct {
+	collector
+	path      string
+	gh        *githubv4.Client
+	eventPath string
+	locale    *Locale
+	dryRun    bool
+	ctx       context.Context
+}
+
+// SetContext sets the context that the comment is posted with
+func (s *scoreDiffReporter) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// Finish writes the diff to the file, and posts it to the pull request unless the run is a dry run
+func (s *scoreDiffReporter) Finish(summary Summary) error {
+	body := NewScoreDiff(s.results).Markdown(scoreDiffTop, s.locale)
+
+	if s.path != "" {
+		if err := os.WriteFile(s.path, []byte(body), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if s.dryRun {
+		return nil
+	}
+
+	pr, err := pullRequestFromEvent(s.eventPath)
+	if err != nil || pr == "" {
+		return err
+	}
+
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return upsertPullRequestComment(ctx, s.gh, pr, redact(body))
+}
+
+// pullRequestFromEvent returns the node ID of the pull request that triggered the workflow, from the
+// webhook event at path, or an empty ID if the workflow was not triggered by a pull request
+func pullRequestFromEvent(path string) (githubv4.ID, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading workflow event: %w", err)
+	}
+
+	var event struct {
+		PullRequest *struct {
+			NodeID string `json:"node_id"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(b, &event); err != nil {
+		return "", fmt.Errorf("reading workflow event: %w", err)
+	}
+	if event.PullRequest == nil {
+		return "", nil
+	}
+
+	return githubv4.ID(event.PullRequest.NodeID), nil
+}
+
+// PullRequestCommentsQuery lists the most recent comments on a pull request
+type PullRequestCommentsQuery struct {
+	Node struct {
+		PullRequest struct {
+			Comments struct {
+				Nodes []struct {
+					Id              githubv4.ID
+					Body            githubv4.String
+					ViewerDidAuthor githubv4.Boolean
+				}
+			} `graphql:"comments(last: 100)"`
+		} `graphql:"...on PullRequest"`
+	} `graphql:"node(id: $id)"`
+}
+
+// upsertPullRequestComment updates the score diff comment the token previously posted on the pull
+// request, or adds one if there is none
+func upsertPullRequestComment(ctx context.Context, gh *githubv4.Client, pr githubv4.ID, body string) error {
+	var query PullRequestCommentsQuery
+	if err := runQuery(ctx, gh, &query, Variables().ID("id", pr)); err != nil {
+		return fmt.Errorf("listing pull request comments: %w", err)
+	}
+
+	for _, comment := range query.Node.PullRequest.Comments.Nodes {
+		if !bool(comment.ViewerDidAuthor) || !strings.Contains(string(comment.Body), scoreDiffMarker) {
+			continue
+		}
+
+		var m struct {
+			UpdateIssueComment struct {
+				IssueComment struct {
+					Id githubv4.ID
+				}
+			} `graphql:"updateIssueComment(input: $input)"`
+		}
+		input := githubv4.UpdateIssueCommentInput{ID: comment.Id, Body: githubv4.String(body)}
+		if err := gh.Mutate(ctx, &m, input, nil); err != nil {
+			return fmt.Errorf("updating pull request comment: %w", err)
+		}
+		return nil
+	}
+
+	var m struct {
+		AddComment struct {
+			CommentEdge struct {
+				Node struct {
+					Id githubv4.ID
+				}
+			}
+		} `graphql:"addComment(input: $input)"`
+	}
+	input := githubv4.AddCommentInput{SubjectID: pr, Body: githubv4.String(body)}
+	if err := gh.Mutate(ctx, &m, input, nil); err != nil {
+		return fmt.Errorf("adding pull request comment: %w", err)
+	}
+
+	return nil
+}
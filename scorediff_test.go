@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestScoreDiff runs twice for a pull request that doubles the weight of comments, without writing, and
+// checks that the score diff is commented on the pull request once and then updated
+func TestScoreDiff(t *testing.T) {
+	cfg := testConfig(t)
+	server := scoredFakeGitHub(t, cfg)
+
+	event := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(event, []byte(`{"pull_request": {"node_id": "PR_selftest"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.ReadOnly = true
+	cfg.Scoring.Comments = 2
+	cfg.Reporters = []string{"score-diff"}
+	cfg.EventPath = event
+	server.mutations = make(map[string]float64)
+
+	for i := 0; i < 2; i++ {
+		run(t, cfg, server)
+	}
+
+	expectCount(t, "comments", len(server.comments), 1)
+	expectCount(t, "comment writes", server.commentWrites, 2)
+	expectCount(t, "mutations", len(server.mutations), 0)
+
+	var changed int
+	for _, item := range server.items {
+		if !item.closed && !item.archived && item.comments > 0 {
+			changed++
+		}
+	}
+	if want := fmt.Sprintf("%d of %d items would change", changed, selftestItems-2); !strings.Contains(server.comments[0], want) {
+		t.Fatalf("expected the comment to say %q, got:\n%s", want, server.comments[0])
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// botReferenceComponent is the name of the component counting the references made by bots from commits
+// in the item's own repository
+const botReferenceComponent = "timeline:ReferencedEvent:bot"
+
+// ScoreComponent is a named contribution to an item's score. Components are reported in explain
+// mode, so that it is clear where a score came from.
+type ScoreComponent struct {
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Total returns the sum of the components' values
+func Total(components []ScoreComponent) float64 {
+	var total float64
+	for _, c := range components {
+		total += c.Value
+	}
+	return total
+}
+
+// formatComponents renders components as a compact single line, for example `comments=3 reactions=5`
+func formatComponents(components []ScoreComponent) string {
+	parts := make([]string, 0, len(components))
+	for _, c := range components {
+		part := fmt.Sprintf("%s=%g", c.Name, c.Value)
+		if c.Reason != "" {
+			part += fmt.Sprintf(" (%s)", c.Reason)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Components returns the upvotes for the Issue or Pull Request broken down by where they came from.
+// The values sum to Upvotes, apart from the counts of comments mentioning teams, which the Scorer weighs.
+func (c ContentFragment) Components() []ScoreComponent {
+	return append(c.countComponents(), c.timelineComponents()...)
+}
+
+// countComponents returns the components counted from the totals of comments and reactions
+func (c ContentFragment) countComponents() []ScoreComponent {
+	return []ScoreComponent{
+		{Name: "comments", Value: float64(c.Comments.TotalCount)},
+		{Name: "reactions", Value: float64(c.Reactions.TotalCount)},
+	}
+}
+
+// timelineComponents returns a component for each type of timeline item, which requires every page of
+// timeline items to have been fetched
+func (c ContentFragment) timelineComponents() []ScoreComponent {
+	var components []ScoreComponent
+
+	// references made by bots are counted apart, so that the profile can weigh them on their own
+	timeline := make(map[string]float64)
+	for _, node := range c.TimelineItems.Nodes {
+		name := "timeline:" + string(node.Type)
+		if node.Type == "ReferencedEvent" && node.ReferencedEvent.bot() {
+			name = botReferenceComponent
+		}
+		timeline[name] += float64(node.upvotes())
+	}
+
+	types := make([]string, 0, len(timeline))
+	for t := range timeline {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	for _, t := range types {
+		components = append(components, ScoreComponent{Name: t, Value: timeline[t]})
+	}
+
+	return append(components, mentionComponents(c.TimelineItems.Nodes)...)
+}
+
+// ScoringProfile holds the weights applied to each kind of activity when calculating upvotes. Every
+// score written is tagged with the ID of the profile it was calculated with, so that values calculated
+// with a previous profile can be found once the profile changes.
+type ScoringProfile struct {
+	// Version names the profile. If empty, the profile is identified by a hash of its weights.
+	Version string `mapstructure:"version" json:"version,omitempty"`
+
+	// Comments is the weight of each comment on the Issue or Pull Request
+	Comments float64 `mapstructure:"comments" json:"comments"`
+
+	// Reactions is the weight of each reaction to the Issue or Pull Request
+	Reactions float64 `mapstructure:"reactions" json:"reactions"`
+
+	// Timeline is the weight of the upvotes from each timeline item, such as cross-references
+	Timeline float64 `mapstructure:"timeline" json:"timeline"`
+
+	// BotReferences is the weight of the references made by bots from commits in the item's own
+	// repository. It is the Timeline weight unless set, and zero leaves them out.
+	BotReferences *float64 `mapstructure:"bot_references" json:"bot_references,omitempty"`
+
+	// Rollup is the weight of the scores of the open issues tracked in an epic's task list, which are
+	// added to the epic's own score. Zero turns rollups off.
+	Rollup float64 `mapstructure:"rollup" json:"rollup,omitempty"`
+
+	// Issues and PullRequests override the weights for items of that content type
+	Issues       *ContentScoring `mapstructure:"issues" json:"issues,omitempty"`
+	PullRequests *ContentScoring `mapstructure:"pull_requests" json:"pull_requests,omitempty"`
+}
+
+// ContentScoring overrides the weights of a ScoringProfile for a single content type, such as Pull
+// Requests, where comments are often review chatter rather than demand. Weights that are not set are
+// taken from the profile.
+type ContentScoring struct {
+	Comments  *float64 `mapstructure:"comments" json:"comments,omitempty"`
+	Reactions *float64 `mapstructure:"reactions" json:"reactions,omitempty"`
+	Timeline  *float64 `mapstructure:"timeline" json:"timeline,omitempty"`
+
+	// Disabled skips items of the content type entirely, leaving their fields as they are
+	Disabled bool `mapstructure:"disabled" json:"disabled,omitempty"`
+}
+
+// DefaultScoringProfile weighs every kind of activity equally
+func DefaultScoringProfile() ScoringProfile {
+	return ScoringProfile{Comments: 1, Reactions: 1, Timeline: 1}
+}
+
+// ID returns the identifier that scores calculated with the profile are tagged with
+func (p ScoringProfile) ID() string {
+	if p.Version != "" {
+		return p.Version
+	}
+
+	b, _ := json.Marshal(p)
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// ForType returns the profile used for items whose content is of the given type, Issue or PullRequest
+func (p ScoringProfile) ForType(contentType string) ScoringProfile {
+	override := p.override(contentType)
+	if override == nil {
+		return p
+	}
+
+	if override.Comments != nil {
+		p.Comments = *override.Comments
+	}
+	if override.Reactions != nil {
+		p.Reactions = *override.Reactions
+	}
+	if override.Timeline != nil {
+		p.Timeline = *override.Timeline
+	}
+
+	return p
+}
+
+// Disabled returns true if items whose content is of the given type should not be scored
+func (p ScoringProfile) Disabled(contentType string) bool {
+	override := p.override(contentType)
+	return override != nil && override.Disabled
+}
+
+// override returns the overrides for the content type, or nil if there are none
+func (p ScoringProfile) override(contentType string) *ContentScoring {
+	switch contentType {
+	case "Issue":
+		return p.Issues
+	case "PullRequest":
+		return p.PullRequests
+	}
+	return nil
+}
+
+// Weight returns the weight of the named component
+func (p ScoringProfile) Weight(component string) float64 {
+	switch {
+	case component == "comments":
+		return p.Comments
+	case component == "reactions":
+		return p.Reactions
+	case component == botReferenceComponent && p.BotReferences != nil:
+		return *p.BotReferences
+	case strings.HasPrefix(component, "timeline:"):
+		return p.Timeline
+	}
+	return 1
+}
+
+// Apply weighs each of the components, noting the weight of any that are not weighed equally
+func (p ScoringProfile) Apply(components []ScoreComponent) []ScoreComponent {
+	for i, c := range components {
+		if weight := p.Weight(c.Name); weight != 1 {
+			components[i].Value = c.Value * weight
+			components[i].Reason = fmt.Sprintf("%g x %g", c.Value, weight)
+		}
+	}
+	return components
+}
+
+// Scorer calculates the score of a project item from its content, applying any configured changes
+// on top of the upvotes calculated from GitHub activity
+type Scorer struct {
+	profile     ScoringProfile
+	rollout     Rollout
+	adjustments map[string][]Adjustment
+	teams       map[string]Team
+	issueFields []IssueField
+	keywords    []Keyword
+	inactivity  Inactivity
+	owners      Owners
+
+	// activity, if not nil, keeps when each item last had new engagement
+	activity *ActivityLog
+
+	// dump, if not nil, records what each item is scored from
+	dump *Dump
+}
+
+// NewScorer returns a Scorer for the Config
+func NewScorer(cfg Config) (*Scorer, error) {
+	adjustments, err := LoadAdjustments(cfg.AdjustmentsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make(map[string]Team, len(cfg.Teams))
+	for _, team := range cfg.Teams {
+		teams[team.Team] = team
+	}
+
+	return &Scorer{profile: cfg.Scoring, rollout: cfg.Rollout, adjustments: adjustments, teams: teams, issueFields: cfg.IssueFields, keywords: cfg.Keywords, inactivity: cfg.Inactivity, owners: cfg.Owners}, nil
+}
+
+// Profile returns the scoring profile used by the Scorer
+func (s *Scorer) Profile() ScoringProfile {
+	if s == nil {
+		return DefaultScoringProfile()
+	}
+	return s.profile
+}
+
+// Mentions returns true if teams are configured, so that comments are searched for their mentions
+func (s *Scorer) Mentions() bool {
+	return s != nil && len(s.teams) > 0
+}
+
+// ProfileFor returns the scoring profile used for the item, which differs from Profile for items in a
+// rollout of a new profile
+func (s *Scorer) ProfileFor(itemId interface{}) ScoringProfile {
+	if s == nil {
+		return DefaultScoringProfile()
+	}
+	return s.rollout.ProfileFor(s.profile, itemId)
+}
+
+// profileOf returns a function naming the ID of the profile each item is scored with
+func (s *Scorer) profileOf() func(itemId interface{}) string {
+	if s == nil {
+		return profileOf(DefaultScoringProfile().ID(), nil)
+	}
+	return profileOf(s.profile.ID(), s.rollout.plan())
+}
+
+// Score returns the components of the item's score. Epics include the rolled up scores of their
+// tracked issues, when enabled by the profile. Pins set through the item's project fields are
+// applied last, as a component that brings the total to the pinned value.
+func (s *Scorer) Score(item Item, content ContentFragment) []ScoreComponent {
+	return s.ScoreComponents(item, content, content.Components())
+}
+
+// ScoreComponents is Score with the unweighted components of the content already counted, for example
+// taken from the score cache
+func (s *Scorer) ScoreComponents(item Item, content ContentFragment, components []ScoreComponent) []ScoreComponent {
+	if s == nil {
+		return components
+	}
+
+	// the values of issue fields and the keywords are found in the title and body, which are not
+	// recorded, so they are recorded as components of their own
+	title, body := content.text()
+	components = append(components, formComponents(body, s.issueFields)...)
+	components = append(components, keywordComponents(title, body, s.keywords)...)
+	components = append(components, s.activity.inactiveComponents(item, content, components, time.Now())...)
+
+	// the components are recorded unweighted, and weighing changes them in place
+	unweighted := append([]ScoreComponent(nil), components...)
+
+	profile := s.ProfileFor(item.Id)
+	components = weighMentions(s.teams, profile.ForType(item.Content.Type).Apply(components))
+	components = weighKeywords(s.keywords, boostFields(s.issueFields, components))
+
+	for _, adjustment := range s.adjustments[content.Info().URL] {
+		components = append(components, ScoreComponent{
+			Name:   "adjustment",
+			Value:  adjustment.Points,
+			Reason: adjustment.Reason,
+		})
+	}
+
+	if profile.Rollup != 0 {
+		if rollup, ok := rollupScore(profile, item); ok {
+			components = append(components, rollup)
+		}
+	}
+
+	// the weight of the item's owners applies to everything else, so that each team's board is scaled as a whole
+	if owner, ok := ownerComponent(s.owners, item.Owners, components); ok {
+		components = append(components, owner)
+	}
+
+	scored := pinScore(item, s.inactivity.apply(components))
+	s.dump.Record(item, unweighted, scored)
+	return scored
+}
+
+// Names that the pinning fields are fetched under
+const (
+	scoreOverrideField = "score_override"
+	minScoreField      = "min_score"
+	maxScoreField      = "max_score"
+)
+
+// numberFieldSelection returns the selection used to fetch the value of a project number field
+func numberFieldSelection(field string) string {
+	return fmt.Sprintf("fieldValueByName(name: %q) { ...on ProjectV2ItemFieldNumberValue { number } }", field)
+}
+
+// registerPinFields registers the fragments used to fetch the pinning fields configured in cfg
+func registerPinFields(fragments *Fragments, cfg Config) error {
+	fields := map[string]string{
+		scoreOverrideField: cfg.ScoreOverrideField,
+		minScoreField:      cfg.MinScoreField,
+		maxScoreField:      cfg.MaxScoreField,
+	}
+
+	for name, field := range fields {
+		if field == "" {
+			continue
+		}
+		if err := fragments.Register(name, numberFieldSelection(field)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// numberField returns the value of a number field fetched for the item under name, and whether it is set
+func numberField(item Item, name string) (float64, bool) {
+	raw, ok := item.Extra[name]
+	if !ok {
+		return 0, false
+	}
+
+	var value struct {
+		Number *float64
+	}
+	if err := json.Unmarshal(raw, &value); err != nil || value.Number == nil {
+		return 0, false
+	}
+
+	return *value.Number, true
+}
+
+// pinScore applies the item's score override, or its minimum and maximum score, to the components. A
+// score override replaces the calculated score, while the minimum and maximum clamp it.
+func pinScore(item Item, components []ScoreComponent) []ScoreComponent {
+	total := Total(components)
+
+	if override, ok := numberField(item, scoreOverrideField); ok {
+		return append(components, ScoreComponent{Name: "override", Value: override - total, Reason: "score override field"})
+	}
+
+	if floor, ok := numberField(item, minScoreField); ok && total < floor {
+		return append(components, ScoreComponent{Name: "floor", Value: floor - total, Reason: "min score field"})
+	}
+
+	if ceiling, ok := numberField(item, maxScoreField); ok && total > ceiling {
+		return append(components, ScoreComponent{Name: "ceiling", Value: ceiling - total, Reason: "max score field"})
+	}
+
+	return components
+}
+
+// Adjustment is a documented manual change to an item's score
+type Adjustment struct {
+	URL    string
+	Points float64
+	Reason string
+}
+
+// LoadAdjustments reads a CSV file of manual adjustments, with one `url,points,reason` row per
+// adjustment, keyed by URL. Points may be negative, and a header row is allowed. An empty path
+// returns no adjustments.
+func LoadAdjustments(path string) (map[string][]Adjustment, error) {
+	adjustments := make(map[string][]Adjustment)
+	if path == "" {
+		return adjustments, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening adjustments file: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading adjustments file: %w", err)
+		}
+
+		if len(record) < 2 {
+			return nil, fmt.Errorf("adjustments file line %d: expected url,points,reason", line)
+		}
+
+		points, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if line == 1 {
+				// header row
+				continue
+			}
+			return nil, fmt.Errorf("adjustments file line %d: invalid points %q", line, record[1])
+		}
+
+		adjustment := Adjustment{URL: strings.TrimSpace(record[0]), Points: points}
+		if len(record) > 2 {
+			adjustment.Reason = strings.TrimSpace(record[2])
+		}
+
+		adjustments[adjustment.URL] = append(adjustments[adjustment.URL], adjustment)
+	}
+
+	return adjustments, nil
+}
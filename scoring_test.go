@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestReferences scores an Issue referenced once by a bot's commit in its own repository and once by a
+// commit from another repository whose pull request has two comments and a reaction, and checks that the
+// pull request is scored as a cross-reference, and that the bot's reference can be left out
+func TestReferences(t *testing.T) {
+	pr, err := url.Parse("https://github.com/selftest/other/pull/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bot, person TimelineItem
+	bot.Type = "ReferencedEvent"
+	bot.ReferencedEvent.Actor.Type = "Bot"
+	person.Type = "ReferencedEvent"
+	person.ReferencedEvent.IsCrossRepository = true
+	person.ReferencedEvent.Actor.Type = "User"
+	commit := &ReferencingCommit{}
+	commit.AssociatedPullRequests.Nodes = []LinkedContentFragment{{
+		CommentsAndReactionsFragment: CommentsAndReactionsFragment{Comments: TotalCountFragment{TotalCount: 2}, Reactions: TotalCountFragment{TotalCount: 1}},
+		Url:                          githubv4.URI{URL: pr},
+	}}
+	person.ReferencedEvent.Commit = commit
+
+	var content ContentFragment
+	content.TimelineItems.Nodes = []TimelineItem{bot, person}
+
+	components := content.timelineComponents()
+	if got := fmt.Sprint(components); got != "[{timeline:ReferencedEvent 4 } {timeline:ReferencedEvent:bot 1 }]" {
+		t.Fatalf("expected the references to be counted apart, got %s", got)
+	}
+	if links := content.Links(); len(links) != 1 || links[0].URL != pr.String() {
+		t.Fatalf("expected a link to %s, got %v", pr, links)
+	}
+
+	profile := DefaultScoringProfile()
+	if total := Total(profile.Apply(content.timelineComponents())); total != 5 {
+		t.Fatalf("expected the references to score 5, got %v", total)
+	}
+	zero := 0.0
+	profile.BotReferences = &zero
+	if total := Total(profile.Apply(content.timelineComponents())); total != 4 {
+		t.Fatalf("expected the references to score 4 without the bot's, got %v", total)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// upvotersField is the name that the people who reacted to or commented on an item are fetched under
+const upvotersField = "upvoters"
+
+// upvotersSelection fetches the people who reacted to or commented on the Issue or Pull Request. Only
+// the first 100 of each are fetched, to keep the cost of the query down.
+const upvotersSelection = "content { " +
+	"...on Issue { reactions(first: 100) { nodes { user { login } } } comments(first: 100) { nodes { author { login } } } } " +
+	"...on PullRequest { reactions(first: 100) { nodes { user { login } } } comments(first: 100) { nodes { author { login } } } } }"
+
+// Segment is a named group of people, such as enterprise customers or partners, whose upvotes are
+// counted separately. If Field is set, the count is also written to the project number field of that name.
+type Segment struct {
+	Name    string   `mapstructure:"name"`
+	Members []string `mapstructure:"members"`
+	Field   string   `mapstructure:"field"`
+
+	members map[string]bool
+	fieldId githubv4.ID
+}
+
+// segmentFieldName returns the name that the current value of the i-th segment's field is fetched under
+func segmentFieldName(i int) string {
+	return fmt.Sprintf("segment_%d", i)
+}
+
+// compileSegments checks the segments and indexes their members, case-insensitively
+func compileSegments(segments []Segment) ([]Segment, error) {
+	compiled := make([]Segment, 0, len(segments))
+	names := make(map[string]bool)
+
+	for i, segment := range segments {
+		if segment.Name == "" {
+			return nil, fmt.Errorf("segment %d: missing name", i)
+		}
+		if names[segment.Name] {
+			return nil, fmt.Errorf("segment %q: duplicate name", segment.Name)
+		}
+		names[segment.Name] = true
+
+		segment.members = make(map[string]bool, len(segment.Members))
+		for _, login := range segment.Members {
+			segment.members[strings.ToLower(strings.TrimPrefix(login, "@"))] = true
+		}
+		compiled = append(compiled, segment)
+	}
+
+	return compiled, nil
+}
+
+// registerSegmentFields registers the fragments used to fetch each item's upvoters and the current
+// values of the segments' fields, if any segments are configured
+func registerSegmentFields(fragments *Fragments, segments []Segment) error {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	if err := fragments.Register(upvotersField, upvotersSelection); err != nil {
+		return err
+	}
+
+	for i, segment := range segments {
+		if segment.Field == "" {
+			continue
+		}
+		if err := fragments.Register(segmentFieldName(i), numberFieldSelection(segment.Field)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upvoters returns the unique logins of the people who reacted to or commented on the item, if they
+// were fetched
+func upvoters(extra map[string]json.RawMessage) map[string]bool {
+	raw, ok := extra[upvotersField]
+	if !ok {
+		return nil
+	}
+
+	var content struct {
+		Reactions struct {
+			Nodes []struct {
+				User *struct {
+					Login string
+				}
+			}
+		}
+		Comments struct {
+			Nodes []struct {
+				Author *struct {
+					Login string
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil
+	}
+
+	logins := make(map[string]bool)
+	for _, node := range content.Reactions.Nodes {
+		if node.User != nil {
+			logins[strings.ToLower(node.User.Login)] = true
+		}
+	}
+	for _, node := range content.Comments.Nodes {
+		if node.Author != nil {
+			logins[strings.ToLower(node.Author.Login)] = true
+		}
+	}
+
+	return logins
+}
+
+// countSegments returns the number of the item's unique upvoters in each segment
+func countSegments(segments []Segment, result Result) map[string]int {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	logins := upvoters(result.Extra)
+	counts := make(map[string]int, len(segments))
+	for _, segment := range segments {
+		var count int
+		for login := range logins {
+			if segment.members[login] {
+				count++
+			}
+		}
+		counts[segment.Name] = count
+	}
+
+	return counts
+}
+
+// formatSegments renders the segment counts as a compact single line, for example `enterprise=3 partners=1`
+func formatSegments(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, counts[name]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// resolveSegmentFields looks up the IDs of the segments' project fields by name
+func (e *Engine) resolveSegmentFields(ctx context.Context) error {
+	for i, segment := range e.segments {
+		if segment.Field == "" {
+			continue
+		}
+
+		id, err := projectNumberField(ctx, e.gh, e.cfg.ProjectID, segment.Field)
+		if err != nil {
+			return fmt.Errorf("segment %q: %w", segment.Name, err)
+		}
+		e.segments[i].fieldId = id
+	}
+
+	return nil
+}
+
+// projectNumberField returns the ID of the project's number field with the given name
+func projectNumberField(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID, name string) (githubv4.ID, error) {
+	var query struct {
+		Node struct {
+			ProjectV2 struct {
+				Field struct {
+					ProjectV2Field struct {
+						Id githubv4.ID
+					} `graphql:"...on ProjectV2Field"`
+				} `graphql:"field(name: $name)"`
+			} `graphql:"...on ProjectV2"`
+		} `graphql:"node(id: $nodeId)"`
+	}
+
+	variables := Variables().ID("nodeId", projectId).String("name", name)
+	if err := runQuery(ctx, gh, &query, variables); err != nil {
+		return nil, fmt.Errorf("looking up field %q: %w", name, err)
+	}
+
+	id := query.Node.ProjectV2.Field.ProjectV2Field.Id
+	if id == nil {
+		return nil, fmt.Errorf("project has no number field named %q", name)
+	}
+	return id, nil
+}
+
+// writeSegments writes each segment's count to its project field, if it has one and the count has
+// changed. Nothing is written when the token cannot update the project.
+func (e *Engine) writeSegments(ctx context.Context, result Result) {
+	if e.readOnly || result.Segments == nil {
+		return
+	}
+
+	for i, segment := range e.segments {
+		if segment.fieldId == nil {
+			continue
+		}
+
+		count := float64(result.Segments[segment.Name])
+		current, ok := numberField(Item{Extra: result.Extra}, segmentFieldName(i))
+		if ok && current == count {
+			continue
+		}
+
+		if err := e.limiter.Wait(ctx); err != nil {
+			return
+		}
+
+		record := AuditRecord{Mutation: "set-segment", ItemID: result.ItemID, FieldID: segment.fieldId, OldValue: current, NewValue: count}
+		err := e.audit.Mutation(record, func() error {
+			value := githubv4.ProjectV2FieldValue{Number: githubv4.NewFloat(githubv4.Float(count))}
+			return setField(ctx, e.gh, e.cfg.ProjectID, result.ItemID, segment.fieldId, value)
+		})
+		e.limiter.Spend(1)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to write segment field", "item_id", result.ItemID, "segment", segment.Name, "error", err)
+		}
+	}
+}
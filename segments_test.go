@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// upvotersExtra returns the additional fields of an item whose content was reacted to and commented on by
+// the logins, as fetched. A nil login is a deleted user, or ghost.
+func upvotersExtra(reacted, commented []*string) map[string]json.RawMessage {
+	reactions := []interface{}{}
+	for _, login := range reacted {
+		var user interface{}
+		if login != nil {
+			user = map[string]string{"login": *login}
+		}
+		reactions = append(reactions, map[string]interface{}{"user": user})
+	}
+	comments := []interface{}{}
+	for _, login := range commented {
+		var author interface{}
+		if login != nil {
+			author = map[string]string{"login": *login}
+		}
+		comments = append(comments, map[string]interface{}{"author": author})
+	}
+
+	raw, _ := json.Marshal(map[string]interface{}{
+		"reactions": map[string]interface{}{"nodes": reactions},
+		"comments":  map[string]interface{}{"nodes": comments},
+	})
+	return map[string]json.RawMessage{upvotersField: raw}
+}
+
+// logins returns pointers to the logins, for upvotersExtra
+func logins(names ...string) []*string {
+	pointers := make([]*string, 0, len(names))
+	for i := range names {
+		pointers = append(pointers, &names[i])
+	}
+	return pointers
+}
+
+// TestCountSegments checks that each segment counts the unique people among an item's upvoters who are its
+// members, matching logins case-insensitively and with or without an @
+func TestCountSegments(t *testing.T) {
+	segments, err := compileSegments([]Segment{
+		{Name: "enterprise", Members: []string{"@Alice", "bob", "carol"}},
+		{Name: "partners", Members: []string{"dave"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		extra map[string]json.RawMessage
+		want  string
+	}{
+		{name: "not fetched", want: "enterprise=0 partners=0"},
+		{name: "no upvoters", extra: upvotersExtra(nil, nil), want: "enterprise=0 partners=0"},
+		{name: "reactions", extra: upvotersExtra(logins("alice", "bob", "eve"), nil), want: "enterprise=2 partners=0"},
+		{name: "comments", extra: upvotersExtra(nil, logins("dave", "eve")), want: "enterprise=0 partners=1"},
+		{name: "reacted and commented", extra: upvotersExtra(logins("alice", "dave"), logins("alice", "Alice")), want: "enterprise=1 partners=1"},
+		{name: "case", extra: upvotersExtra(logins("ALICE", "Bob"), logins("CaRoL")), want: "enterprise=3 partners=0"},
+		{name: "deleted users", extra: upvotersExtra([]*string{nil}, append(logins("bob"), nil)), want: "enterprise=1 partners=0"},
+	}
+	for _, test := range tests {
+		if got := formatSegments(countSegments(segments, Result{Extra: test.extra})); got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+
+	if counts := countSegments(nil, Result{Extra: upvotersExtra(logins("alice"), nil)}); counts != nil {
+		t.Errorf("expected no counts without segments, got %v", counts)
+	}
+}
+
+// TestCompileSegments checks that segments without a name, or sharing one, are refused
+func TestCompileSegments(t *testing.T) {
+	tests := []struct {
+		segments []Segment
+		valid    bool
+	}{
+		{segments: []Segment{{Name: "enterprise"}, {Name: "partners"}}, valid: true},
+		{segments: []Segment{{Members: []string{"alice"}}}},
+		{segments: []Segment{{Name: "enterprise"}, {Name: "enterprise"}}},
+	}
+	for i, test := range tests {
+		if _, err := compileSegments(test.segments); (err == nil) != test.valid {
+			t.Errorf("%d: expected valid to be %v, got %v", i, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Shape of the synthetic project used by the selftest command
+const (
+	selftestItems         = 25
+	selftestTimelineItem  = 5
+	selftestTimelineCount = 15
+	selftestClosedItem    = 7
+	selftestArchivedItem  = 9
+	selftestPageSize      = 10
+	selftestTeam          = "selftest/triage"
+	selftestAuthor        = "selftest-author"
+)
+
+// selftestCreatedAt is when every Issue in the synthetic project was created. Its timeline items are
+// commented an hour apart after it.
+var selftestCreatedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// selftestItem is a project item in the synthetic project
+type selftestItem struct {
+	id        string
+	value     *float64
+	comments  int
+	reactions int
+	timeline  int
+	closed    bool
+	archived  bool
+	updatedAt string
+
+	// hidden is whether the Issue is in a repository the token cannot read
+	hidden bool
+}
+
+// upvotes returns the upvotes the item is expected to be given: every comment, reaction, and timeline
+// item counts once
+func (s selftestItem) upvotes() float64 {
+	return float64(s.comments + s.reactions + s.timeline)
+}
+
+// selftestServer is a synthetic GitHub GraphQL API, served in memory as an http.RoundTripper so that no
+// network is used. It answers the queries and mutations made by a run, recording the requests it receives.
+type selftestServer struct {
+	mu        sync.Mutex
+	items     []*selftestItem
+	pages     int
+	timelines int
+	mutations map[string]float64
+	cursors   []string
+
+	// onMutation, if set, is called after each mutation is recorded
+	onMutation func()
+
+	// mentions is whether the request being answered fetches the bodies of comments, every third of
+	// which mentions selftestTeam
+	mentions bool
+
+	// text is whether the request being answered fetches the titles and bodies of Issues. Every fourth
+	// Issue is a question, and every fifth is of critical severity.
+	text bool
+
+	// denied are the errors of the request being answered, for the Issues of hidden items
+	denied []interface{}
+}
+
+// newSelftestServer returns a selftestServer with a synthetic project. Every other item already holds
+// its expected value, two items have enough timeline items to need a second page, and one item each is
+// closed and archived.
+func newSelftestServer() *selftestServer {
+	s := &selftestServer{mutations: make(map[string]float64)}
+
+	for i := 1; i <= selftestItems; i++ {
+		item := &selftestItem{
+			id:        fmt.Sprintf("PVTI_%d", i),
+			comments:  i % 4,
+			reactions: i % 3,
+			closed:    i == selftestClosedItem,
+			archived:  i == selftestArchivedItem,
+			updatedAt: "2024-01-02T00:00:00Z",
+		}
+		if i == selftestTimelineItem || i == selftestTimelineItem+1 {
+			item.timeline = selftestTimelineCount
+		}
+		if i%2 == 0 {
+			value := item.upvotes()
+			item.value = &value
+		}
+		s.items = append(s.items, item)
+	}
+
+	return s
+}
+
+// selftestRequest is a GraphQL request received by the synthetic API
+type selftestRequest struct {
+	Query     string
+	Variables map[string]json.RawMessage
+}
+
+// RoundTrip answers a GraphQL request
+func (s *selftestServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	return s.serve(req, s.answer)
+}
+
+// serve decodes a GraphQL request, answers it with answer while holding the lock, and encodes the data or
+// error it returns as the response
+func (s *selftestServer) serve(req *http.Request, answer func(selftestRequest) (interface{}, error)) (*http.Response, error) {
+	var body selftestRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mentions = string(body.Variables["mentions"]) == "true"
+	s.text = string(body.Variables["text"]) == "true"
+	s.denied = nil
+
+	data, err := answer(body)
+	response := map[string]interface{}{"data": data}
+	if len(s.denied) > 0 {
+		response["errors"] = s.denied
+	}
+	if err != nil {
+		response = map[string]interface{}{"errors": []map[string]string{{"message": err.Error()}}}
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(b)),
+		Request:    req,
+	}, nil
+}
+
+// answer answers the queries and mutations made by a run, recognizing each by the fields it selects
+func (s *selftestServer) answer(r selftestRequest) (interface{}, error) {
+	switch q := r.Query; {
+	case strings.HasPrefix(q, "mutation"):
+		return s.mutate(r.Variables)
+	case strings.Contains(q, "viewerCanUpdate"):
+		return map[string]interface{}{"node": map[string]interface{}{"viewerCanUpdate": true}}, nil
+	case strings.Contains(q, "items(first: 100"):
+		return s.cursorsPage(variable(r.Variables, "cursor")), nil
+	case strings.Contains(q, "items(first:10,"):
+		return s.itemsPage(variable(r.Variables, "cursor")), nil
+	case strings.Contains(q, "nodes(ids:"):
+		return s.values(r.Variables)
+	case strings.Contains(q, "n0: node("):
+		return s.timelineBatch(r.Variables), nil
+	case strings.Contains(q, "...on ProjectV2Item"):
+		return s.timelinePage(variable(r.Variables, "nodeId"), variable(r.Variables, "timelineCursor")), nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", r.Query)
+}
+
+// variable returns a string variable of the request, or an empty string if it is null or missing
+func variable(variables map[string]json.RawMessage, name string) string {
+	var value string
+	json.Unmarshal(variables[name], &value)
+	return value
+}
+
+// offset returns the index of the item after the cursor
+func offset(cursor string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(cursor, "c"))
+	return n
+}
+
+// rateLimit is the rate limit reported by every query
+func (s *selftestServer) rateLimit() map[string]interface{} {
+	return map[string]interface{}{"limit": 5000, "remaining": 4000, "cost": 1, "resetAt": time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}
+}
+
+// itemNode returns the JSON of a project item, with the first page of its timeline items
+func (s *selftestServer) itemNode(i int, item *selftestItem) map[string]interface{} {
+	var value interface{}
+	if item.value != nil {
+		value = map[string]interface{}{"number": *item.value}
+	}
+
+	return map[string]interface{}{
+		"id":               item.id,
+		"isArchived":       item.archived,
+		"type":             "ISSUE",
+		"fieldValueByName": value,
+		"content":          s.content(i, item, ""),
+	}
+}
+
+// content returns the JSON of the Issue connected to a project item, with the page of timeline items
+// after the cursor
+func (s *selftestServer) content(i int, item *selftestItem, after string) map[string]interface{} {
+	start := offset(strings.TrimPrefix(after, "t"))
+	end := min(start+selftestPageSize, item.timeline)
+
+	nodes := []interface{}{}
+	for n := start; n < end; n++ {
+		node := map[string]interface{}{
+			"__typename":        "IssueComment",
+			"reactions":         map[string]int{"totalCount": 0},
+			"createdAt":         selftestCreatedAt.Add(time.Duration(n+1) * time.Hour).Format(time.RFC3339),
+			"authorAssociation": "MEMBER",
+			"author":            map[string]string{"login": "selftest-maintainer"},
+		}
+		if n == 0 {
+			node["authorAssociation"] = "OWNER"
+			node["author"] = map[string]string{"login": selftestAuthor}
+		}
+		if s.mentions {
+			node["body"] = "a comment"
+			if n%3 == 0 {
+				node["body"] = "cc @" + selftestTeam
+			}
+		}
+		nodes = append(nodes, node)
+	}
+
+	content := map[string]interface{}{
+		"__typename": "Issue",
+		"comments":   map[string]int{"totalCount": item.comments},
+		"reactions":  map[string]int{"totalCount": item.reactions},
+		"id":         fmt.Sprintf("I_%d", i),
+		"url":        fmt.Sprintf("https://github.com/selftest/repo/issues/%d", i),
+		"closed":     item.closed,
+		"createdAt":  selftestCreatedAt.Format(time.RFC3339),
+		"author":     map[string]string{"login": selftestAuthor},
+		"updatedAt":  item.updatedAt,
+		"labels":     map[string]interface{}{"nodes": []interface{}{}},
+		"timelineItems": map[string]interface{}{
+			"pageInfo": map[string]interface{}{"endCursor": fmt.Sprintf("t%d", end), "hasNextPage": end < item.timeline},
+			"nodes":    nodes,
+		},
+	}
+	if s.text {
+		content["textTitle"] = fmt.Sprintf("Issue %d", i)
+		if i%4 == 0 {
+			content["textTitle"] = fmt.Sprintf("Question about issue %d", i)
+		}
+		content["body"] = "### Severity\n\nLow"
+		if i%5 == 0 {
+			content["body"] = "### Severity\n\nCritical"
+		}
+	}
+	return content
+}
+
+// itemsPage answers ProjectItemsQuery with the page of items after the cursor
+func (s *selftestServer) itemsPage(cursor string) interface{} {
+	s.pages++
+
+	start := offset(cursor)
+	end := min(start+selftestPageSize, len(s.items))
+
+	edges := []interface{}{}
+	for i := start; i < end; i++ {
+		node := s.itemNode(i+1, s.items[i])
+		if s.items[i].hidden {
+			node["content"] = nil
+			s.denied = append(s.denied, map[string]interface{}{
+				"type":    "FORBIDDEN",
+				"path":    []interface{}{"node", "items", "edges", i - start, "node", "content"},
+				"message": "Resource not accessible by personal access token",
+			})
+		}
+		edges = append(edges, map[string]interface{}{"cursor": fmt.Sprintf("c%d", i+1), "node": node})
+	}
+
+	return map[string]interface{}{
+		"node": map[string]interface{}{
+			"items": map[string]interface{}{
+				"pageInfo": map[string]interface{}{"endCursor": fmt.Sprintf("c%d", end), "hasNextPage": end < len(s.items)},
+				"edges":    edges,
+			},
+		},
+		"rateLimit": s.rateLimit(),
+	}
+}
+
+// cursorsPage answers ProjectItemCursorsQuery with the cursors of the items after the cursor
+func (s *selftestServer) cursorsPage(cursor string) interface{} {
+	start := offset(cursor)
+	end := min(start+100, len(s.items))
+
+	edges := []interface{}{}
+	for i := start; i < end; i++ {
+		edges = append(edges, map[string]interface{}{"cursor": fmt.Sprintf("c%d", i+1)})
+	}
+
+	return map[string]interface{}{
+		"node": map[string]interface{}{
+			"items": map[string]interface{}{
+				"totalCount": len(s.items),
+				"pageInfo":   map[string]interface{}{"endCursor": fmt.Sprintf("c%d", end), "hasNextPage": end < len(s.items)},
+				"edges":      edges,
+			},
+		},
+	}
+}
+
+// timelinePage answers ProjectItemQuery with the item's timeline items after the cursor
+func (s *selftestServer) timelinePage(id, cursor string) interface{} {
+	s.timelines++
+
+	for i, item := range s.items {
+		if item.id == id {
+			node := s.itemNode(i+1, item)
+			node["content"] = s.content(i+1, item, cursor)
+			return map[string]interface{}{"node": node, "rateLimit": s.rateLimit()}
+		}
+	}
+
+	return map[string]interface{}{"node": nil, "rateLimit": s.rateLimit()}
+}
+
+// timelineBatch answers a batched timeline query with the timeline items of each aliased Issue after
+// its cursor
+func (s *selftestServer) timelineBatch(variables map[string]json.RawMessage) interface{} {
+	s.timelines++
+
+	data := map[string]interface{}{"rateLimit": s.rateLimit()}
+	for n := 0; ; n++ {
+		id := variable(variables, fmt.Sprintf("id%d", n))
+		if id == "" {
+			break
+		}
+
+		i, _ := strconv.Atoi(strings.TrimPrefix(id, "I_"))
+		cursor := variable(variables, fmt.Sprintf("c%d", n))
+		s.cursors = append(s.cursors, cursor)
+
+		var node interface{}
+		if i >= 1 && i <= len(s.items) {
+			content := s.content(i, s.items[i-1], cursor)
+			node = map[string]interface{}{"timelineItems": content["timelineItems"]}
+		}
+		data[fmt.Sprintf("n%d", n)] = node
+	}
+
+	return data
+}
+
+// values answers ProjectItemValuesQuery with the current value of each item
+func (s *selftestServer) values(variables map[string]json.RawMessage) (interface{}, error) {
+	var ids []string
+	if err := json.Unmarshal(variables["ids"], &ids); err != nil {
+		return nil, err
+	}
+
+	nodes := []interface{}{}
+	for _, id := range ids {
+		for _, item := range s.items {
+			if item.id != id {
+				continue
+			}
+			var value interface{}
+			if item.value != nil {
+				value = map[string]interface{}{"number": *item.value}
+			}
+			nodes = append(nodes, map[string]interface{}{"id": item.id, "fieldValueByName": value})
+		}
+	}
+
+	return map[string]interface{}{"nodes": nodes, "rateLimit": s.rateLimit()}, nil
+}
+
+// mutate performs updateProjectV2ItemFieldValue, recording the value written
+func (s *selftestServer) mutate(variables map[string]json.RawMessage) (interface{}, error) {
+	var input struct {
+		ItemID string
+		Value  struct {
+			Number *float64
+		}
+	}
+	if err := json.Unmarshal(variables["input"], &input); err != nil {
+		return nil, err
+	}
+	if input.Value.Number == nil {
+		return nil, fmt.Errorf("expected a number value for %s", input.ItemID)
+	}
+
+	for _, item := range s.items {
+		if item.id == input.ItemID {
+			value := *input.Value.Number
+			item.value = &value
+			s.mutations[item.id] = value
+			if s.onMutation != nil {
+				s.onMutation()
+			}
+			return map[string]interface{}{"updateProjectV2ItemFieldValue": map[string]string{"clientMutationId": ""}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not resolve to a node with the global id of %q", input.ItemID)
+}
+
+// selftestCheck is the outcome of a single selftest check
+type selftestCheck struct {
+	name string
+	err  error
+}
+
+// selftestConfig returns the configuration of a run of the synthetic project, which keeps its state
+// in dir and writes its JSON report to report.json in it
+func selftestConfig(dir string) Config {
+	return Config{
+		ProjectID:      githubv4.ID("PVT_selftest"),
+		FieldID:        githubv4.ID("PVTF_selftest"),
+		ConflictPolicy: ConflictSkip,
+		Scoring:        DefaultScoringProfile(),
+		Reporters:      []string{"json=" + filepath.Join(dir, "report.json")},
+		StateDir:       dir,
+		PlanFile:       filepath.Join(dir, "plan.json"),
+		RangeIndex:     -1,
+	}
+}
+
+// Selftest runs the whole pipeline against a synthetic in-memory GraphQL API, without using the
+// network, and checks pagination of items and timelines, resuming from a range's cursor, the mutations
+// written, and the JSON report. The results are written to w, and an error is returned if any check fails.
+func Selftest(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "github-upvotes-selftest-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	server := newSelftestServer()
+	client := &http.Client{Transport: server}
+
+	cfg := selftestConfig(dir)
+	report := filepath.Join(dir, "report.json")
+
+	var checks []selftestCheck
+	check := func(name string, err error) {
+		checks = append(checks, selftestCheck{name: name, err: err})
+	}
+
+	// a full run
+	engine, err := newEngine(cfg, client)
+	if err != nil {
+		return err
+	}
+	check("full run completes", engine.Run(ctx))
+
+	expectedPages := (selftestItems + selftestPageSize - 1) / selftestPageSize
+	check("items are paginated", expect("pages", server.pages, expectedPages))
+	check("timeline items are paginated in batches", expect("additional timeline requests", server.timelines, (selftestTimelineCount-1)/selftestPageSize))
+
+	var written []error
+	var changed int
+	for i, item := range server.items {
+		if item.closed || item.archived || (i+1)%2 == 0 {
+			if _, ok := server.mutations[item.id]; ok {
+				written = append(written, fmt.Errorf("%s was written but should not have been", item.id))
+			}
+			continue
+		}
+		changed++
+		if value, ok := server.mutations[item.id]; !ok || value != item.upvotes() {
+			written = append(written, fmt.Errorf("%s: expected %v to be written, got %v", item.id, item.upvotes(), value))
+		}
+	}
+	if len(written) == 0 {
+		written = append(written, expect("mutations", len(server.mutations), changed))
+	}
+	check("changed values are written, and only those", written[0])
+
+	check("report is written", checkReport(report, selftestItems, changed))
+
+	// resume from the cursor of the second of three ranges
+	assignment, err := Partition(ctx, engine.gh, cfg.ProjectID, 3)
+	if err != nil {
+		return err
+	}
+	if len(assignment.Ranges) < 2 {
+		return fmt.Errorf("expected 3 ranges, got %d", len(assignment.Ranges))
+	}
+
+	server.pages = 0
+	server.mutations = make(map[string]float64)
+	r := assignment.Ranges[1]
+	cfg.Range = &r
+
+	engine, err = newEngine(cfg, client)
+	if err != nil {
+		return err
+	}
+	check("range run completes", engine.Run(ctx))
+	check("range resumes from its cursor", checkRange(report, r))
+	check("written values are not written again", expect("mutations", len(server.mutations), 0))
+
+	// a second cached run reuses the timeline scores of the first
+	cfg.Range = nil
+	cfg.Cache = true
+	cfg.CacheTTL = time.Hour
+	for i := 0; i < 2; i++ {
+		server.timelines = 0
+		engine, err = newEngine(cfg, client)
+		if err != nil {
+			return err
+		}
+		if err := engine.Run(ctx); err != nil {
+			check("cached run completes", err)
+			break
+		}
+	}
+	check("cached timelines are not fetched again", expect("additional timeline requests", server.timelines, 0))
+
+	// an updated item resumes after the timeline items already tallied
+	long := server.items[selftestTimelineItem-1]
+	long.timeline += 3
+	long.updatedAt = "2024-01-03T00:00:00Z"
+	server.timelines, server.cursors = 0, nil
+	server.mutations = make(map[string]float64)
+
+	engine, err = newEngine(cfg, client)
+	if err != nil {
+		return err
+	}
+	check("updated run completes", engine.Run(ctx))
+	check("updated timelines resume after the tallied items", checkResumed(server, long))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT")
+	var failed int
+	for _, c := range checks {
+		if c.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tFAIL: %v\n", c.name, c.err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tok\n", c.name)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d selftest checks failed", failed, len(checks))
+	}
+
+	return nil
+}
+
+// checkResumed checks that only the new timeline items of the updated item were fetched, and that it
+// was written with its whole timeline counted
+func checkResumed(server *selftestServer, item *selftestItem) error {
+	if err := expect("additional timeline requests", server.timelines, 1); err != nil {
+		return err
+	}
+	if want := fmt.Sprintf("t%d", selftestTimelineCount); len(server.cursors) != 1 || server.cursors[0] != want {
+		return fmt.Errorf("expected timeline items after %s to be fetched, got %v", want, server.cursors)
+	}
+	if value, ok := server.mutations[item.id]; !ok || value != item.upvotes() {
+		return fmt.Errorf("%s: expected %v to be written, got %v", item.id, item.upvotes(), value)
+	}
+	return expect("mutations", len(server.mutations), 1)
+}
+
+// expect returns an error if got is not want
+func expect(name string, got, want int) error {
+	if got != want {
+		return fmt.Errorf("expected %d %s, got %d", want, name, got)
+	}
+	return nil
+}
+
+// readReport reads the items of the JSON report
+func readReport(path string) ([]Result, Summary, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Summary{}, err
+	}
+
+	var report struct {
+		Summary Summary
+		Items   []Result
+	}
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, Summary{}, err
+	}
+
+	return report.Items, report.Summary, nil
+}
+
+// checkReport checks that the JSON report lists every item, with the expected number updated
+func checkReport(path string, total, updated int) error {
+	items, summary, err := readReport(path)
+	if err != nil {
+		return err
+	}
+
+	if err := expect("items", len(items), total); err != nil {
+		return err
+	}
+	if err := expect("items in the summary", summary.Total, total); err != nil {
+		return err
+	}
+	return expect("updated items", summary.Statuses[StatusUpdated], updated)
+}
+
+// checkRange checks that the JSON report lists exactly the items in the range
+func checkRange(path string, r Range) error {
+	items, _, err := readReport(path)
+	if err != nil {
+		return err
+	}
+
+	if err := expect("items", len(items), r.Count); err != nil {
+		return err
+	}
+
+	start := offset(r.After)
+	for _, item := range items {
+		n, _ := strconv.Atoi(strings.TrimPrefix(fmt.Sprint(item.ItemID), "PVTI_"))
+		if n <= start || n > start+r.Count {
+			return fmt.Errorf("%v is outside the range", item.ItemID)
+		}
+	}
+
+	return nil
+}
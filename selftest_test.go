@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Iteration and milestone of the synthetic project that top items are assigned to
+const (
+	selftestIteration = "IT_current"
+	selftestMilestone = "selftest"
+)
+
+// fakeGitHub extends the synthetic GitHub API of the selftest command with the queries and mutations of
+// the features beyond its smoke path, recording what they change
+type fakeGitHub struct {
+	*selftestServer
+
+	// comments are the bodies of the comments on the synthetic pull request, and commentWrites the
+	// number of times they were added or updated
+	comments      []string
+	commentWrites int
+
+	// resets is the number of requests that cleared or zeroed items' upvotes together
+	resets int
+
+	// iterations are the iterations items are assigned to, and milestones the milestones of their
+	// Issues, by item
+	iterations map[string]string
+	milestones map[string]string
+
+	// auditFile is the org audit file on the default branch of the audit repository, and auditCommits
+	// the number of commits made to it
+	auditFile    string
+	auditCommits int
+
+	// unarchived are the items unarchived by the archived sweep
+	unarchived []string
+
+	// searches is the number of pages of repository search results requested
+	searches int
+}
+
+// newFakeGitHub returns a fakeGitHub serving the synthetic project of the selftest command
+func newFakeGitHub() *fakeGitHub {
+	return &fakeGitHub{selftestServer: newSelftestServer(), iterations: make(map[string]string), milestones: make(map[string]string)}
+}
+
+// RoundTrip answers a GraphQL request
+func (f *fakeGitHub) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f.serve(req, f.answer)
+}
+
+// answer answers the queries and mutations of the features beyond the smoke path, and passes the rest to
+// the synthetic API
+func (f *fakeGitHub) answer(r selftestRequest) (interface{}, error) {
+	switch q := r.Query; {
+	case strings.Contains(q, "createCommitOnBranch"):
+		return f.commitAudit(r.Variables)
+	case strings.Contains(q, "defaultBranchRef"):
+		return f.auditRepository(), nil
+	case strings.Contains(q, "viewer{login}"):
+		return map[string]interface{}{"viewer": map[string]string{"login": selftestAuthor}}, nil
+	case strings.Contains(q, "addComment") || strings.Contains(q, "updateIssueComment"):
+		return f.comment(r.Variables)
+	case strings.Contains(q, "r0: "):
+		return f.reset(q, r.Variables)
+	case strings.Contains(q, "updateIssue("):
+		return f.assignMilestone(r.Variables)
+	case strings.Contains(q, "unarchiveProjectV2Item"):
+		return f.unarchive(r.Variables)
+	case strings.HasPrefix(q, "mutation") && strings.Contains(string(r.Variables["input"]), `"iterationId"`):
+		return f.assignIteration(r.Variables)
+	case strings.Contains(q, "...on ProjectV2IterationField"):
+		return f.iterationField(), nil
+	case strings.Contains(q, "ProjectV2ItemFieldIterationValue"):
+		return f.assignments(r.Variables)
+	case strings.Contains(q, "milestones(query"):
+		return map[string]interface{}{"repository": map[string]interface{}{"milestones": map[string]interface{}{"nodes": []map[string]string{
+			{"id": "MI_later", "title": selftestMilestone + ".1"},
+			{"id": "MI_selftest", "title": selftestMilestone},
+		}}}}, nil
+	case strings.Contains(q, "fields(first: 100)"):
+		return f.fields(), nil
+	case strings.Contains(q, "comments(last: 100)"):
+		return f.pullRequestComments(), nil
+	case strings.Contains(q, "owner{__typename}"):
+		return f.permissions(), nil
+	case strings.Contains(q, "items(first: 100") && strings.Contains(q, "fieldValueByName"):
+		return f.valuesPage(variable(r.Variables, "cursor")), nil
+	case strings.Contains(q, "search(type: REPOSITORY"):
+		return f.repoSearch(variable(r.Variables, "cursor")), nil
+	case strings.Contains(q, "items(first: 100") && strings.Contains(q, "isArchived"):
+		return f.archivedPage(variable(r.Variables, "cursor")), nil
+	case strings.Contains(q, "timelineItems(since:"):
+		return f.activity(variable(r.Variables, "nodeId"))
+	}
+	return f.selftestServer.answer(r)
+}
+
+// reset clears or zeroes the upvotes of every item in a batched mutation, recording each as a mutation
+func (f *fakeGitHub) reset(query string, variables map[string]json.RawMessage) (interface{}, error) {
+	f.resets++
+	data := make(map[string]interface{})
+	for i := 0; ; i++ {
+		id := variable(variables, fmt.Sprintf("i%d", i))
+		if id == "" {
+			break
+		}
+		var found bool
+		for _, item := range f.items {
+			if item.id != id {
+				continue
+			}
+			found = true
+			item.value = nil
+			if strings.Contains(query, "number: 0") {
+				zero := 0.0
+				item.value = &zero
+			}
+			f.mutations[id] = 0
+		}
+		if !found {
+			return nil, fmt.Errorf("could not resolve to a node with the global id of %q", id)
+		}
+		data[fmt.Sprintf("r%d", i)] = map[string]string{"clientMutationId": ""}
+	}
+	return data, nil
+}
+
+// valuesPage answers ResetItemsQuery with the items after the cursor and their upvotes
+func (f *fakeGitHub) valuesPage(cursor string) interface{} {
+	start := offset(cursor)
+	end := min(start+100, len(f.items))
+
+	nodes := []interface{}{}
+	for i, item := range f.items[start:end] {
+		var value interface{}
+		if item.value != nil {
+			value = map[string]interface{}{"number": *item.value}
+		}
+		nodes = append(nodes, map[string]interface{}{
+			"id":               item.id,
+			"fieldValueByName": value,
+			"status":           map[string]interface{}{"name": selftestStatus(start + i + 1)},
+			"content": map[string]interface{}{
+				"__typename": "Issue",
+				"url":        fmt.Sprintf("https://github.com/selftest/repo/issues/%d", start+i+1),
+				"labels":     map[string]interface{}{"nodes": []interface{}{map[string]interface{}{"name": selftestLabel(start + i + 1)}}},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"node": map[string]interface{}{
+			"items": map[string]interface{}{
+				"pageInfo": map[string]interface{}{"endCursor": fmt.Sprintf("c%d", end), "hasNextPage": end < len(f.items)},
+				"nodes":    nodes,
+			},
+		},
+		"rateLimit": f.rateLimit(),
+	}
+}
+
+// selftestStatus returns the status of the ith synthetic item, which is Done for every fifth item
+func selftestStatus(i int) string {
+	if i%5 == 0 {
+		return "Done"
+	}
+	return "Todo"
+}
+
+// selftestLabel returns the label of the ith synthetic item, which is bug for every third item
+func selftestLabel(i int) string {
+	if i%3 == 0 {
+		return "bug"
+	}
+	return "enhancement"
+}
+
+// archivedPage answers ArchivedItemsQuery with the items after the cursor. Each item was last updated
+// when the project was created, before its Issue, so every open archived item is checked for activity.
+func (f *fakeGitHub) archivedPage(cursor string) interface{} {
+	start := offset(cursor)
+	end := min(start+100, len(f.items))
+
+	nodes := []interface{}{}
+	for i, item := range f.items[start:end] {
+		nodes = append(nodes, map[string]interface{}{
+			"id":         item.id,
+			"isArchived": item.archived,
+			"updatedAt":  selftestCreatedAt.Format(time.RFC3339),
+			"content": map[string]interface{}{
+				"__typename": "Issue",
+				"id":         fmt.Sprintf("I_%d", start+i+1),
+				"url":        fmt.Sprintf("https://github.com/selftest/repo/issues/%d", start+i+1),
+				"closed":     item.closed,
+				"updatedAt":  item.updatedAt,
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"node": map[string]interface{}{
+			"items": map[string]interface{}{
+				"pageInfo": map[string]interface{}{"endCursor": fmt.Sprintf("c%d", end), "hasNextPage": end < len(f.items)},
+				"nodes":    nodes,
+			},
+		},
+		"rateLimit": f.rateLimit(),
+	}
+}
+
+// repoSearch answers RepoSearchQuery with one repository per page, so that following the pages is
+// checked: the repository of the synthetic project's Issues, then another
+func (f *fakeGitHub) repoSearch(cursor string) interface{} {
+	f.searches++
+
+	repos := []string{"selftest/repo", "selftest/other"}
+	i := offset(cursor)
+	return map[string]interface{}{
+		"search": map[string]interface{}{
+			"pageInfo": map[string]interface{}{"endCursor": fmt.Sprintf("c%d", i+1), "hasNextPage": i+1 < len(repos)},
+			"nodes":    []interface{}{map[string]interface{}{"nameWithOwner": repos[i]}},
+		},
+		"rateLimit": f.rateLimit(),
+	}
+}
+
+// activity answers ContentActivityQuery, counting every comment on the Issue as new
+func (f *fakeGitHub) activity(contentId string) (interface{}, error) {
+	i, err := strconv.Atoi(strings.TrimPrefix(contentId, "I_"))
+	if err != nil || i < 1 || i > len(f.items) {
+		return nil, fmt.Errorf("unknown content %q", contentId)
+	}
+	return map[string]interface{}{"node": map[string]interface{}{"__typename": "Issue", "timelineItems": map[string]int{"totalCount": f.items[i-1].comments}}}, nil
+}
+
+// unarchive performs unarchiveProjectV2Item, recording the item unarchived
+func (f *fakeGitHub) unarchive(variables map[string]json.RawMessage) (interface{}, error) {
+	var input struct {
+		ItemID string
+	}
+	if err := json.Unmarshal(variables["input"], &input); err != nil {
+		return nil, err
+	}
+
+	for _, item := range f.items {
+		if item.id == input.ItemID {
+			item.archived = false
+			f.unarchived = append(f.unarchived, item.id)
+			return map[string]interface{}{"unarchiveProjectV2Item": map[string]string{"clientMutationId": ""}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown item %s", input.ItemID)
+}
+
+// iterationField answers a query for the iteration field of the synthetic project, whose current
+// iteration started three days ago
+func (f *fakeGitHub) iterationField() interface{} {
+	start := time.Now().UTC().AddDate(0, 0, -3)
+	iterations := []map[string]interface{}{
+		{"id": "IT_previous", "title": "Iteration 1", "startDate": start.AddDate(0, 0, -14).Format(time.DateOnly), "duration": 14},
+		{"id": selftestIteration, "title": "Iteration 2", "startDate": start.Format(time.DateOnly), "duration": 14},
+	}
+	field := map[string]interface{}{"id": "PVTIF_selftest", "configuration": map[string]interface{}{"iterations": iterations}}
+	return map[string]interface{}{"node": map[string]interface{}{"field": field}}
+}
+
+// assignments answers a query for the iterations and milestones of items, whose Issues are all in the
+// same repository
+func (f *fakeGitHub) assignments(variables map[string]json.RawMessage) (interface{}, error) {
+	var ids []string
+	if err := json.Unmarshal(variables["ids"], &ids); err != nil {
+		return nil, err
+	}
+
+	nodes := []interface{}{}
+	for _, id := range ids {
+		var iteration, milestone interface{}
+		if it, ok := f.iterations[id]; ok {
+			iteration = map[string]string{"iterationId": it}
+		}
+		if mi, ok := f.milestones[id]; ok {
+			milestone = map[string]string{"title": strings.TrimPrefix(mi, "MI_")}
+		}
+		content := map[string]interface{}{"__typename": "Issue", "id": "I_" + id, "repository": map[string]string{"nameWithOwner": "selftest/repo"}, "milestone": milestone}
+		nodes = append(nodes, map[string]interface{}{"id": id, "fieldValueByName": iteration, "content": content})
+	}
+	return map[string]interface{}{"nodes": nodes}, nil
+}
+
+// assignMilestone sets the milestone of an item's Issue
+func (f *fakeGitHub) assignMilestone(variables map[string]json.RawMessage) (interface{}, error) {
+	var input struct {
+		ID          string
+		MilestoneID string
+	}
+	if err := json.Unmarshal(variables["input"], &input); err != nil {
+		return nil, err
+	}
+	f.milestones[strings.TrimPrefix(input.ID, "I_")] = input.MilestoneID
+	return map[string]interface{}{"updateIssue": map[string]string{"clientMutationId": ""}}, nil
+}
+
+// fields answers a query for the fields of the synthetic project: its upvotes field, and a single select
+// field with one option
+func (f *fakeGitHub) fields() interface{} {
+	return map[string]interface{}{"node": map[string]interface{}{"fields": map[string]interface{}{"nodes": []map[string]interface{}{
+		{"id": "PVTF_selftest", "name": upvotesFieldName, "dataType": "NUMBER"},
+		{"id": "PVTSSF_priority", "name": "Priority", "dataType": "SINGLE_SELECT", "options": []map[string]string{{"id": "P1", "name": "P1"}}},
+	}}}}
+}
+
+// pullRequestComments answers a query for the comments on the synthetic pull request, all of which were
+// written by the viewer
+func (f *fakeGitHub) pullRequestComments() interface{} {
+	nodes := make([]map[string]interface{}, 0, len(f.comments))
+	for i, body := range f.comments {
+		nodes = append(nodes, map[string]interface{}{"id": fmt.Sprintf("IC_%d", i), "body": body, "viewerDidAuthor": true})
+	}
+	return map[string]interface{}{"node": map[string]interface{}{"comments": map[string]interface{}{"nodes": nodes}}}
+}
+
+// comment adds or updates a comment on the synthetic pull request
+func (f *fakeGitHub) comment(variables map[string]json.RawMessage) (interface{}, error) {
+	var input struct {
+		ID   string
+		Body string
+	}
+	if err := json.Unmarshal(variables["input"], &input); err != nil {
+		return nil, err
+	}
+
+	f.commentWrites++
+	if input.ID == "" {
+		f.comments = append(f.comments, input.Body)
+		id := map[string]string{"id": fmt.Sprintf("IC_%d", len(f.comments)-1)}
+		return map[string]interface{}{"addComment": map[string]interface{}{"commentEdge": map[string]interface{}{"node": id}}}, nil
+	}
+
+	var i int
+	if _, err := fmt.Sscanf(input.ID, "IC_%d", &i); err != nil || i >= len(f.comments) {
+		return nil, fmt.Errorf("could not resolve to a node with the global id of %q", input.ID)
+	}
+	f.comments[i] = input.Body
+	return map[string]interface{}{"updateIssueComment": map[string]interface{}{"issueComment": map[string]string{"id": input.ID}}}, nil
+}
+
+// permissions answers PermissionsQuery for a token that can update the project, but cannot read the
+// Issues of hidden items
+func (f *fakeGitHub) permissions() interface{} {
+	nodes := []interface{}{}
+	for i, item := range f.items[:min(20, len(f.items))] {
+		var content interface{} = map[string]string{"__typename": "Issue"}
+		if item.hidden {
+			content = nil
+			f.denied = append(f.denied, map[string]interface{}{
+				"type":    "FORBIDDEN",
+				"path":    []interface{}{"node", "items", "nodes", i, "content"},
+				"message": "Resource not accessible by personal access token",
+			})
+		}
+		nodes = append(nodes, map[string]interface{}{"type": "ISSUE", "content": content})
+	}
+
+	return map[string]interface{}{
+		"node": map[string]interface{}{
+			"viewerCanUpdate": true,
+			"owner":           map[string]string{"__typename": "Organization"},
+			"items":           map[string]interface{}{"nodes": nodes},
+		},
+	}
+}
+
+// auditRepository answers OrgAuditFileQuery with the org audit file, on a branch whose head is named
+// after the number of commits made to it
+func (f *fakeGitHub) auditRepository() interface{} {
+	var object interface{}
+	if f.auditFile != "" {
+		object = map[string]string{"text": f.auditFile}
+	}
+
+	return map[string]interface{}{
+		"repository": map[string]interface{}{
+			"defaultBranchRef": map[string]interface{}{
+				"name":   "main",
+				"target": map[string]string{"oid": fmt.Sprintf("head-%d", f.auditCommits)},
+			},
+			"object": object,
+		},
+	}
+}
+
+// commitAudit performs createCommitOnBranch on the org audit file, failing if the branch has moved on
+// from the expected head
+func (f *fakeGitHub) commitAudit(variables map[string]json.RawMessage) (interface{}, error) {
+	var input struct {
+		ExpectedHeadOid string
+		FileChanges     struct {
+			Additions []struct {
+				Path     string
+				Contents []byte
+			}
+		}
+	}
+	if err := json.Unmarshal(variables["input"], &input); err != nil {
+		return nil, err
+	}
+	if want := fmt.Sprintf("head-%d", f.auditCommits); input.ExpectedHeadOid != want {
+		return nil, fmt.Errorf("expected head %s, but the branch is at %s", input.ExpectedHeadOid, want)
+	}
+	if len(input.FileChanges.Additions) != 1 || input.FileChanges.Additions[0].Path != defaultOrgAuditPath {
+		return nil, fmt.Errorf("unexpected file changes")
+	}
+
+	f.auditFile = string(input.FileChanges.Additions[0].Contents)
+	f.auditCommits++
+	oid := map[string]string{"oid": fmt.Sprintf("head-%d", f.auditCommits)}
+	return map[string]interface{}{"createCommitOnBranch": map[string]interface{}{"commit": oid}}, nil
+}
+
+// assignIteration performs updateProjectV2ItemFieldValue with an iteration, recording the item's iteration
+func (f *fakeGitHub) assignIteration(variables map[string]json.RawMessage) (interface{}, error) {
+	var input struct {
+		ItemID string
+		Value  struct {
+			IterationId string
+		}
+	}
+	if err := json.Unmarshal(variables["input"], &input); err != nil {
+		return nil, err
+	}
+	f.iterations[input.ItemID] = input.Value.IterationId
+	return map[string]interface{}{"updateProjectV2ItemFieldValue": map[string]string{"clientMutationId": ""}}, nil
+}
+
+// testConfig returns the configuration of a run of the synthetic project, with its state kept in a
+// directory removed once the test ends
+func testConfig(t *testing.T) Config {
+	return selftestConfig(t.TempDir())
+}
+
+// run runs the engine for the configuration against the transport, failing the test if it fails, and
+// returns the engine
+func run(t *testing.T, cfg Config, transport http.RoundTripper) *Engine {
+	t.Helper()
+	engine, err := newEngine(cfg, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// scoredFakeGitHub returns a fakeGitHub whose project has been run once with the configuration, so that
+// every open item already holds its upvotes
+func scoredFakeGitHub(t *testing.T, cfg Config) *fakeGitHub {
+	t.Helper()
+	f := newFakeGitHub()
+	run(t, cfg, f)
+	return f
+}
+
+// expectCount fails the test if got is not want
+func expectCount(t *testing.T, name string, got, want int) {
+	t.Helper()
+	if err := expect(name, got, want); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// finalValue returns the value of the item once the run is over: the value written, or else the value
+// it already held
+func finalValue(f *fakeGitHub, item *selftestItem) float64 {
+	if value, ok := f.mutations[item.id]; ok {
+		return value
+	}
+	if item.value != nil {
+		return *item.value
+	}
+	return 0
+}
+
+// expectBoosts runs a fresh synthetic project, and checks that each open item's value is its upvotes
+// plus the boost expected for the nth item
+func expectBoosts(t *testing.T, cfg Config, boost func(n int) float64) {
+	t.Helper()
+	server := newFakeGitHub()
+	run(t, cfg, server)
+
+	for i, item := range server.items {
+		if item.closed || item.archived {
+			continue
+		}
+		if want, got := item.upvotes()+boost(i+1), finalValue(server, item); got != want {
+			t.Errorf("%s: expected %v, got %v", item.id, want, got)
+		}
+	}
+}
+
+// TestSelftest runs the smoke path of the selftest command
+func TestSelftest(t *testing.T) {
+	var out strings.Builder
+	if err := Selftest(context.Background(), &out); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// seriesHeader is the header row of every time series file
+var seriesHeader = []string{"run_ts", "run_id", "item_id", "url", "score"}
+
+// SeriesStore keeps the time series of scores as CSV files, one row per item per run, for charting in a
+// spreadsheet without any other infrastructure
+type SeriesStore interface {
+	// AppendSeries adds the rows to the end of the named file, starting it with seriesHeader if it is new
+	AppendSeries(name string, rows [][]string) error
+}
+
+// seriesFile returns the name of the time series file that a run started at t is appended to. A new file
+// is started each month, so that no file grows without bound and old months can be removed.
+func seriesFile(t time.Time) string {
+	return "series-" + t.UTC().Format("2006-01") + ".csv"
+}
+
+// seriesRows encodes the rows as CSV, with the header first if header is true
+func seriesRows(rows [][]string, header bool) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if header {
+		if err := w.Write(seriesHeader); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// AppendSeries implements SeriesStore, appending the rows to the named file in the state directory
+func (s FileStore) AppendSeries(name string, rows [][]string) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening series file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Join(err, f.Close())
+	}
+	b, err := seriesRows(rows, info.Size() == 0)
+	if err != nil {
+		return errors.Join(err, f.Close())
+	}
+
+	_, err = f.Write(b)
+	return errors.Join(err, f.Close())
+}
+
+// AppendSeries implements SeriesStore
+func (s *MemoryStore) AppendSeries(name string, rows [][]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := seriesRows(rows, len(s.documents[name]) == 0)
+	if err != nil {
+		return err
+	}
+	s.documents[name] = append(s.documents[name], b...)
+	return nil
+}
+
+// AppendSeries implements SeriesStore, POSTing the rows to the named file under the base URL, as the
+// history is. The header is sent first if a HEAD request finds no file yet.
+func (s *RemoteStore) AppendSeries(name string, rows [][]string) error {
+	resp, err := s.do(http.MethodHead, name, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	b, err := seriesRows(rows, resp.StatusCode == http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+
+	if resp, err = s.do(http.MethodPost, name, b); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("post state %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// seriesReporter appends the score of every item calculated during the run to the time series
+type seriesReporter struct {
+	collector
+	store SeriesStore
+}
+
+// Finish appends a row for each item
+func (r *seriesReporter) Finish(summary Summary) error {
+	ts := r.run.StartedAt.UTC().Format(time.RFC3339)
+
+	var rows [][]string
+	for _, result := range r.results {
+		if !exportable(result) {
+			continue
+		}
+		rows = append(rows, []string{ts, r.run.ID, fmt.Sprint(result.ItemID), result.Content.URL, strconv.FormatFloat(result.Upvotes, 'f', -1, 64)})
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return r.store.AppendSeries(seriesFile(r.run.StartedAt), rows)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Tenant is a named configuration run by the serve command, for a project of its own. Options that are
+// not set are taken from the base configuration.
+type Tenant struct {
+	// Name identifies the tenant in logs, metrics, and its state directory
+	Name string `mapstructure:"name"`
+
+	// ProjectID and FieldID are the node IDs of the tenant's project and its upvotes field
+	ProjectID string `mapstructure:"project_id"`
+	FieldID   string `mapstructure:"field_id"`
+
+	// Token authenticates the tenant's requests, for example for a project in another organization
+	Token string `mapstructure:"token"`
+
+	// Interval is the time between the tenant's runs
+	Interval time.Duration `mapstructure:"interval"`
+
+	// StateDir is the tenant's state directory. It defaults to a directory named after the tenant in
+	// the base state directory, so that tenants never share state. With a remote store, the tenant's
+	// state is kept under its name at the base state URL.
+	StateDir string `mapstructure:"state_dir"`
+}
+
+// Config returns the configuration the tenant runs with
+func (t Tenant) Config(base Config) Config {
+	cfg := base
+	cfg.Command = "daemon"
+
+	if t.ProjectID != "" {
+		cfg.ProjectID = githubv4.ID(t.ProjectID)
+	}
+	if t.FieldID != "" {
+		cfg.FieldID = githubv4.ID(t.FieldID)
+	}
+	if t.Token != "" {
+		cfg.Token = t.Token
+	}
+	if t.Interval > 0 {
+		cfg.Interval = t.Interval
+	}
+
+	cfg.StateDir = t.StateDir
+	if cfg.StateDir == "" && base.StateDir != "" {
+		cfg.StateDir = filepath.Join(base.StateDir, t.Name)
+	}
+	if base.StateURL != "" {
+		cfg.StateURL = strings.TrimSuffix(base.StateURL, "/") + "/" + url.PathEscape(t.Name)
+	}
+
+	return cfg
+}
+
+// validateTenants returns an error if any tenant is unnamed, shares its name, or lacks a project, field,
+// token, or interval once the base configuration is applied
+func validateTenants(base Config, tenants []Tenant) error {
+	if len(tenants) == 0 {
+		return errors.New("serve requires at least one tenant in the config file")
+	}
+
+	seen := make(map[string]bool)
+	for i, tenant := range tenants {
+		if tenant.Name == "" {
+			return fmt.Errorf("tenant %d has no name", i)
+		}
+		if strings.ContainsAny(tenant.Name, `/\`) {
+			return fmt.Errorf("tenant %q: name must not contain a path separator", tenant.Name)
+		}
+		if seen[tenant.Name] {
+			return fmt.Errorf("tenant %q is configured more than once", tenant.Name)
+		}
+		seen[tenant.Name] = true
+
+		cfg := tenant.Config(base)
+		if fmt.Sprint(cfg.ProjectID) == "" || fmt.Sprint(cfg.FieldID) == "" || cfg.Token == "" {
+			return fmt.Errorf("tenant %q requires a project_id, field_id, and token", tenant.Name)
+		}
+		if cfg.Interval <= 0 {
+			return fmt.Errorf("tenant %q requires a positive interval", tenant.Name)
+		}
+	}
+
+	return nil
+}
+
+// TenantStatus is what the serve command knows about a tenant's runs
+type TenantStatus struct {
+	Name          string         `json:"name"`
+	ProjectID     string         `json:"project_id"`
+	Interval      string         `json:"interval"`
+	Running       bool           `json:"running"`
+	Runs          int            `json:"runs"`
+	Failures      int            `json:"failures"`
+	LastStart     time.Time      `json:"last_start,omitempty"`
+	LastEnd       time.Time      `json:"last_end,omitempty"`
+	LastError     string         `json:"last_error,omitempty"`
+	Statuses      map[Status]int `json:"statuses,omitempty"`
+	RateRemaining int            `json:"rate_limit_remaining,omitempty"`
+
+	// API is the tenant's calls to the GitHub API since the server started, by kind of query
+	API map[string]CallStats `json:"api,omitempty"`
+
+	// Breaker is the state of the tenant's circuit breaker, and BreakerOpens the number of times it opened
+	Breaker      string `json:"breaker,omitempty"`
+	BreakerOpens int    `json:"breaker_opens,omitempty"`
+}
+
+// RunRecorder records the runs of a daemon in its TenantStatus. A nil RunRecorder records nothing.
+type RunRecorder struct {
+	mu     sync.Mutex
+	status TenantStatus
+
+	// client records the daemon's calls to the GitHub API
+	client *ClientMetrics
+}
+
+// started records the start of a run
+func (r *RunRecorder) started(now time.Time) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Running = true
+	r.status.LastStart = now
+}
+
+// finished records the outcome of a run
+func (r *RunRecorder) finished(now time.Time, summary Summary, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Running = false
+	r.status.Runs++
+	r.status.LastEnd = now
+	r.status.LastError = ""
+	if err != nil {
+		r.status.Failures++
+		r.status.LastError = redact(err.Error())
+	}
+	r.status.Statuses = summary.Statuses
+	if summary.RateLimit != nil {
+		r.status.RateRemaining = summary.RateLimit.Remaining
+	}
+}
+
+// Status returns a copy of the recorded status
+func (r *RunRecorder) Status() TenantStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.API = r.client.Snapshot()
+	status.Breaker, status.BreakerOpens = r.client.BreakerState()
+	return status
+}
+
+// Server runs the daemon for each tenant, and serves their status and metrics over HTTP
+type Server struct {
+	base      Config
+	tenants   []Tenant
+	recorders []*RunRecorder
+}
+
+// NewServer returns a Server for the tenants, which are validated against the base configuration
+func NewServer(base Config, tenants []Tenant) (*Server, error) {
+	if err := validateTenants(base, tenants); err != nil {
+		return nil, err
+	}
+
+	s := &Server{base: base, tenants: tenants}
+	for _, tenant := range tenants {
+		cfg := tenant.Config(base)
+		registerSecrets(cfg.Token)
+		s.recorders = append(s.recorders, &RunRecorder{
+			status: TenantStatus{Name: tenant.Name, ProjectID: fmt.Sprint(cfg.ProjectID), Interval: cfg.Interval.String()},
+			client: NewClientMetrics(),
+		})
+	}
+
+	return s, nil
+}
+
+// Handler returns the admin endpoints: /tenants lists the status of every tenant as JSON, and
+// /metrics exposes their metrics in the Prometheus text format, labelled by tenant
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/tenants", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.statuses()); err != nil {
+			slog.ErrorContext(r.Context(), "failed to write tenants", "error", err)
+		}
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := writeMetrics(w, s.statuses()); err != nil {
+			slog.ErrorContext(r.Context(), "failed to write metrics", "error", err)
+		}
+	})
+
+	return mux
+}
+
+// statuses returns the status of every tenant
+func (s *Server) statuses() []TenantStatus {
+	statuses := make([]TenantStatus, 0, len(s.recorders))
+	for _, recorder := range s.recorders {
+		statuses = append(statuses, recorder.Status())
+	}
+	return statuses
+}
+
+// Serve runs every tenant's daemon and the admin endpoints on addr until the context is cancelled. A
+// tenant whose daemon stops with an error is logged, and the others carry on.
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.InfoContext(ctx, "serving tenant status and metrics", "addr", addr, "tenants", len(s.tenants))
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	var wg sync.WaitGroup
+	for i, tenant := range s.tenants {
+		wg.Add(1)
+		go func(tenant Tenant, recorder *RunRecorder) {
+			defer wg.Done()
+
+			// tenants are given no watcher, as serve does not reload its config file: a reload could add
+			// or remove tenants, which the running daemons cannot follow
+			ctx := withClientMetrics(withLogAttrs(ctx, slog.String("tenant", tenant.Name)), recorder.client)
+			if err := Daemon(ctx, tenant.Config(s.base), recorder, nil); err != nil {
+				slog.ErrorContext(ctx, "tenant stopped", "error", err)
+			}
+		}(tenant, s.recorders[i])
+	}
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = errors.Join(err, server.Shutdown(shutdown))
+
+	// the daemons stop once the context is cancelled, which only happens on an interrupt
+	if ctx.Err() != nil {
+		wg.Wait()
+	}
+
+	return err
+}
+
+// writeMetrics writes the tenants' metrics in the Prometheus text format
+func writeMetrics(w io.Writer, tenants []TenantStatus) error {
+	var b strings.Builder
+
+	metric := func(name, kind, help string, value func(TenantStatus) float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, t := range tenants {
+			fmt.Fprintf(&b, "%s{tenant=%q} %v\n", name, t.Name, value(t))
+		}
+	}
+
+	metric("github_upvotes_runs_total", "counter", "Runs finished.", func(t TenantStatus) float64 { return float64(t.Runs) })
+	metric("github_upvotes_run_failures_total", "counter", "Runs that returned an error.", func(t TenantStatus) float64 { return float64(t.Failures) })
+	metric("github_upvotes_running", "gauge", "Whether a run is in progress.", func(t TenantStatus) float64 {
+		if t.Running {
+			return 1
+		}
+		return 0
+	})
+	metric("github_upvotes_last_run_timestamp_seconds", "gauge", "When the last run finished, as a Unix timestamp.", func(t TenantStatus) float64 {
+		if t.LastEnd.IsZero() {
+			return 0
+		}
+		return float64(t.LastEnd.Unix())
+	})
+	metric("github_upvotes_last_run_duration_seconds", "gauge", "How long the last run took.", func(t TenantStatus) float64 {
+		if t.LastEnd.Before(t.LastStart) {
+			return 0
+		}
+		return t.LastEnd.Sub(t.LastStart).Seconds()
+	})
+	metric("github_upvotes_rate_limit_remaining", "gauge", "Rate limit points remaining after the last run.", func(t TenantStatus) float64 { return float64(t.RateRemaining) })
+
+	fmt.Fprintf(&b, "# HELP github_upvotes_items Items in the last run, by status.\n# TYPE github_upvotes_items gauge\n")
+	for _, t := range tenants {
+		statuses := make([]string, 0, len(t.Statuses))
+		for status := range t.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		sort.Strings(statuses)
+
+		for _, status := range statuses {
+			fmt.Fprintf(&b, "github_upvotes_items{tenant=%q,status=%q} %d\n", t.Name, status, t.Statuses[Status(status)])
+		}
+	}
+
+	writeClientMetrics(&b, tenants)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestTenantConfig checks that a tenant's options override the base configuration, that the options it
+// leaves unset are taken from it, and that every tenant is given state of its own
+func TestTenantConfig(t *testing.T) {
+	base := Config{
+		ProjectID: githubv4.ID("PVT_base"),
+		FieldID:   githubv4.ID("PVTF_base"),
+		Token:     "base-token",
+		Interval:  time.Hour,
+		StateDir:  "state",
+		StateURL:  "https://state.example.com/upvotes/",
+		Command:   "serve",
+	}
+
+	tests := []struct {
+		tenant Tenant
+		want   Config
+	}{
+		{
+			tenant: Tenant{Name: "inherited"},
+			want:   Config{ProjectID: base.ProjectID, FieldID: base.FieldID, Token: base.Token, Interval: time.Hour, StateDir: filepath.Join("state", "inherited"), StateURL: "https://state.example.com/upvotes/inherited"},
+		},
+		{
+			tenant: Tenant{Name: "docs team", ProjectID: "PVT_docs", FieldID: "PVTF_docs", Token: "docs-token", Interval: 6 * time.Hour, StateDir: "docs-state"},
+			want:   Config{ProjectID: githubv4.ID("PVT_docs"), FieldID: githubv4.ID("PVTF_docs"), Token: "docs-token", Interval: 6 * time.Hour, StateDir: "docs-state", StateURL: "https://state.example.com/upvotes/docs%20team"},
+		},
+	}
+	for _, test := range tests {
+		got := test.tenant.Config(base)
+		if fmt.Sprint(got.ProjectID) != fmt.Sprint(test.want.ProjectID) || fmt.Sprint(got.FieldID) != fmt.Sprint(test.want.FieldID) ||
+			got.Token != test.want.Token || got.Interval != test.want.Interval || got.StateDir != test.want.StateDir || got.StateURL != test.want.StateURL {
+			t.Errorf("%s: expected %v %v %s %v %s %s, got %v %v %s %v %s %s", test.tenant.Name,
+				test.want.ProjectID, test.want.FieldID, test.want.Token, test.want.Interval, test.want.StateDir, test.want.StateURL,
+				got.ProjectID, got.FieldID, got.Token, got.Interval, got.StateDir, got.StateURL)
+		}
+		if got.Command != "daemon" {
+			t.Errorf("%s: expected to run as a daemon, got %s", test.tenant.Name, got.Command)
+		}
+	}
+
+	if err := validateTenants(base, []Tenant{{Name: "a"}, {Name: "a"}}); err == nil {
+		t.Error("expected tenants sharing a name to be refused")
+	}
+	if err := validateTenants(Config{Interval: time.Hour}, []Tenant{{Name: "a", ProjectID: "PVT_a", FieldID: "PVTF_a"}}); err == nil {
+		t.Error("expected a tenant without a token to be refused")
+	}
+	if err := validateTenants(base, []Tenant{{Name: "a/b"}}); err == nil {
+		t.Error("expected a tenant whose name is a path to be refused")
+	}
+}
+
+// TestTenantState runs one tenant twice and another once, each against a synthetic project of its own,
+// and checks that each tenant's history holds only its own runs
+func TestTenantState(t *testing.T) {
+	base := testConfig(t)
+	base.StateDir = filepath.Join(t.TempDir(), "state")
+	tenants := []Tenant{{Name: "a", ProjectID: "PVT_a"}, {Name: "b", ProjectID: "PVT_b"}}
+
+	for i, tenant := range tenants {
+		cfg := tenant.Config(base)
+		server := newFakeGitHub()
+		for run := 0; run < 2-i; run++ {
+			engine, err := newEngine(cfg, &http.Client{Transport: server})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := engine.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for i, tenant := range tenants {
+		store, err := openStore(tenant.Config(base))
+		if err != nil {
+			t.Fatal(err)
+		}
+		history, err := store.History()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCount(t, "runs of tenant "+tenant.Name, len(history), 2-i)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateStore keeps named JSON documents between runs, such as the score cache or the notifications that
+// have fired
+type StateStore interface {
+	// Load reads the named document into v. A missing document is not an error, and leaves v untouched.
+	Load(name string, v interface{}) error
+
+	// Save replaces the named document with v
+	Save(name string, v interface{}) error
+}
+
+// HistoryStore keeps the score history, one entry per run
+type HistoryStore interface {
+	// AppendHistory adds the entry to the end of the history
+	AppendHistory(entry HistoryEntry) error
+
+	// History returns every entry in the history, oldest first
+	History() ([]HistoryEntry, error)
+}
+
+// Store keeps the state, the score history, and the time series of scores
+type Store interface {
+	StateStore
+	HistoryStore
+	SeriesStore
+}
+
+// openStore returns the store configured in cfg: a remote store if a state URL is set, the state
+// directory if one is set, or nil if no state is kept
+func openStore(cfg Config) (Store, error) {
+	switch {
+	case cfg.StateURL != "":
+		return NewRemoteStore(cfg.StateURL, cfg.StateToken)
+	case cfg.StateDir != "":
+		return FileStore{Dir: cfg.StateDir}, nil
+	}
+	return nil, nil
+}
+
+// FileStore keeps each document as a JSON file, and the history as a JSON Lines file, in a directory
+type FileStore struct {
+	Dir string
+}
+
+// Load implements StateStore
+func (s FileStore) Load(name string, v interface{}) error {
+	return loadState(s.Dir, name, v)
+}
+
+// Save implements StateStore
+func (s FileStore) Save(name string, v interface{}) error {
+	return saveState(s.Dir, name, v)
+}
+
+// loadState reads the named JSON state file from the state directory into v. A missing file is not
+// an error, and leaves v untouched.
+func loadState(dir, name string, v interface{}) error {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading state file %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("parsing state file %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// saveState writes v as the named JSON state file in the state directory. The file is written to a
+// temporary file first and renamed, so an interrupted write never leaves a corrupt state file.
+func saveState(dir, name string, v interface{}) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("writing state file %s: %w", name, err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// MemoryStore keeps the state and history in memory, for runs whose state is not needed afterwards,
+// such as those of the selftest command. Documents are kept as JSON, so that they are loaded as they
+// would be from any other store.
+type MemoryStore struct {
+	mu        sync.Mutex
+	documents map[string][]byte
+	history   []HistoryEntry
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{documents: make(map[string][]byte)}
+}
+
+// Load implements StateStore
+func (s *MemoryStore) Load(name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.documents[name]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("parsing state %s: %w", name, err)
+	}
+	return nil
+}
+
+// Save implements StateStore
+func (s *MemoryStore) Save(name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.documents[name] = b
+	return nil
+}
+
+// AppendHistory implements HistoryStore
+func (s *MemoryStore) AppendHistory(entry HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.history = append(s.history, entry)
+	return nil
+}
+
+// History implements HistoryStore
+func (s *MemoryStore) History() ([]HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]HistoryEntry(nil), s.history...), nil
+}
+
+// dryRunStore reads the state of another store, but discards everything saved or appended to it, so
+// that a dry run leaves the state, the history, and the time series as they were
+type dryRunStore struct {
+	Store
+}
+
+// Save implements StateStore, discarding the document
+func (s dryRunStore) Save(name string, v interface{}) error {
+	return nil
+}
+
+// AppendHistory implements HistoryStore, discarding the entry
+func (s dryRunStore) AppendHistory(entry HistoryEntry) error {
+	return nil
+}
+
+// AppendSeries implements SeriesStore, discarding the rows
+func (s dryRunStore) AppendSeries(name string, rows [][]string) error {
+	return nil
+}
+
+// remoteStoreTimeout bounds each request to a remote store
+const remoteStoreTimeout = 30 * time.Second
+
+// RemoteStore keeps the state and history behind an HTTP endpoint, so that runs on ephemeral machines
+// share their state. Each document is read with GET and replaced with PUT at its name under the base
+// URL, and a missing document is answered with 404 Not Found. Entries are appended to the history by
+// POSTing them as a line of JSON to history.jsonl, and the whole history is read with GET as JSON
+// Lines. Rows are appended to the time series by POSTing them as CSV to its monthly file.
+type RemoteStore struct {
+	base   *url.URL
+	token  string
+	client *http.Client
+}
+
+// NewRemoteStore returns a RemoteStore for the base URL, authenticating with the bearer token if set
+func NewRemoteStore(base, token string) (*RemoteStore, error) {
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + "/")
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid state URL %q", base)
+	}
+	return &RemoteStore{base: u, token: token, client: &http.Client{Timeout: remoteStoreTimeout}}, nil
+}
+
+// do sends a request for the named document, returning the response if its status is 2xx or 404
+func (s *RemoteStore) do(method, name string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.base.JoinPath(name).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	if body != nil {
+		contentType := "application/json"
+		if path.Ext(name) == ".csv" {
+			contentType = "text/csv"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s state %s: %w", strings.ToLower(method), name, err)
+	}
+	if resp.StatusCode != http.StatusNotFound && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s state %s: %s", strings.ToLower(method), name, resp.Status)
+	}
+	return resp, nil
+}
+
+// Load implements StateStore
+func (s *RemoteStore) Load(name string, v interface{}) error {
+	resp, err := s.do(http.MethodGet, name, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("parsing state %s: %w", name, err)
+	}
+	return nil
+}
+
+// Save implements StateStore
+func (s *RemoteStore) Save(name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPut, name, b)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("put state %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// AppendHistory implements HistoryStore
+func (s *RemoteStore) AppendHistory(entry HistoryEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPost, historyFile, append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("post state %s: %s", historyFile, resp.Status)
+	}
+	return nil
+}
+
+// History implements HistoryStore
+func (s *RemoteStore) History() ([]HistoryEntry, error) {
+	resp, err := s.do(http.MethodGet, historyFile, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return readHistory(resp.Body)
+}
+
+// readHistory reads history entries written as JSON Lines
+func readHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("parsing history line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStateStore is a remote state store served in memory as an http.RoundTripper
+type fakeStateStore struct {
+	mu        sync.Mutex
+	documents map[string][]byte
+}
+
+// RoundTrip answers GET, HEAD, and PUT of a document, and POST of lines appended to one
+func (s *fakeStateStore) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		body = b
+	}
+
+	name := path.Base(req.URL.Path)
+	status := http.StatusNoContent
+	var response []byte
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		doc, ok := s.documents[name]
+		if !ok {
+			status = http.StatusNotFound
+			break
+		}
+		status = http.StatusOK
+		if req.Method == http.MethodGet {
+			response = doc
+		}
+	case http.MethodPut:
+		s.documents[name] = body
+	case http.MethodPost:
+		s.documents[name] = append(s.documents[name], body...)
+	default:
+		status = http.StatusMethodNotAllowed
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Body:       io.NopCloser(bytes.NewReader(response)),
+		Request:    req,
+	}, nil
+}
+
+// TestRemoteStore runs a fresh synthetic project twice with the score cache kept in a remote store, and
+// checks that the second run reuses the timelines cached by the first, and that both are in the history
+// and the time series
+func TestRemoteStore(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.Cache = true
+	cfg.CacheTTL = time.Hour
+	cfg.Series = true
+	server := newFakeGitHub()
+
+	remote := &fakeStateStore{documents: make(map[string][]byte)}
+	store, err := NewRemoteStore("https://state.selftest/upvotes", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.client = &http.Client{Transport: remote}
+
+	for i := 0; i < 2; i++ {
+		server.timelines = 0
+		engine, err := newEngineWithStore(cfg, &http.Client{Transport: server}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectCount(t, "additional timeline requests", server.timelines, 0)
+
+	history, err := store.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "history entries", len(history), 2)
+
+	// both runs append a row for each item scored to the same monthly series, after a single header
+	rows, err := csv.NewReader(bytes.NewReader(remote.documents[seriesFile(time.Now())])).ReadAll()
+	if err != nil {
+		t.Fatalf("reading series: %v", err)
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(seriesHeader, ",") {
+		t.Fatalf("expected the series to start with its header, got %q", rows)
+	}
+	expectCount(t, "series rows", len(rows)-1, len(history[0].Items)+len(history[1].Items))
+}
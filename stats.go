@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// statsBuckets are the lower bounds of the histogram buckets of ScoreStats. They are fixed rather than
+// fitted to the scores, so that the histograms of different runs can be compared.
+var statsBuckets = []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// ScoreStats is the distribution of the upvotes across the board in a single run, kept in the history so
+// that overall demand can be followed from run to run
+type ScoreStats struct {
+	Items   int           `json:"items"`
+	Total   float64       `json:"total"`
+	Min     float64       `json:"min"`
+	Median  float64       `json:"median"`
+	P90     float64       `json:"p90"`
+	Max     float64       `json:"max"`
+	Buckets []StatsBucket `json:"buckets"`
+}
+
+// StatsBucket is the number of items whose upvotes are at least Min and below Max. The last bucket has
+// no upper bound, and a Max of zero.
+type StatsBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max,omitempty"`
+	Items int     `json:"items"`
+}
+
+// Label returns the range of upvotes in the bucket, such as `5-9` or `1000+`
+func (b StatsBucket) Label() string {
+	switch {
+	case b.Max == 0:
+		return fmt.Sprintf("%v+", b.Min)
+	case b.Max-b.Min == 1:
+		return fmt.Sprint(b.Min)
+	}
+	return fmt.Sprintf("%v-%v", b.Min, b.Max-1)
+}
+
+// NewScoreStats returns the distribution of the scores, or nil if there are none. Negative scores are
+// counted in the first bucket.
+func NewScoreStats(scores []float64) *ScoreStats {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	sorted := append([]float64(nil), scores...)
+	sort.Float64s(sorted)
+
+	stats := &ScoreStats{
+		Items:   len(sorted),
+		Min:     sorted[0],
+		Median:  median(sorted),
+		P90:     percentile(sorted, 90),
+		Max:     sorted[len(sorted)-1],
+		Buckets: make([]StatsBucket, len(statsBuckets)),
+	}
+	for i, lower := range statsBuckets {
+		stats.Buckets[i].Min = lower
+		if i+1 < len(statsBuckets) {
+			stats.Buckets[i].Max = statsBuckets[i+1]
+		}
+	}
+
+	for _, score := range sorted {
+		stats.Total += score
+		i := sort.Search(len(statsBuckets), func(i int) bool { return statsBuckets[i] > score })
+		stats.Buckets[max(i-1, 0)].Items++
+	}
+
+	return stats
+}
+
+// percentile returns the pth percentile of the sorted values by the nearest rank, or zero if there are
+// none
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
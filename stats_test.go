@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestStats summarizes the distribution of ten scores, and checks its percentiles and histogram
+func TestStats(t *testing.T) {
+	var results []Result
+	for _, upvotes := range []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 100} {
+		results = append(results, Result{ItemID: fmt.Sprintf("PVTI_%v", upvotes), Status: StatusUnchanged, Upvotes: upvotes})
+	}
+	results = append(results, Result{ItemID: "PVTI_failed", Status: StatusFailed, Upvotes: 1000})
+
+	stats := NewSummary(results).Stats
+	if stats == nil {
+		t.Fatal("expected the summary to have a distribution")
+	}
+	expectCount(t, "items in the distribution", stats.Items, 10)
+	if got := fmt.Sprint(stats.Min, stats.Median, stats.P90, stats.Max, stats.Total); got != "0 4.5 8 100 136" {
+		t.Fatalf("expected min, median, p90, max, and total of 0 4.5 8 100 136, got %s", got)
+	}
+
+	var buckets []string
+	for _, bucket := range stats.Buckets {
+		if bucket.Items > 0 {
+			buckets = append(buckets, fmt.Sprintf("%s:%d", bucket.Label(), bucket.Items))
+		}
+	}
+	if want := "0:1 1-4:4 5-9:4 100-249:1"; strings.Join(buckets, " ") != want {
+		t.Fatalf("expected the buckets %s, got %s", want, strings.Join(buckets, " "))
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Status describes the outcome of processing a single project item
+type Status string
+
+const (
+	// StatusUpdated means that the upvotes field was written with a new value
+	StatusUpdated Status = "updated"
+
+	// StatusPlanned means that the new value was added to the mutation plan rather than written, because
+	// the token cannot update the project
+	StatusPlanned Status = "planned"
+
+	// StatusUnchanged means that the calculated upvotes matched the existing field value, so no write was needed
+	StatusUnchanged Status = "unchanged"
+
+	// StatusSkippedClosed means that the issue or pull request connected to the item is closed
+	StatusSkippedClosed Status = "skipped-closed"
+
+	// StatusSkippedArchived means that the project item is archived
+	StatusSkippedArchived Status = "skipped-archived"
+
+	// StatusSkippedDraft means that the project item is a draft issue
+	StatusSkippedDraft Status = "skipped-draft"
+
+	// StatusSkippedUnsupported means that the content connected to the item is neither an Issue nor a
+	// Pull Request, so there is nothing to score it by
+	StatusSkippedUnsupported Status = "skipped-unsupported"
+
+	// StatusSkippedDisabled means that scoring is disabled for the type of content connected to the item
+	StatusSkippedDisabled Status = "skipped-disabled"
+
+	// StatusSkippedExcluded means that the Issue or Pull Request belongs to an excluded repository or is
+	// owned by an excluded team, or that the item does not match the reset command's filter
+	StatusSkippedExcluded Status = "skipped-excluded"
+
+	// StatusSkippedTerminal means that the item's Status on the board is one of the terminal statuses,
+	// such as Done, so it is resolved from the board's perspective even if its Issue is still open
+	StatusSkippedTerminal Status = "skipped-terminal"
+
+	// StatusSkippedUnmodified means that there has been no new activity on the item since it was last calculated
+	StatusSkippedUnmodified Status = "skipped-unmodified"
+
+	// StatusSkippedBelowDelta means that the calculated upvotes differed from the existing field value by
+	// less than the minimum change, so the field was not written
+	StatusSkippedBelowDelta Status = "skipped-below-delta"
+
+	// StatusNoAccess means that the token cannot read the issue or pull request connected to the item,
+	// usually because it belongs to a repository the token has no access to
+	StatusNoAccess Status = "no-access"
+
+	// StatusArchivedActive means that an archived item has seen enough new activity that it should be
+	// reviewed, reported by the archived sweep
+	StatusArchivedActive Status = "archived-active"
+
+	// StatusUnarchived means that an archived item was unarchived by the archived sweep because it has
+	// seen new activity
+	StatusUnarchived Status = "unarchived"
+
+	// StatusConflict means that the field was changed by someone else after the new value was calculated,
+	// so it was not overwritten
+	StatusConflict Status = "conflict"
+
+	// StatusFailed means that an error occurred while calculating or writing the upvotes
+	StatusFailed Status = "failed"
+
+	// StatusTruncated means that the run stopped before the item could be fully processed
+	StatusTruncated Status = "truncated"
+)
+
+// statuses lists every Status in the order they are reported
+var statuses = []Status{
+	StatusUpdated,
+	StatusPlanned,
+	StatusUnchanged,
+	StatusSkippedClosed,
+	StatusSkippedArchived,
+	StatusSkippedDraft,
+	StatusSkippedUnsupported,
+	StatusSkippedDisabled,
+	StatusSkippedExcluded,
+	StatusSkippedTerminal,
+	StatusSkippedUnmodified,
+	StatusSkippedBelowDelta,
+	StatusNoAccess,
+	StatusArchivedActive,
+	StatusUnarchived,
+	StatusConflict,
+	StatusFailed,
+	StatusTruncated,
+}
+
+// Skipped returns true if the status is one of the skipped-* statuses
+func (s Status) Skipped() bool {
+	return strings.HasPrefix(string(s), "skipped-")
+}
+
+// Result is the outcome of processing a single project item. Upvotes is the precise value calculated,
+// and Value the value written to the project, which may be rounded. Components break down where the
+// upvotes came from, and Extra holds the raw JSON of any additional fields fetched through registered Fragments.
+// AgeDays is the age of the Issue or Pull Request in whole days, and PerDay its upvotes per day of age, which
+// tell old and big items from new and hot ones. Segments counts the item's unique upvoters in each
+// configured Segment.
+type Result struct {
+	ItemID     githubv4.ID                `json:"item_id"`
+	Status     Status                     `json:"status"`
+	Previous   float64                    `json:"previous"`
+	Upvotes    float64                    `json:"upvotes"`
+	Value      float64                    `json:"value"`
+	Components []ScoreComponent           `json:"components,omitempty"`
+	Content    ContentInfo                `json:"content"`
+	Extra      map[string]json.RawMessage `json:"extra,omitempty"`
+	AgeDays    int                        `json:"age_days,omitempty"`
+	PerDay     float64                    `json:"upvotes_per_day,omitempty"`
+	Segments   map[string]int             `json:"segments,omitempty"`
+	Err        error                      `json:"-"`
+
+	// FirstResponseHours is how long the Issue or Pull Request waited for a maintainer's response, if it
+	// has had one, and IdleDays the days since it was last commented on
+	FirstResponseHours *float64 `json:"first_response_hours,omitempty"`
+	IdleDays           int      `json:"idle_days,omitempty"`
+
+	// Inactive is true if the item has had no new engagement for the configured number of days
+	Inactive bool `json:"inactive,omitempty"`
+
+	// Archived is true if the item is archived, which is only scored when every item is recalculated
+	Archived bool `json:"archived,omitempty"`
+}
+
+// setAge sets the age of the Issue or Pull Request at now, and its upvotes per day of age. Items less
+// than a day old count as a day old, and the age is left unset if the creation time is not known.
+func (r *Result) setAge(now time.Time) {
+	if r.Content.CreatedAt.IsZero() {
+		return
+	}
+
+	r.AgeDays = int(now.Sub(r.Content.CreatedAt).Hours() / 24)
+	r.PerDay = math.Round(r.Upvotes/math.Max(float64(r.AgeDays), 1)*100) / 100
+}
+
+// Name returns a human-readable name for the item, using its Issue or Pull Request when known and the
+// item ID otherwise
+func (r Result) Name() string {
+	if name := r.Content.Name(); name != "" {
+		return name
+	}
+	return fmt.Sprint(r.ItemID)
+}
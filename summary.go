@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Summary is the count of project items by status for a single run, along with the items whose field
+// was changed by someone else during the run. Delta is the net change of the values updated or planned,
+// and Movers the items whose values changed the most. Stale is the number of items whose values were
+// calculated with a previous scoring profile, Stats the distribution of the upvotes of the items scored,
+// RateLimit the rate limit used by the run, and Quota the points used by each kind of query.
+type Summary struct {
+	Total     int               `json:"total"`
+	Statuses  map[Status]int    `json:"statuses"`
+	Conflicts []Conflict        `json:"conflicts,omitempty"`
+	Delta     float64           `json:"delta"`
+	Movers    []Mover           `json:"movers,omitempty"`
+	Stale     int               `json:"stale,omitempty"`
+	Inactive  int               `json:"inactive,omitempty"`
+	Stats     *ScoreStats       `json:"stats,omitempty"`
+	RateLimit *RateLimitSummary `json:"rate_limit,omitempty"`
+	Quota     []QuotaUsage      `json:"quota,omitempty"`
+}
+
+// Conflict is an item whose field was changed by someone else during the run
+type Conflict struct {
+	ItemID   githubv4.ID `json:"item_id"`
+	Expected float64     `json:"expected"`
+	Actual   float64     `json:"actual"`
+}
+
+// NewSummary counts the given results by status
+func NewSummary(results []Result) Summary {
+	acc := NewAccumulator(summaryMovers)
+	for _, result := range results {
+		acc.Add(result)
+	}
+	return acc.Summary()
+}
+
+// WriteTable writes the summary as a plain text table
+func (s Summary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "STATUS\tITEMS")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%s\t%d\n", status, s.Statuses[status])
+	}
+	fmt.Fprintf(tw, "total\t%d\n", s.Total)
+	if s.Stale > 0 {
+		fmt.Fprintf(tw, "stale\t%d\n", s.Stale)
+	}
+	if s.Inactive > 0 {
+		fmt.Fprintf(tw, "inactive\t%d\n", s.Inactive)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(s.Movers) > 0 {
+		fmt.Fprintf(w, "\nnet change %s, largest changes:\n", formatChange(s.Delta))
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, m := range s.Movers {
+			fmt.Fprintf(tw, "%s\t%v\t%v\t%s\n", m.Name, m.Previous, m.Value, formatChange(m.Change()))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if st := s.Stats; st != nil {
+		fmt.Fprintf(w, "\nupvotes of %d items: min %v, median %v, p90 %v, max %v, %v in all\n", st.Items, st.Min, st.Median, st.P90, st.Max, st.Total)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, bucket := range st.Buckets {
+			fmt.Fprintf(tw, "%s\t%d\n", bucket.Label(), bucket.Items)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if r := s.RateLimit; r != nil {
+		if _, err := fmt.Fprintf(w, "\nrate limit: %d points used, %d remaining, %d unused above the reserve of %d\n", r.Used, r.Remaining, r.Unused, r.Reserve); err != nil {
+			return err
+		}
+		if r.RecommendedInterval != "" {
+			if _, err := fmt.Fprintf(w, "forecast: %v points per item, next run at %s %s, recommended interval %s\n", r.CostPerItem, r.NextRunAt.Format(time.RFC3339), fitsText(r.NextRunFits), r.RecommendedInterval); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.Quota) > 0 {
+		fmt.Fprintln(w, "\npoints by query:")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "QUERY\tCALLS\tPOINTS\tSIGNAL")
+		for _, q := range s.Quota {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", q.Query, q.Calls, q.Points, q.Signal)
+		}
+		return tw.Flush()
+	}
+
+	return nil
+}
+
+// Markdown returns the summary as a Markdown table, suitable for a GitHub Actions job summary, in the
+// language of the locale
+func (s Summary) Markdown(l *Locale) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### %s\n\n", l.T("summary.title"))
+	b.WriteString(l.row("summary.status", "summary.items"))
+	b.WriteString("| --- | ---: |\n")
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "| %s | %d |\n", status, s.Statuses[status])
+	}
+	fmt.Fprintf(&b, "| **%s** | **%d** |\n", l.T("summary.total"), s.Total)
+
+	if len(s.Conflicts) > 0 {
+		fmt.Fprintf(&b, "\n#### %s\n\n%s\n\n", l.T("summary.conflicts"), l.T("summary.conflicts_by"))
+		b.WriteString(l.row("summary.item", "summary.expected", "summary.found"))
+		b.WriteString("| --- | ---: | ---: |\n")
+		for _, c := range s.Conflicts {
+			fmt.Fprintf(&b, "| %v | %v | %v |\n", c.ItemID, c.Expected, c.Actual)
+		}
+	}
+
+	if len(s.Movers) > 0 {
+		fmt.Fprintf(&b, "\n#### %s\n\n%s\n\n", l.T("summary.movers"), l.T("summary.movers_by", formatChange(s.Delta)))
+		b.WriteString(l.row("summary.item", "summary.previous", "summary.value", "summary.change"))
+		b.WriteString("| --- | ---: | ---: | ---: |\n")
+		for _, m := range s.Movers {
+			fmt.Fprintf(&b, "| %s | %v | %v | %s |\n", m.Name, m.Previous, m.Value, formatChange(m.Change()))
+		}
+	}
+
+	if st := s.Stats; st != nil {
+		fmt.Fprintf(&b, "\n#### %s\n\n%s\n\n", l.T("summary.stats"), l.T("summary.stats_by", st.Items, st.Min, st.Median, st.P90, st.Max, st.Total))
+		b.WriteString(l.row("report.upvotes", "summary.items"))
+		b.WriteString("| --- | ---: |\n")
+		for _, bucket := range st.Buckets {
+			fmt.Fprintf(&b, "| %s | %d |\n", bucket.Label(), bucket.Items)
+		}
+	}
+
+	if r := s.RateLimit; r != nil {
+		fmt.Fprintf(&b, "\n%s\n", l.T("summary.rate_limit", r.Used, r.Remaining, r.Unused, r.Reserve))
+		if r.RecommendedInterval != "" {
+			fits := l.T("summary.not_fits")
+			if r.NextRunFits {
+				fits = l.T("summary.fits")
+			}
+			fmt.Fprintf(&b, "%s\n", l.T("summary.forecast", r.CostPerItem, r.NextRunAt.Format(time.RFC3339), fits, r.RecommendedInterval))
+		}
+	}
+
+	if len(s.Quota) > 0 {
+		fmt.Fprintf(&b, "\n#### %s\n\n", l.T("summary.quota"))
+		b.WriteString(l.row("summary.query", "summary.calls", "summary.points", "summary.signal"))
+		b.WriteString("| --- | ---: | ---: | --- |\n")
+		for _, q := range s.Quota {
+			fmt.Fprintf(&b, "| %s | %d | %d | %s |\n", q.Query, q.Calls, q.Points, q.Signal)
+		}
+	}
+
+	if s.Stale > 0 {
+		fmt.Fprintf(&b, "\n%s\n", l.T("summary.stale", s.Stale))
+	}
+
+	if s.Inactive > 0 {
+		fmt.Fprintf(&b, "\n%s\n", l.T("summary.inactive", s.Inactive))
+	}
+
+	if n := s.Statuses[StatusNoAccess]; n > 0 {
+		fmt.Fprintf(&b, "\n%s\n", l.T("summary.no_access", n))
+	}
+
+	return b.String()
+}
+
+// fitsText describes whether the next run is expected to fit in the rate limit
+func fitsText(fits bool) string {
+	if fits {
+		return "is expected to fit in the rate limit"
+	}
+	return "is not expected to fit in the rate limit"
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// Sweep checks the archived items in the project for activity since they were archived. Items whose
+// Issue or Pull Request has at least the configured threshold of new timeline items are reported
+// for review, or unarchived if configured to do so, notifying the board's owners through the configured
+// notifiers. Closed items are ignored.
+//
+// Only items whose content was updated after the item itself are checked in detail, so a sweep costs
+// little more than listing the project's items.
+func (e *Engine) Sweep(ctx context.Context) error {
+	run := RunInfo{
+		ID:        runID(e.cfg),
+		ProjectID: e.cfg.ProjectID,
+		FieldID:   e.cfg.FieldID,
+		StartedAt: time.Now(),
+	}
+
+	ctx = withLogAttrs(ctx, slog.String("run_id", run.ID))
+	slog.InfoContext(ctx, "sweeping archived items", "threshold", e.cfg.SweepThreshold, "unarchive", e.cfg.Unarchive)
+
+	// a dry run makes no mutations, so it keeps no audit log of them
+	if e.cfg.StateDir != "" && !e.cfg.DryRun {
+		audit, err := OpenAuditLog(e.cfg.StateDir, run.ID)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		e.audit = audit
+	}
+
+	if err := e.reporters.Start(run); err != nil {
+		return fmt.Errorf("starting reporters: %w", err)
+	}
+
+	var query ArchivedItemsQuery
+	variables := Variables().ID("nodeId", e.cfg.ProjectID).Cursor("cursor", "")
+
+	var all []Result
+	for {
+		if err := runQuery(withQueryKind(ctx, "archived_items"), e.gh, &query, variables); err != nil {
+			return err
+		}
+
+		for _, item := range query.Items.Nodes {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			content := item.GetContent()
+			if !item.IsArchived || content.Id == nil || content.Closed || !content.UpdatedAt.After(item.UpdatedAt.Time) {
+				continue
+			}
+
+			result := e.sweepItem(ctx, item)
+			if result.Status == "" {
+				continue
+			}
+
+			logResult(ctx, result, e.cfg.Explain)
+			if err := e.reporters.ItemResult(result); err != nil {
+				slog.ErrorContext(ctx, "failed to report project item", "item_id", result.ItemID, "error", err)
+			}
+			all = append(all, result)
+		}
+
+		if !query.Items.HasNextPage {
+			break
+		}
+		variables.Cursor("cursor", query.Items.EndCursor)
+	}
+
+	summary := NewSummary(all)
+	if err := e.reporters.Finish(summary); err != nil {
+		slog.ErrorContext(ctx, "failed to write reports", "error", err)
+	}
+
+	if failed := summary.Statuses[StatusFailed]; failed > 0 {
+		return fmt.Errorf("failed to sweep %d archived items", failed)
+	}
+
+	return nil
+}
+
+// sweepItem counts the activity on an archived item since it was archived, and unarchives it if it
+// meets the threshold and unarchiving is enabled. The returned result has an empty status if the
+// item does not meet the threshold.
+func (e *Engine) sweepItem(ctx context.Context, item ArchivedItemFragment) Result {
+	result := Result{ItemID: item.Id, Content: ContentInfo{ID: item.GetContent().Id}}
+	if u := item.GetContent().Url; u.URL != nil {
+		result.Content.URL = u.String()
+	}
+
+	activity, err := activitySince(ctx, e.gh, item.GetContent().Id, item.UpdatedAt)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+
+	// the activity is reported in place of upvotes, since upvotes are not calculated for archived items
+	result.Upvotes = float64(activity)
+	if activity < e.cfg.SweepThreshold {
+		return result
+	}
+
+	if !e.cfg.Unarchive || e.cfg.DryRun {
+		result.Status = StatusArchivedActive
+		return result
+	}
+
+	record := AuditRecord{Mutation: "unarchive", ItemID: item.Id}
+	err = e.audit.Mutation(record, func() error {
+		return unarchiveItem(ctx, e.gh, e.cfg.ProjectID, item.Id)
+	})
+	if err != nil {
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+
+	result.Status = StatusUnarchived
+	e.notifyUnarchived(ctx, result, activity)
+	return result
+}
+
+// notifyUnarchived tells the board's owners that an item was unarchived, and how much activity brought
+// it back, so that it can be triaged again
+func (e *Engine) notifyUnarchived(ctx context.Context, result Result, activity int) {
+	if len(e.notifiers) == 0 {
+		return
+	}
+
+	message := e.cfg.Locale.T("sweep.unarchived", result.Name(), activity)
+	if err := e.notifiers.Notify(ctx, message); err != nil {
+		slog.ErrorContext(ctx, "failed to send unarchive notification", "item_id", result.ItemID, "error", err)
+	}
+}
+
+// activitySince returns the number of timeline items added to an Issue or Pull Request since a point in time
+func activitySince(ctx context.Context, gh *githubv4.Client, contentId githubv4.ID, since githubv4.DateTime) (int, error) {
+	var query ContentActivityQuery
+	variables := Variables().ID("nodeId", contentId).DateTime("since", since)
+	if err := runQuery(ctx, gh, &query, variables); err != nil {
+		return 0, err
+	}
+
+	// both fragments are decoded from the same fields, so only the one of the content's type is counted
+	if query.Node.Typename == "PullRequest" {
+		return query.Node.PullRequest.TimelineItems.TotalCount, nil
+	}
+	return query.Node.Issue.TimelineItems.TotalCount, nil
+}
+
+// unarchiveItem restores an archived project item
+func unarchiveItem(ctx context.Context, gh *githubv4.Client, projectId, itemId githubv4.ID) error {
+	var mutation struct {
+		UnarchiveProjectV2Item struct {
+			ClientMutationId string
+		} `graphql:"unarchiveProjectV2Item(input: $input)"`
+	}
+
+	input := githubv4.UnarchiveProjectV2ItemInput{
+		ProjectID: projectId,
+		ItemID:    itemId,
+	}
+
+	return gh.Mutate(ctx, &mutation, input, nil)
+}
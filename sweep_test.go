@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// recordingNotifier records the messages it is sent
+type recordingNotifier struct {
+	messages []string
+}
+
+// Notify records the message
+func (n *recordingNotifier) Notify(ctx context.Context, message string) error {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+// TestUnarchive sweeps the synthetic project with unarchiving enabled, and checks that the archived
+// item, which has new comments, is unarchived and its unarchiving notified
+func TestUnarchive(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.Unarchive = true
+	cfg.SweepThreshold = 1
+	server := newFakeGitHub()
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &recordingNotifier{}
+	engine.notifiers = Notifiers{notifier}
+	if err := engine.Sweep(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := fmt.Sprintf("PVTI_%d", selftestArchivedItem); len(server.unarchived) != 1 || server.unarchived[0] != want {
+		t.Fatalf("expected %s to be unarchived, got %v", want, server.unarchived)
+	}
+	want := cfg.Locale.T("sweep.unarchived", fmt.Sprintf("https://github.com/selftest/repo/issues/%d", selftestArchivedItem), selftestArchivedItem%4)
+	if len(notifier.messages) != 1 || notifier.messages[0] != want {
+		t.Fatalf("expected the notification %q, got %q", want, notifier.messages)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SyncSummary counts the items of the roll-up project by what the sync did with them
+type SyncSummary struct {
+	Updated   int
+	Unchanged int
+	Unmatched int
+	Failed    int
+}
+
+// syncRollup writes the upvotes calculated for the run's items to the items for the same Issues and Pull
+// Requests in the roll-up project, so that a program board spanning several projects shows the same
+// scores without calculating them again. Items are matched by the URL of their content; items of the
+// roll-up project that are not on this board are left alone. Nothing is written when the run is read-only.
+func (e *Engine) syncRollup(ctx context.Context, results []Result) {
+	if e.cfg.SyncProjectID == nil || len(results) == 0 {
+		return
+	}
+	if e.readOnly {
+		slog.InfoContext(ctx, "read-only run, not syncing scores to the roll-up project", "project_id", e.cfg.SyncProjectID)
+		return
+	}
+
+	summary, err := e.sync(ctx, results)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to sync scores to the roll-up project", "project_id", e.cfg.SyncProjectID, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "synced scores to the roll-up project", "project_id", e.cfg.SyncProjectID, "updated", summary.Updated, "unchanged", summary.Unchanged, "unmatched", summary.Unmatched, "failed", summary.Failed)
+}
+
+// sync lists the items of the roll-up project, and writes the value of the matching result to each
+func (e *Engine) sync(ctx context.Context, results []Result) (SyncSummary, error) {
+	var summary SyncSummary
+
+	canUpdate, err := viewerCanUpdate(ctx, e.gh, e.cfg.SyncProjectID)
+	if err != nil {
+		return summary, fmt.Errorf("checking roll-up project permissions: %w", err)
+	}
+	if !canUpdate {
+		return summary, fmt.Errorf("the token cannot update the roll-up project")
+	}
+
+	values := make(map[string]float64, len(results))
+	for _, result := range results {
+		if exportable(result) && result.Content.URL != "" {
+			values[result.Content.URL] = result.Value
+		}
+	}
+
+	writer := &mutationWriter{gh: e.gh, projectId: e.cfg.SyncProjectID, fieldId: e.cfg.SyncFieldID, audit: e.audit, policy: e.cfg.ConflictPolicy, limiter: e.limiter}
+
+	// the roll-up project's items are listed as the reset command lists them, with their upvotes
+	var query ResetItemsQuery
+	variables := Variables().ID("nodeId", e.cfg.SyncProjectID).Cursor("cursor", "")
+	for {
+		if err := e.limiter.Wait(ctx); err != nil {
+			return summary, err
+		}
+		if err := runQuery(withQueryKind(ctx, "sync"), e.gh, &query, variables); err != nil {
+			return summary, fmt.Errorf("listing roll-up project items: %w", err)
+		}
+		e.limiter.Observe(query.RateLimit)
+
+		for _, item := range query.Node.ProjectV2.Items.Nodes {
+			url := item.content().Url
+			if url.URL == nil {
+				summary.Unmatched++
+				continue
+			}
+			value, ok := values[url.String()]
+			if !ok {
+				summary.Unmatched++
+				continue
+			}
+
+			previous, set := item.value()
+			if set && previous == value {
+				summary.Unchanged++
+				continue
+			}
+
+			if _, err := writer.WriteUpvotes(ctx, item.Id, previous, value); err != nil {
+				slog.ErrorContext(ctx, "failed to sync roll-up project item", "item_id", item.Id, "url", url.String(), "error", err)
+				summary.Failed++
+				continue
+			}
+			summary.Updated++
+		}
+
+		if !query.Node.ProjectV2.Items.HasNextPage {
+			return summary, nil
+		}
+		variables.Cursor("cursor", query.Node.ProjectV2.Items.EndCursor)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// TestSync syncs three results to the synthetic project as if it were the roll-up project: one with a
+// new value, one with the value its item already holds, and one that failed, and checks that only the
+// first is written and the items of the other Issues are left alone
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	server := newFakeGitHub()
+	cfg.SyncProjectID, cfg.SyncFieldID = githubv4.ID("PVT_rollup"), githubv4.ID("PVTF_rollup")
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := func(i int) string { return fmt.Sprintf("https://github.com/selftest/repo/issues/%d", i) }
+	results := []Result{
+		{Status: StatusUpdated, Value: 100, Content: ContentInfo{URL: url(1)}},
+		{Status: StatusUnchanged, Value: *server.items[1].value, Content: ContentInfo{URL: url(2)}},
+		{Status: StatusFailed, Content: ContentInfo{URL: url(3)}},
+	}
+	summary, err := engine.sync(ctx, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (SyncSummary{Updated: 1, Unchanged: 1, Unmatched: selftestItems - 2}); summary != want {
+		t.Fatalf("expected %+v, got %+v", want, summary)
+	}
+	if value, ok := server.mutations["PVTI_1"]; len(server.mutations) != 1 || !ok || value != 100 {
+		t.Fatalf("expected only PVTI_1 to be written, got %v", server.mutations)
+	}
+}
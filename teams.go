@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// mentionComponent prefixes the name of the component counting the comments that mention a team
+const mentionComponent = "mention:"
+
+// teamMentionPattern matches an @-mention of a team, such as @acme/frontend, capturing the team
+var teamMentionPattern = regexp.MustCompile(`(?:^|[^\w@/.-])@([A-Za-z0-9][A-Za-z0-9-]*/[A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// Team is a team whose mentions in the comments on an item are weighed, or trigger a notification to
+// the team when the item's score crosses a threshold
+type Team struct {
+	// Team is the team's organization and slug, such as acme/frontend
+	Team string `mapstructure:"team"`
+
+	// Weight is added to the item's upvotes for each comment that mentions the team
+	Weight float64 `mapstructure:"weight"`
+
+	// Threshold, if set, notifies the team once the score of an item that mentions it reaches it
+	Threshold float64 `mapstructure:"threshold"`
+
+	// Notifiers are where the team is notified, in the same form as --notifier. The configured
+	// notifiers are used if there are none.
+	Notifiers []string `mapstructure:"notifiers"`
+}
+
+// normalizeTeams strips any leading @ from the teams' names and lowercases them, as mentions are
+// matched regardless of case, and returns an error if any is not of the form org/team, is listed more
+// than once, or has a negative weight
+func normalizeTeams(teams []Team) ([]Team, error) {
+	seen := make(map[string]bool)
+	normalized := make([]Team, 0, len(teams))
+	for i, team := range teams {
+		team.Team = strings.ToLower(strings.TrimPrefix(team.Team, "@"))
+		if org, slug, ok := strings.Cut(team.Team, "/"); !ok || org == "" || slug == "" {
+			return nil, fmt.Errorf("teams[%d]: team must be of the form org/team, got %q", i, team.Team)
+		}
+		if seen[team.Team] {
+			return nil, fmt.Errorf("team %s is configured more than once", team.Team)
+		}
+		seen[team.Team] = true
+		if team.Weight < 0 {
+			return nil, fmt.Errorf("team %s: weight must not be negative, got %v", team.Team, team.Weight)
+		}
+		normalized = append(normalized, team)
+	}
+	return normalized, nil
+}
+
+// teamMentions returns the teams mentioned in a comment body, lowercased, each once
+func teamMentions(body string) []string {
+	var teams []string
+	for _, match := range teamMentionPattern.FindAllStringSubmatch(body, -1) {
+		team := strings.ToLower(match[1])
+		if !contains(teams, team) {
+			teams = append(teams, team)
+		}
+	}
+	return teams
+}
+
+// mentionComponents returns a component for each team mentioned in the comments among the timeline
+// items, counting the comments that mention it. Comment bodies are only fetched when teams are
+// configured, so there are none otherwise.
+func mentionComponents(nodes []TimelineItem) []ScoreComponent {
+	counts := make(map[string]float64)
+	for _, node := range nodes {
+		if node.Type != "IssueComment" {
+			continue
+		}
+		for _, team := range teamMentions(node.IssueComment.Body) {
+			counts[team]++
+		}
+	}
+
+	teams := make([]string, 0, len(counts))
+	for team := range counts {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+
+	components := make([]ScoreComponent, 0, len(teams))
+	for _, team := range teams {
+		components = append(components, ScoreComponent{Name: mentionComponent + team, Value: counts[team]})
+	}
+	return components
+}
+
+// weighMentions weighs the mentions of each configured team by the team's weight, and drops the
+// mentions of other teams, which only matter once they are configured
+func weighMentions(teams map[string]Team, components []ScoreComponent) []ScoreComponent {
+	weighed := make([]ScoreComponent, 0, len(components))
+	for _, c := range components {
+		name, ok := strings.CutPrefix(c.Name, mentionComponent)
+		if !ok {
+			weighed = append(weighed, c)
+			continue
+		}
+
+		team, ok := teams[name]
+		if !ok {
+			continue
+		}
+		c.Reason = fmt.Sprintf("%g x %g", c.Value, team.Weight)
+		c.Value *= team.Weight
+		weighed = append(weighed, c)
+	}
+	return weighed
+}
+
+// teamNotifyTemplate is the message template of a team's notifications, with the team filled in
+const teamNotifyTemplate = `@%s: {{.Name}}, which mentions the team, crossed {{.Threshold}} upvotes ({{.Previous}} -> {{.Upvotes}}, {{printf "%%+g" .Delta}})`
+
+// teamRules returns a rule for each team with a threshold, which notifies the team once the score of an
+// item that mentions it crosses the threshold, with its message in the language of the locale
+func teamRules(teams []Team, l *Locale) []Rule {
+	var rules []Rule
+	for _, team := range teams {
+		if team.Threshold <= 0 {
+			continue
+		}
+		rules = append(rules, Rule{
+			Name:    "team:" + team.Team,
+			When:    fmt.Sprintf("score >= %[1]g and previous < %[1]g and mention:%[2]s", team.Threshold, team.Team),
+			Actions: []Action{{Type: "notify", Message: l.T("notify.team", team.Team), Notifiers: team.Notifiers}},
+		})
+	}
+	return rules
+}
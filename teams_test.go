@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// TestMentions runs a fresh synthetic project with a team whose mentions are weighed, and notified
+// of once an item reaches a score of 1, and checks that only the items whose comments mention the team
+// gain its weight, and that the team's rule fired only for the one that crossed the threshold
+func TestMentions(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Teams = []Team{{Team: selftestTeam, Weight: 2, Threshold: 1}}
+	server := newFakeGitHub()
+	engine := run(t, cfg, server)
+
+	rule := "team:" + selftestTeam
+	for i, item := range server.items {
+		if item.closed || item.archived {
+			continue
+		}
+
+		want := item.upvotes()
+		if item.timeline > 0 {
+			want += 2 * float64((item.timeline+2)/3)
+		}
+		if got := finalValue(server, item); got != want {
+			t.Errorf("%s: expected %v, got %v", item.id, want, got)
+		}
+
+		// only the item without a previous value crosses the threshold
+		if fired := engine.fired.HasFired(rule, item.id); fired != (i+1 == selftestTimelineItem) {
+			t.Errorf("%s: expected the team's rule to have fired %v, got %v", item.id, !fired, fired)
+		}
+	}
+}
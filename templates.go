@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportData is the data model that report templates are executed with
+type ReportData struct {
+	// Run describes the run being reported on
+	Run RunInfo
+
+	// Summary holds the count of items by status, and any conflicts
+	Summary Summary
+
+	// Statuses lists every status in the order they are reported in
+	Statuses []Status
+
+	// Items holds the result of every item, in the order they were processed
+	Items []Result
+
+	// Groups holds the leaderboard of each group, with the most upvotes first, if items are grouped
+	Groups []Group
+}
+
+// templateFuncs are the functions available to report templates
+var templateFuncs = map[string]any{
+	// top returns the n results with the most upvotes, for leaderboards
+	"top": func(n int, results []Result) []Result {
+		sorted := append([]Result(nil), results...)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Upvotes > sorted[j].Upvotes })
+		if n < len(sorted) {
+			sorted = sorted[:n]
+		}
+		return sorted
+	},
+	// withStatus returns the results with any of the given statuses
+	"withStatus": func(results []Result, statuses ...Status) []Result {
+		var filtered []Result
+		for _, result := range results {
+			for _, status := range statuses {
+				if result.Status == status {
+					filtered = append(filtered, result)
+					break
+				}
+			}
+		}
+		return filtered
+	},
+	// explain renders the breakdown of a result's upvotes
+	"explain": formatComponents,
+	// date formats a time using a Go reference layout
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// reportTemplate renders reports from a user-supplied Go template. Templates whose file name ends in
+// .html are parsed with html/template so that item data is escaped.
+type reportTemplate struct {
+	name string
+	exec func(w io.Writer, data ReportData) error
+}
+
+// loadReportTemplate parses the report template at path
+func loadReportTemplate(path string) (*reportTemplate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading report template: %w", err)
+	}
+
+	name := filepath.Base(path)
+	if strings.EqualFold(filepath.Ext(path), ".html") {
+		t, err := htmltemplate.New(name).Funcs(templateFuncs).Parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("parsing report template: %w", err)
+		}
+		return &reportTemplate{name: name, exec: func(w io.Writer, data ReportData) error { return t.Execute(w, data) }}, nil
+	}
+
+	t, err := template.New(name).Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing report template: %w", err)
+	}
+	return &reportTemplate{name: name, exec: func(w io.Writer, data ReportData) error { return t.Execute(w, data) }}, nil
+}
+
+// Execute renders the report
+func (r *reportTemplate) Execute(w io.Writer, data ReportData) error {
+	if err := r.exec(w, data); err != nil {
+		return fmt.Errorf("executing report template %s: %w", r.name, err)
+	}
+	return nil
+}
+
+// templateReporter writes a report rendered from a user-supplied template
+type templateReporter struct {
+	collector
+	path     string
+	append   bool
+	template *reportTemplate
+	groupBy  *Grouper
+	groupTop int
+}
+
+// Finish writes the file
+func (t *templateReporter) Finish(summary Summary) error {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if t.append {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(t.path, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.template.Execute(f, ReportData{
+		Run:      t.run,
+		Summary:  summary,
+		Statuses: statuses,
+		Items:    t.results,
+		Groups:   t.groupBy.Groups(t.results, t.groupTop),
+	})
+}
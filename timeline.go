@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// timelineBatchSize is the most items whose additional timeline pages are fetched in one request, and
+// timelineBatchWait how long an item waits for others to fill its batch
+const (
+	timelineBatchSize = 20
+	timelineBatchWait = 50 * time.Millisecond
+)
+
+// timelineField is the timelineItems field of ContentFragment, whose type and selection are reused by
+// batched queries so that every page of timeline items is fetched the same way
+var timelineField, _ = reflect.TypeOf(ContentFragment{}).FieldByName("TimelineItems")
+
+// timelineBatchQuery returns a query for the next page of timeline items of n Issues or Pull Requests at
+// once. Each is selected under its own alias, n0, n1, and so on, with the variables id0 and c0, id1 and
+// c1, and so on holding its node ID and timeline cursor, and $mentions whether comment bodies are
+// fetched. The query is built at runtime, since the number of aliases varies.
+func timelineBatchQuery(n int) reflect.Value {
+	fields := make([]reflect.StructField, 0, n+1)
+	for i := 0; i < n; i++ {
+		selection := strings.Replace(timelineField.Tag.Get("graphql"), "$timelineCursor", fmt.Sprintf("$c%d", i), 1)
+		timeline := reflect.StructOf([]reflect.StructField{{
+			Name: "TimelineItems",
+			Type: timelineField.Type,
+			Tag:  reflect.StructTag(fmt.Sprintf("graphql:%q", selection)),
+		}})
+
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("N%d", i),
+			Type: reflect.StructOf([]reflect.StructField{
+				{Name: "Issue", Type: timeline, Tag: `graphql:"...on Issue"`},
+				{Name: "PullRequest", Type: timeline, Tag: `graphql:"...on PullRequest"`},
+			}),
+			Tag: reflect.StructTag(fmt.Sprintf(`graphql:"n%d: node(id: $id%d)"`, i, i)),
+		})
+	}
+	fields = append(fields, reflect.StructField{Name: "RateLimit", Type: reflect.TypeOf(RateLimit{})})
+
+	return reflect.New(reflect.StructOf(fields))
+}
+
+// timelineFetch is an Issue or Pull Request with more timeline items to fetch after the cursor. Once
+// fetched, the cursor is the end cursor of its last page.
+type timelineFetch struct {
+	id      githubv4.ID
+	cursor  githubv4.String
+	content *ContentFragment
+	err     error
+}
+
+// fetchTimelines fetches the remaining timeline items of each Issue or Pull Request, appending them to
+// its content, for up to timelineBatchSize at a time. Each request fetches the next page of every item
+// that has one, so the number of requests depends on the longest timeline rather than the number of
+// items. If a batched request fails, each of its items is fetched on its own, so that an error only
+// fails the item it belongs to. With mentions, the bodies of comments are fetched, to find the teams
+// they mention.
+func fetchTimelines(ctx context.Context, gh *githubv4.Client, limiter *rateLimiter, mentions bool, fetches []*timelineFetch) {
+	for start := 0; start < len(fetches); start += timelineBatchSize {
+		pending := fetches[start:min(start+timelineBatchSize, len(fetches))]
+
+		for len(pending) > 0 {
+			if err := limiter.Wait(ctx); err != nil {
+				for _, f := range pending {
+					f.err = err
+				}
+				break
+			}
+
+			query := timelineBatchQuery(len(pending))
+			variables := Variables().Bool("mentions", mentions)
+			for i, f := range pending {
+				variables.ID(fmt.Sprintf("id%d", i), f.id).Cursor(fmt.Sprintf("c%d", i), f.cursor)
+			}
+
+			if err := runQuery(withQueryKind(ctx, "timelines"), gh, query.Interface(), variables); err != nil {
+				if len(pending) == 1 || ctx.Err() != nil {
+					for _, f := range pending {
+						f.err = err
+					}
+					break
+				}
+
+				// fall back to fetching each item on its own, to find the one that failed
+				for _, f := range pending {
+					clientMetrics(ctx).Retry("timelines")
+					fetchTimelines(ctx, gh, limiter, mentions, []*timelineFetch{f})
+				}
+				break
+			}
+			limiter.Observe(query.Elem().FieldByName("RateLimit").Interface().(RateLimit))
+
+			var next []*timelineFetch
+			for i, f := range pending {
+				// both fragments are decoded from the same object, so either holds the page
+				page := query.Elem().Field(i).FieldByName("Issue").Field(0)
+				nodes := page.FieldByName("Nodes").Interface().([]TimelineItem)
+				info := page.FieldByName("PageInfo").Interface().(PageInfo)
+
+				// an empty page has no end cursor, and the cursor is kept so that a later run can resume after it
+				f.content.TimelineItems.Nodes = append(f.content.TimelineItems.Nodes, nodes...)
+				if info.EndCursor != "" {
+					f.cursor = info.EndCursor
+				}
+				if info.HasNextPage {
+					next = append(next, f)
+				}
+			}
+			pending = next
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// defaultUserAgent is sent with every request to GitHub unless another is configured
+const defaultUserAgent = "github-upvotes"
+
+// headerTransport sets the User-Agent and any additional headers on every request, other than
+// Authorization
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   map[string]string
+}
+
+// RoundTrip implements http.RoundTripper
+func (h *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the original request
+	req = req.Clone(req.Context())
+
+	if h.userAgent != "" {
+		req.Header.Set("User-Agent", h.userAgent)
+	}
+	for name, value := range h.headers {
+		// the token is the only credential for GitHub, so it is never replaced
+		if http.CanonicalHeaderKey(name) == "Authorization" {
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	return h.base.RoundTrip(req)
+}
+
+// timeoutTransport limits each request, including the reading of its response, to the timeout. It is
+// the innermost transport, so that each retry has the whole timeout and the waits for retries and for
+// the circuit breaker are not limited by it.
+type timeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody is a response body that cancels the context of its request once it is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context of its request
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// accessTransport lets the items of a project whose Issue or Pull Request the token cannot read be
+// skipped, rather than failing the whole page. GitHub answers such items with null content and a FORBIDDEN
+// error, which the GraphQL client would return as the query's error; these errors are dropped from the
+// response, so the item is seen with no content and given the no-access status. Other errors are left
+// as they are.
+type accessTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (a *accessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := a.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return resp, nil
+	}
+
+	var response struct {
+		Data   json.RawMessage   `json:"data,omitempty"`
+		Errors []json.RawMessage `json:"errors,omitempty"`
+	}
+	if json.Unmarshal(b, &response) != nil || len(response.Errors) == 0 {
+		return resp, nil
+	}
+
+	kept := response.Errors[:0:0]
+	for _, raw := range response.Errors {
+		if !contentDenied(raw) {
+			kept = append(kept, raw)
+		}
+	}
+	if len(kept) == len(response.Errors) {
+		return resp, nil
+	}
+
+	response.Errors = kept
+	if b, err = json.Marshal(response); err != nil {
+		return resp, nil
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	resp.ContentLength = int64(len(b))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// contentDenied returns true if the GraphQL error is the token being denied the content of a project item
+func contentDenied(raw json.RawMessage) bool {
+	var e struct {
+		Type string
+		Path []interface{}
+	}
+	if json.Unmarshal(raw, &e) != nil || e.Type != "FORBIDDEN" || len(e.Path) == 0 {
+		return false
+	}
+	return e.Path[len(e.Path)-1] == "content"
+}
+
+// newTransport returns the transport used for requests to GitHub. Proxies are taken from the standard
+// HTTPS_PROXY and NO_PROXY environment variables, unless a proxy is configured explicitly. Requests pass
+// through a circuit breaker, unless it is disabled, and are made again after transient failures, unless
+// retries are disabled. The request timeout limits each attempt, not the retries or the waits between
+// them. Calls are recorded in metrics, if not nil. Items whose
+// content the token cannot read are answered without it, rather than failing their page.
+func newTransport(cfg Config, metrics *ClientMetrics) (http.RoundTripper, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+		}
+		base.Proxy = http.ProxyURL(proxy)
+	}
+
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+
+	var transport http.RoundTripper = base
+	if cfg.RequestTimeout > 0 {
+		transport = &timeoutTransport{base: transport, timeout: cfg.RequestTimeout}
+	}
+	transport = &headerTransport{base: transport, userAgent: userAgent, headers: cfg.Headers}
+	transport = &accessTransport{base: transport}
+	if metrics != nil {
+		transport = &metricsTransport{base: transport, metrics: metrics}
+	}
+	if cfg.BreakerThreshold > 0 {
+		transport = newBreakerTransport(transport, cfg.BreakerThreshold, cfg.BreakerCooldown, metrics)
+	}
+
+	// every attempt passes through the breaker, so that failures that outlast the retries open it
+	if cfg.Retries > 0 {
+		transport = &retryTransport{base: transport, retries: cfg.Retries, backoff: cfg.RetryBackoff, metrics: metrics}
+	}
+
+	return transport, nil
+}
+
+// newGitHubClient returns an HTTP client authenticated with the token in the Config, using the
+// configured transport. Calls are recorded in the context's ClientMetrics, if any.
+func newGitHubClient(ctx context.Context, cfg Config) (*http.Client, error) {
+	transport, err := newTransport(cfg, clientMetrics(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// oauth2 wraps the transport of the client in the context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Token})
+
+	return oauth2.NewClient(ctx, src), nil
+}
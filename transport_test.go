@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestNoAccess runs a fresh synthetic project in which the token cannot read the Issues of two open
+// items, and checks that they are given the no-access status and left as they are, while the rest of the
+// project is scored as usual
+func TestNoAccess(t *testing.T) {
+	dir := t.TempDir()
+	cfg := selftestConfig(dir)
+	server := newFakeGitHub()
+
+	hidden := make(map[string]bool)
+	for _, item := range server.items {
+		if !item.closed && !item.archived && len(hidden) < 2 {
+			item.hidden = true
+			hidden[item.id] = true
+		}
+	}
+
+	run(t, cfg, &accessTransport{base: server})
+
+	results, summary, err := readReport(filepath.Join(dir, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		id := fmt.Sprint(result.ItemID)
+		if hidden[id] != (result.Status == StatusNoAccess) {
+			t.Errorf("%s: unexpected status %s", id, result.Status)
+		}
+		if _, written := server.mutations[id]; written && hidden[id] {
+			t.Errorf("%s: written without access to its Issue", id)
+		}
+	}
+	for _, item := range server.items {
+		if value, ok := server.mutations[item.id]; ok && value != item.upvotes() {
+			t.Errorf("%s: expected %v, got %v", item.id, item.upvotes(), value)
+		}
+	}
+	expectCount(t, "items without access", summary.Statuses[StatusNoAccess], 2)
+}
+
+// TestRequestTimeout makes a request whose first attempt outlasts the request timeout, and checks that
+// it is retried, with the whole timeout for the retry even though the wait before it is longer
+func TestRequestTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if attempts.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+
+	cfg := Config{Token: "token", RequestTimeout: 50 * time.Millisecond, Retries: 1, RetryBackoff: 200 * time.Millisecond}
+	client, err := newGitHubClient(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"query":"query{viewer{login}}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	expectCount(t, "attempts", int(attempts.Load()), 2)
+}
+
+// TestHeaders makes requests through clients with and without a User-Agent and additional headers, and
+// checks that they are sent, and that the Authorization header always carries the token
+func TestHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		headers   map[string]string
+		want      map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{"User-Agent": defaultUserAgent, "Authorization": "Bearer token"},
+		},
+		{
+			name:      "configured",
+			userAgent: "upvotes-test",
+			headers:   map[string]string{"X-Gateway-Key": "gateway", "authorization": "Bearer other", "AUTHORIZATION": "Basic other"},
+			want:      map[string]string{"User-Agent": "upvotes-test", "X-Gateway-Key": "gateway", "Authorization": "Bearer token"},
+		},
+	}
+	for _, test := range tests {
+		var got http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Clone()
+			w.Write([]byte(`{"data":{}}`))
+		}))
+
+		client, err := newGitHubClient(context.Background(), Config{Token: "token", UserAgent: test.userAgent, Headers: test.headers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"query":"query{viewer{login}}"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		server.Close()
+
+		for name, want := range test.want {
+			if values := got.Values(name); len(values) != 1 || values[0] != want {
+				t.Errorf("%s: expected %s to be %q, got %q", test.name, name, want, values)
+			}
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
-import "github.com/shurcooL/githubv4"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
 
 // ProjectItemsQuery is used to list the project items in a project
 type ProjectItemsQuery struct {
 	ProjectV2ObjectFragment `graphql:"node(id: $nodeId)"`
+	RateLimit               RateLimit
 }
 
 // HasNextPage returns true if there are additional project items to be listed
@@ -49,6 +56,11 @@ type ProjectItemFragment struct {
 	UpvotesField struct {
 		ProjectV2ItemFieldNumberValueFragment `graphql:"...on ProjectV2ItemFieldNumberValue"`
 	} `graphql:"fieldValueByName(name:\"Upvotes\")"` // todo: reconsider opinionated field name
+	StatusField struct {
+		SingleSelectValue struct {
+			Name string
+		} `graphql:"...on ProjectV2ItemFieldSingleSelectValue"`
+	} `graphql:"status: fieldValueByName(name:\"Status\")"`
 	Content Content
 }
 
@@ -66,15 +78,30 @@ func (p ProjectItemFragment) GetContent() ContentFragment {
 	return content
 }
 
-// Skip returns true if upvotes should not be calculated for the project item. A project item should
-// be skipped if it meets any of these criterea:
+// SkipStatus returns the status to record if upvotes should not be calculated for the project item, or
+// an empty Status if the item should be processed. A project item should be skipped if it meets any of
+// these criterea:
 //
+// - The token cannot read the issue or pull request connected to the project item
 // - It is a draft item
+// - Its content is neither an Issue nor a Pull Request, which would otherwise be scored zero
 // - The item is archived
 // - The issue or pull request connected to the project item is closed
-// - There are no new timeline items since the existing cursor
-func (p ProjectItemFragment) Skip() bool {
-	return p.Type == "DraftIssue" || p.IsArchived || p.GetContent().Closed
+func (p ProjectItemFragment) SkipStatus() Status {
+	switch {
+	case p.Type == "REDACTED" || (p.Content.Type == "" && p.Type != "DRAFT_ISSUE"):
+		return StatusNoAccess
+	case p.Type == "DRAFT_ISSUE":
+		return StatusSkippedDraft
+	case p.Content.Type != "Issue" && p.Content.Type != "PullRequest":
+		return StatusSkippedUnsupported
+	case p.IsArchived:
+		return StatusSkippedArchived
+	case p.GetContent().Closed:
+		return StatusSkippedClosed
+	}
+
+	return ""
 }
 
 // ProjectV2ItemFieldNumberValueFragment is used to get the value of a number field in a project
@@ -92,8 +119,36 @@ type Content struct {
 // Common content fragment represents an Issue or Pull Request.
 type ContentFragment struct {
 	CommentsAndReactionsFragment
-	Id     githubv4.String
-	Closed bool
+	Id        githubv4.String
+	Url       githubv4.URI
+	Closed    bool
+	CreatedAt githubv4.DateTime
+	UpdatedAt githubv4.DateTime
+	Author    struct {
+		Login string
+	}
+	Labels struct {
+		Nodes []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 20)"`
+
+	// fetched only when enrichment is enabled, to keep the cost of the query down
+	Title      string `graphql:"title @include(if: $enrich)"`
+	Number     int    `graphql:"number @include(if: $enrich)"`
+	Repository struct {
+		NameWithOwner string
+	} `graphql:"repository @include(if: $enrich)"`
+	Assignees struct {
+		Nodes []struct {
+			Login string
+		}
+	} `graphql:"assignees(first: 10) @include(if: $enrich)"`
+
+	// fetched only when issue fields or keywords are configured, to search them. The title is fetched
+	// under an alias, as it is also fetched when enrichment is enabled.
+	Body      string `graphql:"body @include(if: $text)"`
+	TextTitle string `graphql:"textTitle: title @include(if: $text)"`
 
 	TimelineItems struct {
 		PageInfo `graphql:"pageInfo"`
@@ -101,6 +156,24 @@ type ContentFragment struct {
 	} `graphql:"timelineItems(first: 10, after: $timelineCursor, itemTypes: [CONNECTED_EVENT, CROSS_REFERENCED_EVENT, ISSUE_COMMENT, MARKED_AS_DUPLICATE_EVENT, REFERENCED_EVENT, SUBSCRIBED_EVENT])"`
 }
 
+// text returns the title and body of the Issue or Pull Request, which are empty unless fetched
+func (c ContentFragment) text() (string, string) {
+	title := c.TextTitle
+	if title == "" {
+		title = c.Title
+	}
+	return title, c.Body
+}
+
+// LabelNames returns the names of the labels on the Issue or Pull Request
+func (c ContentFragment) LabelNames() []string {
+	names := make([]string, 0, len(c.Labels.Nodes))
+	for _, label := range c.Labels.Nodes {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
 // Upvotes returns the total upvotes for the Issue or Pull Request
 func (c ContentFragment) Upvotes() int {
 	upvotes := c.Comments.TotalCount + c.Reactions.TotalCount
@@ -132,6 +205,7 @@ type TimelineItem struct {
 	CrossReferencedEvent   ConnectedOrCrossReferencedEvent `graphql:"...on CrossReferencedEvent"`
 	IssueComment           IssueComment                    `graphql:"...on IssueComment"`
 	MarkedAsDuplicateEvent MarkedAsDuplicateEvent          `graphql:"...on MarkedAsDuplicateEvent"`
+	ReferencedEvent        ReferencedEvent                 `graphql:"...on ReferencedEvent"`
 }
 
 // Upvotes returns the total upvotes for the given timeline item
@@ -148,6 +222,8 @@ func (t TimelineItem) upvotes() int {
 		upvotes += t.IssueComment.Reactions.TotalCount
 	case "MarkedAsDuplicateEvent":
 		upvotes += t.MarkedAsDuplicateEvent.upvotes()
+	case "ReferencedEvent":
+		upvotes += t.ReferencedEvent.upvotes()
 	}
 
 	return upvotes
@@ -156,25 +232,41 @@ func (t TimelineItem) upvotes() int {
 // IssueOrPullRequestCommentsAndReactionsFragment is embedded in the common case of separate Issue and Pull Request
 // fields that are both of type CommentsAndReactionsFragment.
 type IssueOrPullRequestCommentsAndReactionsFragment struct {
-	Type        string                       `graphql:"__typename"`
-	Issue       CommentsAndReactionsFragment `graphql:"...on Issue"`
-	PullRequest CommentsAndReactionsFragment `graphql:"...on PullRequest"`
+	Type        string                `graphql:"__typename"`
+	Issue       LinkedContentFragment `graphql:"...on Issue"`
+	PullRequest LinkedContentFragment `graphql:"...on PullRequest"`
 }
 
-// upvotes returns the count of comments and reactions to the Issue or Pull Request connected to a TimelineItem
-func (i IssueOrPullRequestCommentsAndReactionsFragment) upvotes() int {
-
-	var content CommentsAndReactionsFragment
+// LinkedContentFragment is an Issue or Pull Request linked from a TimelineItem
+type LinkedContentFragment struct {
+	CommentsAndReactionsFragment
+	Url githubv4.URI
+}
 
+// content returns the Issue or Pull Request connected to a TimelineItem
+func (i IssueOrPullRequestCommentsAndReactionsFragment) content() LinkedContentFragment {
 	switch i.Type {
 	case "Issue":
-		content = i.Issue
+		return i.Issue
 	case "PullRequest":
-		content = i.PullRequest
+		return i.PullRequest
 	}
+	return LinkedContentFragment{}
+}
 
+// upvotes returns the count of comments and reactions to the Issue or Pull Request connected to a TimelineItem
+func (i IssueOrPullRequestCommentsAndReactionsFragment) upvotes() int {
+	content := i.content()
 	return content.Comments.TotalCount + content.Reactions.TotalCount
+}
 
+// url returns the URL of the Issue or Pull Request connected to a TimelineItem, or an empty string if
+// there is none
+func (i IssueOrPullRequestCommentsAndReactionsFragment) url() string {
+	if u := i.content().Url; u.URL != nil {
+		return u.String()
+	}
+	return ""
 }
 
 // Represents events when an issue or pull request was connected to, or cross-referenced
@@ -185,7 +277,55 @@ type ConnectedOrCrossReferencedEvent struct {
 
 // Represents an event of someone commenting on the item
 type IssueComment struct {
-	Reactions TotalCountFragment
+	Reactions         TotalCountFragment
+	CreatedAt         githubv4.DateTime
+	AuthorAssociation string
+	Author            struct {
+		Login string
+	}
+
+	// fetched only when teams are configured, to find their mentions
+	Body string `graphql:"body @include(if: $mentions)"`
+}
+
+// Represents the item being referenced from a commit message. The commit's pull request, if it has one,
+// is scored as a cross-reference would be.
+type ReferencedEvent struct {
+	IsCrossRepository bool
+	Actor             struct {
+		Type string `graphql:"__typename"`
+	}
+	Commit *ReferencingCommit
+}
+
+// ReferencingCommit is the commit that referenced the item, with the pull request it was merged by
+type ReferencingCommit struct {
+	AssociatedPullRequests struct {
+		Nodes []LinkedContentFragment
+	} `graphql:"associatedPullRequests(first: 1)"`
+}
+
+// pullRequest returns the pull request the referencing commit belongs to, if any
+func (r ReferencedEvent) pullRequest() (LinkedContentFragment, bool) {
+	if r.Commit == nil || len(r.Commit.AssociatedPullRequests.Nodes) == 0 {
+		return LinkedContentFragment{}, false
+	}
+	return r.Commit.AssociatedPullRequests.Nodes[0], true
+}
+
+// upvotes returns the count of comments and reactions to the referencing commit's pull request
+func (r ReferencedEvent) upvotes() int {
+	pr, ok := r.pullRequest()
+	if !ok {
+		return 0
+	}
+	return pr.Comments.TotalCount + pr.Reactions.TotalCount
+}
+
+// bot returns true if the reference was made by a bot from a commit in the item's own repository, as
+// release and dependency automation does, rather than by a person working on the item
+func (r ReferencedEvent) bot() bool {
+	return !r.IsCrossRepository && r.Actor.Type == "Bot"
 }
 
 // Represents the item being marked as a duplicate of the canonical item
@@ -200,15 +340,23 @@ type AdditionalTimelineItemQuery struct {
 	RateLimit RateLimit
 }
 
+// RateLimitQuery is used to read the current GraphQL rate limit
+type RateLimitQuery struct {
+	RateLimit RateLimit
+}
+
 // RateLimit represents information related to the GitHub GraphQL rate limit
 type RateLimit struct {
+	Limit     int
 	Remaining int
 	Cost      int
+	ResetAt   githubv4.DateTime
 }
 
 // ProjectItemQuery is used to list the timeline items for a specific project item
 type ProjectItemQuery struct {
 	ProjectV2ItemObjectFragment `graphql:"node(id: $nodeId)"`
+	RateLimit                   RateLimit
 }
 
 // HasNextPage returns true if there are additional timeline items for the project item
@@ -221,9 +369,187 @@ type ProjectV2ItemObjectFragment struct {
 	ProjectItemFragment `graphql:"...on ProjectV2Item"`
 }
 
-// Update instructs what node to update and the number of votes to update with
+// Update instructs what node to update and the number of votes to update with. Previous holds the
+// value of the field before the update, Components break down where the upvotes came from, and Content
+// describes the Issue or Pull Request connected to the item. Cached is set if the timeline components were taken
+// from the score cache, and Archived if the item is archived. PageItems is the number of items of the item's page
+// that are scored, or zero if it is not known. If Err is set, the upvotes could not be calculated and the item
+// should not be updated.
 type Update struct {
-	Id      githubv4.ID
-	Upvotes *githubv4.Float
-	Cursor  githubv4.String
+	Id         githubv4.ID
+	Upvotes    *githubv4.Float
+	Components []ScoreComponent
+	Previous   float64
+	Cursor     githubv4.String
+	Content    ContentInfo
+	Extra      map[string]json.RawMessage
+	Cached     bool
+	Archived   bool
+	PageItems  int
+	Err        error
+}
+
+// ContentInfo is the information about an Issue or Pull Request that is passed along with its result
+type ContentInfo struct {
+	ID        githubv4.ID `json:"id,omitempty"`
+	URL       string      `json:"url,omitempty"`
+	Labels    []string    `json:"labels,omitempty"`
+	CreatedAt time.Time   `json:"created_at,omitempty"`
+	Closed    bool        `json:"closed,omitempty"`
+
+	// set only when enrichment is enabled
+	Title      string   `json:"title,omitempty"`
+	Number     int      `json:"number,omitempty"`
+	Repository string   `json:"repository,omitempty"`
+	Assignees  []string `json:"assignees,omitempty"`
+
+	// Links are the other Issues and Pull Requests found in the timeline
+	Links []ContentLink `json:"links,omitempty"`
+
+	// Responses are when the first response and the last comment were found in the timeline
+	Responses
+
+	// Owners are the teams that own the Issue or Pull Request, when items are mapped to owners
+	Owners []string `json:"owners,omitempty"`
+}
+
+// Name returns a human-readable name for the Issue or Pull Request, such as `owner/repo#12: Title`,
+// falling back to its URL when it was not enriched
+func (c ContentInfo) Name() string {
+	switch {
+	case c.Number != 0 && c.Title != "":
+		return fmt.Sprintf("%s#%d: %s", c.Repository, c.Number, c.Title)
+	case c.Number != 0:
+		return fmt.Sprintf("%s#%d", c.Repository, c.Number)
+	}
+	return c.URL
+}
+
+// Info returns the ContentInfo for the Issue or Pull Request
+func (c ContentFragment) Info() ContentInfo {
+	var url string
+	if c.Url.URL != nil {
+		url = c.Url.String()
+	}
+
+	var assignees []string
+	for _, assignee := range c.Assignees.Nodes {
+		assignees = append(assignees, assignee.Login)
+	}
+
+	return ContentInfo{
+		ID:         githubv4.ID(string(c.Id)),
+		URL:        url,
+		Labels:     c.LabelNames(),
+		CreatedAt:  c.CreatedAt.Time,
+		Closed:     c.Closed,
+		Title:      c.Title,
+		Number:     c.Number,
+		Repository: c.Repository.NameWithOwner,
+		Assignees:  assignees,
+	}
+}
+
+// ProjectItemCursorsQuery is used to cheaply list only the cursors of the items in a project
+type ProjectItemCursorsQuery struct {
+	ProjectV2CursorsObjectFragment `graphql:"node(id: $nodeId)"`
+}
+
+// ProjectV2CursorsObjectFragment is an intermediary fragment used for selecting the ProjectV2 object
+type ProjectV2CursorsObjectFragment struct {
+	ProjectCursorsFragment `graphql:"...on ProjectV2"`
+}
+
+// ProjectCursorsFragment represents the cursors of the items in a ProjectV2 object
+type ProjectCursorsFragment struct {
+	Items struct {
+		TotalCount int
+		PageInfo   `graphql:"pageInfo"`
+		Edges      []struct {
+			Cursor githubv4.String
+		}
+	} `graphql:"items(first: 100, after: $cursor)"`
+}
+
+// ProjectItemValuesQuery is used to read the current upvotes of a batch of project items
+type ProjectItemValuesQuery struct {
+	Nodes []struct {
+		ProjectItemValueFragment `graphql:"...on ProjectV2Item"`
+	} `graphql:"nodes(ids: $ids)"`
+	RateLimit RateLimit
+}
+
+// ProjectItemValueFragment represents the current upvotes of a project item
+type ProjectItemValueFragment struct {
+	Id           githubv4.ID
+	UpvotesField struct {
+		ProjectV2ItemFieldNumberValueFragment `graphql:"...on ProjectV2ItemFieldNumberValue"`
+	} `graphql:"fieldValueByName(name:\"Upvotes\")"`
+}
+
+// ArchivedItemsQuery is used to cheaply list the items in a project along with when they, and the
+// content connected to them, were last updated
+type ArchivedItemsQuery struct {
+	ProjectV2ArchivedObjectFragment `graphql:"node(id: $nodeId)"`
+	RateLimit                       RateLimit
+}
+
+// ProjectV2ArchivedObjectFragment is an intermediary fragment used for selecting the ProjectV2 object
+type ProjectV2ArchivedObjectFragment struct {
+	ProjectArchivedFragment `graphql:"...on ProjectV2"`
+}
+
+// ProjectArchivedFragment represents the items in a ProjectV2 object
+type ProjectArchivedFragment struct {
+	Items struct {
+		PageInfo `graphql:"pageInfo"`
+		Nodes    []ArchivedItemFragment
+	} `graphql:"items(first: 100, after: $cursor)"`
+}
+
+// ArchivedItemFragment represents a project item and when it was last updated. Archiving an item
+// updates it, so for archived items UpdatedAt is no earlier than when it was archived.
+type ArchivedItemFragment struct {
+	Id         githubv4.ID
+	IsArchived bool
+	UpdatedAt  githubv4.DateTime
+	Content    struct {
+		Type        string                 `graphql:"__typename"`
+		Issue       UpdatedContentFragment `graphql:"...on Issue"`
+		PullRequest UpdatedContentFragment `graphql:"...on PullRequest"`
+	}
+}
+
+// GetContent returns the issue or pull request that is connected to the project item
+func (a ArchivedItemFragment) GetContent() UpdatedContentFragment {
+	switch a.Content.Type {
+	case "Issue":
+		return a.Content.Issue
+	case "PullRequest":
+		return a.Content.PullRequest
+	}
+	return UpdatedContentFragment{}
+}
+
+// UpdatedContentFragment represents an Issue or Pull Request and when it was last updated
+type UpdatedContentFragment struct {
+	Id        githubv4.ID
+	Url       githubv4.URI
+	Closed    bool
+	UpdatedAt githubv4.DateTime
+}
+
+// ContentActivityQuery is used to count the timeline items added to an Issue or Pull Request since a
+// point in time
+type ContentActivityQuery struct {
+	Node struct {
+		Typename    string                  `graphql:"__typename"`
+		Issue       ContentActivityFragment `graphql:"...on Issue"`
+		PullRequest ContentActivityFragment `graphql:"...on PullRequest"`
+	} `graphql:"node(id: $nodeId)"`
+}
+
+// ContentActivityFragment represents the count of timeline items since a point in time
+type ContentActivityFragment struct {
+	TimelineItems TotalCountFragment `graphql:"timelineItems(since: $since)"`
 }
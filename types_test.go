@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// TestUnsupported checks that items are only scored if their content is an Issue or a Pull Request
+func TestUnsupported(t *testing.T) {
+	for typename, want := range map[string]Status{"Issue": "", "PullRequest": "", "Discussion": StatusSkippedUnsupported} {
+		var item ProjectItemFragment
+		item.Content.Type = typename
+		if got := item.SkipStatus(); got != want {
+			t.Errorf("%s: expected status %q, got %q", typename, want, got)
+		}
+	}
+}
+
+// TestSkipDraft checks that draft items, whose type is DRAFT_ISSUE, are skipped as drafts, including
+// those whose content is null, which are not mistaken for items the token cannot read
+func TestSkipDraft(t *testing.T) {
+	for _, typename := range []string{"DraftIssue", ""} {
+		var item ProjectItemFragment
+		item.Type = "DRAFT_ISSUE"
+		item.Content.Type = typename
+		if got := item.SkipStatus(); got != StatusSkippedDraft {
+			t.Errorf("content %q: expected status %q, got %q", typename, StatusSkippedDraft, got)
+		}
+	}
+}
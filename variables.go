@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// VariableSet holds the variables of a GraphQL query. It is built with typed setters, so that each
+// variable is given the Go type that githubv4 declares as the GraphQL type the query expects, and is
+// checked against the query before it is sent, so that a variable missing from the set, or left in it
+// after the query stopped using it, is an error at once rather than a rejected request.
+type VariableSet map[string]interface{}
+
+// Variables returns an empty VariableSet
+func Variables() VariableSet {
+	return VariableSet{}
+}
+
+// ID sets a required ID variable
+func (v VariableSet) ID(name string, id githubv4.ID) VariableSet {
+	v[name] = id
+	return v
+}
+
+// IDs sets a required list of IDs variable
+func (v VariableSet) IDs(name string, ids []githubv4.ID) VariableSet {
+	v[name] = ids
+	return v
+}
+
+// String sets a required String variable
+func (v VariableSet) String(name, s string) VariableSet {
+	v[name] = githubv4.String(s)
+	return v
+}
+
+// Int sets a required Int variable
+func (v VariableSet) Int(name string, n int) VariableSet {
+	v[name] = githubv4.Int(n)
+	return v
+}
+
+// Bool sets a required Boolean variable
+func (v VariableSet) Bool(name string, b bool) VariableSet {
+	v[name] = githubv4.Boolean(b)
+	return v
+}
+
+// DateTime sets a required DateTime variable
+func (v VariableSet) DateTime(name string, t githubv4.DateTime) VariableSet {
+	v[name] = t
+	return v
+}
+
+// Cursor sets an optional String variable holding the cursor a connection is paged from. An empty
+// cursor starts from the first page. A connection paged by more than one cursor, such as the items of a
+// project and the timeline of each item, is given a variable for each.
+func (v VariableSet) Cursor(name string, cursor githubv4.String) VariableSet {
+	if cursor == "" {
+		v[name] = (*githubv4.String)(nil)
+		return v
+	}
+	v[name] = githubv4.NewString(cursor)
+	return v
+}
+
+// Check returns an error naming the variables the query uses that are not in the set, and those in the
+// set that the query does not use, both of which GitHub rejects
+func (v VariableSet) Check(query interface{}) error {
+	used := queryVariables(reflect.TypeOf(query))
+
+	var missing, unused []string
+	for name := range used {
+		if _, ok := v[name]; !ok {
+			missing = append(missing, "$"+name)
+		}
+	}
+	for name := range v {
+		if !used[name] {
+			unused = append(unused, "$"+name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unused)
+
+	switch {
+	case len(missing) > 0 && len(unused) > 0:
+		return fmt.Errorf("query uses undefined variables %s, and does not use %s", strings.Join(missing, ", "), strings.Join(unused, ", "))
+	case len(missing) > 0:
+		return fmt.Errorf("query uses undefined variables %s", strings.Join(missing, ", "))
+	case len(unused) > 0:
+		return fmt.Errorf("query does not use variables %s", strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// variablePattern matches a variable referred to in a graphql struct tag
+var variablePattern = regexp.MustCompile(`\$([_A-Za-z][_0-9A-Za-z]*)`)
+
+// queryVariableCache holds the variables used by each type of query already checked, as a query's
+// variables only depend on its type
+var queryVariableCache sync.Map
+
+// queryVariables returns the names of the variables referred to in the graphql tags of the query type
+// and every type nested in it
+func queryVariables(t reflect.Type) map[string]bool {
+	if used, ok := queryVariableCache.Load(t); ok {
+		return used.(map[string]bool)
+	}
+
+	used := make(map[string]bool)
+	collectVariables(t, used, make(map[reflect.Type]bool))
+	queryVariableCache.Store(t, used)
+	return used
+}
+
+// collectVariables adds the variables referred to by the type's fields to used, visiting each type once
+func collectVariables(t reflect.Type, used map[string]bool, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		for _, match := range variablePattern.FindAllStringSubmatch(field.Tag.Get("graphql"), -1) {
+			used[match[1]] = true
+		}
+		collectVariables(field.Type, used, seen)
+	}
+}
+
+// runQuery checks the variables against the query, then executes it
+func runQuery(ctx context.Context, gh *githubv4.Client, query interface{}, variables VariableSet) error {
+	if err := variables.Check(query); err != nil {
+		return fmt.Errorf("building query: %w", err)
+	}
+	return gh.Query(ctx, query, variables)
+}
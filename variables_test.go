@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+// TestVariables checks the variables of the project items query, and that a missing or unused variable
+// is reported
+func TestVariables(t *testing.T) {
+	variables := Variables().
+		ID("nodeId", "project").
+		Cursor("cursor", "").
+		Cursor("timelineCursor", "").
+		Bool("enrich", true).
+		Bool("mentions", false).
+		Bool("text", false)
+	if err := variables.Check(&ProjectItemsQuery{}); err != nil {
+		t.Fatalf("expected the project items query's variables to be complete: %v", err)
+	}
+
+	delete(variables, "timelineCursor")
+	variables.String("unused", "")
+	err := variables.Check(&ProjectItemsQuery{})
+	if err == nil || err.Error() != "query uses undefined variables $timelineCursor, and does not use $unused" {
+		t.Fatalf("expected the missing and unused variables to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set by the release build with -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// toolVersion returns the version the tool was built as: the version set by the release build, the
+// module version if it was installed with go install, or "devel"
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return "devel"
+	}
+	return info.Main.Version
+}
+
+// toolCommit returns the commit the tool was built from, or "unknown"
+func toolCommit() string {
+	if commit != "" {
+		return commit
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// versionString describes the build of the tool
+func versionString() string {
+	s := fmt.Sprintf("github-upvotes %s (commit %s", toolVersion(), toolCommit())
+	if date != "" {
+		s += ", built " + date
+	}
+	return s + fmt.Sprintf(", %s %s/%s)", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
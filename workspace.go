@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inActionsContainer returns true if the tool is running as root inside a container started by
+// GitHub Actions, where files it writes to the workspace would otherwise be owned by root and could
+// not be changed by later steps of the job
+func inActionsContainer() bool {
+	if os.Getenv("GITHUB_ACTIONS") != "true" || os.Getenv("GITHUB_WORKSPACE") == "" || os.Geteuid() != 0 {
+		return false
+	}
+
+	for _, path := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// outputPath resolves a relative path of a file the tool writes against the output directory
+func (cfg Config) outputPath(path string) string {
+	if path == "" || cfg.OutputDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cfg.OutputDir, path)
+}
+
+// resolveOutputPaths resolves the relative paths of the files and directories the tool writes, and
+// of the plans and range files it reads back, against the output directory. In an Actions container,
+// the output directory defaults to the workspace.
+func (cfg *Config) resolveOutputPaths() {
+	if cfg.OutputDir == "" && inActionsContainer() {
+		cfg.OutputDir = os.Getenv("GITHUB_WORKSPACE")
+	}
+	if cfg.OutputDir == "" {
+		return
+	}
+
+	for _, path := range []*string{&cfg.StateDir, &cfg.SummaryFile, &cfg.PlanFile, &cfg.Plan, &cfg.RangeFile, &cfg.ReportFile, &cfg.DumpDir, &cfg.RescoreFrom, &cfg.ProfileA, &cfg.ProfileB} {
+		*path = cfg.outputPath(*path)
+	}
+
+	for i, spec := range cfg.Reporters {
+		if name, path, ok := strings.Cut(spec, "="); ok {
+			cfg.Reporters[i] = name + "=" + cfg.outputPath(path)
+		}
+	}
+}
+
+// outputPaths returns the files and directories the tool may have written
+func (cfg Config) outputPaths() []string {
+	paths := []string{cfg.StateDir, cfg.SummaryFile, cfg.PlanFile, cfg.RangeFile, cfg.ReportFile, cfg.DumpDir}
+	for _, spec := range cfg.Reporters {
+		if _, path, ok := strings.Cut(spec, "="); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// fixOwnership gives the files written in the output directory to the owner of the output directory,
+// when running as root in an Actions container. Files that were not written by this run, or are
+// outside the output directory, are left alone.
+func fixOwnership(cfg Config) error {
+	if cfg.OutputDir == "" || !inActionsContainer() {
+		return nil
+	}
+
+	uid, gid, ok := fileOwner(cfg.OutputDir)
+	if !ok || uid == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, path := range cfg.outputPaths() {
+		if path == "" || !withinDir(cfg.OutputDir, path) {
+			continue
+		}
+
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if owner, _, ok := fileOwner(p); ok && owner == 0 {
+				return os.Lchown(p, uid, gid)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	slog.Debug("gave written files to the owner of the output directory", "dir", cfg.OutputDir, "uid", uid, "gid", gid)
+	return nil
+}
+
+// withinDir returns true if path is dir or inside it
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// FieldWriter writes the calculated upvotes of a project item, returning the status to record for it
+type FieldWriter interface {
+	WriteUpvotes(ctx context.Context, itemId githubv4.ID, previous, upvotes float64) (Status, error)
+}
+
+// Conflict policies, applied when a field was changed by someone else after the new value was calculated
+const (
+	// ConflictSkip leaves the field as it is and records the item as a conflict
+	ConflictSkip = "skip"
+
+	// ConflictOverwrite writes the new value regardless, without re-reading the field first
+	ConflictOverwrite = "overwrite"
+
+	// ConflictFail leaves the field as it is and records the item as failed
+	ConflictFail = "fail"
+)
+
+// ConflictError is returned when a field's current value does not match the expected old value
+type ConflictError struct {
+	Expected float64
+	Actual   float64
+}
+
+// Error implements the error interface
+func (c *ConflictError) Error() string {
+	return fmt.Sprintf("field was changed during the run: expected %v, found %v", c.Expected, c.Actual)
+}
+
+// mutationWriter writes upvotes directly to the project field, recording each mutation to the audit log.
+// Unless the conflict policy is to overwrite, the field is re-read immediately before writing, and the
+// policy is applied if it no longer holds the value read at the start of the run. Writes wait on the
+// limiter, which may be nil.
+type mutationWriter struct {
+	gh        *githubv4.Client
+	projectId githubv4.ID
+	fieldId   githubv4.ID
+	audit     *AuditLog
+	policy    string
+	limiter   *rateLimiter
+}
+
+// WriteUpvotes updates the project item's upvotes field
+func (m *mutationWriter) WriteUpvotes(ctx context.Context, itemId githubv4.ID, previous, upvotes float64) (Status, error) {
+	if err := m.limiter.Wait(ctx); err != nil {
+		return StatusFailed, err
+	}
+
+	if m.policy != ConflictOverwrite {
+		current, err := currentValues(ctx, m.gh, m.limiter, []githubv4.ID{itemId})
+		if err != nil {
+			return StatusFailed, fmt.Errorf("re-reading field: %w", err)
+		}
+
+		if value := current[fmt.Sprint(itemId)]; value != previous {
+			if m.policy == ConflictFail {
+				return StatusFailed, &ConflictError{Expected: previous, Actual: value}
+			}
+			return StatusConflict, &ConflictError{Expected: previous, Actual: value}
+		}
+	}
+
+	record := AuditRecord{
+		Mutation: "update-upvotes",
+		ItemID:   itemId,
+		FieldID:  m.fieldId,
+		OldValue: previous,
+		NewValue: upvotes,
+	}
+
+	err := m.audit.Mutation(record, func() error {
+		value := githubv4.ProjectV2FieldValue{Number: githubv4.NewFloat(githubv4.Float(upvotes))}
+		return setField(ctx, m.gh, m.projectId, itemId, m.fieldId, value)
+	})
+	m.limiter.Spend(1)
+	if err != nil {
+		return StatusFailed, err
+	}
+
+	return StatusUpdated, nil
+}
+
+// cappedWriter performs at most max field updates with its FieldWriter, queueing the rest in a plan to be
+// reviewed and applied later, so that a new configuration cannot rewrite the whole board in one run. Every
+// update passed on counts towards the cap, even if the writer found a conflict rather than writing it.
+type cappedWriter struct {
+	FieldWriter
+	max  int
+	plan *Plan
+
+	mu     sync.Mutex
+	writes int
+}
+
+// WriteUpvotes updates the project item's upvotes field, or adds the update to the plan once the cap is
+// reached
+func (c *cappedWriter) WriteUpvotes(ctx context.Context, itemId githubv4.ID, previous, upvotes float64) (Status, error) {
+	c.mu.Lock()
+	capped := c.writes >= c.max
+	if !capped {
+		c.writes++
+	}
+	c.mu.Unlock()
+
+	if capped {
+		return c.plan.WriteUpvotes(ctx, itemId, previous, upvotes)
+	}
+	return c.FieldWriter.WriteUpvotes(ctx, itemId, previous, upvotes)
+}
+
+// dryRunWriter performs no updates, so that a dry run leaves no trace on the project. Every update is
+// given the planned status, and the value it would have written is reported by the dryRunReporter.
+type dryRunWriter struct{}
+
+// WriteUpvotes does nothing
+func (dryRunWriter) WriteUpvotes(ctx context.Context, itemId githubv4.ID, previous, upvotes float64) (Status, error) {
+	return StatusPlanned, nil
+}
+
+// Plan is a list of pending field updates, written when the token cannot update the project so that
+// a privileged job can apply them later, or when a run reaches its maximum number of updates
+type Plan struct {
+	ProjectID githubv4.ID       `json:"project_id"`
+	FieldID   githubv4.ID       `json:"field_id"`
+	RunID     string            `json:"run_id"`
+	Profile   string            `json:"profile,omitempty"`
+	Rollout   *PlanRollout      `json:"rollout,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Mutations []PlannedMutation `json:"mutations"`
+
+	mu sync.Mutex
+}
+
+// PlannedMutation is a single pending field update
+type PlannedMutation struct {
+	ItemID   githubv4.ID `json:"item_id"`
+	OldValue float64     `json:"old_value"`
+	NewValue float64     `json:"new_value"`
+}
+
+// Delta returns the size of the change the mutation makes, in either direction
+func (m PlannedMutation) Delta() float64 {
+	return math.Abs(m.NewValue - m.OldValue)
+}
+
+// NewPlan returns an empty Plan for the run
+func NewPlan(run RunInfo) *Plan {
+	return &Plan{
+		ProjectID: run.ProjectID,
+		FieldID:   run.FieldID,
+		RunID:     run.ID,
+		CreatedAt: time.Now().UTC(),
+		Mutations: []PlannedMutation{},
+	}
+}
+
+// WriteUpvotes adds the update to the plan rather than performing it
+func (p *Plan) WriteUpvotes(ctx context.Context, itemId githubv4.ID, previous, upvotes float64) (Status, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Mutations = append(p.Mutations, PlannedMutation{ItemID: itemId, OldValue: previous, NewValue: upvotes})
+	return StatusPlanned, nil
+}
+
+// Prioritize orders the mutations by the size of their change, largest first, so that when the rate limit
+// or the time available runs out while the plan is applied, the largest corrections have already landed.
+// Mutations of the same size keep their order.
+func (p *Plan) Prioritize() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prioritize()
+}
+
+// prioritize is Prioritize with the lock held
+func (p *Plan) prioritize() {
+	sort.SliceStable(p.Mutations, func(i, j int) bool {
+		return p.Mutations[i].Delta() > p.Mutations[j].Delta()
+	})
+}
+
+// Write writes the plan to a JSON file, with its mutations in the order they are applied
+func (p *Plan) Write(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prioritize()
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// LoadPlan reads a plan from a JSON file
+func LoadPlan(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// viewerCanUpdate returns true if the token is allowed to update the project
+func viewerCanUpdate(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID) (bool, error) {
+	var query struct {
+		Node struct {
+			ProjectV2 struct {
+				ViewerCanUpdate bool
+			} `graphql:"...on ProjectV2"`
+		} `graphql:"node(id: $nodeId)"`
+	}
+
+	if err := runQuery(ctx, gh, &query, Variables().ID("nodeId", projectId)); err != nil {
+		return false, err
+	}
+
+	return query.Node.ProjectV2.ViewerCanUpdate, nil
+}
+
+// projectIsPublic returns true if the project is visible to everyone
+func projectIsPublic(ctx context.Context, gh *githubv4.Client, projectId githubv4.ID) (bool, error) {
+	var query struct {
+		Node struct {
+			ProjectV2 struct {
+				Public bool
+			} `graphql:"...on ProjectV2"`
+		} `graphql:"node(id: $nodeId)"`
+	}
+
+	if err := runQuery(ctx, gh, &query, Variables().ID("nodeId", projectId)); err != nil {
+		return false, err
+	}
+
+	return query.Node.ProjectV2.Public, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+)
+
+// TestMaxUpdates runs with a maximum of three updates, which must write only the three largest changes
+// of the run and plan the rest, then applies the plan, which must write the rest
+func TestMaxUpdates(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	cfg.MaxUpdates = 3
+	server := newFakeGitHub()
+
+	var changed int
+	previous := make(map[string]float64)
+	for _, item := range server.items {
+		if item.value != nil {
+			previous[item.id] = *item.value
+		}
+		if !item.closed && !item.archived && (item.value == nil || *item.value != item.upvotes()) {
+			changed++
+		}
+	}
+
+	engine, err := newEngine(cfg, &http.Client{Transport: server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "updates written", len(server.mutations), cfg.MaxUpdates)
+
+	plan, err := LoadPlan(cfg.PlanFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "updates planned", len(plan.Mutations), changed-cfg.MaxUpdates)
+
+	smallest := math.Inf(1)
+	for _, item := range server.items {
+		if value, ok := server.mutations[item.id]; ok {
+			smallest = min(smallest, math.Abs(value-previous[item.id]))
+		}
+	}
+	for _, mutation := range plan.Mutations {
+		if mutation.Delta() > smallest {
+			t.Errorf("%v: expected a change of %v to be written before one of %v", mutation.ItemID, mutation.Delta(), smallest)
+		}
+	}
+
+	if engine, err = newEngine(cfg, &http.Client{Transport: server}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Apply(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(t, "updates written once applied", len(server.mutations), changed)
+}